@@ -0,0 +1,467 @@
+package main
+
+import (
+	"bufio"
+	"chessAnalyserFree/api"
+	"chessAnalyserFree/board"
+	gameengine "chessAnalyserFree/gameEngine"
+	"chessAnalyserFree/lichess"
+	pgnimport "chessAnalyserFree/pgnImport"
+	"chessAnalyserFree/query"
+	"chessAnalyserFree/report"
+	"chessAnalyserFree/stats"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// runAnalyse implements the analyse and report subcommands, and the program without a subcommand.
+// analyse lets the user pick games to analyse from a menu, or with --batch analyses them all;
+// report always analyses every game and writes its reports.
+func runAnalyse(name string, arguments []string, defaults *userConfig) {
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	flags.Usage = usageFunc(flags)
+	selection := addGameFlags(flags)
+	enginePath := flags.String("engine", "", "path to the Stockfish binary (default: the configured engine)")
+	gameURL := flags.String("game", "", "chess.com game URL to analyse directly, skipping the game list")
+	pgnFiles := flags.String("pgn", "", "comma-separated PGN files or URLs to read instead of fetching games from a player's archive")
+	importFiles := flags.String("import", "", "comma-separated review bundles or JSON analysis exports to review without analysing the games again")
+	presetName := flags.String("preset", gameengine.DefaultPresetName, "analysis preset: "+strings.Join(gameengine.PresetNames(), ", "))
+	cloudEval := flags.Bool("cloud-eval", false, "use deep Lichess cloud evaluations when available instead of searching locally")
+	batch, boardName, tui, evalViewName := new(bool), new(string), new(bool), new(string)
+	stdin, pipeFormat := new(bool), new(string)
+	if name == "report" {
+		*batch = true
+	} else {
+		batch = flags.Bool("batch", false, "analyse every fetched game and write its reports, without the interactive menu")
+		boardName = flags.String("board", "unicode", "how the game menu draws boards: unicode, or ascii for terminals without chess symbols")
+		evalViewName = flags.String("eval-view", evalViewWhite, "point of view of the evaluations in move tables: white, or mover for the side making the move")
+		tui = flags.Bool("tui", false, "choose and review games in a full-screen terminal interface instead of the line-based menu")
+		stdin = flags.Bool("stdin", false, "analyse PGN games piped to stdin, writing each analysis to stdout as soon as it completes")
+		pipeFormat = flags.String("format", "jsonl", "with --stdin, the output format: "+strings.Join(pipeFormats, ", "))
+	}
+	retrySkipped := flags.Bool("retry-skipped", false, "in batch mode, only analyse the games recorded in "+skippedGamesFile)
+	dryRun := flags.Bool("dry-run", false, "print the planned API requests and engine workload, then exit without analysing")
+	study := flags.String("study", "", "Lichess study ID or URL to add analysed games to as annotated chapters")
+	lichessToken := flags.String("lichess-token", os.Getenv("LICHESS_TOKEN"), "Lichess API token with the study:write scope (default $LICHESS_TOKEN)")
+	practicalChances := flags.Int("practical-chances", 0, "play this many fast self-play games from every critical position to estimate practical chances")
+	twoPass := flags.Bool("two-pass", false, fmt.Sprintf("scan every position at depth %d first and search only the positions around the moves it finds losing with the preset", gameengine.ScanDepth))
+	queryExpr := flags.String("query", "", "with --batch, print the analysed moves matching this filter, e.g. \"result=loss and cploss>150\"")
+	csvPath := flags.String("csv", "", "with --batch, append one row per analysed move to this CSV file")
+	statsExport := flags.String("stats-export", "", "write the --user's statistics to this directory as stats.json and one CSV file per table, at the end of a batch run or on leaving the game menu")
+	collectionPath := flags.String("collection", "", "append analysed games with their annotations to this PGN file, skipping games it already holds")
+	templatesDir := flags.String("templates", "", "directory with report.html.tmpl, report.md.tmpl and branding.json overrides")
+	reportDir := flags.String("report-dir", "", "directory reports are written to (default: the current directory)")
+	reportFormats := flags.String("report-formats", "markdown,html", "comma-separated report formats: markdown, html, pdf")
+	htmlOnly := flags.Bool("html", false, "write only the self-contained HTML reports, with an interactive evaluation chart and boards of the key positions (same as --report-formats html)")
+	htmlBatch := flags.String("html-batch", "", "in batch mode, also write the HTML reports of all games analysed into this one file")
+	diagramDir := flags.String("diagrams", "", "directory to draw the key positions of every reported game into, shown in the Markdown reports")
+	diagramFormat := flags.String("diagram-format", "svg", "image format of --diagrams: svg, or png for viewers without SVG support")
+	depth := flags.Int("depth", 0, "search every position to this depth instead of the preset's limit, giving the same evaluations on every machine")
+	perf := addPerformanceFlags(flags, true)
+	perf.addFetchFlag(flags)
+	flags.Parse(arguments)
+	if err := defaults.applyFlags(flags); err != nil {
+		log.Fatalf("Error in configuration: %v", err)
+	}
+	sourceName, pgnArchives, checkOpponents, offlineDB := selection.source, selection.pgnArchives, selection.checkOpponents, selection.offline
+	offline := *pgnFiles != "" || *gameURL != "" || *stdin || *importFiles != ""
+	args, ok := defaults.gameArgs(flags.Args(), gameArgs{User: *selection.user, From: *selection.from, To: *selection.to, Engine: *enginePath}, offline, true, time.Now())
+	if !ok {
+		flags.Usage()
+		return
+	}
+
+	// --- Report Renderer Initialization ---
+	renderer, err := report.NewRenderer(*templatesDir)
+	if err != nil {
+		log.Fatalf("Error loading report templates: %v", err)
+	}
+	formats, err := parseReportFormats(*reportFormats)
+	if err != nil {
+		log.Fatalf("Error in --report-formats: %v", err)
+	}
+	if *htmlOnly {
+		formats = []report.Format{report.FormatHTML}
+	}
+	if *diagramFormat, err = board.ParseDiagramFormat(*diagramFormat); err != nil {
+		log.Fatalf("Error in --diagram-format: %v", err)
+	}
+	output := &reportOutput{renderer: renderer, dir: *reportDir, formats: formats, diagramDir: *diagramDir, diagramFormat: *diagramFormat}
+	if *batch {
+		output.batchHTML = *htmlBatch
+	}
+	boardStyle := board.Unicode
+	if *boardName != "" {
+		if boardStyle, err = board.ParseStyle(*boardName); err != nil {
+			log.Fatalf("Error in --board: %v", err)
+		}
+	}
+	if *evalViewName != "" {
+		if evalView, err = parseEvalView(*evalViewName); err != nil {
+			log.Fatalf("Error in --eval-view: %v", err)
+		}
+	}
+
+	studyExporter, err := newStudyExport(*study, *lichessToken)
+	if err != nil {
+		log.Fatalf("Error configuring study export: %v", err)
+	}
+
+	var collection *report.PGNCollection
+	if *collectionPath != "" && !*dryRun {
+		if collection, err = report.OpenPGNCollection(*collectionPath); err != nil {
+			log.Fatalf("Error opening PGN collection: %v", err)
+		}
+		defer collection.Close()
+	}
+
+	gameDB := selection.openDB()
+	if gameDB != nil {
+		defer gameDB.Close()
+	}
+
+	if _, err := query.Parse(*queryExpr); err != nil {
+		log.Fatalf("Error in --query: %v", err)
+	}
+	gameFilter, err := selection.gameFilter()
+	if err != nil {
+		log.Fatalf("Error in the game filter: %v", err)
+	}
+	gameSort, err := selection.gameSort()
+	if err != nil {
+		log.Fatalf("Error in --sort: %v", err)
+	}
+	// filterPlayer is the player whose results, colours and opponents filters and rating sorts
+	// go by: the one given with --user or configured, also for games read from files.
+	filterPlayer := ""
+	if !strings.ContainsAny(args.User, ",:") {
+		filterPlayer = args.User
+	}
+	if gameFilter.NeedsPlayer() && filterPlayer == "" {
+		log.Fatalf("Error in the game filter: filtering by result, colour or opponent needs a single player; give --user.")
+	}
+	if gameSort.NeedsPlayer() && filterPlayer == "" {
+		log.Fatalf("Error in --sort: sorting by rating needs a single player; give --user.")
+	}
+	if *statsExport != "" && filterPlayer == "" {
+		log.Fatalf("Error in --stats-export: the statistics need a single player; give --user.")
+	}
+
+	statsPolicy := selection.policy()
+
+	preset, err := gameengine.LookupPreset(*presetName)
+	if err != nil {
+		log.Fatalf("Error selecting preset: %v", err)
+	}
+	if *depth > 0 {
+		preset = preset.WithDepth(*depth)
+	}
+	settings := perf.resolve(*batch)
+	if *stdin {
+		if *pgnFiles != "" || *gameURL != "" {
+			log.Fatalf("--stdin reads the games from stdin; leave out --pgn and --game.")
+		}
+		runPipe(args.Engine, preset, settings, gameDB, *pipeFormat)
+		return
+	}
+	if *importFiles != "" && (*pgnFiles != "" || *gameURL != "" || *batch) {
+		log.Fatalf("--import reviews the imported games in the menu; leave out --pgn, --game and --batch.")
+	}
+	calibration, err := gameengine.LoadCalibration()
+	if err != nil {
+		log.Printf("Ignoring benchmark calibration: %v", err)
+	}
+
+	// --- Stockfish Analyser Initialization ---
+	// A dry run never starts the engine.
+	var analyser *gameengine.StockfishAnalyser
+	if !*dryRun {
+		analyser, err = settings.startEngine(args.Engine, preset)
+		if err != nil {
+			fatal(exitEngine, "Error starting Stockfish analyser: %v", err)
+		}
+		defer analyser.Close()
+		fmt.Printf("Stockfish engine initialized successfully (preset: %s).\n", preset.Name)
+		fmt.Printf("Performance: %s.\n", settings)
+		analyser.SetPracticalChances(*practicalChances)
+		analyser.SetTwoPass(*twoPass)
+		if gameDB != nil {
+			analyser.SetAnalysisStore(gameDB)
+		}
+		// Positions reached in several games are searched once; with a game database, once ever.
+		analyser.CachePositions(gameDB)
+		if *cloudEval {
+			minDepth := gameengine.MinExternalDepth
+			if preset.Depth > minDepth {
+				minDepth = preset.Depth
+			}
+			analyser.SetEvalSource(lichess.NewClient(), minDepth)
+			fmt.Printf("Using Lichess cloud evaluations of depth %d or more.\n", minDepth)
+		}
+	}
+
+	// --- Game Loading ---
+	var allGames []api.Game
+	var requests int
+	var gamesOrigin string
+	var statsPlayer string // Player whose point of view statistics take, if the games are one player's.
+
+	// Existing analyses of the games to review by game URL: imported ones, or those stored before.
+	var imported map[string]*gameengine.GameAnalysis
+	if *importFiles != "" {
+		gamesOrigin = *importFiles
+		allGames, imported, err = loadImports(strings.Split(*importFiles, ","), gameDB)
+		if err != nil {
+			log.Fatalf("Error importing analyses: %v", err)
+		}
+	} else if *gameURL != "" {
+		gamesOrigin = *gameURL
+		fmt.Printf("Looking up %s...\n", *gameURL)
+		game, err := api.NewClient().FetchGameByURL(*gameURL)
+		if err != nil {
+			fatal(exitNetwork, "Error fetching game: %v", err)
+		}
+		allGames = []api.Game{*game}
+	} else if *pgnFiles != "" {
+		gamesOrigin = *pgnFiles
+		allGames, err = pgnimport.Load(strings.Split(*pgnFiles, ","))
+		if err != nil {
+			log.Fatalf("Error reading PGN files: %v", err)
+		}
+	} else {
+		gamesOrigin = args.User
+		if !strings.ContainsAny(args.User, ",:") {
+			statsPlayer = args.User
+		}
+		allGames, requests = fetchOnlineGames(*sourceName, args.User, args.From, args.To, *pgnArchives, *dryRun, gameDB, *offlineDB, settings)
+	}
+	api.TagBots(allGames)
+	if *checkOpponents && !*dryRun {
+		fmt.Println("Checking opponent profiles...")
+		if err := api.NewClient().TagEngineAccounts(allGames); err != nil {
+			log.Printf("Some profiles could not be checked: %v", err)
+		}
+	}
+	totalGamesFound := len(allGames)
+	loadedGames := allGames // allGames is narrowed by the filters; loadedGames keeps every game.
+	if allGames, err = filterGames(allGames, gameFilter, filterPlayer); err != nil {
+		log.Fatalf("Error in the game filter: %v", err)
+	}
+	allGames = gameSort.Apply(allGames, filterPlayer)
+	dataset := newMoveDataset(statsPlayer)
+	if imported == nil && gameDB != nil && !*dryRun {
+		imported = storedImports(gameDB, allGames)
+	}
+
+	if *dryRun {
+		printDryRunSummary(requests, allGames, preset, calibration)
+		return
+	}
+
+	// --- Display Results ---
+	fmt.Printf("\n--- Finished Fetching --- \n")
+	fmt.Printf("Found a total of %d games for %s.\n\n", totalGamesFound, gamesOrigin)
+	if totalGamesFound == 0 {
+		fail(exitNoGames, "No games found for %s.", gamesOrigin)
+		return
+	}
+	if !gameFilter.IsEmpty() {
+		fmt.Printf("%d of them match the filter (%s).\n\n", len(allGames), gameFilter.Describe())
+		if len(allGames) == 0 {
+			fail(exitNoGames, "No games match the filter.")
+			return
+		}
+	}
+	if gameSort.Key != "" {
+		fmt.Printf("Games sorted by %s.\n\n", gameSort.Describe())
+	}
+	if *tui && !*batch {
+		if err := runTUI(analyser, output, dataset, allGames, imported, boardStyle); err != nil {
+			log.Fatalf("Error in the terminal interface: %v", err)
+		}
+		return
+	}
+	if *gameURL != "" {
+		// Jump straight to the analysis of the requested game.
+		reader := bufio.NewReader(os.Stdin)
+		analysis := analyseGameMoves(analyser, allGames[0])
+		handleSelectedGame(reader, analyser, output, studyExporter, collection, dataset, boardStyle, allGames[0], 1, analysis)
+		return
+	}
+	if *batch {
+		if *retrySkipped {
+			allGames = filterSkippedGames(allGames)
+		}
+		var csvExport *report.CSVWriter
+		if *csvPath != "" {
+			csvFile, err := openCSVExport(*csvPath)
+			if err != nil {
+				log.Fatalf("Error opening CSV export: %v", err)
+			}
+			defer csvFile.Close()
+			if csvExport, err = report.NewCSVWriter(csvFile, csvFile.empty); err != nil {
+				log.Fatalf("Error writing %s: %v", *csvPath, err)
+			}
+		}
+		run := batchRun{Games: gamesOrigin, Preset: preset.Name, RetrySkipped: *retrySkipped}
+		if *gameURL == "" && *pgnFiles == "" {
+			run.Source, run.From, run.To = *sourceName, args.From, args.To
+		}
+		runBatch(analyser, settings.Workers, output, studyExporter, collection, dataset, csvExport, run, allGames, preset, calibration)
+		if *queryExpr != "" {
+			if err := dataset.run(os.Stdout, *queryExpr); err != nil {
+				log.Printf("Error in query: %v", err)
+			}
+		}
+		if *statsExport != "" {
+			if err := exportStats(*statsExport, allGames, filterPlayer, statsPolicy, dataset, imported); err != nil {
+				log.Printf("Error exporting statistics: %v", err)
+			}
+		}
+		return
+	}
+	listGames(allGames)
+
+	// --- Interactive Game Selection ---
+	reader := bufio.NewReader(os.Stdin)
+	for {
+		fmt.Print("\nEnter a game number to select, 'stats [repertoire|openings|vs <opponent>|upsets [gap]|colours|accuracy|acpl|phases|conversion|firstblunder|recurring|clock|terminations|streaks|times]' for statistics, 'filter <words>' to narrow the list, 'sort <key> [desc]' to order it, 'search <text>' to find games by opponent or opening, 'query <filter>' to search analysed moves, 'bundle <n> [out.zip]' to export a game's review, or 'quit' to exit: ")
+		input, _ := reader.ReadString('\n')
+		input = strings.TrimSpace(input)
+
+		if strings.ToLower(input) == "quit" {
+			if *statsExport != "" {
+				if err := exportStats(*statsExport, allGames, filterPlayer, statsPolicy, dataset, imported); err != nil {
+					log.Printf("Error exporting statistics: %v", err)
+				}
+			}
+			fmt.Println("Goodbye!")
+			break
+		}
+		if fields := strings.Fields(strings.ToLower(input)); len(fields) > 0 && fields[0] == "stats" {
+			statsCommand(fields[1:], allGames, statsPlayer, filterPlayer, statsPolicy, dataset, imported)
+			continue
+		}
+		if fields := strings.Fields(input); len(fields) > 1 && len(fields) <= 3 && strings.ToLower(fields[0]) == "bundle" {
+			gameNum, err := strconv.Atoi(fields[1])
+			if err != nil || gameNum < 1 || gameNum > len(allGames) {
+				fmt.Println("Invalid number. Please enter a number from the list.")
+				continue
+			}
+			path := ""
+			if len(fields) == 3 {
+				path = fields[2]
+			}
+			bundleGame(analyser, output, allGames[gameNum-1], gameNum, path)
+			continue
+		}
+		if fields := strings.Fields(input); len(fields) > 0 && strings.ToLower(fields[0]) == "filter" {
+			// A new filter replaces the previous one, including that of the flags.
+			filter, err := stats.ParseGameFilter(fields[1:])
+			var kept []api.Game
+			if err == nil {
+				kept, err = filterGames(loadedGames, filter, filterPlayer)
+			}
+			switch {
+			case err != nil:
+				fmt.Printf("Invalid filter: %v\n", err)
+			case len(kept) == 0:
+				fmt.Printf("No games match the filter (%s); the list is unchanged.\n", filter.Describe())
+			default:
+				allGames = gameSort.Apply(kept, filterPlayer)
+				fmt.Printf("Showing %d of %d games (%s).\n", len(allGames), len(loadedGames), filter.Describe())
+				listGames(allGames)
+			}
+			continue
+		}
+		if fields := strings.Fields(input); len(fields) > 0 && strings.ToLower(fields[0]) == "sort" {
+			order, err := stats.ParseGameSort(fields[1:])
+			if err == nil && order.NeedsPlayer() && filterPlayer == "" {
+				err = errors.New("sorting by rating needs a single player; give --user")
+			}
+			if err != nil {
+				fmt.Printf("Invalid sort: %v\n", err)
+				continue
+			}
+			gameSort = order
+			allGames = gameSort.Apply(allGames, filterPlayer)
+			fmt.Printf("Games sorted by %s.\n", gameSort.Describe())
+			listGames(allGames)
+			continue
+		}
+		if fields := strings.Fields(input); len(fields) > 0 && strings.ToLower(fields[0]) == "search" {
+			text := strings.TrimSpace(input[len(fields[0]):])
+			if text == "" {
+				fmt.Println("Give an opponent name, an opening name or an ECO code to search for.")
+				continue
+			}
+			matches := stats.SearchGames(allGames, text, filterPlayer)
+			if len(matches) == 0 {
+				fmt.Printf("No games match %q.\n", text)
+				continue
+			}
+			fmt.Printf("--- %d of %d games match %q ---\n", len(matches), len(allGames), text)
+			for _, match := range matches {
+				game := allGames[match.Index]
+				fmt.Printf("[%d] %s vs %s (%s) - Played on %s: %s\n", match.Index+1, game.White.Username, game.Black.Username,
+					game.TimeClass, time.Unix(game.EndTime, 0).Format("2006-01-02"), match.Reason)
+			}
+			fmt.Println("-------------------")
+			continue
+		}
+		if fields := strings.Fields(input); len(fields) > 0 && strings.ToLower(fields[0]) == "query" {
+			if err := dataset.run(os.Stdout, strings.TrimSpace(input[len(fields[0]):])); err != nil {
+				fmt.Printf("Invalid query: %v\n", err)
+			}
+			continue
+		}
+
+		gameNum, err := strconv.Atoi(input)
+		if err != nil || gameNum < 1 || gameNum > len(allGames) {
+			fmt.Println("Invalid number. Please enter a number from the list.")
+			continue
+		}
+
+		// Enter the sub-menu for the selected game
+		handleSelectedGame(reader, analyser, output, studyExporter, collection, dataset, boardStyle, allGames[gameNum-1], gameNum, imported[allGames[gameNum-1].URL])
+		listGames(allGames) // Re-list games after returning from sub-menu
+	}
+}
+
+// printDryRunSummary prints the work a full analysis of the fetched games would take.
+func printDryRunSummary(requests int, games []api.Game, preset gameengine.Preset, calibration gameengine.Calibration) {
+	workload := gameengine.EstimateWorkload(games, preset, calibration)
+	fmt.Println("\n--- Dry Run Summary ---")
+	fmt.Printf("API requests:          %d\n", requests)
+	fmt.Printf("Games:                 %d\n", workload.Games)
+	if bots := len(games) - len(api.WithoutBots(games)); bots > 0 {
+		fmt.Printf("Games vs computer:     %d (excluded from statistics)\n", bots)
+	}
+	if workload.Variants > 0 {
+		fmt.Printf("Variant games:         %d (cannot be analysed, not counted below)\n", workload.Variants)
+	}
+	if workload.NotAnalysable > 0 {
+		fmt.Printf("Games without moves:   %d (aborted or empty, not counted below)\n", workload.NotAnalysable)
+	}
+	if workload.Unparseable > 0 {
+		fmt.Printf("Unparseable games:     %d (not counted below)\n", workload.Unparseable)
+	}
+	fmt.Printf("Positions to analyse:  %d (preset: %s)\n", workload.Positions, preset.Name)
+	fmt.Printf("Time per position:     %s%s\n", workload.TimePerSearch, calibrationNote(calibration, preset))
+	fmt.Printf("Estimated engine time: %s\n", workload.EngineTime.Round(time.Second))
+	fmt.Println("-----------------------")
+}
+
+// calibrationNote tells whether the search time was measured by the benchmark command.
+func calibrationNote(calibration gameengine.Calibration, preset gameengine.Preset) string {
+	if _, ok := calibration[preset.Name]; ok {
+		return " (benchmarked)"
+	}
+	return " (not benchmarked, run 'benchmark' for an accurate estimate)"
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"chessAnalyserFree/api"
+	gamedb "chessAnalyserFree/gameDB"
+	"cmp"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// backfillAttempts is how often a month is requested before it is given up for this run.
+const backfillAttempts = 5
+
+// maxBackoff caps the wait after a rate-limited request.
+const maxBackoff = 10 * time.Minute
+
+// runBackfill implements the backfill subcommand, which stores a player's whole Chess.com history
+// in the game database. It is meant to run for hours: requests are spaced out, every month is
+// committed as soon as it is fetched, and months stored before are skipped, so an interrupted
+// backfill resumes where it stopped. It ends with an integrity check of the stored months.
+func runBackfill(arguments []string, defaults *userConfig) {
+	flags := flag.NewFlagSet("backfill", flag.ExitOnError)
+	user := flags.String("user", defaults.User, "Chess.com username to backfill")
+	since := flags.String("since", "", "first month to store, YYYY or YYYY-MM (default: the first archive)")
+	dbPath := flags.String("db", cmp.Or(defaults.DB, "games.db"), "game database: a bbolt file, an SQLite file (.sqlite) or a postgres:// URL")
+	delay := flags.Duration("delay", 3*time.Second, "pause between requests")
+	flags.Parse(arguments)
+	if *user == "" || flags.NArg() != 0 {
+		fmt.Println("Usage: go run . backfill [--user <username>] [--since YYYY[-MM]] [--db games.db] [--delay 3s]")
+		flags.PrintDefaults()
+		return
+	}
+	var first time.Time
+	if *since != "" {
+		var err error
+		if first, err = time.Parse("2006-01", *since); err != nil {
+			if first, err = time.Parse("2006", *since); err != nil {
+				log.Fatalf("Error parsing --since %q: use YYYY or YYYY-MM.", *since)
+			}
+		}
+	}
+
+	db, err := gamedb.Open(*dbPath)
+	if err != nil {
+		log.Fatalf("Error opening game database: %v", err)
+	}
+	defer db.Close()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	client := api.NewClient()
+	client.HTTPClient.Timeout = time.Minute
+
+	var archives []string
+	err = politely(ctx, *delay, func() error {
+		archives, err = client.FetchArchives(*user)
+		return err
+	})
+	if err != nil {
+		fatal(exitNetwork, "Error listing the archives of %s: %v", *user, err)
+	}
+	var months []time.Time
+	for _, archive := range archives {
+		month, err := time.Parse("2006/01", archive)
+		if err == nil && !month.Before(first) {
+			months = append(months, month)
+		}
+	}
+	fmt.Printf("Backfilling %d months of %s into %s, one request every %s. Press Ctrl-C to stop; run again to resume.\n",
+		len(months), *user, *dbPath, *delay)
+
+	fetched, stored, failed := 0, 0, 0
+	for i, month := range months {
+		record, err := db.Month(client.Name(), *user, month)
+		if err != nil {
+			log.Fatalf("Error reading game database: %v", err)
+		}
+		if record != nil && record.Complete {
+			continue
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		fetchedAt := time.Now()
+		var games []api.Game
+		err = politely(ctx, *delay, func() error {
+			response, err := client.FetchPlayerGamesByMonth(*user, month.Format("2006"), month.Format("01"))
+			if err == nil {
+				games = response.Games
+			}
+			return err
+		})
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("[%d/%d] %s: giving up for now: %v", i+1, len(months), month.Format("2006-01"), err)
+				failed++
+			}
+			continue
+		}
+		if err := db.SaveMonth(client.Name(), *user, month, games, fetchedAt); err != nil {
+			log.Fatalf("Error storing %s: %v", month.Format("2006-01"), err)
+		}
+		fetched++
+		stored += len(games)
+		fmt.Printf("[%d/%d] %s: %d games stored\n", i+1, len(months), month.Format("2006-01"), len(games))
+	}
+	if ctx.Err() != nil {
+		fmt.Printf("\nInterrupted after storing %d months (%d games); run the same command to resume.\n", fetched, stored)
+		return
+	}
+	fmt.Printf("Fetched %d months (%d games)", fetched, stored)
+	if failed > 0 {
+		fmt.Printf("; %d months failed and will be retried on the next run", failed)
+	}
+	fmt.Println(".")
+	if failed > 0 {
+		fail(exitPartial, "%d months could not be fetched.", failed)
+	}
+
+	// --- Integrity Check ---
+	archived, present, problems := 0, 0, 0
+	for _, month := range months {
+		check, err := gamedb.CheckMonth(db, client.Name(), *user, month)
+		if err != nil {
+			log.Fatalf("Error reading game database: %v", err)
+		}
+		archived += check.Archived
+		present += check.Stored
+		switch {
+		case !check.Recorded:
+			fmt.Printf("  %s: not stored\n", month.Format("2006-01"))
+			problems++
+		case !check.OK():
+			fmt.Printf("  %s: %d games in the archive, %d stored\n", month.Format("2006-01"), check.Archived, check.Stored)
+			problems++
+		}
+	}
+	fmt.Printf("Integrity check: %d months, %d games in the archives, %d stored, %d problems.\n", len(months), archived, present, problems)
+}
+
+// politely calls request, retrying failures: after the configured delay for ordinary errors and
+// with an exponentially growing wait when rate limited. It always waits delay first, so consecutive
+// requests are spaced out, and gives up early when ctx is cancelled.
+func politely(ctx context.Context, delay time.Duration, request func() error) error {
+	wait := delay
+	backoff := time.Minute
+	var err error
+	for attempt := 0; attempt < backfillAttempts; attempt++ {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+		if err = request(); err == nil {
+			return nil
+		}
+		if errors.Is(err, api.ErrNotFound) || errors.Is(err, api.ErrAccountClosed) {
+			// Asking again cannot help.
+			return err
+		}
+		wait = delay
+		if errors.Is(err, api.ErrRateLimited) {
+			log.Printf("Rate limited; waiting %s.", backoff)
+			wait = backoff
+			backoff = min(2*backoff, maxBackoff)
+		}
+	}
+	return err
+}
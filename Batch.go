@@ -0,0 +1,411 @@
+package main
+
+import (
+	"bufio"
+	"chessAnalyserFree/api"
+	gameengine "chessAnalyserFree/gameEngine"
+	"chessAnalyserFree/query"
+	"chessAnalyserFree/report"
+	"chessAnalyserFree/stats"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// skippedGamesFile records the games skipped during batch runs so they can be completed later.
+const skippedGamesFile = "skipped-games.json"
+
+// batchAction is a request from the user to change how the current game is analysed.
+type batchAction int32
+
+const (
+	actionNone batchAction = iota
+	actionSkip
+	actionDowngrade
+)
+
+// batchControl receives skip/downgrade requests while a batch is running. With several engine
+// workers, a request applies to the earliest game being analysed, the one the output waits for.
+type batchControl struct {
+	mu      sync.Mutex
+	current map[*gameengine.StockfishAnalyser]int         // The game each busy worker analyses.
+	pending map[*gameengine.StockfishAnalyser]batchAction // Requests the workers have not taken yet.
+}
+
+// newBatchControl returns a batch control with no game being analysed.
+func newBatchControl() *batchControl {
+	return &batchControl{
+		current: make(map[*gameengine.StockfishAnalyser]int),
+		pending: make(map[*gameengine.StockfishAnalyser]batchAction),
+	}
+}
+
+// start records that worker is analysing game i.
+func (c *batchControl) start(worker *gameengine.StockfishAnalyser, i int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.current[worker] = i
+}
+
+// stop records that worker has finished its game, dropping requests it did not take.
+func (c *batchControl) stop(worker *gameengine.StockfishAnalyser) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.current, worker)
+	delete(c.pending, worker)
+}
+
+// request interrupts the earliest game being analysed and remembers what to do with it.
+func (c *batchControl) request(action batchAction) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var target *gameengine.StockfishAnalyser
+	for worker, i := range c.current {
+		if target == nil || i < c.current[target] {
+			target = worker
+		}
+	}
+	if target == nil {
+		return
+	}
+	c.pending[target] = action
+	target.Skip()
+}
+
+// take returns and clears the action pending for worker.
+func (c *batchControl) take(worker *gameengine.StockfishAnalyser) batchAction {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	action := c.pending[worker]
+	delete(c.pending, worker)
+	return action
+}
+
+// listenStdin turns "s" and "d" lines on standard input into skip and downgrade requests.
+func (c *batchControl) listenStdin() {
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		switch strings.ToLower(strings.TrimSpace(scanner.Text())) {
+		case "s", "skip":
+			c.request(actionSkip)
+		case "d", "downgrade":
+			c.request(actionDowngrade)
+		}
+	}
+}
+
+// skippedGame is an entry of the skipped games file.
+type skippedGame struct {
+	URL       string    `json:"url"`
+	Reason    string    `json:"reason"`
+	SkippedAt time.Time `json:"skipped_at"`
+}
+
+// loadSkippedGames reads the skipped games file. A missing file yields no entries.
+func loadSkippedGames() ([]skippedGame, error) {
+	data, err := os.ReadFile(skippedGamesFile)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var skipped []skippedGame
+	if err := json.Unmarshal(data, &skipped); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", skippedGamesFile, err)
+	}
+	return skipped, nil
+}
+
+// saveSkippedGames writes the skipped games file, removing it when nothing is left to complete.
+func saveSkippedGames(skipped []skippedGame) error {
+	if len(skipped) == 0 {
+		if err := os.Remove(skippedGamesFile); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	data, err := json.MarshalIndent(skipped, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(skippedGamesFile, data, 0o644)
+}
+
+// filterSkippedGames keeps only the games recorded as skipped by an earlier batch run.
+func filterSkippedGames(games []api.Game) []api.Game {
+	skipped, err := loadSkippedGames()
+	if err != nil {
+		log.Fatalf("Error reading skipped games: %v", err)
+	}
+	urls := make(map[string]bool, len(skipped))
+	for _, entry := range skipped {
+		urls[entry.URL] = true
+	}
+	var filtered []api.Game
+	for _, game := range games {
+		if urls[game.URL] {
+			filtered = append(filtered, game)
+		}
+	}
+	fmt.Printf("Retrying %d of %d previously skipped games.\n", len(filtered), len(skipped))
+	return filtered
+}
+
+// runBatch analyses every game, writing reports and showing a live ETA.
+// The games are analysed in parallel by a pool of workers engine processes, the analyser and
+// processes spawned from it with its settings, while the results are reported, recorded and
+// checkpointed one at a time in the order of the games.
+// While it runs, the earliest game being analysed can be skipped ("s" + Enter, or SIGUSR1) or
+// restarted with a cheaper preset ("d" + Enter, or SIGUSR2). Skipped games are recorded in
+// skippedGamesFile.
+// When study is not nil, every analysed game is also added to the Lichess study, and when collection
+// is not nil, to the PGN collection unless it holds the game already. Valid analyses are
+// added to the query dataset and, when csvExport is not nil, written to the CSV export. Progress is checkpointed after every game in checkpointFile, so an
+// interrupted run started again with the same parameters, run, analyses only the games it has not
+// finished, whatever games it finds this time.
+// Games that failed are not checkpointed, and the checkpoint is kept after a run with failures, so
+// running it again retries just those games.
+// Games that failed or were skipped make the run a partial success, or an engine failure when no
+// game was analysed.
+func runBatch(analyser *gameengine.StockfishAnalyser, workers int, output *reportOutput, study *studyExport, collection *report.PGNCollection, dataset *moveDataset, csvExport *report.CSVWriter, run batchRun, games []api.Game, preset gameengine.Preset, calibration gameengine.Calibration) {
+	checkpoint, err := loadCheckpoint(run)
+	if err != nil {
+		log.Printf("Ignoring unreadable checkpoint: %v", err)
+	}
+	// remaining are the games left to analyse and numbers their positions in games. Games the run has
+	// found since it was interrupted are analysed along with the others.
+	remaining, numbers := checkpoint.pending(games)
+	if len(remaining) < len(games) {
+		fmt.Printf("Resuming an interrupted run: %d of %d games already done.\n", len(games)-len(remaining), len(games))
+	}
+	// finish records a game as done: analysed, or not analysed for a reason retrying cannot change.
+	// Games whose analysis failed are left pending, so that running the batch again retries them.
+	finish := func(game api.Game) {
+		if err := checkpoint.markDone(game.URL); err != nil {
+			log.Printf("Error saving %s: %v", checkpointFile, err)
+		}
+	}
+
+	workers = max(min(workers, len(remaining)), 1)
+	pool, err := gameengine.NewPool(analyser, workers)
+	if err != nil {
+		fatal(exitEngine, "Error starting Stockfish analyser: %v", err)
+	}
+	defer pool.Close()
+
+	workload := gameengine.EstimateWorkload(remaining, preset, calibration)
+	// The workers share the machine, so the engine time is not divided by their number.
+	fmt.Printf("Analysing %d games (%d positions, preset: %s). Estimated engine time: %s\n",
+		workload.Games, workload.Positions, preset.Name, workload.EngineTime.Round(time.Second))
+	if workers > 1 {
+		fmt.Printf("Analysing %d games at a time.\n", workers)
+	}
+	fmt.Println("Type 's' + Enter to skip the current game, 'd' + Enter to restart it with a cheaper preset.")
+
+	// progress guards the ETA and the progress line, which the workers update as they search.
+	var progress sync.Mutex
+	eta := gameengine.NewETA(workload.Positions, workload.TimePerSearch)
+	line := newProgressLine()
+	pool.OnPositionAnalysed(func() {
+		progress.Lock()
+		defer progress.Unlock()
+		eta.Advance(1)
+		line.show(eta.String())
+	})
+	defer pool.OnPositionAnalysed(nil)
+	// printf prints a line of output in place of the progress line.
+	printf := func(format string, a ...any) {
+		progress.Lock()
+		defer progress.Unlock()
+		line.clear()
+		fmt.Printf(format, a...)
+	}
+
+	control := newBatchControl()
+	go control.listenStdin()
+	stopSignals := control.listenSignals()
+	defer stopSignals()
+
+	skipped, err := loadSkippedGames()
+	if err != nil {
+		log.Printf("Ignoring unreadable %s: %v", skippedGamesFile, err)
+	}
+	newlySkipped, variantGames, emptyGames, cachedGames, collected := 0, 0, 0, 0, 0
+	analysed, failed := 0, 0
+
+	// analyse runs on a worker's goroutine, restarting the game with cheaper presets when asked to.
+	analyse := func(worker *gameengine.StockfishAnalyser, j int, game api.Game) gameengine.GameResult {
+		control.start(worker, j)
+		defer control.stop(worker)
+		gamePreset := preset
+		analysis, err := worker.AnalyseGame(game)
+		for errors.Is(err, gameengine.ErrAnalysisSkipped) && control.take(worker) == actionDowngrade {
+			lower, ok := gameengine.DowngradePreset(gamePreset)
+			if !ok {
+				break
+			}
+			gamePreset = lower
+			printf("Restarting game %d with preset '%s'.\n", numbers[j]+1, gamePreset.Name)
+			if err := worker.SetPreset(gamePreset); err != nil {
+				fatal(exitEngine, "Error configuring Stockfish: %v", err)
+			}
+			analysis, err = worker.AnalyseGame(game)
+		}
+		if gamePreset.Name != preset.Name {
+			if err := worker.SetPreset(preset); err != nil {
+				fatal(exitEngine, "Error configuring Stockfish: %v", err)
+			}
+		}
+		return gameengine.GameResult{Analysis: analysis, Err: err}
+	}
+
+	// record runs on this goroutine for every game in turn.
+	record := func(j int, result gameengine.GameResult) {
+		i, game, analysis, err := numbers[j], remaining[j], result.Analysis, result.Err
+		if errors.Is(err, gameengine.ErrAnalysisSkipped) {
+			printf("[%d] %s vs %s: skipped\n", i+1, game.White.Username, game.Black.Username)
+			skipped = recordSkip(skipped, game.URL, "skipped by user")
+			newlySkipped++
+			finish(game)
+			return
+		}
+		if errors.Is(err, gameengine.ErrUnsupportedVariant) {
+			// Not recorded as skipped: retrying cannot help.
+			printf("[%d] %s vs %s: not analysed, %s is not supported\n", i+1, game.White.Username, game.Black.Username, game.Rules)
+			variantGames++
+			finish(game)
+			return
+		}
+		if errors.Is(err, gameengine.ErrNotAnalysable) {
+			// Not recorded as skipped either: the game has no moves to analyse.
+			printf("[%d] %s vs %s: not analysable, %s\n", i+1, game.White.Username, game.Black.Username, gameengine.NotAnalysableReason(game))
+			emptyGames++
+			finish(game)
+			return
+		}
+		if err != nil {
+			// Not recorded as skipped: the checkpoint leaves the game pending, so running the batch
+			// again retries it.
+			log.Printf("Game %d (%s) could not be analysed: %v", i+1, game.URL, err)
+			failed++
+			return
+		}
+		defer finish(game)
+		if !analysis.IsValid() {
+			// Do not write reports from an analysis that is probably garbage.
+			reason := "invalid analysis: " + strings.Join(analysis.Issues, "; ")
+			printf("[%d] %s vs %s: %s\n", i+1, game.White.Username, game.Black.Username, reason)
+			skipped = recordSkip(skipped, game.URL, reason)
+			newlySkipped++
+			return
+		}
+		skipped = removeSkip(skipped, game.URL)
+		analysed++
+		status := "analysed"
+		if analysis.Cached {
+			status = "read from the game database"
+			cachedGames++
+		}
+		depth := ""
+		if depths := gameengine.SearchDepths(analysis.Moves); depths.Positions > 0 {
+			depth = fmt.Sprintf(", reached depth %d-%d", depths.Min, depths.Max)
+			if depths.Min == depths.Max {
+				depth = fmt.Sprintf(", reached depth %d", depths.Min)
+			}
+		}
+		printf("[%d] %s vs %s: %d moves %s (preset: %s%s)\n", i+1, game.White.Username, game.Black.Username, len(analysis.Moves), status, analysis.Preset, depth)
+		writeGameReports(output, game, analysis, i+1)
+		dataset.add(game, analysis)
+		if csvExport != nil {
+			if err := csvExport.WriteGame(game, analysis); err != nil {
+				log.Printf("Game %d could not be written to the CSV export: %v", i+1, err)
+			}
+		}
+		if study != nil {
+			if err := study.push(game, analysis); err != nil {
+				log.Printf("Game %d could not be added to the study: %v", i+1, err)
+			}
+		}
+		if collection != nil {
+			if added, err := collection.Add(game, analysis); err != nil {
+				log.Printf("Game %d could not be added to the PGN collection: %v", i+1, err)
+			} else if added {
+				collected++
+			}
+		}
+	}
+	pool.AnalyseGames(remaining, analyse, record)
+	if failed == 0 {
+		if err := checkpoint.remove(); err != nil {
+			log.Printf("Error removing %s: %v", checkpointFile, err)
+		}
+	}
+
+	if err := saveSkippedGames(skipped); err != nil {
+		log.Printf("Error saving %s: %v", skippedGamesFile, err)
+	}
+	fmt.Printf("Batch finished in %s.\n", eta.Elapsed().Round(time.Second))
+	var trend *stats.AccuracyTrend
+	if dataset.player != "" && analysed > 0 {
+		built := stats.BuildAccuracyTrend(dataset.analysed(games, nil), dataset.player)
+		trend = &built
+		trend.Write(os.Stdout)
+		query.Weaknesses(dataset.analysed(games, nil), dataset.player).Write(os.Stdout)
+		query.RecurringMistakes(dataset.analysed(games, nil), dataset.player).Write(os.Stdout)
+		query.TimeTrouble(dataset.analysed(games, nil), dataset.player).Write(os.Stdout)
+	}
+	output.writeBatchHTML(fmt.Sprintf("Reports of %d games", len(output.batch)), trend)
+	if newlySkipped > 0 {
+		fmt.Printf("%d games skipped; rerun with --batch --retry-skipped to complete them.\n", newlySkipped)
+	}
+	if failed > 0 {
+		fmt.Printf("%d games failed; run the same command again to retry them, the others are checkpointed as done.\n", failed)
+	}
+	if variantGames > 0 {
+		fmt.Printf("%d variant games (bughouse, crazyhouse, ...) were not analysed.\n", variantGames)
+	}
+	if emptyGames > 0 {
+		fmt.Printf("%d aborted games and games without moves were not analysable.\n", emptyGames)
+	}
+	if cachedGames > 0 {
+		fmt.Printf("%d games were already analysed with these settings and read from the game database.\n", cachedGames)
+	}
+	if collection != nil {
+		fmt.Printf("%d new games added to the PGN collection, which holds %d games.\n", collected, collection.Len())
+	}
+	if hits := pool.PositionCacheHits(); hits > 0 {
+		fmt.Printf("%d positions had been searched before and were taken from the position cache.\n", hits)
+	}
+	if hits := pool.ExternalHits(); hits > 0 {
+		fmt.Printf("%d positions were taken from the Lichess cloud.\n", hits)
+	}
+	switch {
+	case failed > 0 && analysed == 0:
+		fail(exitEngine, "None of the games could be analysed; %d failed.", failed)
+	case failed+newlySkipped > 0:
+		fail(exitPartial, "%d of %d games were not analysed.", failed+newlySkipped, len(games))
+	}
+}
+
+// recordSkip adds or updates the skip entry for a game.
+func recordSkip(skipped []skippedGame, url, reason string) []skippedGame {
+	skipped = removeSkip(skipped, url)
+	return append(skipped, skippedGame{URL: url, Reason: reason, SkippedAt: time.Now()})
+}
+
+// removeSkip drops the skip entry for a game, if any.
+func removeSkip(skipped []skippedGame, url string) []skippedGame {
+	kept := skipped[:0]
+	for _, entry := range skipped {
+		if entry.URL != url {
+			kept = append(kept, entry)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"chessAnalyserFree/engine"
+	gameengine "chessAnalyserFree/gameEngine"
+	"flag"
+	"fmt"
+	"log"
+	"time"
+)
+
+// runBenchmark measures the engine's search time per position for each preset and saves it
+// as calibration for workload estimates.
+func runBenchmark(arguments []string, defaults *userConfig) {
+	flags := flag.NewFlagSet("benchmark", flag.ExitOnError)
+	presetName := flags.String("preset", "", "benchmark only this preset (default: all presets)")
+	flags.Parse(arguments)
+	enginePath, ok := defaults.engineArg(flags)
+	if !ok {
+		fmt.Println("Usage: go run . benchmark [--preset name] [<path_to_stockfish>]")
+		return
+	}
+
+	names := engine.PresetNames()
+	if *presetName != "" {
+		names = []string{*presetName}
+	}
+
+	sf, err := engine.Start(enginePath, engine.DefaultOptions())
+	if err != nil {
+		fatal(exitEngine, "Error starting Stockfish analyser: %v", err)
+	}
+	defer sf.Close()
+
+	calibration, err := gameengine.LoadCalibration()
+	if err != nil {
+		log.Printf("Replacing unreadable calibration: %v", err)
+	}
+	for _, name := range names {
+		preset, err := engine.LookupPreset(name)
+		if err != nil {
+			log.Fatalf("Error selecting preset: %v", err)
+		}
+		fmt.Printf("Benchmarking preset '%s'...\n", preset.Name)
+		perPosition, err := sf.Benchmark(preset)
+		if err != nil {
+			log.Fatalf("Error benchmarking preset %s: %v", preset.Name, err)
+		}
+		calibration[preset.Name] = perPosition
+		fmt.Printf("  %s per position\n", perPosition.Round(time.Millisecond))
+	}
+	if err := calibration.Save(); err != nil {
+		log.Fatalf("Error saving calibration: %v", err)
+	}
+	fmt.Println("Calibration saved.")
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"chessAnalyserFree/api"
+	"chessAnalyserFree/board"
+	gameengine "chessAnalyserFree/gameEngine"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+)
+
+// showPosition prints a position of the game as a board for the game menu's board command: the
+// final position, or the one before the move given in args as e.g. "12" (White's 12th move) or
+// "12..." (Black's), with the engine's view of it when the game was analysed. "flip" in args shows
+// the board from Black's side.
+func showPosition(game api.Game, analysis *gameengine.GameAnalysis, args []string, style board.Style) {
+	flipped := false
+	label := ""
+	for _, arg := range args {
+		if arg == "flip" {
+			flipped = true
+		} else {
+			label = arg
+		}
+	}
+	if label == "" {
+		printGameBoard(game, style, flipped)
+		return
+	}
+
+	positions, err := gameengine.ReplayPositions(game.PGN)
+	if err != nil {
+		log.Printf("Error reading game: %v", err)
+		return
+	}
+	i, ok := findMove(positions, label)
+	if !ok {
+		return
+	}
+	position := positions[i]
+	text, err := board.Render(position.FEN, style, flipped)
+	if err != nil {
+		log.Printf("Error drawing the board: %v", err)
+		return
+	}
+	fmt.Printf("\nPosition before %s %s\n%s", moveLabel(position), position.SAN, text)
+	fmt.Printf("FEN: %s\n", position.FEN)
+	if analysis != nil && i < len(analysis.Moves) && analysis.Moves[i].Classification != gameengine.ClassBook {
+		move := analysis.Moves[i]
+		fmt.Printf("Eval: %s, best move %s; played %s (%s)\n", colorEval(move, move.EvaluationText),
+			gameengine.MoveSAN(position.FEN, move.BestMove),
+			colorMove(move.Classification, position.SAN+annotationSymbol(move.Classification)), move.Classification)
+	}
+}
+
+// findMove returns the index in positions of the move given as e.g. "12" (White's 12th move) or
+// "12..." (Black's). It tells the user when there is no such move.
+func findMove(positions []gameengine.PlyPosition, label string) (int, bool) {
+	number, err := strconv.Atoi(strings.TrimSuffix(label, "..."))
+	if err != nil || number < 1 {
+		fmt.Println("Give the move as e.g. 12 for White's 12th move or 12... for Black's.")
+		return 0, false
+	}
+	whiteMove := !strings.HasSuffix(label, "...")
+	for i, position := range positions {
+		if gameengine.FullMoveNumber(position.FEN) == number && strings.Contains(position.FEN, " w ") == whiteMove {
+			return i, true
+		}
+	}
+	fmt.Printf("The game has no move %s.\n", label)
+	return 0, false
+}
+
+// moveLabel returns the move number of the move played from a position, e.g. "12." or "12...".
+func moveLabel(position gameengine.PlyPosition) string {
+	if strings.Contains(position.FEN, " w ") {
+		return fmt.Sprintf("%d.", gameengine.FullMoveNumber(position.FEN))
+	}
+	return fmt.Sprintf("%d...", gameengine.FullMoveNumber(position.FEN))
+}
+
+// finalPosition returns the FEN of the final position of a game.
+func finalPosition(game api.Game) (string, error) {
+	if game.FEN != "" {
+		return game.FEN, nil
+	}
+	return gameengine.FinalFEN(game.PGN)
+}
+
+// printGameBoard prints the final position of a game as a board.
+func printGameBoard(game api.Game, style board.Style, flipped bool) {
+	fen, err := finalPosition(game)
+	if err != nil {
+		log.Printf("Error reading game: %v", err)
+		return
+	}
+	text, err := board.Render(fen, style, flipped)
+	if err != nil {
+		log.Printf("Error drawing the board: %v", err)
+		return
+	}
+	fmt.Printf("\nFinal position\n%s", text)
+	fmt.Printf("FEN: %s\n", fen)
+}
+
+// The replay shows the engine's replayLines best lines, replayLineMoves moves long.
+const (
+	replayLines     = 3
+	replayLineMoves = 6
+)
+
+// gameReplay steps through a game in the game menu, move by move.
+type gameReplay struct {
+	positions []gameengine.PlyPosition
+	finalFEN  string
+	ply       int  // Moves played so far; 0 is the starting position.
+	flipped   bool // Whether boards are drawn from Black's side.
+	// lines are the engine's top lines already searched, by the index of the position.
+	lines map[int][]string
+}
+
+// newGameReplay returns the replay of a game, at its starting position.
+func newGameReplay(game api.Game) (*gameReplay, error) {
+	positions, err := gameengine.ReplayPositions(game.PGN)
+	if err != nil {
+		return nil, err
+	}
+	finalFEN, err := gameengine.FinalFEN(game.PGN)
+	if err != nil {
+		return nil, err
+	}
+	return &gameReplay{positions: positions, finalFEN: finalFEN, lines: make(map[int][]string)}, nil
+}
+
+// step carries out the game menu's replay commands: "next" and "prev" move one move forward or
+// back, and "goto" to the position after the move given as e.g. "12" or "12...", or to the start
+// with "goto 0" or the end with "goto end". "flip" among the arguments turns the board around. It
+// returns false when the position did not change, after telling the user why.
+func (r *gameReplay) step(command string, args []string) bool {
+	var target string
+	for _, arg := range args {
+		if arg == "flip" {
+			r.flipped = !r.flipped
+		} else {
+			target = arg
+		}
+	}
+	switch command {
+	case "next":
+		if r.ply == len(r.positions) {
+			fmt.Println("End of the game.")
+			return false
+		}
+		r.ply++
+	case "prev":
+		if r.ply == 0 {
+			fmt.Println("Start of the game.")
+			return false
+		}
+		r.ply--
+	case "goto":
+		switch target {
+		case "":
+			fmt.Println("Give the move as e.g. 'goto 12' for White's 12th move or 'goto 12...' for Black's.")
+			return false
+		case "0", "start":
+			r.ply = 0
+		case "end":
+			r.ply = len(r.positions)
+		default:
+			i, ok := findMove(r.positions, target)
+			if !ok {
+				return false
+			}
+			r.ply = i + 1
+		}
+	}
+	return true
+}
+
+// show prints the replay's current position: the board after the move just played, the move with
+// its evaluation and classification when the game was analysed, and the engine's top lines from
+// the position the move was played in, searched with analyser.
+func (r *gameReplay) show(analyser *gameengine.StockfishAnalyser, analysis *gameengine.GameAnalysis, style board.Style) {
+	fen := r.fen()
+	text, err := board.Render(fen, style, r.flipped)
+	if err != nil {
+		log.Printf("Error drawing the board: %v", err)
+		return
+	}
+	if r.ply == 0 {
+		fmt.Printf("\nStarting position\n%s", text)
+		fmt.Printf("FEN: %s\n", fen)
+		if len(r.positions) > 0 {
+			printLines("Top lines:", r.topLines(analyser, 0))
+		}
+		return
+	}
+
+	i := r.ply - 1
+	played := r.positions[i]
+	playedText := played.SAN
+	if analysis != nil && i < len(analysis.Moves) {
+		class := analysis.Moves[i].Classification
+		playedText = colorMove(class, played.SAN+annotationSymbol(class))
+	}
+	fmt.Printf("\nMove %d of %d: %s %s\n%s", r.ply, len(r.positions), moveLabel(played), playedText, text)
+	fmt.Printf("FEN: %s\n", fen)
+	if analysis != nil && i < len(analysis.Moves) {
+		move := analysis.Moves[i]
+		if move.Classification == gameengine.ClassBook {
+			fmt.Println(colorize(ansiDim, "Book move."))
+		} else {
+			fmt.Printf("Eval before the move: %s; %s (%s, centipawn loss %d)\n", colorEval(move, move.EvaluationText), played.SAN,
+				move.Classification, move.CentipawnLoss)
+		}
+	}
+	printLines(fmt.Sprintf("Top lines before %s %s:", moveLabel(played), played.SAN), r.topLines(analyser, i))
+}
+
+// fen returns the FEN of the replay's current position.
+func (r *gameReplay) fen() string {
+	if r.ply < len(r.positions) {
+		return r.positions[r.ply].FEN
+	}
+	return r.finalFEN
+}
+
+// currentFEN returns the FEN of the replay's current position, or of the game's final position
+// before the game menu starts a replay, when r is nil.
+func (r *gameReplay) currentFEN(game api.Game) (string, error) {
+	if r == nil {
+		return finalPosition(game)
+	}
+	return r.fen(), nil
+}
+
+// printLines prints engine lines under a heading, numbered from the best.
+func printLines(heading string, lines []string) {
+	fmt.Println(heading)
+	for i, line := range lines {
+		fmt.Printf("  %d) %s\n", i+1, line)
+	}
+}
+
+// topLines returns the engine's top lines from a position of the game with their scores, numbered
+// like a PGN, e.g. "12... Nc6 13. Nf3 (+0.35)". Lines are searched once.
+func (r *gameReplay) topLines(analyser *gameengine.StockfishAnalyser, i int) []string {
+	if lines, ok := r.lines[i]; ok {
+		return lines
+	}
+	fen := r.positions[i].FEN
+	engineLines, err := analyser.TopLines(fen, replayLines, replayLineMoves)
+	if err != nil {
+		log.Printf("Error searching the position: %v", err)
+		return []string{"unavailable"}
+	}
+	if len(engineLines) == 0 {
+		return []string{"none"}
+	}
+	var lines []string
+	for _, engineLine := range engineLines {
+		lines = append(lines, fmt.Sprintf("%s (%s)", numberLine(fen, engineLine.Moves), engineLine.Score))
+	}
+	r.lines[i] = lines
+	return lines
+}
+
+// numberLine numbers the SAN moves of a line played from fen like a PGN, e.g. "12... Nc6 13. Nf3".
+func numberLine(fen string, moves []string) string {
+	number, whiteMove := gameengine.FullMoveNumber(fen), strings.Contains(fen, " w ")
+	var line []string
+	for j, san := range moves {
+		switch {
+		case whiteMove:
+			line = append(line, fmt.Sprintf("%d. %s", number, san))
+		case j == 0:
+			line = append(line, fmt.Sprintf("%d... %s", number, san))
+		default:
+			line = append(line, san)
+		}
+		if !whiteMove {
+			number++
+		}
+		whiteMove = !whiteMove
+	}
+	return strings.Join(line, " ")
+}
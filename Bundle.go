@@ -0,0 +1,57 @@
+package main
+
+import (
+	"chessAnalyserFree/api"
+	gameengine "chessAnalyserFree/gameEngine"
+	"chessAnalyserFree/report"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// bundleGame analyses a game and writes its review bundle, a zip archive with the annotated PGN,
+// the JSON analysis, the HTML report and diagrams, to path; without a path, to game-<n>.zip in the
+// report directory.
+func bundleGame(analyser *gameengine.StockfishAnalyser, output *reportOutput, game api.Game, gameNum int, path string) {
+	if path == "" {
+		path = filepath.Join(output.dir, fmt.Sprintf("game-%d.zip", gameNum))
+	}
+	if !strings.EqualFold(filepath.Ext(path), ".zip") {
+		fmt.Println("The bundle is a zip archive; give a file name ending in .zip.")
+		return
+	}
+	fmt.Printf("Analysing game %d for the bundle...\n", gameNum)
+	analysis, err := analyseWithProgress(analyser, game)
+	if err != nil {
+		log.Printf("Error during analysis: %v", err)
+		return
+	}
+	if !analysis.IsValid() {
+		fmt.Printf("The analysis failed the sanity checks (%s); no bundle written.\n", strings.Join(analysis.Issues, "; "))
+		return
+	}
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			log.Printf("Error creating %s: %v", dir, err)
+			return
+		}
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		log.Printf("Error writing bundle: %v", err)
+		return
+	}
+	if err := report.WriteBundle(file, output.renderer, game, analysis); err != nil {
+		file.Close()
+		os.Remove(path)
+		log.Printf("Error writing bundle: %v", err)
+		return
+	}
+	if err := file.Close(); err != nil {
+		log.Printf("Error writing bundle: %v", err)
+		return
+	}
+	fmt.Printf("Bundle written to %s\n", path)
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	gameengine "chessAnalyserFree/gameEngine"
+	"chessAnalyserFree/lichess"
+	"chessAnalyserFree/references"
+	"cmp"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+// runCalibrate implements the calibrate subcommand, which analyses reference games whose accuracy
+// figures were published by Lichess, reports how far our figures deviate from them, and suggests
+// classification thresholds that reproduce the published inaccuracy, mistake and blunder counts.
+// It keeps our numbers comparable to the ones players see elsewhere. With --fetch it instead
+// exports games analysed on Lichess into a reference file.
+func runCalibrate(arguments []string, defaults *userConfig) {
+	flags := flag.NewFlagSet("calibrate", flag.ExitOnError)
+	referencesPath := flags.String("references", "", "PGN file of reference games (default: the bundled set)")
+	presetName := flags.String("preset", cmp.Or(defaults.Preset, gameengine.DefaultPresetName), "analysis preset: "+strings.Join(gameengine.PresetNames(), ", "))
+	depth := flags.Int("depth", defaults.Depth, "search every position to this depth instead of the preset's limits")
+	fetch := flags.String("fetch", "", "comma-separated Lichess game IDs or URLs to add to the reference file instead of calibrating")
+	out := flags.String("out", "references.pgn", "reference file fetched games are appended to")
+	flags.Parse(arguments)
+	if *fetch != "" {
+		if err := fetchReferences(strings.Split(*fetch, ","), *out); err != nil {
+			log.Fatalf("Error fetching reference games: %v", err)
+		}
+		return
+	}
+	enginePath, ok := defaults.engineArg(flags)
+	if !ok {
+		fmt.Println("Usage: go run . calibrate [--references file.pgn] [--preset standard] [--depth n] [<path_to_stockfish>]")
+		fmt.Println("       go run . calibrate --fetch <id>[,<id>...] [--out references.pgn]")
+		flags.PrintDefaults()
+		return
+	}
+
+	var games []references.Reference
+	var err error
+	if *referencesPath == "" {
+		games, err = references.Bundled()
+	} else {
+		var file *os.File
+		if file, err = os.Open(*referencesPath); err == nil {
+			games, err = references.Read(file, "file://"+*referencesPath)
+			file.Close()
+		}
+	}
+	if err != nil {
+		log.Fatalf("Error reading reference games: %v", err)
+	}
+	if len(games) == 0 {
+		log.Fatalf("No reference games; add games analysed on Lichess with --fetch and pass the file with --references.")
+	}
+
+	preset, err := gameengine.LookupPreset(*presetName)
+	if err != nil {
+		log.Fatalf("Error selecting preset: %v", err)
+	}
+	if *depth > 0 {
+		preset = preset.WithDepth(*depth)
+	}
+	analyser, err := gameengine.NewStockfishAnalyser(enginePath)
+	if err != nil {
+		fatal(exitEngine, "Error starting Stockfish analyser: %v", err)
+	}
+	defer analyser.Close()
+	if err := analyser.SetPreset(preset); err != nil {
+		fatal(exitEngine, "Error setting preset: %v", err)
+	}
+	analyser.CachePositions(nil)
+
+	fmt.Printf("Analysing %d reference games with preset '%s'...\n", len(games), preset.Name)
+	var comparison references.Comparison
+	for i, reference := range games {
+		game := reference.Game
+		analysis, err := analyser.AnalyseGame(game)
+		if err == nil && !analysis.IsValid() {
+			err = fmt.Errorf("invalid analysis: %s", strings.Join(analysis.Issues, "; "))
+		}
+		if err != nil {
+			log.Printf("Skipping game %d (%s vs %s): %v", i+1, game.White.Username, game.Black.Username, err)
+			continue
+		}
+		first := len(comparison.Sides)
+		comparison.Add(reference, analysis)
+		fmt.Printf("\n%d. %s vs %s\n", i+1, game.White.Username, game.Black.Username)
+		for _, side := range comparison.Sides[first:] {
+			printCalibrationSide(side)
+		}
+	}
+	if len(comparison.Sides) == 0 {
+		log.Fatalf("No reference game could be analysed.")
+	}
+
+	fmt.Println("\n--- Calibration ---")
+	acpl := comparison.ACPL()
+	fmt.Printf("ACPL: mean deviation %+.1f, mean absolute deviation %.1f (%d sides)\n", acpl.Mean, acpl.MeanAbs, acpl.Samples)
+	if accuracy := comparison.Accuracy(); accuracy.Samples > 0 {
+		fmt.Printf("Accuracy: mean deviation %+.1f, mean absolute deviation %.1f (%d sides)\n", accuracy.Mean, accuracy.MeanAbs, accuracy.Samples)
+	}
+	measured, published := comparison.Counts()
+	fmt.Printf("Inaccuracies/mistakes/blunders: %d/%d/%d, published %d/%d/%d\n",
+		measured[0], measured[1], measured[2], published[0], published[1], published[2])
+
+	current := preset.Thresholds
+	suggested := comparison.SuggestThresholds(current)
+	fmt.Printf("Thresholds: inaccuracy %d, mistake %d, blunder %d centipawns\n", current.Inaccuracy, current.Mistake, current.Blunder)
+	if suggested == current {
+		fmt.Println("The thresholds match the published counts as closely as any; no adjustment suggested.")
+		return
+	}
+	fmt.Printf("Suggested:  inaccuracy %d, mistake %d, blunder %d centipawns\n", suggested.Inaccuracy, suggested.Mistake, suggested.Blunder)
+	if analysed := len(comparison.Sides) / 2; analysed < 20 {
+		fmt.Printf("Only %d reference games were analysed; check the suggestion against more games before adopting it.\n", analysed)
+	}
+}
+
+// printCalibrationSide prints one side's measured figures next to the published ones.
+func printCalibrationSide(side references.Side) {
+	name := "Black"
+	if side.White {
+		name = "White"
+	}
+	m, p := side.Measured, side.Published
+	line := fmt.Sprintf("   %s: ACPL %.0f (published %.0f)", name, m.ACPL, p.ACPL)
+	if p.Accuracy > 0 {
+		line += fmt.Sprintf(", accuracy %.1f (published %.0f)", m.Accuracy, p.Accuracy)
+	} else {
+		line += fmt.Sprintf(", accuracy %.1f", m.Accuracy)
+	}
+	line += fmt.Sprintf(", %d/%d/%d (published %d/%d/%d)", m.Inaccuracies, m.Mistakes, m.Blunders,
+		p.Inaccuracies, p.Mistakes, p.Blunders)
+	fmt.Println(line)
+}
+
+// fetchReferences exports games analysed on Lichess, with the figures Lichess published for them,
+// and appends them to the reference file at path.
+func fetchReferences(ids []string, path string) error {
+	client := lichess.NewClient()
+	client.Clocks = false
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	for _, arg := range ids {
+		id, err := lichess.ParseGameID(arg)
+		if err != nil {
+			return err
+		}
+		game, err := client.ExportGame(id)
+		if err != nil {
+			return err
+		}
+		white, black := game.Players.White.Analysis, game.Players.Black.Analysis
+		if white == nil || black == nil {
+			log.Printf("Skipping %s: the game has no computer analysis on Lichess.", id)
+			continue
+		}
+		pgn := references.Format(game.PGN, publishedFigures(*white), publishedFigures(*black))
+		if _, err := fmt.Fprintln(file, pgn); err != nil {
+			return err
+		}
+		fmt.Printf("Added %s: %s vs %s\n", id, game.Players.White.User.Name, game.Players.Black.User.Name)
+	}
+	return file.Close()
+}
+
+// publishedFigures converts Lichess's analysis of one player into reference figures.
+func publishedFigures(a lichess.PlayerAnalysis) references.Published {
+	return references.Published{
+		ACPL:         float64(a.ACPL),
+		Accuracy:     float64(a.Accuracy),
+		Inaccuracies: a.Inaccuracy,
+		Mistakes:     a.Mistake,
+		Blunders:     a.Blunder,
+	}
+}
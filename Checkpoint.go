@@ -0,0 +1,89 @@
+package main
+
+import (
+	"chessAnalyserFree/api"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// checkpointFile records the progress of a batch run so an interrupted run can resume.
+const checkpointFile = "batch-checkpoint.json"
+
+// batchRun identifies a batch run by the parameters it was started with rather than by the games it
+// found, so that running the same command again resumes it even when new games were played since or
+// the filters and sorting changed.
+type batchRun struct {
+	Games        string `json:"games"`            // Players, PGN files or game URL the games come from.
+	Source       string `json:"source,omitempty"` // Game source of the players, e.g. chesscom.
+	From         string `json:"from,omitempty"`   // Date range of the players' games.
+	To           string `json:"to,omitempty"`
+	Preset       string `json:"preset"`
+	RetrySkipped bool   `json:"retry_skipped,omitempty"` // Whether the run retries the skipped games.
+}
+
+// batchCheckpoint is the progress of a batch run.
+type batchCheckpoint struct {
+	Run       batchRun        `json:"run"`
+	Done      map[string]bool `json:"done"` // URLs of the games finished, including skipped ones but not failed ones.
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+// loadCheckpoint returns the checkpoint of the batch run. A missing file, or a checkpoint of another
+// run, yields an empty checkpoint for this run.
+func loadCheckpoint(run batchRun) (*batchCheckpoint, error) {
+	checkpoint := &batchCheckpoint{Run: run, Done: make(map[string]bool)}
+	data, err := os.ReadFile(checkpointFile)
+	if os.IsNotExist(err) {
+		return checkpoint, nil
+	}
+	if err != nil {
+		return checkpoint, err
+	}
+	var saved batchCheckpoint
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return checkpoint, fmt.Errorf("failed to parse %s: %w", checkpointFile, err)
+	}
+	if saved.Run == run && saved.Done != nil {
+		checkpoint.Done = saved.Done
+	}
+	return checkpoint, nil
+}
+
+// pending returns the games of the run that are not done yet, and the positions of those in games.
+func (c *batchCheckpoint) pending(games []api.Game) ([]api.Game, []int) {
+	var remaining []api.Game
+	var numbers []int
+	for i, game := range games {
+		if !c.Done[game.URL] {
+			remaining = append(remaining, game)
+			numbers = append(numbers, i)
+		}
+	}
+	return remaining, numbers
+}
+
+// markDone records a finished game and saves the checkpoint. The file is replaced atomically, so a
+// crash while saving leaves the previous checkpoint intact.
+func (c *batchCheckpoint) markDone(url string) error {
+	c.Done[url] = true
+	c.UpdatedAt = time.Now()
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	temp := checkpointFile + ".tmp"
+	if err := os.WriteFile(temp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(temp, checkpointFile)
+}
+
+// remove deletes the checkpoint once the batch run has finished.
+func (c *batchCheckpoint) remove() error {
+	if err := os.Remove(checkpointFile); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// clipboardTool is a program that copies its standard input to the system clipboard.
+type clipboardTool struct {
+	name string
+	args []string
+}
+
+// clipboardTools returns the clipboard programs to try on this system, in order.
+func clipboardTools() []clipboardTool {
+	switch runtime.GOOS {
+	case "darwin":
+		return []clipboardTool{{name: "pbcopy"}}
+	case "windows":
+		return []clipboardTool{{name: "clip"}}
+	}
+	var tools []clipboardTool
+	if os.Getenv("WAYLAND_DISPLAY") != "" {
+		tools = append(tools, clipboardTool{name: "wl-copy"})
+	}
+	if os.Getenv("DISPLAY") != "" {
+		tools = append(tools, clipboardTool{name: "xclip", args: []string{"-selection", "clipboard"}},
+			clipboardTool{name: "xsel", args: []string{"--clipboard", "--input"}})
+	}
+	// Under WSL, the Windows clipboard.
+	return append(tools, clipboardTool{name: "clip.exe"})
+}
+
+// copyToClipboard places text on the system clipboard and returns how: with the first clipboard
+// program found, or else, on a terminal, with the OSC 52 escape sequence, which most terminal
+// emulators honour, also over SSH.
+func copyToClipboard(text string) (string, error) {
+	for _, tool := range clipboardTools() {
+		path, err := exec.LookPath(tool.name)
+		if err != nil {
+			continue
+		}
+		cmd := exec.Command(path, tool.args...)
+		cmd.Stdin = strings.NewReader(text)
+		if err := cmd.Run(); err != nil {
+			return "", fmt.Errorf("%s failed: %w", tool.name, err)
+		}
+		return tool.name, nil
+	}
+	if term.IsTerminal(int(os.Stdout.Fd())) {
+		fmt.Fprintf(os.Stdout, "\033]52;c;%s\a", base64.StdEncoding.EncodeToString([]byte(text)))
+		return "the terminal", nil
+	}
+	return "", errors.New("no clipboard program found; install wl-copy, xclip or xsel")
+}
+
+// copyAndReport copies text to the clipboard and tells the user what was copied.
+func copyAndReport(what, text string) {
+	via, err := copyToClipboard(text)
+	if err != nil {
+		log.Printf("Error copying the %s: %v", what, err)
+		return
+	}
+	fmt.Printf("Copied the %s to the clipboard (via %s).\n", what, via)
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	gameengine "chessAnalyserFree/gameEngine"
+	"os"
+
+	"golang.org/x/term"
+)
+
+// ANSI escape sequences of the colours of terminal output.
+const (
+	ansiReset = "\033[0m"
+	ansiDim   = "\033[2m"
+	ansiRed   = "\033[31m"
+	ansiGreen = "\033[32m"
+	ansiCyan  = "\033[36m"
+)
+
+// evenEval is the evaluation, in pawns, below which neither side is shown as better.
+const evenEval = 0.5
+
+// colorOutput is set when terminal output is coloured: stdout is a terminal, NO_COLOR is not set
+// and --no-color was not given.
+var colorOutput bool
+
+// takeNoColor removes --no-color from the arguments, wherever it is, and decides whether output is
+// coloured. Like --errors-json, it applies to every command.
+func takeNoColor(arguments []string) []string {
+	noColor := os.Getenv("NO_COLOR") != ""
+	kept := arguments[:0]
+	for _, arg := range arguments {
+		if arg == "--no-color" || arg == "-no-color" {
+			noColor = true
+			continue
+		}
+		kept = append(kept, arg)
+	}
+	colorOutput = !noColor && term.IsTerminal(int(os.Stdout.Fd()))
+	return kept
+}
+
+// colorize wraps text in an ANSI colour when output is coloured.
+func colorize(color, text string) string {
+	if !colorOutput || color == "" {
+		return text
+	}
+	return color + text + ansiReset
+}
+
+// colorMove colours the text of a move by its classification: blunders in red, sacrifices in cyan
+// and book moves dimmed.
+func colorMove(classification, text string) string {
+	switch classification {
+	case gameengine.ClassBlunder:
+		return colorize(ansiRed, text)
+	case gameengine.ClassSacrifice:
+		return colorize(ansiCyan, text)
+	case gameengine.ClassBook:
+		return colorize(ansiDim, text)
+	default:
+		return text
+	}
+}
+
+// colorEval colours the text of a move's evaluation green when the side to move is better and red
+// when it is worse; book moves, which have no evaluation, are dimmed.
+func colorEval(move gameengine.MoveAnalysis, text string) string {
+	switch {
+	case move.Classification == gameengine.ClassBook:
+		return colorize(ansiDim, text)
+	case move.Evaluation >= evenEval:
+		return colorize(ansiGreen, text)
+	case move.Evaluation <= -evenEval:
+		return colorize(ansiRed, text)
+	default:
+		return text
+	}
+}
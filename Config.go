@@ -0,0 +1,287 @@
+package main
+
+import (
+	gameengine "chessAnalyserFree/gameEngine"
+	"chessAnalyserFree/report"
+	"cmp"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// configFileName is the configuration file looked up in the home directory.
+const configFileName = ".chessanalyser.yaml"
+
+// configEnv names the environment variable that points to another configuration file.
+const configEnv = "CHESSANALYSER_CONFIG"
+
+// monthArgRegex matches a YYYY-MM positional argument.
+var monthArgRegex = regexp.MustCompile(`^\d{4}-\d{2}$`)
+
+// userConfig holds the defaults read from the configuration file. Flags and arguments given on the
+// command line take precedence over it.
+type userConfig struct {
+	path string // File the configuration was read from; empty when there is none.
+
+	Engine  string       `yaml:"engine"`  // Path to the Stockfish binary.
+	User    string       `yaml:"user"`    // Username whose games are fetched when none is given.
+	Source  string       `yaml:"source"`  // Game source, as with --source.
+	Preset  string       `yaml:"preset"`  // Analysis preset, as with --preset.
+	Depth   int          `yaml:"depth"`   // Search depth per position, as with --depth.
+	Threads int          `yaml:"threads"` // Engine threads, as with --threads; older spelling of performance.threads.
+	DB      string       `yaml:"db"`      // Game database, as with --db.
+	Output  outputConfig `yaml:"output"`
+
+	// EngineTimeout is how long an engine may stay silent, as with --engine-timeout, e.g. "1m".
+	EngineTimeout string `yaml:"engine_timeout"`
+	// Retries is the retry budget of failed Chess.com requests, as with --retries.
+	Retries string `yaml:"retries"`
+
+	Performance performanceConfig `yaml:"performance"`
+	// Presets are analysis presets of your own, keyed by name, or changes to the built-in ones.
+	Presets map[string]presetConfig `yaml:"presets"`
+}
+
+// presetConfig is an analysis preset of the configuration file. It starts from the built-in preset
+// of its name, or from its base, and changes the settings it gives.
+type presetConfig struct {
+	Base       string `yaml:"base"`       // Built-in preset to start from; standard by default.
+	MoveTime   string `yaml:"movetime"`   // Search time per position, e.g. "300ms".
+	Depth      int    `yaml:"depth"`      // Search depth per position.
+	MultiPV    int    `yaml:"multipv"`    // Principal variations the engine reports.
+	BookPlies  *int   `yaml:"book_plies"` // Opening plies that are not analysed.
+	Inaccuracy int    `yaml:"inaccuracy"` // Centipawn loss from which a move is an inaccuracy.
+	Mistake    int    `yaml:"mistake"`    // Centipawn loss from which a move is a mistake.
+	Blunder    int    `yaml:"blunder"`    // Centipawn loss from which a move is a blunder.
+}
+
+// performanceConfig holds the throughput settings of the configuration file. Each is a positive
+// number or auto.
+type performanceConfig struct {
+	Workers string `yaml:"workers"` // Engine processes of batch runs, as with --workers.
+	Threads string `yaml:"threads"` // Search threads per engine, as with --threads.
+	Hash    string `yaml:"hash"`    // Hash table size per engine in MB, as with --hash.
+	Fetch   string `yaml:"fetch"`   // Archives downloaded at a time, as with --fetch-concurrency.
+}
+
+// outputConfig holds the report output preferences of the configuration file.
+type outputConfig struct {
+	Dir       string   `yaml:"dir"`       // Directory reports are written to, as with --report-dir.
+	Formats   []string `yaml:"formats"`   // Report formats, as with --report-formats.
+	Templates string   `yaml:"templates"` // Report templates directory, as with --templates.
+	// Collection is the PGN file analysed games are appended to, as with --collection.
+	Collection string `yaml:"collection"`
+	// EvalView is the point of view of the evaluations in move tables, as with --eval-view.
+	EvalView string `yaml:"eval_view"`
+}
+
+// loadUserConfig reads the configuration file named by $CHESSANALYSER_CONFIG or, by default,
+// ~/.chessanalyser.yaml. A missing default file yields an empty configuration; unknown keys are
+// rejected so that typos do not go unnoticed. Paths may start with "~/".
+func loadUserConfig() (*userConfig, error) {
+	path, explicit := os.LookupEnv(configEnv)
+	if !explicit {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return &userConfig{}, nil
+		}
+		path = filepath.Join(home, configFileName)
+	}
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) && !explicit {
+		return &userConfig{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	config := &userConfig{path: path}
+	decoder := yaml.NewDecoder(file)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(config); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if config.Depth < 0 || config.Threads < 0 {
+		return nil, fmt.Errorf("%s: depth and threads cannot be negative", path)
+	}
+	if config.Threads > 0 && config.Performance.Threads != "" {
+		return nil, fmt.Errorf("%s: threads is set both at the top and in the performance section", path)
+	}
+	for _, p := range []*string{&config.Engine, &config.DB, &config.Output.Dir, &config.Output.Templates, &config.Output.Collection} {
+		*p = expandHome(*p)
+	}
+	if err := config.addPresets(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return config, nil
+}
+
+// addPresets adds the configured presets to the built-in ones, so that --preset and the preset
+// settings of the configuration file and daemon jobs can name them.
+func (c *userConfig) addPresets() error {
+	// The built-in presets the configured ones start from, before any of them is replaced.
+	builtIn := make(map[string]gameengine.Preset, len(gameengine.Presets))
+	for name, preset := range gameengine.Presets {
+		builtIn[name] = preset
+	}
+	for name, configured := range c.Presets {
+		key := strings.ToLower(strings.TrimSpace(name))
+		base, ok := builtIn[key]
+		if configured.Base != "" || !ok {
+			baseName := cmp.Or(strings.ToLower(configured.Base), gameengine.DefaultPresetName)
+			if base, ok = builtIn[baseName]; !ok {
+				return fmt.Errorf("preset %s: unknown base preset %q", name, configured.Base)
+			}
+		}
+		preset := base
+		preset.Key = key
+		// A depth or time limit of its own replaces both limits of the base.
+		if configured.MoveTime != "" || configured.Depth != 0 {
+			preset.MoveTime, preset.Depth = 0, configured.Depth
+			if configured.MoveTime != "" {
+				moveTime, err := time.ParseDuration(configured.MoveTime)
+				if err != nil {
+					return fmt.Errorf("preset %s: movetime: %w", name, err)
+				}
+				preset.MoveTime = moveTime
+			}
+		}
+		preset.MultiPV = cmp.Or(configured.MultiPV, preset.MultiPV)
+		if configured.BookPlies != nil {
+			preset.SkipBookPlies = *configured.BookPlies
+		}
+		preset.Thresholds.Inaccuracy = cmp.Or(configured.Inaccuracy, preset.Thresholds.Inaccuracy)
+		preset.Thresholds.Mistake = cmp.Or(configured.Mistake, preset.Thresholds.Mistake)
+		preset.Thresholds.Blunder = cmp.Or(configured.Blunder, preset.Thresholds.Blunder)
+		if err := gameengine.AddPreset(preset); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// expandHome replaces a leading "~/" of a configured path with the home directory, as a shell would.
+func expandHome(path string) string {
+	rest, ok := strings.CutPrefix(path, "~/")
+	if !ok {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, rest)
+}
+
+// applyFlags sets the flags of a command that were not given on the command line to the configured
+// values, validating them as if they had been given. Settings the command has no flag for are skipped.
+func (c *userConfig) applyFlags(flags *flag.FlagSet) error {
+	given := make(map[string]bool)
+	flags.Visit(func(f *flag.Flag) { given[f.Name] = true })
+	values := map[string]string{
+		"source":            c.Source,
+		"preset":            c.Preset,
+		"db":                c.DB,
+		"report-dir":        c.Output.Dir,
+		"report-formats":    strings.Join(c.Output.Formats, ","),
+		"templates":         c.Output.Templates,
+		"collection":        c.Output.Collection,
+		"eval-view":         c.Output.EvalView,
+		"workers":           c.Performance.Workers,
+		"threads":           c.Performance.Threads,
+		"hash":              c.Performance.Hash,
+		"fetch-concurrency": c.Performance.Fetch,
+		"engine-timeout":    c.EngineTimeout,
+		"retries":           c.Retries,
+	}
+	if c.Depth > 0 {
+		values["depth"] = strconv.Itoa(c.Depth)
+	}
+	if c.Threads > 0 {
+		values["threads"] = strconv.Itoa(c.Threads)
+	}
+	for name, value := range values {
+		if value == "" || given[name] || flags.Lookup(name) == nil {
+			continue
+		}
+		if err := flags.Set(name, value); err != nil {
+			return fmt.Errorf("%s: %s: %w", c.path, name, err)
+		}
+	}
+	return nil
+}
+
+// gameArgs are the games and engine of the analysis commands.
+type gameArgs struct {
+	User   string
+	From   string // First month, YYYY-MM.
+	To     string // Last month, YYYY-MM.
+	Engine string
+}
+
+// gameArgs resolves the games and engine of the analysis commands. They come from the --user,
+// --from, --to and --engine flags, given as flagged, or from the positional arguments of the older
+// form [<username>] <start_YYYY-MM> <end_YYYY-MM> [<path_to_stockfish>]. The configured user and
+// engine fill in what is left out, and the last month defaults to the current one. When games are
+// given with --pgn or --game (offline), no player or months are needed, and without needEngine no
+// engine. ok is false when the arguments do not fit.
+func (c *userConfig) gameArgs(args []string, flagged gameArgs, offline, needEngine bool, now time.Time) (gameArgs, bool) {
+	a := flagged
+	if needEngine && len(args) > 0 && !monthArgRegex.MatchString(args[len(args)-1]) {
+		a.Engine, args = args[len(args)-1], args[:len(args)-1]
+	}
+	switch {
+	case len(args) == 3 && !offline:
+		a.User, a.From, a.To = args[0], args[1], args[2]
+	case len(args) == 2 && !offline:
+		a.From, a.To = args[0], args[1]
+	case len(args) != 0:
+		return a, false
+	}
+	a.User = cmp.Or(a.User, c.User)
+	a.Engine = cmp.Or(a.Engine, c.Engine)
+	if needEngine && a.Engine == "" {
+		return a, false
+	}
+	if offline {
+		return a, true
+	}
+	a.To = cmp.Or(a.To, now.Format("2006-01"))
+	return a, a.User != "" && monthArgRegex.MatchString(a.From) && monthArgRegex.MatchString(a.To)
+}
+
+// engineArg returns the engine path of a subcommand taking it as its only positional argument,
+// falling back to the configured engine.
+func (c *userConfig) engineArg(flags *flag.FlagSet) (string, bool) {
+	switch {
+	case flags.NArg() == 1:
+		return flags.Arg(0), true
+	case flags.NArg() == 0 && c.Engine != "":
+		return c.Engine, true
+	default:
+		return "", false
+	}
+}
+
+// parseReportFormats parses a comma-separated list of report formats.
+func parseReportFormats(list string) ([]report.Format, error) {
+	var formats []report.Format
+	for _, name := range strings.Split(list, ",") {
+		format, err := report.ParseFormat(strings.TrimSpace(name))
+		if err != nil {
+			return nil, err
+		}
+		formats = append(formats, format)
+	}
+	return formats, nil
+}
@@ -0,0 +1,338 @@
+package main
+
+import (
+	"chessAnalyserFree/api"
+	gamedb "chessAnalyserFree/gameDB"
+	gameengine "chessAnalyserFree/gameEngine"
+	"chessAnalyserFree/lichess"
+	"chessAnalyserFree/query"
+	"chessAnalyserFree/report"
+	"chessAnalyserFree/schedule"
+	"chessAnalyserFree/stats"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+// daemonConfig is the configuration file of the daemon subcommand.
+type daemonConfig struct {
+	DB        string      `json:"db"`        // Game database shared by all jobs, as with --db; optional.
+	Templates string      `json:"templates"` // Report templates directory, as with --templates; optional.
+	Jobs      []reportJob `json:"jobs"`
+}
+
+// reportJob is a report generated on a schedule from a player's recent games.
+type reportJob struct {
+	Name     string `json:"name"`
+	Schedule string `json:"schedule"` // Cron expression, e.g. "0 20 * * SUN".
+	User     string `json:"user"`
+	Source   string `json:"source"` // "chesscom" (default) or "lichess".
+	Days     int    `json:"days"`   // Games of the last Days days are included; default 7.
+	Preset   string `json:"preset"` // Analysis preset; empty to report statistics only.
+	Query    string `json:"query"`  // Query listing matching moves of the analysed games; optional.
+	Out      string `json:"out"`    // Directory the reports are stored in; default "reports".
+	// Watch jobs re-fetch the player's month in progress and report only the games that are new
+	// since the last run, instead of the games of the last Days days. They need the game database.
+	Watch bool `json:"watch"`
+
+	cron *schedule.Cron
+}
+
+// loadDaemonConfig reads and checks the daemon configuration.
+func loadDaemonConfig(path string) (*daemonConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var config daemonConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if len(config.Jobs) == 0 {
+		return nil, fmt.Errorf("%s defines no jobs", path)
+	}
+	for i := range config.Jobs {
+		job := &config.Jobs[i]
+		if job.Name == "" || job.User == "" {
+			return nil, fmt.Errorf("job %d needs a name and a user", i+1)
+		}
+		if job.cron, err = schedule.Parse(job.Schedule); err != nil {
+			return nil, fmt.Errorf("job %s: %w", job.Name, err)
+		}
+		if job.Source == "" {
+			job.Source = "chesscom"
+		}
+		if job.Source != "chesscom" && job.Source != "lichess" {
+			return nil, fmt.Errorf("job %s: unknown game source %q", job.Name, job.Source)
+		}
+		if job.Days <= 0 {
+			job.Days = 7
+		}
+		if job.Out == "" {
+			job.Out = "reports"
+		}
+		if job.Preset != "" {
+			if _, err := gameengine.LookupPreset(job.Preset); err != nil {
+				return nil, fmt.Errorf("job %s: %w", job.Name, err)
+			}
+		}
+		if _, err := query.Parse(job.Query); err != nil {
+			return nil, fmt.Errorf("job %s: invalid query: %w", job.Name, err)
+		}
+		if job.Watch && config.DB == "" {
+			return nil, fmt.Errorf("job %s: watching for new games needs a game database (db)", job.Name)
+		}
+	}
+	return &config, nil
+}
+
+// reportDaemon runs report jobs with a shared engine, renderer and game database.
+type reportDaemon struct {
+	analyser *gameengine.StockfishAnalyser // nil when no job analyses games
+	renderer *report.Renderer
+	db       gamedb.Store
+}
+
+// runDaemon implements the daemon subcommand, which generates the configured reports on their
+// schedules until interrupted. Reports are stored in each job's output directory.
+func runDaemon(arguments []string, defaults *userConfig) {
+	flags := flag.NewFlagSet("daemon", flag.ExitOnError)
+	configPath := flags.String("config", "daemon.json", "configuration file with the report jobs")
+	once := flags.Bool("once", false, "run every job once now and exit")
+	flags.Parse(arguments)
+	enginePath, ok := defaults.engineArg(flags)
+	if !ok {
+		fmt.Println("Usage: go run . daemon [--config daemon.json] [--once] [<path_to_stockfish>]")
+		flags.PrintDefaults()
+		return
+	}
+
+	config, err := loadDaemonConfig(*configPath)
+	if err != nil {
+		log.Fatalf("Error loading daemon configuration: %v", err)
+	}
+	d := &reportDaemon{}
+	if d.renderer, err = report.NewRenderer(config.Templates); err != nil {
+		log.Fatalf("Error loading report templates: %v", err)
+	}
+	if config.DB != "" {
+		if d.db, err = gamedb.Open(config.DB); err != nil {
+			log.Fatalf("Error opening game database: %v", err)
+		}
+		defer d.db.Close()
+	}
+	for _, job := range config.Jobs {
+		if job.Preset != "" && d.analyser == nil {
+			if d.analyser, err = gameengine.NewStockfishAnalyser(enginePath); err != nil {
+				fatal(exitEngine, "Error starting Stockfish analyser: %v", err)
+			}
+			defer d.analyser.Close()
+			if d.db != nil {
+				d.analyser.SetAnalysisStore(d.db)
+			}
+			d.analyser.CachePositions(d.db)
+		}
+	}
+
+	if *once {
+		for _, job := range config.Jobs {
+			d.runJob(job)
+		}
+		return
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	next := make([]time.Time, len(config.Jobs))
+	for i, job := range config.Jobs {
+		next[i] = job.cron.Next(time.Now())
+		if next[i].IsZero() {
+			log.Printf("Job %s never runs with schedule %q.", job.Name, job.Schedule)
+			continue
+		}
+		log.Printf("Job %s scheduled for %s.", job.Name, next[i].Format("Mon 2006-01-02 15:04"))
+	}
+
+	for {
+		due := -1
+		for i := range next {
+			if !next[i].IsZero() && (due < 0 || next[i].Before(next[due])) {
+				due = i
+			}
+		}
+		if due < 0 {
+			log.Println("No job is scheduled any more.")
+			return
+		}
+
+		timer := time.NewTimer(time.Until(next[due]))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			log.Println("Daemon stopped.")
+			return
+		case <-timer.C:
+		}
+		job := config.Jobs[due]
+		d.runJob(job)
+		next[due] = job.cron.Next(time.Now())
+		if !next[due].IsZero() {
+			log.Printf("Job %s scheduled for %s.", job.Name, next[due].Format("Mon 2006-01-02 15:04"))
+		}
+	}
+}
+
+// runJob generates one report, logging failures so that the daemon keeps running.
+func (d *reportDaemon) runJob(job reportJob) {
+	log.Printf("Running job %s.", job.Name)
+	generate := d.generate
+	if job.Watch {
+		generate = d.watch
+	}
+	dir, err := generate(job, time.Now())
+	switch {
+	case err != nil:
+		log.Printf("Job %s failed: %v", job.Name, err)
+	case dir == "":
+		log.Printf("Job %s finished; no new games.", job.Name)
+	default:
+		log.Printf("Job %s finished; reports stored in %s.", job.Name, dir)
+	}
+}
+
+// jobSource returns the game source of a job.
+func jobSource(job reportJob) api.GameSource {
+	if job.Source == "lichess" {
+		return lichess.NewClient()
+	}
+	return api.NewClient()
+}
+
+// generate writes the report of a job into a new directory under the job's output directory and
+// returns the directory: a summary.md with the results of the period and the query's matching
+// moves, plus the reports of the analysed games.
+func (d *reportDaemon) generate(job reportJob, now time.Time) (string, error) {
+	source := jobSource(job)
+	if d.db != nil {
+		source = &gamedb.CachedSource{Source: source, DB: d.db}
+	}
+	from := now.AddDate(0, 0, -job.Days)
+	games, err := source.FetchGames(job.User, from, now)
+	if err != nil {
+		log.Printf("Job %s: some games could not be fetched: %v", job.Name, err)
+	}
+	api.TagBots(games)
+
+	dir, summary, err := createJobDir(job, now)
+	if err != nil {
+		return "", err
+	}
+	defer summary.Close()
+
+	fmt.Fprintf(summary, "# %s\n\n%d games of %s from %s to %s.\n\n```\n", job.Name, len(games), job.User,
+		from.Format("2006-01-02"), now.Format("2006-01-02"))
+	stats.Summarize(games, job.User, stats.Policy{}).Write(summary)
+	if err := d.analyseJobGames(job, games, dir, summary); err != nil {
+		return "", err
+	}
+	fmt.Fprintln(summary, "```")
+	return dir, nil
+}
+
+// watch re-fetches the player's month in progress and, if there are games that were not stored
+// before, writes a report of them into a new directory under the job's output directory and returns
+// the directory: a summary.md listing the new games, plus the reports of the analysed games. It
+// returns "" when there are no new games.
+func (d *reportDaemon) watch(job reportJob, now time.Time) (string, error) {
+	cached := &gamedb.CachedSource{Source: jobSource(job), DB: d.db}
+	games, err := cached.FetchNewGames(job.User, now)
+	if err != nil {
+		log.Printf("Job %s: some games could not be fetched: %v", job.Name, err)
+	}
+	if len(games) == 0 {
+		return "", nil
+	}
+	api.TagBots(games)
+
+	dir, summary, err := createJobDir(job, now)
+	if err != nil {
+		return "", err
+	}
+	defer summary.Close()
+
+	fmt.Fprintf(summary, "# %s\n\n%d new games of %s.\n\n", job.Name, len(games), job.User)
+	for i, game := range games {
+		fmt.Fprintf(summary, "%d. %s vs %s, %s, %s: %s\n", i+1, game.White.Username, game.Black.Username, game.TimeClass,
+			time.Unix(game.EndTime, 0).Format("2006-01-02 15:04"), game.URL)
+	}
+	if job.Preset == "" {
+		return dir, nil
+	}
+	fmt.Fprintln(summary, "\n```")
+	if err := d.analyseJobGames(job, games, dir, summary); err != nil {
+		return "", err
+	}
+	fmt.Fprintln(summary, "```")
+	return dir, nil
+}
+
+// createJobDir creates the directory of a job run, <out>/<name>-<YYYY-MM-DD-HHMM>, and its summary.md.
+func createJobDir(job reportJob, now time.Time) (string, *os.File, error) {
+	dir := filepath.Join(job.Out, fmt.Sprintf("%s-%s", job.Name, now.Format("2006-01-02-1504")))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", nil, err
+	}
+	summary, err := os.Create(filepath.Join(dir, "summary.md"))
+	if err != nil {
+		return "", nil, err
+	}
+	return dir, summary, nil
+}
+
+// analyseJobGames analyses the games with the job's preset, if it has one, writing the report of
+// every analysed game into dir and the count and the query's matching moves to the summary.
+func (d *reportDaemon) analyseJobGames(job reportJob, games []api.Game, dir string, summary io.Writer) error {
+	if job.Preset == "" {
+		return nil
+	}
+	preset, err := gameengine.LookupPreset(job.Preset)
+	if err != nil {
+		return err
+	}
+	if err := d.analyser.SetPreset(preset); err != nil {
+		return err
+	}
+	dataset := newMoveDataset(job.User)
+	analysed := 0
+	for i, game := range games {
+		analysis, err := d.analyser.AnalyseGame(game)
+		if err != nil || !analysis.IsValid() {
+			continue
+		}
+		analysed++
+		dataset.add(game, analysis)
+		gameReport := report.GameReport{Game: game, Moves: analysis.Moves, Preset: analysis.Preset}
+		for _, format := range []report.Format{report.FormatMarkdown, report.FormatHTML} {
+			path := filepath.Join(dir, fmt.Sprintf("game-%d.%s", i+1, format))
+			if err := d.renderer.WriteFile(path, format, gameReport); err != nil {
+				log.Printf("Job %s: error writing %s: %v", job.Name, path, err)
+			}
+		}
+	}
+	fmt.Fprintf(summary, "\n%d of %d games analysed (preset: %s).\n", analysed, len(games), preset.Name)
+	if analysed > 0 {
+		stats.BuildAccuracyTrend(dataset.analysed(games, nil), job.User).Write(summary)
+	}
+	if job.Query != "" {
+		return dataset.run(summary, job.Query)
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	gamedb "chessAnalyserFree/gameDB"
+	"cmp"
+	"flag"
+	"fmt"
+	"log"
+)
+
+// runDatabase implements the db subcommand, which maintains the game database. Its only action,
+// verify, audits the database and optionally repairs what it finds.
+func runDatabase(arguments []string, defaults *userConfig) {
+	if len(arguments) == 0 || arguments[0] != "verify" {
+		fmt.Println("Usage: go run . db verify [--db games.db] [--repair]")
+		return
+	}
+	flags := flag.NewFlagSet("db verify", flag.ExitOnError)
+	dbPath := flags.String("db", cmp.Or(defaults.DB, "games.db"), "game database: a bbolt file, an SQLite file (.sqlite) or a postgres:// URL")
+	repair := flags.Bool("repair", false, "fix the problems found instead of only reporting them")
+	flags.Parse(arguments[1:])
+	if flags.NArg() != 0 {
+		fmt.Println("Usage: go run . db verify [--db games.db] [--repair]")
+		flags.PrintDefaults()
+		return
+	}
+
+	db, err := gamedb.Open(*dbPath)
+	if err != nil {
+		log.Fatalf("Error opening game database: %v", err)
+	}
+	defer db.Close()
+	fmt.Printf("Verifying %s...\n", *dbPath)
+	report, err := db.Verify(*repair)
+	if err != nil {
+		log.Fatalf("Error verifying game database: %v", err)
+	}
+
+	fmt.Printf("Checked %d games, %d months and %d analyses.\n", report.Games, report.Months, report.Analyses)
+	repairable := 0
+	for _, problem := range report.Problems {
+		action := "cannot be repaired"
+		if problem.Repair != "" {
+			repairable++
+			action = "repair: " + problem.Repair
+			if report.Repaired {
+				action = "repaired: " + problem.Repair
+			}
+		}
+		fmt.Printf("  %s: %s (%s)\n", problem.Key, problem.Detail, action)
+	}
+	switch {
+	case len(report.Problems) == 0:
+		fmt.Println("No problems found.")
+	case report.Repaired:
+		fmt.Printf("%d problems found, %d repaired.\n", len(report.Problems), repairable)
+	case repairable > 0:
+		fmt.Printf("%d problems found; run with --repair to fix %d of them.\n", len(report.Problems), repairable)
+	default:
+		fmt.Printf("%d problems found.\n", len(report.Problems))
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"chessAnalyserFree/api"
+	gameengine "chessAnalyserFree/gameEngine"
+	"chessAnalyserFree/report"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// saveKeyDiagrams draws the key positions of an analysed game, its sacrifices, mistakes, blunders
+// and missed wins, into game-<n> in the diagram directory, and returns the directory and links to
+// the diagrams relative to the report directory. Without a diagram directory, nothing is drawn.
+// Errors are logged.
+func saveKeyDiagrams(output *reportOutput, game api.Game, analysis *gameengine.GameAnalysis, gameNum int) (string, []report.DiagramLink) {
+	if output.diagramDir == "" {
+		return "", nil
+	}
+	diagrams, err := report.KeyDiagrams(game, analysis, output.diagramFormat)
+	if err != nil {
+		log.Printf("Error drawing the key positions: %v", err)
+		return "", nil
+	}
+	if len(diagrams) == 0 {
+		return "", nil
+	}
+	dir := filepath.Join(output.diagramDir, fmt.Sprintf("game-%d", gameNum))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		log.Printf("Error creating %s: %v", dir, err)
+		return "", nil
+	}
+	reportDir := output.dir
+	if reportDir == "" {
+		reportDir = "."
+	}
+	var links []report.DiagramLink
+	for _, diagram := range diagrams {
+		path := filepath.Join(dir, diagram.Name)
+		if err := os.WriteFile(path, diagram.Image, 0o644); err != nil {
+			log.Printf("Error writing %s: %v", path, err)
+			continue
+		}
+		relative, err := filepath.Rel(reportDir, path)
+		if err != nil {
+			relative = path
+		}
+		links = append(links, report.DiagramLink{Path: filepath.ToSlash(relative), Caption: diagram.Caption})
+	}
+	return dir, links
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bufio"
+	"chessAnalyserFree/api"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// downloadIndexFile lists the downloaded months in the output directory of the download command.
+const downloadIndexFile = "index.json"
+
+// downloadEntry records one downloaded monthly archive.
+type downloadEntry struct {
+	Month        string    `json:"month"` // YYYY-MM
+	File         string    `json:"file"`
+	Games        int       `json:"games"`
+	Bytes        int64     `json:"bytes"`
+	Complete     bool      `json:"complete"` // False for a month still in progress, which is downloaded again.
+	DownloadedAt time.Time `json:"downloaded_at"`
+}
+
+// downloadIndex is the content of downloadIndexFile.
+type downloadIndex struct {
+	Username string          `json:"username"`
+	Months   []downloadEntry `json:"months"`
+}
+
+// runDownload implements the download subcommand, which saves a player's monthly archives as PGN
+// files (one per month) so they can be analysed later with --pgn. Months that were completely
+// downloaded before are skipped, so an interrupted download resumes where it stopped.
+func runDownload(arguments []string, defaults *userConfig) {
+	flags := flag.NewFlagSet("download", flag.ExitOnError)
+	outDir := flags.String("out", "", "output directory (default: <username>-pgn)")
+	from := flags.String("from", "", "first month to download, YYYY-MM (default: the first archive)")
+	to := flags.String("to", "", "last month to download, YYYY-MM (default: the current month)")
+	force := flags.Bool("force", false, "download every month again, even if it is complete")
+	flags.Parse(arguments)
+	username := defaults.User
+	if flags.NArg() == 1 {
+		username = flags.Arg(0)
+	}
+	if username == "" || flags.NArg() > 1 {
+		fmt.Println("Usage: go run . download [--out dir] [--from YYYY-MM] [--to YYYY-MM] [--force] [<username>]")
+		return
+	}
+	if *outDir == "" {
+		*outDir = strings.ToLower(username) + "-pgn"
+	}
+	if err := os.MkdirAll(*outDir, 0o755); err != nil {
+		log.Fatalf("Error creating %s: %v", *outDir, err)
+	}
+
+	client := api.NewClient()
+	// Monthly PGN archives of active players are large; allow for slow transfers.
+	client.HTTPClient.Timeout = 5 * time.Minute
+	archives, err := client.FetchArchives(username)
+	if err != nil {
+		fatal(exitNetwork, "Error listing archives of %s: %v", username, err)
+	}
+
+	indexPath := filepath.Join(*outDir, downloadIndexFile)
+	index, err := loadDownloadIndex(indexPath)
+	if err != nil {
+		log.Fatalf("Error reading %s: %v", indexPath, err)
+	}
+	index.Username = username
+	currentMonth := time.Now().UTC().Format("2006-01")
+
+	downloaded, upToDate, failed, games := 0, 0, 0, 0
+	for _, archive := range archives {
+		month := strings.Replace(archive, "/", "-", 1)
+		if (*from != "" && month < *from) || (*to != "" && month > *to) {
+			continue
+		}
+		entry, known := index.find(month)
+		path := filepath.Join(*outDir, month+".pgn")
+		if known && entry.Complete && !*force && fileHasSize(path, entry.Bytes) {
+			upToDate++
+			games += entry.Games
+			continue
+		}
+
+		if downloaded > 0 {
+			// Be polite to the API between archive requests.
+			time.Sleep(250 * time.Millisecond)
+		}
+		fmt.Printf("Downloading %s...", month)
+		entry, err := downloadMonth(client, username, month, path)
+		if err != nil {
+			fmt.Println()
+			log.Printf("Error downloading %s: %v", month, err)
+			failed++
+			continue
+		}
+		entry.Complete = month < currentMonth
+		fmt.Printf(" %d games\n", entry.Games)
+		index.put(entry)
+		// Save after every month, so an interrupted run resumes from here.
+		if err := index.save(indexPath); err != nil {
+			log.Fatalf("Error writing %s: %v", indexPath, err)
+		}
+		downloaded++
+		games += entry.Games
+	}
+
+	fmt.Printf("%d months downloaded, %d already up to date", downloaded, upToDate)
+	if failed > 0 {
+		fmt.Printf(", %d failed (run again to retry)", failed)
+	}
+	fmt.Printf(". %d games in %s.\n", games, *outDir)
+	fmt.Printf("Analyse them with: go run . --pgn %s <path_to_stockfish>\n", *outDir)
+	switch {
+	case failed > 0 && downloaded+upToDate == 0:
+		fail(exitNetwork, "None of the %d months could be downloaded.", failed)
+	case failed > 0:
+		fail(exitPartial, "%d months could not be downloaded.", failed)
+	}
+}
+
+// downloadMonth downloads one monthly archive to path, via a temporary file so an interrupted
+// download never leaves a truncated archive behind.
+func downloadMonth(client *api.Client, username, month, path string) (downloadEntry, error) {
+	partial := path + ".part"
+	f, err := os.Create(partial)
+	if err != nil {
+		return downloadEntry{}, err
+	}
+	written, err := client.DownloadMonthPGN(username, month[:4], month[5:], f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(partial)
+		return downloadEntry{}, err
+	}
+	games, err := countPGNGames(partial)
+	if err != nil {
+		return downloadEntry{}, err
+	}
+	if err := os.Rename(partial, path); err != nil {
+		return downloadEntry{}, err
+	}
+	return downloadEntry{Month: month, File: filepath.Base(path), Games: games, Bytes: written, DownloadedAt: time.Now()}, nil
+}
+
+// countPGNGames counts the games in a PGN file by their Event tags.
+func countPGNGames(path string) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	games := 0
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		if strings.HasPrefix(scanner.Text(), "[Event ") {
+			games++
+		}
+	}
+	return games, scanner.Err()
+}
+
+// fileHasSize reports whether the file exists with exactly the given size.
+func fileHasSize(path string, size int64) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Size() == size
+}
+
+// loadDownloadIndex reads the index of a download directory. A missing index yields an empty one.
+func loadDownloadIndex(path string) (*downloadIndex, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &downloadIndex{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var index downloadIndex
+	if err := json.Unmarshal(data, &index); err != nil {
+		return nil, err
+	}
+	return &index, nil
+}
+
+// find returns the entry for a month, if it was downloaded before.
+func (idx *downloadIndex) find(month string) (downloadEntry, bool) {
+	for _, entry := range idx.Months {
+		if entry.Month == month {
+			return entry, true
+		}
+	}
+	return downloadEntry{}, false
+}
+
+// put adds or replaces the entry for a month, keeping the months in order.
+func (idx *downloadIndex) put(entry downloadEntry) {
+	for i := range idx.Months {
+		if idx.Months[i].Month == entry.Month {
+			idx.Months[i] = entry
+			return
+		}
+	}
+	idx.Months = append(idx.Months, entry)
+	sort.Slice(idx.Months, func(a, b int) bool { return idx.Months[a].Month < idx.Months[b].Month })
+}
+
+// save writes the index.
+func (idx *downloadIndex) save(path string) error {
+	data, err := json.MarshalIndent(idx, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
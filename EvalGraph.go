@@ -0,0 +1,88 @@
+package main
+
+import (
+	"chessAnalyserFree/api"
+	gameengine "chessAnalyserFree/gameEngine"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// evalGraphClamp is the evaluation, in pawns, at which the graph is full: larger advantages and
+// forced mates are drawn at the top or bottom.
+const evalGraphClamp = 5.0
+
+// evalGraphWidth is the number of characters the graph may take; longer games are drawn with
+// several plies per character, averaged.
+const evalGraphWidth = 80
+
+// sparkBlocks are the characters of the graph, from Black winning to White winning.
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// printEvalGraph draws the evaluation curve of an analysed game as a sparkline, from White's point
+// of view, so that the shape of the game, one big swing or a slow slide, shows at a glance. The
+// move that changed the evaluation the most is named below it. Book moves are drawn as dots.
+func printEvalGraph(game api.Game, analysis *gameengine.GameAnalysis) {
+	positions, err := gameengine.ReplayPositions(game.PGN)
+	if err != nil || len(positions) != len(analysis.Moves) || len(positions) == 0 {
+		return
+	}
+	values := make([]float64, len(positions))
+	known := make([]bool, len(positions))
+	for i, position := range positions {
+		move := analysis.Moves[i]
+		if move.Classification == gameengine.ClassBook {
+			continue
+		}
+		// Evaluations are from the point of view of the side to move.
+		value := math.Max(-evalGraphClamp, math.Min(evalGraphClamp, move.Evaluation))
+		if !strings.Contains(position.FEN, " w ") {
+			value = -value
+		}
+		values[i], known[i] = value, true
+	}
+
+	perChar := (len(values) + evalGraphWidth - 1) / evalGraphWidth
+	var graph strings.Builder
+	for start := 0; start < len(values); start += perChar {
+		sum, count := 0.0, 0
+		for i := start; i < min(start+perChar, len(values)); i++ {
+			if known[i] {
+				sum, count = sum+values[i], count+1
+			}
+		}
+		if count == 0 {
+			graph.WriteString(colorize(ansiDim, "·"))
+			continue
+		}
+		value := sum / float64(count)
+		level := int(math.Round((value + evalGraphClamp) / (2 * evalGraphClamp) * float64(len(sparkBlocks)-1)))
+		block := string(sparkBlocks[level])
+		switch {
+		case value >= evenEval:
+			block = colorize(ansiGreen, block)
+		case value <= -evenEval:
+			block = colorize(ansiRed, block)
+		}
+		graph.WriteString(block)
+	}
+
+	scale := "one ply per character"
+	if perChar > 1 {
+		scale = fmt.Sprintf("%d plies per character", perChar)
+	}
+	fmt.Printf("\n--- Evaluation Graph (White's view, ±%.0f pawns, %s) ---\n", evalGraphClamp, scale)
+	fmt.Println(graph.String())
+
+	// The swing of a move is the change between the evaluations before and after it.
+	swing, swingAt := 0.0, -1
+	for i := 0; i+1 < len(values); i++ {
+		if known[i] && known[i+1] && math.Abs(values[i+1]-values[i]) > math.Abs(swing) {
+			swing, swingAt = values[i+1]-values[i], i
+		}
+	}
+	if swingAt >= 0 {
+		position := positions[swingAt]
+		fmt.Printf("Biggest swing: %s %s, %+.2f to %+.2f\n", moveLabel(position), position.SAN, values[swingAt], values[swingAt+1])
+	}
+}
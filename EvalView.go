@@ -0,0 +1,74 @@
+package main
+
+import (
+	gameengine "chessAnalyserFree/gameEngine"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Points of view the move tables show evaluations from.
+const (
+	evalViewWhite = "white" // White's: positive when White is better.
+	evalViewMover = "mover" // The side to move's, which is the side making the move.
+)
+
+// evalViews are the values of --eval-view.
+var evalViews = []string{evalViewWhite, evalViewMover}
+
+// evalView is the point of view of the evaluations in move tables, set with --eval-view.
+var evalView = evalViewWhite
+
+// parseEvalView checks a --eval-view value.
+func parseEvalView(view string) (string, error) {
+	view = strings.ToLower(strings.TrimSpace(view))
+	for _, known := range evalViews {
+		if view == known {
+			return view, nil
+		}
+	}
+	return "", fmt.Errorf("unknown evaluation view %q (want %s)", view, strings.Join(evalViews, " or "))
+}
+
+// evalViewHeading names the point of view in a move table's eval column, e.g. "Eval (White)".
+func evalViewHeading() string {
+	if evalView == evalViewMover {
+		return "Eval (mover)"
+	}
+	return "Eval (White)"
+}
+
+// viewedEval returns a move's evaluation, which the analysis keeps from the mover's point of view,
+// from the point of view of --eval-view, coloured for the side it favours. blackMove says whether
+// Black made the move.
+func viewedEval(move gameengine.MoveAnalysis, blackMove bool) string {
+	if evalView == evalViewWhite && blackMove && move.Classification != gameengine.ClassBook {
+		move.Evaluation = -move.Evaluation
+		move.EvaluationText = negateEvalText(move.EvaluationText)
+	}
+	return colorEval(move, fmt.Sprintf("%-12s", move.EvaluationText))
+}
+
+// negateEvalText turns an evaluation text to the other side's point of view: "+1.20" to "-1.20",
+// "#3" to "#-3".
+func negateEvalText(text string) string {
+	if mate, ok := strings.CutPrefix(text, "#"); ok {
+		if n, err := strconv.Atoi(mate); err == nil {
+			return fmt.Sprintf("#%d", -n)
+		}
+		return text
+	}
+	if pawns, err := strconv.ParseFloat(text, 64); err == nil && pawns != 0 {
+		return fmt.Sprintf("%+.2f", -pawns)
+	}
+	return text
+}
+
+// isBlackMove reports whether Black made the i-th move of an analysis: Black's moves share their
+// number with the preceding White move, and a game set up with Black to move starts with one.
+func isBlackMove(moves []gameengine.MoveAnalysis, i int) bool {
+	if i == 0 {
+		return len(moves) > 1 && moves[1].MoveNumber != moves[0].MoveNumber
+	}
+	return moves[i-1].MoveNumber == moves[i].MoveNumber
+}
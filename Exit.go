@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// Exit codes of the program, so scripts can tell failures apart without reading the log. Usage
+// errors exit with the flag package's code 2.
+const (
+	exitError   = 1 // Any other error.
+	exitUsage   = 2
+	exitNetwork = 3 // Games could not be downloaded.
+	exitEngine  = 4 // The engine could not be started or configured, or analysed no game.
+	exitNoGames = 5 // No games were found.
+	exitPartial = 6 // Some games could not be fetched or analysed; the others were.
+)
+
+// exitKinds names the exit codes in --errors-json output.
+var exitKinds = map[int]string{
+	exitError:   "error",
+	exitUsage:   "usage",
+	exitNetwork: "network",
+	exitEngine:  "engine",
+	exitNoGames: "no-games",
+	exitPartial: "partial",
+}
+
+// errorsJSON is set by --errors-json: the log is then written to stderr as JSON objects.
+var errorsJSON bool
+
+// exitStatus is the code the program exits with once the command returns: that of the first
+// failure reported with fail.
+var exitStatus int
+
+// jsonLogEntry is a log line, or a failure with its exit code, in --errors-json output.
+type jsonLogEntry struct {
+	Time     string `json:"time"`
+	Message  string `json:"message"`
+	Kind     string `json:"kind,omitempty"`
+	ExitCode int    `json:"exit_code,omitempty"`
+}
+
+// jsonLog writes every log line as a JSON object of its own.
+type jsonLog struct {
+	enc *json.Encoder
+}
+
+func (j jsonLog) Write(p []byte) (int, error) {
+	entry := jsonLogEntry{Time: time.Now().Format(time.RFC3339), Message: strings.TrimSuffix(string(p), "\n")}
+	if err := j.enc.Encode(entry); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// takeErrorsJSON removes --errors-json from the arguments, wherever it is, and switches the log to
+// JSON when it was given. It applies to every command, so it is not a flag of any of them.
+func takeErrorsJSON(arguments []string) []string {
+	kept := arguments[:0]
+	for _, arg := range arguments {
+		if arg == "--errors-json" || arg == "-errors-json" {
+			errorsJSON = true
+			continue
+		}
+		kept = append(kept, arg)
+	}
+	if errorsJSON {
+		log.SetFlags(0)
+		log.SetOutput(jsonLog{enc: json.NewEncoder(os.Stderr)})
+	}
+	return kept
+}
+
+// fail reports a failure of the given exit code and lets the command carry on; the program exits
+// with the code of its first failure once the command returns.
+func fail(code int, format string, args ...any) {
+	message := fmt.Sprintf(format, args...)
+	if exitStatus == 0 {
+		exitStatus = code
+	}
+	if !errorsJSON {
+		log.Print(message)
+		return
+	}
+	json.NewEncoder(os.Stderr).Encode(jsonLogEntry{
+		Time:     time.Now().Format(time.RFC3339),
+		Message:  message,
+		Kind:     exitKinds[code],
+		ExitCode: code,
+	})
+}
+
+// fatal reports a failure and exits with its code at once, like log.Fatalf.
+func fatal(code int, format string, args ...any) {
+	fail(code, format, args...)
+	os.Exit(code)
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"chessAnalyserFree/api"
+	gameengine "chessAnalyserFree/gameEngine"
+	"chessAnalyserFree/lichess"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// explorerMaxPlies limits how deep into the game the opening explorer is queried.
+const explorerMaxPlies = 30
+
+// exploreOpening prints, for every opening position of the game, how often the played move occurs
+// in the masters and Lichess databases, its score, the most popular alternatives and the engine eval.
+func exploreOpening(client *lichess.Client, game api.Game, analysis *gameengine.GameAnalysis) {
+	if game.Rules != "" && game.Rules != "chess" {
+		fmt.Printf("The opening explorer only covers standard chess, not %s games.\n", game.Rules)
+		return
+	}
+	positions, err := gameengine.ReplayPositions(game.PGN)
+	if err != nil {
+		log.Printf("Error reading game: %v", err)
+		return
+	}
+
+	fmt.Println("\n--- Opening Explorer ---")
+	for i, position := range positions {
+		if i >= explorerMaxPlies {
+			break
+		}
+		masters, err := client.Explore(lichess.ExplorerMasters, position.FEN)
+		if err != nil {
+			log.Printf("Error querying the masters database: %v", err)
+			return
+		}
+		online, err := client.Explore(lichess.ExplorerLichess, position.FEN)
+		if err != nil {
+			log.Printf("Error querying the lichess database: %v", err)
+			return
+		}
+		if masters.Total() == 0 && online.Total() == 0 {
+			fmt.Printf("Out of book after %d plies.\n", i)
+			break
+		}
+
+		whiteToMove := i%2 == 0
+		moveLabel := fmt.Sprintf("%d.", i/2+1)
+		if !whiteToMove {
+			moveLabel += ".."
+		}
+		san := position.Move
+		if move, ok := online.Find(position.Move); ok {
+			san = move.SAN
+		} else if move, ok := masters.Find(position.Move); ok {
+			san = move.SAN
+		}
+		eval := ""
+		if analysis != nil && i < len(analysis.Moves) {
+			eval = ", eval " + analysis.Moves[i].EvaluationText
+		}
+
+		fmt.Printf("%-6s %-7s masters: %s | lichess: %s%s\n", moveLabel, san,
+			explorerMoveSummary(masters, position.Move, whiteToMove),
+			explorerMoveSummary(online, position.Move, whiteToMove), eval)
+		fmt.Printf("       popular: %s\n", explorerAlternatives(online, whiteToMove))
+		if online.Opening != nil {
+			fmt.Printf("       opening: %s %s\n", online.Opening.ECO, online.Opening.Name)
+		}
+
+		// Be polite to the explorer between positions.
+		time.Sleep(250 * time.Millisecond)
+	}
+	fmt.Println("------------------------")
+}
+
+// explorerMoveSummary describes how often a move was played from a position and how it scored.
+func explorerMoveSummary(result *lichess.ExplorerResult, uci string, whiteToMove bool) string {
+	move, ok := result.Find(uci)
+	if !ok || result.Total() == 0 {
+		return "never played"
+	}
+	share := float64(move.Total()) * 100 / float64(result.Total())
+	return fmt.Sprintf("%.1f%% of %d games, scores %.0f%%", share, result.Total(), move.Score(whiteToMove))
+}
+
+// explorerAlternatives lists the three most played moves of a position with their share and score.
+func explorerAlternatives(result *lichess.ExplorerResult, whiteToMove bool) string {
+	if result.Total() == 0 {
+		return "-"
+	}
+	var parts []string
+	for i, move := range result.Moves {
+		if i == 3 {
+			break
+		}
+		share := float64(move.Total()) * 100 / float64(result.Total())
+		parts = append(parts, fmt.Sprintf("%s %.0f%% (scores %.0f%%)", move.SAN, share, move.Score(whiteToMove)))
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"chessAnalyserFree/api"
+	gamedb "chessAnalyserFree/gameDB"
+	gameengine "chessAnalyserFree/gameEngine"
+	"chessAnalyserFree/source"
+	"chessAnalyserFree/stats"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// runFetch implements the fetch subcommand, which fetches a player's games without starting the
+// engine: it lists them with the player's statistics and, with --out, saves them to a PGN file to
+// be analysed later with --pgn. The game filter flags narrow both down. With --db the games are
+// kept in the game database too.
+func runFetch(arguments []string, defaults *userConfig) {
+	flags := flag.NewFlagSet("fetch", flag.ExitOnError)
+	flags.Usage = usageFunc(flags)
+	selection := addGameFlags(flags)
+	out := flags.String("out", "", "save the games to this PGN file")
+	repertoire := flags.Int("repertoire", 0, "also print the player's opening tree of this many plies")
+	perf := &performanceFlags{}
+	perf.addFetchFlag(flags)
+	flags.Parse(arguments)
+	if err := defaults.applyFlags(flags); err != nil {
+		log.Fatalf("Error in configuration: %v", err)
+	}
+	args, ok := defaults.gameArgs(flags.Args(), gameArgs{User: *selection.user, From: *selection.from, To: *selection.to}, false, false, time.Now())
+	if !ok {
+		flags.Usage()
+		return
+	}
+	gameFilter, err := selection.gameFilter()
+	if err != nil {
+		log.Fatalf("Error in the game filter: %v", err)
+	}
+	statsPlayer := ""
+	if !strings.ContainsAny(args.User, ",:") {
+		statsPlayer = args.User
+	}
+	if gameFilter.NeedsPlayer() && statsPlayer == "" {
+		log.Fatalf("Error in the game filter: filtering by result, colour or opponent needs a single player.")
+	}
+	gameSort, err := selection.gameSort()
+	if err != nil {
+		log.Fatalf("Error in --sort: %v", err)
+	}
+	if gameSort.NeedsPlayer() && statsPlayer == "" {
+		log.Fatalf("Error in --sort: sorting by rating needs a single player.")
+	}
+
+	db := selection.openDB()
+	if db != nil {
+		defer db.Close()
+	}
+	games, _ := fetchOnlineGames(*selection.source, args.User, args.From, args.To, *selection.pgnArchives, false, db, *selection.offline, perf.resolve(false))
+	api.TagBots(games)
+	if *selection.checkOpponents {
+		fmt.Println("Checking opponent profiles...")
+		if err := api.NewClient().TagEngineAccounts(games); err != nil {
+			log.Printf("Some profiles could not be checked: %v", err)
+		}
+	}
+	fmt.Printf("\nFound a total of %d games for %s.\n\n", len(games), args.User)
+	if len(games) == 0 {
+		fail(exitNoGames, "No games found for %s.", args.User)
+		return
+	}
+	if !gameFilter.IsEmpty() {
+		if games, err = filterGames(games, gameFilter, statsPlayer); err != nil {
+			log.Fatalf("Error in the game filter: %v", err)
+		}
+		fmt.Printf("%d of them match the filter (%s).\n\n", len(games), gameFilter.Describe())
+		if len(games) == 0 {
+			fail(exitNoGames, "No games match the filter.")
+			return
+		}
+	}
+	if gameSort.Key != "" {
+		games = gameSort.Apply(games, statsPlayer)
+		fmt.Printf("Games sorted by %s.\n\n", gameSort.Describe())
+	}
+	listGames(games)
+	fmt.Println()
+	stats.Summarize(games, statsPlayer, selection.policy()).Write(os.Stdout)
+	if *repertoire > 0 {
+		if statsPlayer == "" {
+			log.Printf("The repertoire needs a single player.")
+		} else {
+			stats.BuildRepertoire(games, statsPlayer, *repertoire, selection.policy()).Write(os.Stdout, "")
+		}
+	}
+
+	if *out != "" {
+		pgns := make([]string, 0, len(games))
+		for _, game := range games {
+			pgns = append(pgns, strings.TrimSpace(game.PGN)+"\n")
+		}
+		if err := os.WriteFile(*out, []byte(strings.Join(pgns, "\n")), 0o644); err != nil {
+			log.Fatalf("Error writing %s: %v", *out, err)
+		}
+		fmt.Printf("\n%d games saved to %s; analyse them with --pgn %s.\n", len(games), *out, *out)
+	}
+}
+
+// fetchOnlineGames downloads the games of the players described by username for the
+// YYYY-MM date range from the named source. It returns the games and the number of API requests planned.
+// With pgnArchives, Chess.com archives are downloaded in PGN format. With a game database, months
+// fetched completely before are read from it, and with offlineDB nothing is downloaded at all.
+// settings.Fetch archives are downloaded at a time, and failed Chess.com requests are retried within
+// the settings.Retries budget.
+func fetchOnlineGames(sourceName, username, startDateStr, endDateStr string, pgnArchives, dryRun bool, db gamedb.Store, offlineDB bool, settings performance) ([]api.Game, int) {
+	// --- Date Parsing ---
+	layout := "2006-01-02"
+	startDate, err := time.Parse(layout, startDateStr+"-01")
+	if err != nil {
+		log.Fatalf("Error parsing start date: %v. Please use YYYY-MM format.", err)
+	}
+	endDate, err := time.Parse(layout, endDateStr+"-01")
+	if err != nil {
+		log.Fatalf("Error parsing end date: %v. Please use YYYY-MM format.", err)
+	}
+
+	if startDate.After(endDate) {
+		log.Fatal("Start date cannot be after the end date.")
+	}
+
+	// --- API Client Initialization ---
+	gameSource, err := source.OpenClient(sourceName, source.Options{PGNArchives: pgnArchives, Retries: settings.Retries})
+	if err != nil {
+		log.Fatalf("Error selecting game source: %v", err)
+	}
+	var cached *gamedb.CachedSource
+	if db != nil {
+		cached = &gamedb.CachedSource{Source: gameSource, DB: db, Offline: offlineDB}
+		gameSource = cached
+	}
+
+	// Player lists (titled:, top:) always come from Chess.com.
+	client := api.NewClient()
+	client.Retries = settings.Retries
+	usernames, err := resolveUsernames(client, username)
+	if err != nil {
+		log.Fatalf("Error resolving players: %v", err)
+	}
+
+	// --- Game Fetching ---
+	requests := source.PlannedRequests(gameSource, len(usernames), startDate, endDate)
+	if dryRun {
+		fmt.Printf("Dry run: %d %s request(s) planned for %d player(s).\n", requests, gameSource.Name(), len(usernames))
+		fmt.Println("Dry run: fetching archives to count games; the engine will not be started.")
+	}
+	games := fetchGames(gameSource, usernames, startDate, endDate, settings.Fetch)
+	if cached != nil && !offlineDB {
+		fmt.Printf("Game database: %d month(s) read from the database, %d fetched.\n", cached.Hits.Load(), cached.Misses.Load())
+	}
+	return games, requests
+}
+
+// fetchGames downloads the games of every user from the first day of startDate's month to the last
+// day of endDate's month, concurrency downloads at a time, as source.Fetch does, with a progress bar
+// over the months of all users from sources that fetch month by month. Games that could not be
+// fetched make the run a partial success, or a network failure when no games were fetched at all.
+func fetchGames(gameSource api.GameSource, usernames []string, startDate, endDate time.Time, concurrency int) []api.Game {
+	for _, user := range usernames {
+		fmt.Printf("Fetching %s games for user '%s' from %s to %s\n", gameSource.Name(), user, startDate.Format("Jan 2006"), endDate.Format("Jan 2006"))
+	}
+	downloads := source.PlannedRequests(gameSource, len(usernames), startDate, endDate)
+	if concurrency = min(concurrency, downloads); concurrency > 1 {
+		fmt.Printf("Downloading %d archives at a time.\n", concurrency)
+	}
+
+	var eta *gameengine.ETA
+	if source.FetchesMonthly(gameSource) && downloads > 1 {
+		eta = gameengine.NewETAOf(downloads, "months", 0)
+	}
+	line := newProgressLine()
+	var progress func(done, total int)
+	if eta != nil {
+		line.show(eta.String())
+		progress = func(done, total int) {
+			eta.Advance(1)
+			line.show(eta.String())
+		}
+	}
+	allGames, errs := source.FetchClient(gameSource, usernames, startDate, endDate, concurrency, progress)
+	line.clear()
+
+	failedUsers := 0
+	for i, user := range usernames {
+		if errs[i] != nil {
+			log.Printf("Some games could not be fetched for %s: %v", user, errs[i])
+			failedUsers++
+		}
+	}
+	switch {
+	case failedUsers > 0 && len(allGames) == 0:
+		fatal(exitNetwork, "No games could be fetched.")
+	case failedUsers > 0:
+		fail(exitPartial, "Some games of %d of %d player(s) could not be fetched.", failedUsers, len(usernames))
+	}
+	return allGames
+}
+
+// resolveUsernames expands the username argument into the list of players to fetch.
+// It accepts a single username, a comma-separated list, "titled:<TITLE>" (e.g., "titled:GM")
+// or "top:<leaderboard>:<n>" (e.g., "top:live_blitz:10").
+func resolveUsernames(client *api.Client, spec string) ([]string, error) {
+	parts := strings.Split(spec, ":")
+	switch strings.ToLower(parts[0]) {
+	case "titled":
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("expected titled:<TITLE>, got %q", spec)
+		}
+		fmt.Printf("Fetching %s players...\n", strings.ToUpper(parts[1]))
+		return client.FetchTitledPlayers(parts[1])
+	case "top":
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("expected top:<leaderboard>:<n>, got %q", spec)
+		}
+		n, err := strconv.Atoi(parts[2])
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid player count %q", parts[2])
+		}
+		fmt.Printf("Fetching the %s leaderboard...\n", parts[1])
+		leaderboards, err := client.FetchLeaderboards()
+		if err != nil {
+			return nil, err
+		}
+		return leaderboards.TopUsernames(parts[1], n)
+	default:
+		var usernames []string
+		for _, name := range strings.Split(spec, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				usernames = append(usernames, name)
+			}
+		}
+		return usernames, nil
+	}
+}
+
+// listGames prints the list of fetched games.
+func listGames(games []api.Game) {
+	fmt.Println("--- Games Found ---")
+	for i, game := range games {
+		endTime := time.Unix(game.EndTime, 0)
+		fmt.Printf("[%d] %s vs %s (%s) - Played on %s%s\n",
+			i+1, game.White.Username, game.Black.Username, game.TimeClass, endTime.Format("2006-01-02"), listingNotes(game))
+	}
+	fmt.Println("-------------------")
+}
+
+// listingNotes marks variant games and games against bots and computer opponents in listings.
+func listingNotes(game api.Game) string {
+	notes := ""
+	if !gameengine.IsSupportedVariant(game.Rules) {
+		notes += " [" + game.Rules + "]"
+	}
+	if game.AgainstBot() {
+		notes += " [vs computer]"
+	}
+	if reason := gameengine.NotAnalysableReason(game); reason != "" {
+		notes += " [" + reason + "]"
+	}
+	return notes
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"chessAnalyserFree/api"
+	"chessAnalyserFree/board"
+	gameengine "chessAnalyserFree/gameEngine"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// writeGameGIF writes a game as an animated GIF to path, one move a second; without a path, to
+// game-<n>.gif in the report directory. With an analysis, an eval bar shows the evaluation of
+// every position.
+func writeGameGIF(output *reportOutput, game api.Game, analysis *gameengine.GameAnalysis, gameNum int, path string) {
+	if path == "" {
+		path = filepath.Join(output.dir, fmt.Sprintf("game-%d.gif", gameNum))
+	}
+	if !strings.EqualFold(filepath.Ext(path), ".gif") {
+		fmt.Println("Give a file name ending in .gif.")
+		return
+	}
+	frames, err := gifFrames(game, analysis)
+	if err != nil {
+		log.Printf("Error reading game: %v", err)
+		return
+	}
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			log.Printf("Error creating %s: %v", dir, err)
+			return
+		}
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		log.Printf("Error writing GIF: %v", err)
+		return
+	}
+	if err := board.AnimatedGIF(file, frames, false, analysis != nil); err != nil {
+		file.Close()
+		os.Remove(path)
+		log.Printf("Error writing GIF: %v", err)
+		return
+	}
+	if err := file.Close(); err != nil {
+		log.Printf("Error writing GIF: %v", err)
+		return
+	}
+	fmt.Printf("GIF of %d positions written to %s\n", len(frames), path)
+}
+
+// gifFrames returns the positions of a game, from the start to the final position, with the
+// evaluation of each from White's point of view when an analysis is given. Evaluations are those
+// of the position before each move, so the final position has none.
+func gifFrames(game api.Game, analysis *gameengine.GameAnalysis) ([]board.Frame, error) {
+	positions, err := gameengine.ReplayPositions(game.PGN)
+	if err != nil {
+		return nil, err
+	}
+	finalFEN, err := gameengine.FinalFEN(game.PGN)
+	if err != nil {
+		return nil, err
+	}
+	if analysis != nil && len(analysis.Moves) != len(positions) {
+		return nil, fmt.Errorf("analysis covers %d moves but the game has %d", len(analysis.Moves), len(positions))
+	}
+	frames := make([]board.Frame, 0, len(positions)+1)
+	for i, position := range positions {
+		frame := board.Frame{FEN: position.FEN}
+		if i > 0 {
+			frame.Played = positions[i-1].Move
+		}
+		if analysis != nil && analysis.Moves[i].Classification != gameengine.ClassBook {
+			eval := analysis.Moves[i].Evaluation
+			if !strings.Contains(position.FEN, " w ") {
+				eval = -eval
+			}
+			frame.Eval = &eval
+		}
+		frames = append(frames, frame)
+	}
+	final := board.Frame{FEN: finalFEN}
+	if len(positions) > 0 {
+		final.Played = positions[len(positions)-1].Move
+	}
+	return append(frames, final), nil
+}
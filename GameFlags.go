@@ -0,0 +1,122 @@
+package main
+
+import (
+	"chessAnalyserFree/api"
+	gamedb "chessAnalyserFree/gameDB"
+	"chessAnalyserFree/stats"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// gameFlags are the flags selecting a player's games, shared by the fetch, analyse and report commands.
+type gameFlags struct {
+	user, from, to *string
+	source         *string
+	pgnArchives    *bool
+	checkOpponents *bool
+	db             *string
+	offline        *bool
+	stats          struct {
+		includeUnrated, includeBots *bool
+		provisionalGames            *int
+	}
+	filter struct {
+		timeClasses, results *string
+		rated                *bool
+		color, opponent      *string
+	}
+	sort *string
+}
+
+// addGameFlags defines the game selection flags on flags.
+func addGameFlags(flags *flag.FlagSet) *gameFlags {
+	g := &gameFlags{}
+	g.user = flags.String("user", "", "Chess.com or Lichess username, a comma-separated list, titled:<TITLE> or top:<leaderboard>:<n> (default: the configured user)")
+	g.from = flags.String("from", "", "first month of games, YYYY-MM")
+	g.to = flags.String("to", "", "last month of games, YYYY-MM (default: the current month)")
+	g.source = flags.String("source", "chesscom", "game source: chesscom or lichess")
+	g.pgnArchives = flags.Bool("pgn-archives", false, "download Chess.com monthly archives in PGN format instead of JSON (smaller; fewer game details)")
+	g.checkOpponents = flags.Bool("check-opponents", false, "look up Chess.com profiles and tag accounts closed for fair-play violations as computer opponents")
+	g.db = flags.String("db", "", "local game database (a bbolt file, an SQLite file ending in .sqlite, or a postgres:// URL); months already fetched completely are read from it instead of downloaded")
+	g.offline = flags.Bool("offline", false, "with --db, read the player's games from the database only, without network access")
+	g.stats.includeUnrated = flags.Bool("include-unrated", false, "count unrated games in statistics")
+	g.stats.includeBots = flags.Bool("include-bots", false, "count games against bots and computer opponents in statistics")
+	g.stats.provisionalGames = flags.Int("exclude-provisional", 0, "leave each player's first N rated games of every time class out of statistics")
+	g.filter.timeClasses = flags.String("time-class", "", "only keep games of these comma-separated time classes: bullet, blitz, rapid, daily, classical")
+	g.filter.rated = flags.Bool("rated", false, "only keep rated games")
+	g.filter.results = flags.String("result", "", "only keep the player's comma-separated results: wins, draws, losses")
+	g.filter.color = flags.String("color", "", "only keep the games the player had this colour in: white or black")
+	g.filter.opponent = flags.String("opponent", "", "only keep games against opponents whose username contains this")
+	g.sort = flags.String("sort", "", "order the games by date, rating (the opponent's), diff (rating difference), length or timeclass; a leading - sorts descending")
+	return g
+}
+
+// gameFilter returns the game filter the flags select.
+func (g *gameFlags) gameFilter() (stats.GameFilter, error) {
+	f := stats.GameFilter{RatedOnly: *g.filter.rated, Color: strings.ToLower(*g.filter.color), Opponent: *g.filter.opponent}
+	for _, list := range []struct {
+		flag, value string
+		count       func(stats.GameFilter) int
+	}{
+		{"--time-class", *g.filter.timeClasses, func(parsed stats.GameFilter) int { return len(parsed.TimeClasses) }},
+		{"--result", *g.filter.results, func(parsed stats.GameFilter) int { return len(parsed.Results) }},
+	} {
+		if list.value == "" {
+			continue
+		}
+		words := strings.Split(list.value, ",")
+		for i := range words {
+			words[i] = strings.TrimSpace(words[i])
+		}
+		parsed, err := stats.ParseGameFilter(words)
+		if err != nil || list.count(parsed) != len(words) {
+			return stats.GameFilter{}, fmt.Errorf("invalid %s %q", list.flag, list.value)
+		}
+		f.TimeClasses = append(f.TimeClasses, parsed.TimeClasses...)
+		f.Results = append(f.Results, parsed.Results...)
+	}
+	if f.Color != "" && f.Color != "white" && f.Color != "black" {
+		return stats.GameFilter{}, fmt.Errorf("invalid --color %q: use white or black", *g.filter.color)
+	}
+	return f, nil
+}
+
+// gameSort returns the game order the --sort flag selects.
+func (g *gameFlags) gameSort() (stats.GameSort, error) {
+	if *g.sort == "" {
+		return stats.GameSort{}, nil
+	}
+	return stats.ParseGameSort(strings.Fields(*g.sort))
+}
+
+// filterGames applies a game filter for player, the player whose games were loaded. Filters on
+// results, colours and opponents need a player.
+func filterGames(games []api.Game, filter stats.GameFilter, player string) ([]api.Game, error) {
+	if filter.NeedsPlayer() && player == "" {
+		return nil, errors.New("filtering by result, colour or opponent needs a single player; give --user")
+	}
+	return filter.Apply(games, player), nil
+}
+
+// policy returns the statistics policy the flags select.
+func (g *gameFlags) policy() stats.Policy {
+	return stats.Policy{IncludeUnrated: *g.stats.includeUnrated, IncludeBots: *g.stats.includeBots, ProvisionalGames: *g.stats.provisionalGames}
+}
+
+// openDB opens the game database given with --db, or returns nil without one.
+func (g *gameFlags) openDB() gamedb.Store {
+	if *g.db == "" {
+		if *g.offline {
+			log.Fatal("--offline needs a game database given with --db.")
+		}
+		return nil
+	}
+	db, err := gamedb.Open(*g.db)
+	if err != nil {
+		log.Fatalf("Error opening game database: %v", err)
+	}
+	return db
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"chessAnalyserFree/api"
+	gamedb "chessAnalyserFree/gameDB"
+	gameengine "chessAnalyserFree/gameEngine"
+	"chessAnalyserFree/report"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+// loadImports reads analysed games from review bundles and JSON Lines exports, telling them apart by
+// content, and returns the games with their analyses by game URL. Analyses that fail the sanity
+// checks are left out, so the game is analysed again when reviewed. With a game database, the games
+// and valid analyses are stored in it, so that later runs that load the games review them too.
+func loadImports(paths []string, db gamedb.Store) ([]api.Game, map[string]*gameengine.GameAnalysis, error) {
+	var games []api.Game
+	analyses := make(map[string]*gameengine.GameAnalysis)
+	for _, path := range paths {
+		path = strings.TrimSpace(path)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		var imported []report.ImportedGame
+		if bytes.HasPrefix(data, []byte("PK\x03\x04")) {
+			imported, err = report.ReadBundle(data, path)
+		} else {
+			imported, err = report.ReadJSONL(bytes.NewReader(data), path)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, entry := range imported {
+			games = append(games, entry.Game)
+			if !entry.Analysis.IsValid() {
+				log.Printf("Ignoring the analysis of %s vs %s from %s, which failed the sanity checks: %s",
+					entry.Game.White.Username, entry.Game.Black.Username, path, strings.Join(entry.Analysis.Issues, "; "))
+				continue
+			}
+			analyses[entry.Game.URL] = entry.Analysis
+			if db != nil {
+				if err := db.SaveAnalysis(entry.Game, gameengine.ImportedSettings, entry.Analysis); err != nil {
+					log.Printf("Error storing the analysis of %s: %v", entry.Game.URL, err)
+				}
+			}
+		}
+	}
+	if db != nil && len(analyses) > 0 {
+		fmt.Printf("Stored %d imported analyses in the game database.\n", len(analyses))
+	}
+	return games, analyses, nil
+}
+
+// storedImports returns the imported analyses the game database holds for games, by game URL.
+// Analyses that no longer match their game are left out.
+func storedImports(db gamedb.Store, games []api.Game) map[string]*gameengine.GameAnalysis {
+	analyses := make(map[string]*gameengine.GameAnalysis)
+	for _, game := range games {
+		if game.URL == "" {
+			continue
+		}
+		analysis, err := db.LoadAnalysis(game.URL, gameengine.ImportedSettings)
+		if err != nil || analysis == nil || len(gameengine.ValidateAnalysis(game, analysis)) > 0 {
+			continue
+		}
+		analysis.Cached = true
+		analyses[game.URL] = analysis
+	}
+	return analyses
+}
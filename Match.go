@@ -0,0 +1,85 @@
+package main
+
+import (
+	gameengine "chessAnalyserFree/gameEngine"
+	"flag"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+// runMatch implements the match subcommand, which plays an engine against itself (or a second
+// engine) from a position several times and reports how the games ended. It shows whether an
+// advantage the engine sees can actually be converted at a fast time control.
+func runMatch(arguments []string, defaults *userConfig) {
+	flags := flag.NewFlagSet("match", flag.ExitOnError)
+	fen := flags.String("fen", gameengine.StandardStartFEN, "position to play from")
+	games := flags.Int("games", 20, "number of games")
+	moveTime := flags.Duration("movetime", 100*time.Millisecond, "search time per move")
+	depth := flags.Int("depth", 0, "search depth per move instead of a search time")
+	maxPlies := flags.Int("max-plies", 300, "adjudicate games as draws after this many plies")
+	randomPlies := flags.Int("random-plies", 4, "opening plies picked at random among near-best moves, so games differ")
+	margin := flags.Int("margin", 30, "centipawns a randomly picked move may be worse than the best one")
+	engine2 := flags.String("engine2", "", "path to a second engine; the engines alternate playing the side to move")
+	flags.Parse(arguments)
+	enginePath, ok := defaults.engineArg(flags)
+	if !ok {
+		fmt.Println("Usage: go run . match [--fen FEN] [--games n] [--movetime 100ms] [--engine2 path] [<path_to_stockfish>]")
+		flags.PrintDefaults()
+		return
+	}
+
+	first, err := gameengine.NewStockfishAnalyser(enginePath)
+	if err != nil {
+		fatal(exitEngine, "Error starting Stockfish analyser: %v", err)
+	}
+	defer first.Close()
+	var second *gameengine.StockfishAnalyser
+	if *engine2 != "" {
+		second, err = gameengine.NewStockfishAnalyser(*engine2)
+		if err != nil {
+			fatal(exitEngine, "Error starting second engine: %v", err)
+		}
+		defer second.Close()
+	}
+
+	config := gameengine.MatchConfig{
+		StartFEN:     *fen,
+		Games:        *games,
+		MoveTime:     *moveTime,
+		Depth:        *depth,
+		MaxPlies:     *maxPlies,
+		RandomPlies:  *randomPlies,
+		RandomMargin: *margin,
+	}
+	if *depth > 0 {
+		config.MoveTime = 0
+	}
+	fmt.Printf("Playing %d games from %s\n", *games, *fen)
+	result, err := gameengine.RunMatch(first, second, config, func(n int, game gameengine.MatchGame) {
+		fmt.Printf("Game %d/%d: %s %s (%d plies)\n", n, *games, game.Outcome, game.Termination, len(game.Moves))
+	})
+	if err != nil {
+		log.Fatalf("Error playing match: %v", err)
+	}
+
+	side := "White"
+	if strings.Fields(*fen)[1] == "b" {
+		side = "Black"
+	}
+	fmt.Println("\n--- Match Result ---")
+	fmt.Printf("Side to move (%s): +%d =%d -%d (%.1f%%)\n", side, result.Wins, result.Draws, result.Losses, result.Score())
+	terminations := make([]string, 0, len(result.Terminations))
+	for termination, count := range result.Terminations {
+		terminations = append(terminations, fmt.Sprintf("%s %d", termination, count))
+	}
+	sort.Strings(terminations)
+	fmt.Printf("Terminations: %s\n", strings.Join(terminations, ", "))
+	if second != nil {
+		fmt.Printf("%s: %.1f/%d, %s: %.1f/%d\n", enginePath, result.FirstPoints, len(result.Games),
+			*engine2, float64(len(result.Games))-result.FirstPoints, len(result.Games))
+	}
+	fmt.Println("--------------------")
+}
@@ -0,0 +1,349 @@
+package main
+
+import (
+	"bufio"
+	"chessAnalyserFree/api"
+	"chessAnalyserFree/board"
+	gameengine "chessAnalyserFree/gameEngine"
+	"chessAnalyserFree/lichess"
+	"chessAnalyserFree/report"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// handleSelectedGame provides options for a selected game (details, board, analyse, merge, report,
+// explorer, study, collect). analysis may hold an existing analysis of the game, or nil; study and
+// collection are nil when no study or PGN collection is configured. Once the game has been analysed
+// more than once, reports, exports and the query dataset use the merged analysis.
+func handleSelectedGame(reader *bufio.Reader, analyser *gameengine.StockfishAnalyser, output *reportOutput, study *studyExport, collection *report.PGNCollection, dataset *moveDataset, boardStyle board.Style, game api.Game, gameNum int, analysis *gameengine.GameAnalysis) {
+	var analyses []*gameengine.GameAnalysis
+	if analysis != nil {
+		analyses = append(analyses, analysis)
+		dataset.add(game, analysis)
+	}
+	// current returns the analysis to export, analysing the game first if needed.
+	current := func() *gameengine.GameAnalysis {
+		if len(analyses) == 0 {
+			result := analyseGameMoves(analyser, game)
+			if result == nil {
+				return nil
+			}
+			analyses = append(analyses, result)
+			dataset.add(game, result)
+		}
+		if len(analyses) == 1 {
+			return analyses[0]
+		}
+		merged, err := gameengine.MergeAnalyses(game, analyses...)
+		if err != nil {
+			log.Printf("Error merging analyses, using the latest: %v", err)
+			return analyses[len(analyses)-1]
+		}
+		return merged
+	}
+	var replay *gameReplay // Created by the first replay command.
+
+	for {
+		fmt.Printf("\nSelected Game %d: %s vs %s\n", gameNum, game.White.Username, game.Black.Username)
+		fmt.Print("Enter command ('details', 'board [move] [flip]', 'next', 'prev', 'goto <move>', 'fen', 'copy fen|pgn [annotated]', 'analyse [preset]', 'merge', 'report', 'csv', 'gif [eval] [out.gif]', 'explorer', 'study', 'collect', 'back'): ")
+		input, _ := reader.ReadString('\n')
+		fields := strings.Fields(strings.ToLower(input))
+		if len(fields) == 0 {
+			fmt.Println("Invalid command.")
+			continue
+		}
+
+		switch fields[0] {
+		case "details":
+			displayGameDetails(game, gameNum, boardStyle)
+		case "board":
+			var latest *gameengine.GameAnalysis
+			if len(analyses) > 0 {
+				latest = current()
+			}
+			showPosition(game, latest, fields[1:], boardStyle)
+		case "next", "prev", "goto":
+			if replay == nil {
+				var err error
+				if replay, err = newGameReplay(game); err != nil {
+					log.Printf("Error reading game: %v", err)
+					continue
+				}
+			}
+			if replay.step(fields[0], fields[1:]) {
+				var latest *gameengine.GameAnalysis
+				if len(analyses) > 0 {
+					latest = current()
+				}
+				replay.show(analyser, latest, boardStyle)
+			}
+		case "fen":
+			// Alone on its line, for pasting into another tool.
+			if fen, err := replay.currentFEN(game); err != nil {
+				log.Printf("Error reading game: %v", err)
+			} else {
+				fmt.Println(fen)
+			}
+		case "copy":
+			switch strings.Join(fields[1:], " ") {
+			case "fen":
+				if fen, err := replay.currentFEN(game); err != nil {
+					log.Printf("Error reading game: %v", err)
+				} else {
+					copyAndReport("FEN", fen)
+				}
+			case "pgn":
+				copyAndReport("PGN", game.PGN)
+			case "pgn annotated":
+				if analysis := current(); analysis != nil {
+					if pgn, err := report.AnnotatedPGN(game, analysis); err != nil {
+						log.Printf("Error annotating the PGN: %v", err)
+					} else {
+						copyAndReport("annotated PGN", pgn)
+					}
+				}
+			default:
+				fmt.Println("Copy what? 'copy fen', 'copy pgn' or 'copy pgn annotated'.")
+			}
+		case "analyse":
+			var result *gameengine.GameAnalysis
+			if len(fields) > 1 {
+				result = analyseWithPreset(analyser, game, fields[1])
+			} else {
+				result = analyseGameMoves(analyser, game)
+			}
+			if result != nil {
+				analyses = append(analyses, result)
+				if latest := current(); latest != nil {
+					dataset.add(game, latest)
+				}
+			}
+		case "merge":
+			if len(analyses) < 2 {
+				fmt.Println("Analyse the game with another preset first, e.g. 'analyse deep'.")
+				continue
+			}
+			if merged := current(); merged != nil {
+				printMergedAnalysis(game, merged)
+			}
+		case "report":
+			if analysis := current(); analysis != nil {
+				writeGameReports(output, game, analysis, gameNum)
+			}
+		case "csv":
+			if analysis := current(); analysis != nil {
+				writeGameCSV(output.dir, game, analysis, gameNum)
+			}
+		case "gif":
+			// The path keeps its case; the eval bar needs the analysis.
+			path, withEval := "", false
+			for _, field := range strings.Fields(input)[1:] {
+				if strings.EqualFold(field, "eval") {
+					withEval = true
+				} else {
+					path = field
+				}
+			}
+			var analysis *gameengine.GameAnalysis
+			if withEval {
+				if analysis = current(); analysis == nil {
+					continue
+				}
+			}
+			writeGameGIF(output, game, analysis, gameNum, path)
+		case "explorer":
+			var latest *gameengine.GameAnalysis
+			if len(analyses) > 0 {
+				latest = analyses[len(analyses)-1]
+			}
+			exploreOpening(lichess.NewClient(), game, latest)
+		case "study":
+			if study == nil {
+				fmt.Println("No study configured; start with --study <id> and a Lichess token.")
+				continue
+			}
+			if analysis := current(); analysis != nil {
+				if err := study.push(game, analysis); err != nil {
+					log.Printf("Error exporting to study: %v", err)
+				}
+			}
+		case "collect":
+			if collection == nil {
+				fmt.Println("No PGN collection configured; start with --collection <file.pgn>.")
+				continue
+			}
+			if analysis := current(); analysis != nil {
+				addToCollection(collection, game, analysis)
+			}
+		case "back":
+			return
+		default:
+			fmt.Println("Invalid command.")
+		}
+	}
+}
+
+// analyseWithPreset analyses a game with another preset than the analyser's current one, which is
+// restored afterwards.
+func analyseWithPreset(analyser *gameengine.StockfishAnalyser, game api.Game, presetName string) *gameengine.GameAnalysis {
+	preset, err := gameengine.LookupPreset(presetName)
+	if err != nil {
+		log.Printf("Error: %v", err)
+		return nil
+	}
+	previous := analyser.Preset()
+	if err := analyser.SetPreset(preset); err != nil {
+		log.Printf("Error applying preset %s: %v", preset.Name, err)
+		return nil
+	}
+	defer func() {
+		if err := analyser.SetPreset(previous); err != nil {
+			log.Printf("Error restoring preset %s: %v", previous.Name, err)
+		}
+	}()
+	return analyseGameMoves(analyser, game)
+}
+
+// printMergedAnalysis prints the merged evaluation of every move with the analysis it came from.
+func printMergedAnalysis(game api.Game, analysis *gameengine.GameAnalysis) {
+	if !analysis.IsValid() {
+		fmt.Println("\nWARNING: the merged analysis failed the sanity checks and may be wrong:")
+		for _, issue := range analysis.Issues {
+			fmt.Printf("  - %s\n", issue)
+		}
+	}
+	fmt.Printf("\n--- Merged Analysis (%s) ---\n", analysis.Preset)
+	fmt.Printf("Move | Played     | %-12s | Depth | Source\n", evalViewHeading())
+	fmt.Println("-------------------------------------------------------------")
+	sans := movesSAN(game, analysis.Moves)
+	for i, move := range analysis.Moves {
+		blackMove := isBlackMove(analysis.Moves, i)
+		number := fmt.Sprintf("%d.", move.MoveNumber)
+		if blackMove {
+			number = fmt.Sprintf("%d...", move.MoveNumber)
+		}
+		source := move.Source
+		if move.Classification == gameengine.ClassBook {
+			source = "book"
+		}
+		fmt.Printf("%-4s | %s | %s | %-5d | %s\n", number,
+			colorMove(move.Classification, fmt.Sprintf("%-10s", sans[i]+annotationSymbol(move.Classification))),
+			viewedEval(move, blackMove), move.Depth, source)
+	}
+	fmt.Println("-------------------------------------------------------------")
+}
+
+// displayGameDetails shows detailed information for a selected game, with its final position.
+func displayGameDetails(game api.Game, index int, boardStyle board.Style) {
+	endTime := time.Unix(game.EndTime, 0)
+	fmt.Printf("\n--- Game Details (%d) ---\n", index)
+	fmt.Printf("URL: %s\n", game.URL)
+	fmt.Printf("Date: %s\n", endTime.Format("2006-01-02 15:04:05"))
+	fmt.Printf("Result: White: %s, Black: %s\n", game.White.Result, game.Black.Result)
+	if game.AgainstBot() {
+		fmt.Println("Opponent: bot or computer (excluded from statistics)")
+	}
+	printGameBoard(game, boardStyle, false)
+	fmt.Println("--- PGN ---")
+	fmt.Println(game.PGN)
+	fmt.Println("-------------")
+}
+
+// analyseGameMoves triggers the stockfish analysis, prints the results and returns them.
+func analyseGameMoves(analyser *gameengine.StockfishAnalyser, game api.Game) *gameengine.GameAnalysis {
+	fmt.Println("\nAnalysing game... this may take a moment.")
+	analysis, err := analyseWithProgress(analyser, game)
+	if err != nil {
+		log.Printf("Error during analysis: %v", err)
+		return nil
+	}
+
+	if !analysis.IsValid() {
+		fmt.Println("\nWARNING: this analysis failed the sanity checks and may be wrong:")
+		for _, issue := range analysis.Issues {
+			fmt.Printf("  - %s\n", issue)
+		}
+	}
+
+	cached := ""
+	if analysis.Cached {
+		cached = ", from the game database"
+	}
+	fmt.Printf("\n--- Move Analysis (preset: %s%s) ---\n", analysis.Preset, cached)
+	heading := evalViewHeading()
+	fmt.Printf("Move | %-20s | %-12s | %-20s | %s\n", "White", heading, "Black", heading)
+	fmt.Println("-----------------------------------------------------------------------------")
+	moves := analysis.Moves
+	sans := movesSAN(game, moves)
+	for i := 0; i < len(moves); {
+		// A row holds a White move and the Black reply; a game set up with Black to move starts
+		// with Black's move alone.
+		whiteCell, blackCell := fmt.Sprintf("%-20s | %-12s", "", ""), ""
+		number := moves[i].MoveNumber
+		if !isBlackMove(moves, i) {
+			whiteCell = moveCell(moves[i], sans[i], false)
+			i++
+		}
+		if i < len(moves) && isBlackMove(moves, i) {
+			blackCell = moveCell(moves[i], sans[i], true)
+			i++
+		}
+		fmt.Printf("%-4d | %s | %s\n", number, whiteCell, blackCell)
+	}
+	fmt.Println("---------------------")
+	if depths := gameengine.SearchDepths(moves); depths.Positions > 0 {
+		fmt.Printf("Search depth reached: %s\n", depths)
+	}
+	printEvalGraph(game, analysis)
+
+	for i, move := range moves {
+		if move.PracticalChances == nil {
+			continue
+		}
+		fmt.Printf("Critical position before %d. %s (eval %s), practical chances for %s\n",
+			move.MoveNumber, colorMove(move.Classification, sans[i]+annotationSymbol(move.Classification)),
+			colorEval(move, move.EvaluationText), move.PracticalChances)
+	}
+	return analysis
+}
+
+// moveCell formats a move of the analysis table, given in SAN, with its annotation and evaluation.
+func moveCell(move gameengine.MoveAnalysis, san string, blackMove bool) string {
+	return colorMove(move.Classification, fmt.Sprintf("%-20s", san+annotationSymbol(move.Classification))) +
+		" | " + viewedEval(move, blackMove)
+}
+
+// movesSAN returns the moves of an analysis of game in SAN, e.g. "Nf3" or "e8=Q+", replaying the game
+// for the position of every move. A move that cannot be replayed is left in UCI notation.
+func movesSAN(game api.Game, moves []gameengine.MoveAnalysis) []string {
+	positions, err := gameengine.ReplayPositions(game.PGN)
+	if err != nil {
+		log.Printf("Showing the moves in UCI notation, as the game could not be replayed: %v", err)
+	}
+	sans := make([]string, len(moves))
+	for i, move := range moves {
+		sans[i] = move.Move
+		if i < len(positions) && positions[i].Move == move.Move && positions[i].SAN != "" {
+			sans[i] = positions[i].SAN
+		}
+	}
+	return sans
+}
+
+// annotationSymbol returns the PGN-style suffix for a move classification.
+func annotationSymbol(classification string) string {
+	switch classification {
+	case gameengine.ClassSacrifice:
+		return "!"
+	case gameengine.ClassInaccuracy:
+		return "?!"
+	case gameengine.ClassMistake:
+		return "?"
+	case gameengine.ClassBlunder:
+		return "??"
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bufio"
+	"chessAnalyserFree/api"
+	gameengine "chessAnalyserFree/gameEngine"
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// autoFetchConcurrency is the number of archives fetched at a time in auto mode: enough to overlap
+// the requests' round trips without running into the sites' rate limits.
+const autoFetchConcurrency = 2
+
+// autoHashShare is the share of the available memory auto mode gives the engines' hash tables, and
+// autoHashMin and autoHashMax bound the hash of each engine in MB.
+const (
+	autoHashShare = 4
+	autoHashMin   = 16
+	autoHashMax   = 2048
+)
+
+// countSetting is a positive count, or "auto" to fit it to the machine. The zero value is unset.
+type countSetting struct {
+	n    int
+	auto bool
+}
+
+// String returns the count, "auto" or, unset, an empty string.
+func (c *countSetting) String() string {
+	switch {
+	case c.auto:
+		return "auto"
+	case c.n == 0:
+		return ""
+	}
+	return strconv.Itoa(c.n)
+}
+
+// Set parses a positive count or "auto".
+func (c *countSetting) Set(value string) error {
+	if value == "auto" {
+		*c = countSetting{auto: true}
+		return nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		return fmt.Errorf("%q is neither a positive number nor auto", value)
+	}
+	*c = countSetting{n: n}
+	return nil
+}
+
+// performanceFlags are the throughput settings of a command, as given or configured, along with how
+// long its engines may take to answer.
+type performanceFlags struct {
+	workers, threads, hash, fetch countSetting
+	engineTimeout                 time.Duration
+	retries                       int
+}
+
+// addPerformanceFlags defines --threads, --hash and --engine-timeout on flags and, for commands
+// running batches, --workers.
+func addPerformanceFlags(flags *flag.FlagSet, batch bool) *performanceFlags {
+	p := &performanceFlags{}
+	flags.DurationVar(&p.engineTimeout, "engine-timeout", gameengine.DefaultResponseTimeout, "stop an engine that prints nothing for this long while it is expected to answer, restarting it if need be, and fail the game it was analysing (0 waits forever)")
+	if batch {
+		flags.Var(&p.workers, "workers", "in batch mode, analyse this many games at a time, each on its own engine process, or auto for one per core --threads leaves (default 1)")
+	}
+	flags.Var(&p.threads, "threads", "search threads per engine, or auto to share the cores between the engines (default: the engine's own default)")
+	flags.Var(&p.hash, "hash", "hash table size per engine in MB, or auto for a quarter of the available memory shared between the engines (default: the engine's own default)")
+	return p
+}
+
+// addFetchFlag defines --fetch-concurrency and --retries on flags, for commands downloading archives.
+func (p *performanceFlags) addFetchFlag(flags *flag.FlagSet) {
+	flags.Var(&p.fetch, "fetch-concurrency", fmt.Sprintf("download this many monthly archives or players at a time, or auto for %d (default 1)", autoFetchConcurrency))
+	flags.IntVar(&p.retries, "retries", api.DefaultRetries, "retry budget of a run: how many times in all Chess.com requests failing with a server or network error are tried again, with a growing wait (0 turns retries off)")
+}
+
+// performance is the resolved throughput of a run.
+type performance struct {
+	Workers       int           // Engine processes analysing games at once.
+	Threads       int           // Search threads per engine, or 0 for the engine's default.
+	Hash          int           // Hash table per engine in MB, or 0 for the engine's default.
+	Fetch         int           // Archives downloaded at once.
+	Retries       int           // Retry budget of the Chess.com requests.
+	EngineTimeout time.Duration // How long an engine may stay silent; 0 waits forever.
+}
+
+// resolve fills in the settings left to auto from the machine's cores and available memory. Only
+// batch runs use more than one engine; the others give it all the cores auto threads can use.
+func (p *performanceFlags) resolve(batch bool) performance {
+	cores := runtime.NumCPU()
+	resolved := performance{Workers: 1, Threads: p.threads.n, Hash: p.hash.n, Fetch: max(p.fetch.n, 1), Retries: max(p.retries, 0), EngineTimeout: p.engineTimeout}
+	if batch {
+		switch {
+		case p.workers.auto && p.threads.auto:
+			resolved.Workers, resolved.Threads = cores, 1
+		case p.workers.auto:
+			resolved.Workers = max(cores/max(p.threads.n, 1), 1)
+		case p.workers.n > 0:
+			resolved.Workers = p.workers.n
+		}
+	}
+	if p.threads.auto && resolved.Threads == 0 {
+		resolved.Threads = max(cores/resolved.Workers, 1)
+	}
+	if p.hash.auto {
+		resolved.Hash = autoHash(availableMemory(), resolved.Workers)
+	}
+	if p.fetch.auto {
+		resolved.Fetch = autoFetchConcurrency
+	}
+	return resolved
+}
+
+// autoHash returns the hash size in MB of each of the engines sharing a quarter of memory bytes:
+// a power of two, as engines round the size down to one anyway, between autoHashMin and
+// autoHashMax. Without knowing the memory it returns 0 for the engine's default.
+func autoHash(memory uint64, engines int) int {
+	if memory == 0 {
+		return 0
+	}
+	share := memory / autoHashShare / uint64(engines) >> 20
+	hash := autoHashMin
+	for hash*2 <= autoHashMax && uint64(hash*2) <= share {
+		hash *= 2
+	}
+	return hash
+}
+
+// availableMemory returns the bytes of memory available to new processes, as the kernel estimates
+// them, or 0 where it does not tell.
+func availableMemory() uint64 {
+	file, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return 0
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		rest, ok := strings.CutPrefix(scanner.Text(), "MemAvailable:")
+		if !ok {
+			continue
+		}
+		kb, err := strconv.ParseUint(strings.TrimSuffix(strings.TrimSpace(rest), " kB"), 10, 64)
+		if err != nil {
+			return 0
+		}
+		return kb << 10
+	}
+	return 0
+}
+
+// startEngine starts the engine at path searching with preset, with the threads, hash size and
+// engine timeout of the settings. Workers spawned from it take them over.
+func (p performance) startEngine(path string, preset gameengine.Preset) (*gameengine.StockfishAnalyser, error) {
+	analyser, err := gameengine.NewStockfishAnalyser(path)
+	if err != nil {
+		return nil, err
+	}
+	analyser.SetResponseTimeout(p.EngineTimeout)
+	err = analyser.SetPreset(preset)
+	if err == nil && p.Threads > 0 {
+		err = analyser.SetThreads(p.Threads)
+	}
+	if err == nil && p.Hash > 0 {
+		err = analyser.SetHash(p.Hash)
+	}
+	if err != nil {
+		analyser.Close()
+		return nil, err
+	}
+	return analyser, nil
+}
+
+// String describes the settings, e.g. "4 engines × 2 threads, 256 MB hash each".
+func (p performance) String() string {
+	engines := "1 engine"
+	if p.Workers > 1 {
+		engines = fmt.Sprintf("%d engines", p.Workers)
+	}
+	threads := "default threads"
+	if p.Threads > 0 {
+		threads = fmt.Sprintf("%d threads", p.Threads)
+		if p.Threads == 1 {
+			threads = "1 thread"
+		}
+	}
+	hash := "default hash"
+	if p.Hash > 0 {
+		hash = fmt.Sprintf("%d MB hash", p.Hash)
+	}
+	each := ""
+	if p.Workers > 1 {
+		each = " each"
+	}
+	return fmt.Sprintf("%s × %s, %s%s", engines, threads, hash, each)
+}
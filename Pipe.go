@@ -0,0 +1,87 @@
+package main
+
+import (
+	"chessAnalyserFree/api"
+	gamedb "chessAnalyserFree/gameDB"
+	gameengine "chessAnalyserFree/gameEngine"
+	pgnimport "chessAnalyserFree/pgnImport"
+	"chessAnalyserFree/report"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+// pipeFormats are the output formats of --stdin.
+var pipeFormats = []string{"jsonl", "pgn"}
+
+// runPipe analyses the PGN games read from stdin one by one, as they arrive, and writes each
+// analysis to stdout as soon as it completes: a JSON object per line with --format jsonl, or the
+// annotated PGN with --format pgn. Only the analyses go to stdout; progress and problems go to
+// stderr, and a game that cannot be analysed yields an object with an error rather than stopping
+// the run, so the command can sit in a pipeline or run as another program's subprocess.
+func runPipe(enginePath string, preset gameengine.Preset, settings performance, db gamedb.Store, format string) {
+	var write func(game api.Game, analysis *gameengine.GameAnalysis, err error) error
+	switch format {
+	case "jsonl":
+		jsonl := report.NewJSONLWriter(os.Stdout)
+		write = func(game api.Game, analysis *gameengine.GameAnalysis, err error) error {
+			if err != nil {
+				return jsonl.WriteError(game, err)
+			}
+			return jsonl.WriteGame(game, analysis)
+		}
+	case "pgn":
+		write = func(game api.Game, analysis *gameengine.GameAnalysis, err error) error {
+			if err != nil {
+				log.Printf("Skipping %s: %v", game.URL, err)
+				return nil
+			}
+			pgn, err := report.AnnotatedPGN(game, analysis)
+			if err != nil {
+				return err
+			}
+			_, err = fmt.Fprintf(os.Stdout, "%s\n\n", strings.TrimSpace(pgn))
+			return err
+		}
+	default:
+		log.Fatalf("Unknown --format %q (available: %s).", format, strings.Join(pipeFormats, ", "))
+	}
+
+	analyser, err := settings.startEngine(enginePath, preset)
+	if err != nil {
+		fatal(exitEngine, "Error starting Stockfish analyser: %v", err)
+	}
+	defer analyser.Close()
+	if db != nil {
+		analyser.SetAnalysisStore(db)
+	}
+	analyser.CachePositions(db)
+
+	count, failed := 0, 0
+	err = pgnimport.StreamGames(os.Stdin, "stdin", func(game api.Game) error {
+		count++
+		analysis, err := analyser.AnalyseGame(game)
+		if err == nil {
+			log.Printf("[%d] %s vs %s: %d moves analysed", count, game.White.Username, game.Black.Username, len(analysis.Moves))
+		} else if !errors.Is(err, gameengine.ErrUnsupportedVariant) && !errors.Is(err, gameengine.ErrNotAnalysable) {
+			failed++
+		}
+		if err := write(game, analysis, err); err != nil {
+			return fmt.Errorf("writing %s: %w", game.URL, err)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Fatalf("Error reading games from stdin: %v", err)
+	}
+	switch {
+	case count == 0:
+		fail(exitNoGames, "No games were read from stdin.")
+	case failed > 0 && failed == count:
+		fail(exitEngine, "None of the %d games could be analysed.", count)
+	case failed > 0:
+		fail(exitPartial, "%d of %d games could not be analysed.", failed, count)
+	}
+}
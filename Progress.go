@@ -0,0 +1,70 @@
+package main
+
+import (
+	"chessAnalyserFree/api"
+	gameengine "chessAnalyserFree/gameEngine"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"golang.org/x/term"
+)
+
+// progressLine is a progress bar redrawn in place on the current line of the terminal. When stdout
+// is not a terminal, e.g. a log file or a pipe, nothing is drawn.
+type progressLine struct {
+	enabled bool
+	length  int // Length of the text shown, to blank it out.
+}
+
+// newProgressLine returns a progress line for stdout.
+func newProgressLine() *progressLine {
+	return &progressLine{enabled: term.IsTerminal(int(os.Stdout.Fd()))}
+}
+
+// show replaces the text of the line.
+func (p *progressLine) show(text string) {
+	if !p.enabled {
+		return
+	}
+	fmt.Print("\r" + text + strings.Repeat(" ", max(p.length-len(text), 0)))
+	p.length = len(text)
+}
+
+// clear blanks the line, so that the next output starts at its beginning.
+func (p *progressLine) clear() {
+	if !p.enabled || p.length == 0 {
+		return
+	}
+	fmt.Print("\r" + strings.Repeat(" ", p.length) + "\r")
+	p.length = 0
+}
+
+// analyseWithProgress analyses a game, drawing a progress bar with the move last evaluated and its
+// evaluation while the engine works through the positions.
+func analyseWithProgress(analyser *gameengine.StockfishAnalyser, game api.Game) (*gameengine.GameAnalysis, error) {
+	calibration, _ := gameengine.LoadCalibration()
+	workload := gameengine.EstimateWorkload([]api.Game{game}, analyser.Preset(), calibration)
+	eta := gameengine.NewETA(workload.Positions, workload.TimePerSearch)
+	line := newProgressLine()
+	// The ETA advances on the analysis goroutine and is read here.
+	var mu sync.Mutex
+	analyser.OnPositionAnalysed(func() {
+		mu.Lock()
+		defer mu.Unlock()
+		eta.Advance(1)
+	})
+	defer analyser.OnPositionAnalysed(nil)
+
+	moves, result := analyser.AnalyseGameStream(game)
+	for move := range moves {
+		mu.Lock()
+		progress := eta.String()
+		mu.Unlock()
+		line.show(fmt.Sprintf("%s | %s %s %s", progress, move.Label(), move.SAN(), move.Move.EvaluationText))
+	}
+	line.clear()
+	analysed := <-result
+	return analysed.Analysis, analysed.Err
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"chessAnalyserFree/api"
+	gameengine "chessAnalyserFree/gameEngine"
+	"chessAnalyserFree/query"
+	"chessAnalyserFree/stats"
+	"fmt"
+	"io"
+	"log"
+	"time"
+)
+
+// moveDataset collects the analysed moves of the session for queries, one analysis per game.
+type moveDataset struct {
+	player   string // Player whose moves are collected; empty for both sides.
+	games    []string
+	rows     map[string][]query.Row
+	analyses map[string]*gameengine.GameAnalysis // By game URL, for the accuracy trend.
+}
+
+// newMoveDataset creates an empty dataset of player's moves, or of all moves if player is empty.
+func newMoveDataset(player string) *moveDataset {
+	return &moveDataset{player: player, rows: make(map[string][]query.Row), analyses: make(map[string]*gameengine.GameAnalysis)}
+}
+
+// add records the moves of an analysed game, replacing any earlier analysis of the same game.
+func (d *moveDataset) add(game api.Game, analysis *gameengine.GameAnalysis) {
+	d.analyses[game.URL] = analysis
+	rows, err := query.MoveRows(game, analysis, d.player)
+	if err != nil {
+		log.Printf("Game %s not added to the query dataset: %v", game.URL, err)
+		return
+	}
+	if _, seen := d.rows[game.URL]; !seen {
+		d.games = append(d.games, game.URL)
+	}
+	d.rows[game.URL] = rows
+}
+
+// analysed returns the games analysed in the session, or else found in earlier, which may be nil,
+// with their analyses, in the order of games.
+func (d *moveDataset) analysed(games []api.Game, earlier map[string]*gameengine.GameAnalysis) []stats.AnalysedGame {
+	var analysed []stats.AnalysedGame
+	for _, game := range games {
+		analysis := d.analyses[game.URL]
+		if analysis == nil {
+			analysis = earlier[game.URL]
+		}
+		if analysis != nil {
+			analysed = append(analysed, stats.AnalysedGame{Game: game, Analysis: analysis})
+		}
+	}
+	return analysed
+}
+
+// run prints the moves matching a query expression.
+func (d *moveDataset) run(w io.Writer, expr string) error {
+	q, err := query.Parse(expr)
+	if err != nil {
+		return err
+	}
+	var all []query.Row
+	for _, url := range d.games {
+		all = append(all, d.rows[url]...)
+	}
+	matched := q.Filter(all)
+
+	fmt.Fprintf(w, "\n--- Query: %s ---\n", q)
+	fmt.Fprintf(w, "%d of %d analysed moves in %d games match.\n", len(matched), len(all), len(d.games))
+	if len(matched) == 0 {
+		return nil
+	}
+	fmt.Fprintln(w, "Date       | Move     | Played | Class      | CP loss | Eval    | Phase      | Game")
+	fmt.Fprintln(w, "------------------------------------------------------------------------------------")
+	for _, row := range matched {
+		number := fmt.Sprintf("%d.", row.Move.MoveNumber)
+		if row.Color == "black" {
+			number = fmt.Sprintf("%d...", row.Move.MoveNumber)
+		}
+		fmt.Fprintf(w, "%s | %-8s | %-6s | %-10s | %7d | %-7s | %-10s | %s vs %s %s\n",
+			time.Unix(row.Game.EndTime, 0).Format("2006-01-02"), number, row.Move.Move, row.Move.Classification,
+			row.Move.CentipawnLoss, row.Move.EvaluationText, row.Phase,
+			row.Game.White.Username, row.Game.Black.Username, row.Game.URL)
+	}
+	return nil
+}
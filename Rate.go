@@ -0,0 +1,242 @@
+package main
+
+import (
+	"chessAnalyserFree/api"
+	gamedb "chessAnalyserFree/gameDB"
+	gameengine "chessAnalyserFree/gameEngine"
+	"chessAnalyserFree/lichess"
+	"chessAnalyserFree/source"
+	"chessAnalyserFree/stats"
+	"cmp"
+	"flag"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// runRate implements the rate subcommand, which rates the opponent of a lost game: it analyses
+// their recent games of the same time class and tells whether their accuracy and engine move
+// matches in the lost game were in line with those games. Without --game the user's most recent
+// loss is rated.
+func runRate(arguments []string, defaults *userConfig) {
+	flags := flag.NewFlagSet("rate", flag.ExitOnError)
+	flags.Usage = usageFunc(flags)
+	user := flags.String("user", defaults.User, "your username; the opponent is the other side of the game")
+	gameURL := flags.String("game", "", "Chess.com or Lichess URL of the game (default: your most recent loss)")
+	sourceName := flags.String("source", "chesscom", "game source of your most recent loss: chesscom or lichess")
+	count := flags.Int("games", 50, "number of the opponent's recent games to compare with")
+	months := flags.Int("months", 12, "number of months to look back for the opponent's games")
+	presetName := flags.String("preset", "quick", "analysis preset: "+strings.Join(gameengine.PresetNames(), ", "))
+	depth := flags.Int("depth", 0, "search every position to this depth instead of the preset's limit")
+	perf := addPerformanceFlags(flags, false)
+	dbPath := flags.String("db", "", "keep games and analyses in this game database, so a second rating is quicker")
+	flags.Parse(arguments)
+	if err := defaults.applyFlags(flags); err != nil {
+		log.Fatalf("Error in configuration: %v", err)
+	}
+	enginePath, ok := defaults.engineArg(flags)
+	if !ok || *gameURL == "" && *user == "" {
+		flags.Usage()
+		return
+	}
+
+	var db gamedb.Store
+	if *dbPath != "" {
+		var err error
+		if db, err = gamedb.Open(*dbPath); err != nil {
+			log.Fatalf("Error opening game database: %v", err)
+		}
+		defer db.Close()
+	}
+
+	var gameSource api.GameSource
+	var game api.Game
+	var err error
+	if *gameURL != "" {
+		if gameSource, game, err = lookupGame(*gameURL); err != nil {
+			fatal(exitNetwork, "Error fetching the game: %v", err)
+		}
+	} else {
+		if gameSource, err = source.OpenClient(*sourceName, source.DefaultOptions()); err != nil {
+			log.Fatalf("Error selecting game source: %v", err)
+		}
+		if game, err = lastLoss(cachedSource(gameSource, db), *user, time.Now()); err != nil {
+			fatal(exitNoGames, "Error finding the game: %v", err)
+		}
+	}
+	player, opponent, err := gameOpponent(game, *user)
+	if err != nil {
+		log.Fatalf("Error in the game: %v", err)
+	}
+	fmt.Printf("Rating %s (%d) from %s's game of %s (%s): %s\n", opponent.Username, opponent.Rating,
+		player.Username, time.Unix(game.EndTime, 0).Format("2006-01-02"), game.TimeClass, game.URL)
+
+	fmt.Printf("Fetching %s's recent %s games...\n", opponent.Username, game.TimeClass)
+	history := opponentHistory(cachedSource(gameSource, db), opponent.Username, game, *count, *months, time.Now())
+	if len(history) == 0 {
+		fatal(exitNoGames, "No other %s games of %s were found to compare with.", game.TimeClass, opponent.Username)
+	}
+
+	preset, err := gameengine.LookupPreset(*presetName)
+	if err != nil {
+		log.Fatalf("Error selecting preset: %v", err)
+	}
+	if *depth > 0 {
+		preset = preset.WithDepth(*depth)
+	}
+	analyser, err := perf.resolve(false).startEngine(enginePath, preset)
+	if err != nil {
+		fatal(exitEngine, "Error starting Stockfish analyser: %v", err)
+	}
+	defer analyser.Close()
+	if db != nil {
+		analyser.SetAnalysisStore(db)
+	}
+	analyser.CachePositions(db)
+
+	fmt.Printf("Analysing the game and %d of %s's games with preset '%s'...\n", len(history), opponent.Username, preset.Name)
+	performance, err := gamePerformance(analyser, game, opponent.Username)
+	if err != nil {
+		fatal(exitEngine, "Error analysing the game: %v", err)
+	}
+	var performances []stats.GamePerformance
+	for i, other := range history {
+		p, err := gamePerformance(analyser, other, opponent.Username)
+		if err != nil {
+			log.Printf("Skipping %s: %v", other.URL, err)
+			continue
+		}
+		fmt.Printf("[%d/%d] %s: accuracy %.1f, %d/%d engine moves\n", i+1, len(history),
+			time.Unix(other.EndTime, 0).Format("2006-01-02"), p.Accuracy, p.BestMoves, p.Moves)
+		performances = append(performances, p)
+	}
+	if len(performances) == 0 {
+		fatal(exitEngine, "None of %s's games could be analysed.", opponent.Username)
+	}
+
+	check := stats.ComparePerformance(performance, performances)
+	fmt.Printf("\n--- %s in the game, against their last %d %s games ---\n", opponent.Username, check.History, game.TimeClass)
+	check.Write(os.Stdout)
+	if unusual := check.Unusual(); len(unusual) > 0 {
+		fmt.Printf("Verdict: unusually strong for them: %s.\n", strings.Join(unusual, "; "))
+		fmt.Println("Strong games happen to everyone; one game proves nothing. Report real suspicions to the site's fair-play team.")
+	} else {
+		fmt.Println("Verdict: in line with their history.")
+	}
+}
+
+// cachedSource returns source reading through the game database, if there is one.
+func cachedSource(source api.GameSource, db gamedb.Store) api.GameSource {
+	if db == nil {
+		return source
+	}
+	return &gamedb.CachedSource{Source: source, DB: db}
+}
+
+// lookupGame fetches the game at a Chess.com or Lichess URL, along with the source it came from.
+func lookupGame(gameURL string) (api.GameSource, api.Game, error) {
+	if parsed, err := url.Parse(gameURL); err == nil && strings.HasSuffix(parsed.Host, "lichess.org") {
+		id, err := lichess.ParseGameID(gameURL)
+		if err != nil {
+			return nil, api.Game{}, err
+		}
+		client := lichess.NewClient()
+		game, err := client.ExportGame(id)
+		if err != nil {
+			return nil, api.Game{}, err
+		}
+		return client, game.ToGame(), nil
+	}
+	client := api.NewClient()
+	game, err := client.FetchGameByURL(gameURL)
+	if err != nil {
+		return nil, api.Game{}, err
+	}
+	return client, *game, nil
+}
+
+// lastLoss returns the most recent game user lost this month or last month.
+func lastLoss(source api.GameSource, user string, now time.Time) (api.Game, error) {
+	from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, -1, 0)
+	games, err := source.FetchGames(user, from, now)
+	if err != nil {
+		log.Printf("Some games could not be fetched: %v", err)
+	}
+	var last *api.Game
+	for i, game := range games {
+		if outcome, ok := stats.Outcome(game, user); ok && outcome == -1 && (last == nil || game.EndTime > last.EndTime) {
+			last = &games[i]
+		}
+	}
+	if last == nil {
+		return api.Game{}, fmt.Errorf("%s lost no game since %s; pass one with --game", user, from.Format("2006-01"))
+	}
+	return *last, nil
+}
+
+// gameOpponent returns the user's side of the game and their opponent's. Without a user, the
+// loser of the game is taken for the user.
+func gameOpponent(game api.Game, user string) (api.Player, api.Player, error) {
+	switch {
+	case user != "" && strings.EqualFold(game.White.Username, user):
+		return game.White, game.Black, nil
+	case user != "" && strings.EqualFold(game.Black.Username, user):
+		return game.Black, game.White, nil
+	case user != "":
+		return api.Player{}, api.Player{}, fmt.Errorf("%s did not play %s", user, game.URL)
+	}
+	switch outcome, ok := stats.Outcome(game, ""); {
+	case ok && outcome == 1:
+		return game.Black, game.White, nil
+	case ok && outcome == -1:
+		return game.White, game.Black, nil
+	default:
+		return api.Player{}, api.Player{}, fmt.Errorf("%s was not lost by either side; pass your username with --user", game.URL)
+	}
+}
+
+// opponentHistory returns up to count of the opponent's most recent games with the time class and
+// rules of game, leaving game itself out. It fetches a month at a time, newest first, for at most
+// months months.
+func opponentHistory(source api.GameSource, opponent string, game api.Game, count, months int, now time.Time) []api.Game {
+	key := api.GameKey(game.URL)
+	var history []api.Game
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < months && len(history) < count; i, month = i+1, month.AddDate(0, -1, 0) {
+		games, err := source.FetchGames(opponent, month, month.AddDate(0, 1, 0).Add(-time.Second))
+		if err != nil {
+			log.Printf("Some of %s's games could not be fetched: %v", opponent, err)
+		}
+		for _, other := range games {
+			if other.TimeClass == game.TimeClass && cmp.Or(other.Rules, "chess") == cmp.Or(game.Rules, "chess") &&
+				api.GameKey(other.URL) != key {
+				history = append(history, other)
+			}
+		}
+	}
+	slices.SortStableFunc(history, func(a, b api.Game) int { return cmp.Compare(b.EndTime, a.EndTime) })
+	return history[:min(count, len(history))]
+}
+
+// gamePerformance analyses a game and returns how player played it.
+func gamePerformance(analyser *gameengine.StockfishAnalyser, game api.Game, player string) (stats.GamePerformance, error) {
+	analysis, err := analyser.AnalyseGame(game)
+	if err != nil {
+		return stats.GamePerformance{}, err
+	}
+	if !analysis.IsValid() {
+		return stats.GamePerformance{}, fmt.Errorf("invalid analysis: %s", strings.Join(analysis.Issues, "; "))
+	}
+	side, black := gameengine.GameAccuracy(game.PGN, analysis)
+	if strings.EqualFold(game.Black.Username, player) {
+		side = black
+	}
+	if side.Moves == 0 {
+		return stats.GamePerformance{}, fmt.Errorf("no analysed moves of %s", player)
+	}
+	return stats.GamePerformance{Accuracy: side.Accuracy, Moves: side.Moves, BestMoves: side.BestMoves}, nil
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"chessAnalyserFree/api"
+	gameengine "chessAnalyserFree/gameEngine"
+	"chessAnalyserFree/report"
+	"chessAnalyserFree/stats"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// reportOutput is where, and in which formats, game reports are written.
+type reportOutput struct {
+	renderer *report.Renderer
+	dir      string // Directory the reports are written to; empty for the current directory.
+	formats  []report.Format
+	// diagramDir is the directory the key positions of reported games are drawn into, if any, as
+	// images of diagramFormat.
+	diagramDir    string
+	diagramFormat string
+	// batchHTML is the file the HTML reports of a whole batch are written to, if any; batch holds
+	// the reports until then.
+	batchHTML string
+	batch     []report.GameReport
+}
+
+// writeGameReports renders the reports for an analysed game into the output directory.
+func writeGameReports(output *reportOutput, game api.Game, analysis *gameengine.GameAnalysis, gameNum int) {
+	for _, path := range saveGameReports(output, game, analysis, gameNum) {
+		fmt.Printf("Report written to %s\n", path)
+	}
+}
+
+// saveGameReports renders the reports for an analysed game into the output directory and returns
+// the paths written. Errors are logged.
+func saveGameReports(output *reportOutput, game api.Game, analysis *gameengine.GameAnalysis, gameNum int) []string {
+	if output.dir != "" {
+		if err := os.MkdirAll(output.dir, 0o755); err != nil {
+			log.Printf("Error creating %s: %v", output.dir, err)
+			return nil
+		}
+	}
+	var paths []string
+	gameReport := report.GameReport{Game: game, Moves: analysis.Moves, Preset: analysis.Preset}
+	diagramDir, diagrams := saveKeyDiagrams(output, game, analysis, gameNum)
+	gameReport.Diagrams = diagrams
+	if output.batchHTML != "" {
+		output.batch = append(output.batch, gameReport)
+	}
+	for _, format := range output.formats {
+		path := filepath.Join(output.dir, fmt.Sprintf("game-%d.%s", gameNum, format))
+		if err := output.renderer.WriteFile(path, format, gameReport); err != nil {
+			log.Printf("Error writing %s report: %v", format, err)
+			continue
+		}
+		paths = append(paths, path)
+	}
+	if diagramDir != "" {
+		paths = append(paths, diagramDir+string(filepath.Separator))
+	}
+	return paths
+}
+
+// writeBatchHTML writes the HTML reports of the batch collected so far, with the accuracy trend if
+// not nil, into one page, if asked to.
+func (output *reportOutput) writeBatchHTML(title string, trend *stats.AccuracyTrend) {
+	if output.batchHTML == "" || len(output.batch) == 0 {
+		return
+	}
+	if dir := filepath.Dir(output.batchHTML); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			log.Printf("Error creating %s: %v", dir, err)
+			return
+		}
+	}
+	if err := output.renderer.WriteBatchFile(output.batchHTML, title, output.batch, trend); err != nil {
+		log.Printf("Error writing the batch report: %v", err)
+		return
+	}
+	fmt.Printf("Batch report of %d games written to %s\n", len(output.batch), output.batchHTML)
+}
+
+// writeGameCSV writes the per-move analysis of a game to game-<n>.csv in dir, or in the current
+// directory if dir is empty.
+func writeGameCSV(dir string, game api.Game, analysis *gameengine.GameAnalysis, gameNum int) {
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			log.Printf("Error creating %s: %v", dir, err)
+			return
+		}
+	}
+	path := filepath.Join(dir, fmt.Sprintf("game-%d.csv", gameNum))
+	file, err := os.Create(path)
+	if err != nil {
+		log.Printf("Error writing CSV: %v", err)
+		return
+	}
+	defer file.Close()
+	csvWriter, err := report.NewCSVWriter(file, true)
+	if err == nil {
+		err = csvWriter.WriteGame(game, analysis)
+	}
+	if err != nil {
+		log.Printf("Error writing %s: %v", path, err)
+		return
+	}
+	fmt.Printf("CSV written to %s\n", path)
+}
+
+// addToCollection appends an analysed game to the PGN collection, reporting whether it was new.
+func addToCollection(collection *report.PGNCollection, game api.Game, analysis *gameengine.GameAnalysis) {
+	added, err := collection.Add(game, analysis)
+	switch {
+	case err != nil:
+		log.Printf("Error adding the game to the PGN collection: %v", err)
+	case added:
+		fmt.Printf("Added to the PGN collection (%d games).\n", collection.Len())
+	default:
+		fmt.Println("The PGN collection already holds this game.")
+	}
+}
+
+// csvExportFile is a CSV file opened for appending.
+type csvExportFile struct {
+	*os.File
+	empty bool // Whether the file was new or empty, and so needs a header row.
+}
+
+// openCSVExport opens a CSV file for appending, creating it if needed, so that resumed or repeated
+// batch runs add their rows to the same file.
+func openCSVExport(path string) (*csvExportFile, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &csvExportFile{File: file, empty: info.Size() == 0}, nil
+}
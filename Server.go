@@ -0,0 +1,818 @@
+package main
+
+import (
+	"bytes"
+	"chessAnalyserFree/api"
+	gamedb "chessAnalyserFree/gameDB"
+	gameengine "chessAnalyserFree/gameEngine"
+	"chessAnalyserFree/lichess"
+	pgnimport "chessAnalyserFree/pgnImport"
+	"chessAnalyserFree/report"
+	"chessAnalyserFree/stats"
+	"cmp"
+	"context"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// userNameRegex matches the names users can register with.
+var userNameRegex = regexp.MustCompile(`^[a-zA-Z0-9_-]{2,32}$`)
+
+// passwordIterations is the PBKDF2 iteration count of new password hashes.
+const passwordIterations = 600_000
+
+// gameJobLifetime is how long the result of a single game analysis is kept after it finished.
+const gameJobLifetime = 24 * time.Hour
+
+// analysisJob analyses the games a user's linked accounts finished in a month, or a single game.
+type analysisJob struct {
+	user  string
+	month time.Time
+	game  *gameJob // A single game to analyse instead of a month.
+}
+
+// gameJob is a single game queued by POST /api/analyse. Its fields are guarded by the server's mutex.
+type gameJob struct {
+	id       string
+	user     string
+	url      string // Game to fetch, or empty when the game was given as PGN.
+	game     api.Game
+	status   string // "queued", "running", "done" or "failed".
+	err      string
+	analysis json.RawMessage // The analysis as a JSON Lines export object, once done.
+	finished time.Time
+	moves    []any         // Moves evaluated so far, as JSON Lines export move objects.
+	updated  chan struct{} // Closed and replaced whenever the job changes, to wake live streams.
+}
+
+// notify wakes the live streams of a job. The server's mutex must be held.
+func (job *gameJob) notify() {
+	close(job.updated)
+	job.updated = make(chan struct{})
+}
+
+// gameServer is a small self-hosted game review service: registered users link their Chess.com
+// and Lichess accounts, queue months of their games for analysis, and read the reports of the
+// analysed games. Other apps can also look up any player's games and analyse single games given by
+// URL or PGN. Every request is scoped to the authenticated user. Analyses run one at a time
+// on a single engine.
+type gameServer struct {
+	db           gamedb.Store
+	users        gamedb.Users
+	analyser     *gameengine.StockfishAnalyser
+	renderer     *report.Renderer
+	registration bool
+	jobs         chan analysisJob
+
+	mu       sync.Mutex
+	pending  map[string][]string // Months queued or being analysed, as YYYY-MM, by user.
+	gameJobs map[string]*gameJob // Single game analyses by ID, until gameJobLifetime after they finish.
+}
+
+// runServer implements the serve subcommand, which serves the game review API over HTTP until
+// interrupted.
+func runServer(arguments []string, defaults *userConfig) {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := flags.String("addr", ":8080", "address to listen on")
+	dbPath := flags.String("db", defaults.DB, "game database: a postgres:// URL, or an SQLite file (.sqlite) for a single machine")
+	presetName := flags.String("preset", cmp.Or(defaults.Preset, gameengine.DefaultPresetName), "analysis preset: "+strings.Join(gameengine.PresetNames(), ", "))
+	templatesDir := flags.String("templates", defaults.Output.Templates, "directory with report templates overriding the built-in ones")
+	registration := flags.Bool("registration", true, "let anyone register an account")
+	queueSize := flags.Int("queue", 100, "number of analysis requests that can wait for the engine")
+	perf := addPerformanceFlags(flags, false)
+	flags.Parse(arguments)
+	if err := defaults.applyFlags(flags); err != nil {
+		log.Fatalf("Error in configuration: %v", err)
+	}
+	enginePath, ok := defaults.engineArg(flags)
+	if !ok || *dbPath == "" {
+		fmt.Println("Usage: go run . serve --db <postgres://...> [--addr :8080] [--preset standard] [--registration=false] [<path_to_stockfish>]")
+		flags.PrintDefaults()
+		return
+	}
+
+	preset, err := gameengine.LookupPreset(*presetName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if defaults.Depth > 0 {
+		preset = preset.WithDepth(defaults.Depth)
+	}
+	db, err := gamedb.Open(*dbPath)
+	if err != nil {
+		log.Fatalf("Error opening game database: %v", err)
+	}
+	defer db.Close()
+	users, ok := db.(gamedb.Users)
+	if !ok {
+		log.Fatal("Server mode needs a PostgreSQL or SQLite game database to keep its users in.")
+	}
+
+	s := &gameServer{
+		db:           db,
+		users:        users,
+		registration: *registration,
+		jobs:         make(chan analysisJob, max(*queueSize, 1)),
+		pending:      make(map[string][]string),
+		gameJobs:     make(map[string]*gameJob),
+	}
+	if s.renderer, err = report.NewRenderer(*templatesDir); err != nil {
+		log.Fatalf("Error loading report templates: %v", err)
+	}
+	if s.analyser, err = perf.resolve(false).startEngine(enginePath, preset); err != nil {
+		fatal(exitEngine, "Error starting Stockfish analyser: %v", err)
+	}
+	defer s.analyser.Close()
+	s.analyser.SetAnalysisStore(db)
+	s.analyser.CachePositions(db)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go s.work(ctx)
+
+	server := &http.Server{Addr: *addr, Handler: s.routes()}
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdown)
+	}()
+	// Made now, so that the first unknown user is not answered more slowly than the others.
+	dummyPasswordHash()
+	log.Printf("Serving on %s with %s (preset: %s).", *addr, s.analyser.EngineName(), preset.Name)
+	if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		log.Fatalf("Server failed: %v", err)
+	}
+	log.Println("Server stopped.")
+}
+
+// routes returns the handler of the API.
+func (s *gameServer) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/register", s.register)
+	mux.HandleFunc("GET /api/accounts", s.authenticated(s.listAccounts))
+	mux.HandleFunc("POST /api/accounts", s.authenticated(s.linkAccount))
+	mux.HandleFunc("DELETE /api/accounts/{source}/{username}", s.authenticated(s.unlinkAccount))
+	mux.HandleFunc("GET /api/analyses", s.authenticated(s.listAnalyses))
+	mux.HandleFunc("POST /api/analyses", s.authenticated(s.queueAnalysis))
+	mux.HandleFunc("GET /api/games", s.authenticated(s.listGames))
+	mux.HandleFunc("GET /api/report", s.authenticated(s.gameReport))
+	mux.HandleFunc("GET /api/players/{username}/games", s.authenticated(s.playerGames))
+	mux.HandleFunc("POST /api/analyse", s.authenticated(s.queueGame))
+	mux.HandleFunc("GET /api/analysis/{id}", s.authenticated(s.gameAnalysis))
+	mux.HandleFunc("GET /api/analysis/{id}/live", s.authenticated(s.liveAnalysis))
+	return mux
+}
+
+// authenticated wraps a handler with HTTP basic authentication against the registered users.
+func (s *gameServer) authenticated(handler func(w http.ResponseWriter, r *http.Request, user string)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name, password, ok := r.BasicAuth()
+		if ok {
+			hash, err := s.users.PasswordHash(name)
+			if err != nil {
+				log.Printf("Error looking up user %s: %v", name, err)
+				writeError(w, http.StatusInternalServerError, "failed to look up the user")
+				return
+			}
+			// An unknown user's password is checked against a hash too, so that the time the answer
+			// takes does not tell which names are registered.
+			known := hash != ""
+			if !known {
+				hash = dummyPasswordHash()
+			}
+			if checkPassword(hash, password) && known {
+				handler(w, r, strings.ToLower(name))
+				return
+			}
+		}
+		w.Header().Set("WWW-Authenticate", `Basic realm="chessanalyser"`)
+		writeError(w, http.StatusUnauthorized, "unknown user or wrong password")
+	}
+}
+
+// register handles POST /api/register with {"name": ..., "password": ...}.
+func (s *gameServer) register(w http.ResponseWriter, r *http.Request) {
+	if !s.registration {
+		writeError(w, http.StatusForbidden, "registration is closed")
+		return
+	}
+	var request struct {
+		Name     string `json:"name"`
+		Password string `json:"password"`
+	}
+	if !readJSON(w, r, &request) {
+		return
+	}
+	if !userNameRegex.MatchString(request.Name) {
+		writeError(w, http.StatusBadRequest, "names have 2 to 32 letters, digits, '_' or '-'")
+		return
+	}
+	if len(request.Password) < 8 {
+		writeError(w, http.StatusBadRequest, "passwords need at least 8 characters")
+		return
+	}
+	hash, err := hashPassword(request.Password)
+	if err == nil {
+		err = s.users.CreateUser(request.Name, hash)
+	}
+	if errors.Is(err, gamedb.ErrUserExists) {
+		writeError(w, http.StatusConflict, "the name is taken")
+		return
+	}
+	if err != nil {
+		log.Printf("Error registering %s: %v", request.Name, err)
+		writeError(w, http.StatusInternalServerError, "failed to register")
+		return
+	}
+	log.Printf("User %s registered.", strings.ToLower(request.Name))
+	writeJSON(w, http.StatusCreated, map[string]string{"name": strings.ToLower(request.Name)})
+}
+
+// listAccounts handles GET /api/accounts.
+func (s *gameServer) listAccounts(w http.ResponseWriter, r *http.Request, user string) {
+	accounts, err := s.users.Accounts(user)
+	if err != nil {
+		log.Printf("Error listing accounts of %s: %v", user, err)
+		writeError(w, http.StatusInternalServerError, "failed to list the accounts")
+		return
+	}
+	if accounts == nil {
+		accounts = []gamedb.Account{}
+	}
+	writeJSON(w, http.StatusOK, accounts)
+}
+
+// linkAccount handles POST /api/accounts with {"source": "chesscom" or "lichess", "username": ...}.
+func (s *gameServer) linkAccount(w http.ResponseWriter, r *http.Request, user string) {
+	var account gamedb.Account
+	if !readJSON(w, r, &account) {
+		return
+	}
+	if account.Source != "chesscom" && account.Source != "lichess" {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("unknown game source %q (available: chesscom, lichess)", account.Source))
+		return
+	}
+	if account.Username == "" {
+		writeError(w, http.StatusBadRequest, "a username is needed")
+		return
+	}
+	account.Username = strings.ToLower(account.Username)
+	if err := s.users.LinkAccount(user, account); err != nil {
+		log.Printf("Error linking %s/%s to %s: %v", account.Source, account.Username, user, err)
+		writeError(w, http.StatusInternalServerError, "failed to link the account")
+		return
+	}
+	writeJSON(w, http.StatusCreated, account)
+}
+
+// unlinkAccount handles DELETE /api/accounts/{source}/{username}. Games already analysed stay.
+func (s *gameServer) unlinkAccount(w http.ResponseWriter, r *http.Request, user string) {
+	account := gamedb.Account{Source: r.PathValue("source"), Username: strings.ToLower(r.PathValue("username"))}
+	removed, err := s.users.UnlinkAccount(user, account)
+	switch {
+	case err != nil:
+		log.Printf("Error unlinking %s/%s from %s: %v", account.Source, account.Username, user, err)
+		writeError(w, http.StatusInternalServerError, "failed to unlink the account")
+	case !removed:
+		writeError(w, http.StatusNotFound, "the account is not linked")
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// listAnalyses handles GET /api/analyses, listing the user's months waiting for or in analysis.
+func (s *gameServer) listAnalyses(w http.ResponseWriter, r *http.Request, user string) {
+	s.mu.Lock()
+	months := append([]string{}, s.pending[user]...)
+	s.mu.Unlock()
+	writeJSON(w, http.StatusOK, map[string][]string{"pending": months})
+}
+
+// queueAnalysis handles POST /api/analyses with {"month": "YYYY-MM"}, queueing the games the user's
+// linked accounts finished that month for analysis.
+func (s *gameServer) queueAnalysis(w http.ResponseWriter, r *http.Request, user string) {
+	var request struct {
+		Month string `json:"month"`
+	}
+	if !readJSON(w, r, &request) {
+		return
+	}
+	month, err := time.Parse("2006-01", request.Month)
+	if err != nil || !monthArgRegex.MatchString(request.Month) {
+		writeError(w, http.StatusBadRequest, "the month must be given as YYYY-MM")
+		return
+	}
+	if accounts, err := s.users.Accounts(user); err != nil || len(accounts) == 0 {
+		writeError(w, http.StatusBadRequest, "link a Chess.com or Lichess account first")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if slices.Contains(s.pending[user], request.Month) {
+		writeJSON(w, http.StatusAccepted, map[string]string{"queued": request.Month})
+		return
+	}
+	select {
+	case s.jobs <- analysisJob{user: user, month: month}:
+		s.pending[user] = append(s.pending[user], request.Month)
+		writeJSON(w, http.StatusAccepted, map[string]string{"queued": request.Month})
+	default:
+		writeError(w, http.StatusServiceUnavailable, "the analysis queue is full; try again later")
+	}
+}
+
+// serverGame is a game listed by GET /api/games.
+type serverGame struct {
+	URL        string    `json:"url"`
+	White      string    `json:"white"`
+	Black      string    `json:"black"`
+	EndTime    time.Time `json:"end_time"`
+	TimeClass  string    `json:"time_class"`
+	AnalysedAt time.Time `json:"analysed_at"`
+}
+
+// listGames handles GET /api/games, listing the games analysed for the user.
+func (s *gameServer) listGames(w http.ResponseWriter, r *http.Request, user string) {
+	userGames, err := s.users.UserGames(user)
+	if err != nil {
+		log.Printf("Error listing games of %s: %v", user, err)
+		writeError(w, http.StatusInternalServerError, "failed to list the games")
+		return
+	}
+	urls := make([]string, len(userGames))
+	for i, game := range userGames {
+		urls[i] = game.URL
+	}
+	games, err := s.db.GamesByURL(urls)
+	if err != nil {
+		log.Printf("Error reading games of %s: %v", user, err)
+		writeError(w, http.StatusInternalServerError, "failed to read the games")
+		return
+	}
+	byURL := make(map[string]api.Game, len(games))
+	for _, game := range games {
+		byURL[game.URL] = game
+	}
+	list := []serverGame{}
+	for _, userGame := range userGames {
+		game, ok := byURL[userGame.URL]
+		if !ok {
+			continue
+		}
+		list = append(list, serverGame{
+			URL:        game.URL,
+			White:      game.White.Username,
+			Black:      game.Black.Username,
+			EndTime:    time.Unix(game.EndTime, 0),
+			TimeClass:  game.TimeClass,
+			AnalysedAt: userGame.AnalysedAt,
+		})
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// gameReport handles GET /api/report?url=<game URL>&format=html|md|pdf, rendering the report of a game
+// analysed for the user. Games analysed only for other users are not found.
+func (s *gameServer) gameReport(w http.ResponseWriter, r *http.Request, user string) {
+	format, err := report.ParseFormat(cmp.Or(r.URL.Query().Get("format"), "html"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	url := r.URL.Query().Get("url")
+	userGame, err := s.users.UserGame(user, url)
+	if err != nil {
+		log.Printf("Error looking up game %s of %s: %v", url, user, err)
+		writeError(w, http.StatusInternalServerError, "failed to look up the game")
+		return
+	}
+	if userGame == nil {
+		writeError(w, http.StatusNotFound, "no analysis of this game")
+		return
+	}
+	games, err := s.db.GamesByURL([]string{url})
+	if err != nil || len(games) == 0 {
+		writeError(w, http.StatusNotFound, "the game is no longer stored; analyse its month again")
+		return
+	}
+	analysis, err := s.db.LoadAnalysis(url, userGame.Settings)
+	if err != nil || analysis == nil {
+		writeError(w, http.StatusNotFound, "the analysis is no longer stored; analyse the game's month again")
+		return
+	}
+
+	contentType := "text/html; charset=utf-8"
+	switch format {
+	case report.FormatMarkdown:
+		contentType = "text/markdown; charset=utf-8"
+	case report.FormatPDF:
+		contentType = "application/pdf"
+	}
+	w.Header().Set("Content-Type", contentType)
+	gameReport := report.GameReport{Game: games[0], Moves: analysis.Moves, Preset: analysis.Preset}
+	if err := s.renderer.Render(w, format, gameReport); err != nil {
+		log.Printf("Error rendering the report of %s: %v", url, err)
+	}
+}
+
+// playerGame is a game listed by GET /api/players/{username}/games.
+type playerGame struct {
+	URL       string    `json:"url"`
+	White     string    `json:"white"`
+	Black     string    `json:"black"`
+	EndTime   time.Time `json:"end_time"`
+	TimeClass string    `json:"time_class"`
+	Result    string    `json:"result"` // "win", "draw" or "loss" for the player.
+}
+
+// playerGames handles GET /api/players/{username}/games?source=chesscom|lichess&month=YYYY-MM,
+// listing the games any player finished in a month, by default the current one, on Chess.com or
+// Lichess. Fetched months are stored in the game database like those of linked accounts.
+func (s *gameServer) playerGames(w http.ResponseWriter, r *http.Request, user string) {
+	username := strings.ToLower(r.PathValue("username"))
+	var source api.GameSource
+	switch sourceName := cmp.Or(r.URL.Query().Get("source"), "chesscom"); sourceName {
+	case "chesscom":
+		source = api.NewClient()
+	case "lichess":
+		source = lichess.NewClient()
+	default:
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("unknown game source %q (available: chesscom, lichess)", sourceName))
+		return
+	}
+	now := time.Now()
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	if monthText := r.URL.Query().Get("month"); monthText != "" {
+		var err error
+		month, err = time.Parse("2006-01", monthText)
+		if err != nil || !monthArgRegex.MatchString(monthText) {
+			writeError(w, http.StatusBadRequest, "the month must be given as YYYY-MM")
+			return
+		}
+	}
+	source = &gamedb.CachedSource{Source: source, DB: s.db}
+	games, err := source.FetchGames(username, month, month.AddDate(0, 1, 0).Add(-time.Second))
+	if err != nil && len(games) == 0 {
+		log.Printf("Error fetching games of %s for %s: %v", username, user, err)
+		writeError(w, http.StatusBadGateway, "failed to fetch the games")
+		return
+	}
+	list := []playerGame{}
+	for _, game := range games {
+		result := "draw"
+		if outcome, _ := stats.Outcome(game, username); outcome > 0 {
+			result = "win"
+		} else if outcome < 0 {
+			result = "loss"
+		}
+		list = append(list, playerGame{
+			URL:       game.URL,
+			White:     game.White.Username,
+			Black:     game.Black.Username,
+			EndTime:   time.Unix(game.EndTime, 0),
+			TimeClass: game.TimeClass,
+			Result:    result,
+		})
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// queueGame handles POST /api/analyse with {"url": <Chess.com or Lichess game URL>} or
+// {"pgn": <PGN of a game>}, queueing the game for analysis and answering with the ID to follow it
+// with. Games given as PGN are analysed without a URL, so their analyses are not stored.
+func (s *gameServer) queueGame(w http.ResponseWriter, r *http.Request, user string) {
+	var request struct {
+		URL string `json:"url"`
+		PGN string `json:"pgn"`
+	}
+	if !readJSON(w, r, &request) {
+		return
+	}
+	job := &gameJob{user: user, status: "queued", updated: make(chan struct{})}
+	switch {
+	case (request.URL == "") == (request.PGN == ""):
+		writeError(w, http.StatusBadRequest, "give either the url or the pgn of a game")
+		return
+	case request.URL != "":
+		if _, _, err := api.ParseGameURL(request.URL); err != nil {
+			if _, err := lichess.ParseGameID(request.URL); err != nil {
+				writeError(w, http.StatusBadRequest, "not a Chess.com or Lichess game URL")
+				return
+			}
+		}
+		job.url = request.URL
+	default:
+		job.game = pgnimport.GameFromPGN(request.PGN, "")
+		// A URL from the PGN's tags would let the analysis of any text replace that of the real game.
+		job.game.URL = ""
+		if _, err := gameengine.ReplayPositions(job.game.PGN); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid PGN: "+err.Error())
+			return
+		}
+	}
+	job.id = rand.Text()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, old := range s.gameJobs {
+		if !old.finished.IsZero() && time.Since(old.finished) > gameJobLifetime {
+			delete(s.gameJobs, id)
+		}
+	}
+	select {
+	case s.jobs <- analysisJob{user: user, game: job}:
+		s.gameJobs[job.id] = job
+		writeJSON(w, http.StatusAccepted, map[string]string{"id": job.id, "status": job.status})
+	default:
+		writeError(w, http.StatusServiceUnavailable, "the analysis queue is full; try again later")
+	}
+}
+
+// gameAnalysis handles GET /api/analysis/{id}, answering with the status of a game queued by the
+// user and, once done, its analysis in the object format of the JSON Lines export.
+func (s *gameServer) gameAnalysis(w http.ResponseWriter, r *http.Request, user string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.gameJobs[r.PathValue("id")]
+	if !ok || job.user != user {
+		writeError(w, http.StatusNotFound, "no such analysis")
+		return
+	}
+	writeJSON(w, http.StatusOK, struct {
+		ID       string          `json:"id"`
+		Status   string          `json:"status"`
+		Error    string          `json:"error,omitempty"`
+		Analysis json.RawMessage `json:"analysis,omitempty"`
+	}{job.id, job.status, job.err, job.analysis})
+}
+
+// liveMessage is a message of GET /api/analysis/{id}/live.
+type liveMessage struct {
+	Type     string          `json:"type"` // "status", "move", "done" or "failed".
+	Status   string          `json:"status,omitempty"`
+	Move     any             `json:"move,omitempty"`
+	Error    string          `json:"error,omitempty"`
+	Analysis json.RawMessage `json:"analysis,omitempty"`
+}
+
+// liveAnalysis handles GET /api/analysis/{id}/live, a WebSocket streaming the analysis of a game
+// queued by the user as the engine produces it: a "status" message when the game waits or starts,
+// a "move" message with every move evaluated, and finally "done" with the whole analysis, moves
+// classified, or "failed" with the error. A stream opened late first catches up on the moves
+// evaluated so far. The server closes the connection after the last message.
+func (s *gameServer) liveAnalysis(w http.ResponseWriter, r *http.Request, user string) {
+	s.mu.Lock()
+	job, ok := s.gameJobs[r.PathValue("id")]
+	s.mu.Unlock()
+	if !ok || job.user != user {
+		writeError(w, http.StatusNotFound, "no such analysis")
+		return
+	}
+	ws, err := upgradeWebSocket(w, r)
+	if err != nil {
+		return
+	}
+	defer ws.Close()
+	disconnected := make(chan struct{})
+	go func() {
+		ws.ReadUntilClosed()
+		close(disconnected)
+	}()
+
+	sent, status := 0, ""
+	for {
+		s.mu.Lock()
+		var messages []liveMessage
+		if job.status != status && (job.status == "queued" || job.status == "running") {
+			messages = append(messages, liveMessage{Type: "status", Status: job.status})
+		}
+		status = job.status
+		for _, move := range job.moves[sent:] {
+			messages = append(messages, liveMessage{Type: "move", Move: move})
+		}
+		sent = len(job.moves)
+		switch job.status {
+		case "done":
+			messages = append(messages, liveMessage{Type: "done", Analysis: job.analysis})
+		case "failed":
+			messages = append(messages, liveMessage{Type: "failed", Error: job.err})
+		}
+		updated := job.updated
+		s.mu.Unlock()
+
+		for _, message := range messages {
+			if err := ws.WriteJSON(message); err != nil {
+				return
+			}
+		}
+		if status == "done" || status == "failed" {
+			return
+		}
+		select {
+		case <-updated:
+		case <-disconnected:
+			return
+		}
+	}
+}
+
+// work runs the queued analyses until ctx is done.
+func (s *gameServer) work(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case job := <-s.jobs:
+			if job.game != nil {
+				s.analyseSingleGame(job.game)
+				continue
+			}
+			s.analyseMonth(job)
+			month := job.month.Format("2006-01")
+			s.mu.Lock()
+			s.pending[job.user] = slices.DeleteFunc(s.pending[job.user], func(m string) bool { return m == month })
+			if len(s.pending[job.user]) == 0 {
+				delete(s.pending, job.user)
+			}
+			s.mu.Unlock()
+		}
+	}
+}
+
+// analyseMonth fetches the games the user's linked accounts finished in the job's month and
+// analyses them, recording each valid analysis for the user. Games analysed before with the same
+// settings, for this or another user, are read from the database.
+func (s *gameServer) analyseMonth(job analysisJob) {
+	accounts, err := s.users.Accounts(job.user)
+	if err != nil {
+		log.Printf("Job %s %s failed: %v", job.user, job.month.Format("2006-01"), err)
+		return
+	}
+	end := job.month.AddDate(0, 1, 0).Add(-time.Second)
+	analysed := 0
+	for _, account := range accounts {
+		var source api.GameSource = api.NewClient()
+		if account.Source == "lichess" {
+			source = lichess.NewClient()
+		}
+		source = &gamedb.CachedSource{Source: source, DB: s.db}
+		games, err := source.FetchGames(account.Username, job.month, end)
+		if err != nil {
+			log.Printf("Job %s %s: some games of %s/%s could not be fetched: %v", job.user, job.month.Format("2006-01"),
+				account.Source, account.Username, err)
+		}
+		for _, game := range games {
+			if game.URL == "" {
+				continue
+			}
+			analysis, err := s.analyser.AnalyseGame(game)
+			if err != nil || !analysis.IsValid() {
+				continue
+			}
+			if err := s.users.AddUserGame(job.user, game.URL, s.analyser.SettingsKey(), time.Now()); err != nil {
+				log.Printf("Job %s %s: failed to record %s: %v", job.user, job.month.Format("2006-01"), game.URL, err)
+				continue
+			}
+			analysed++
+		}
+	}
+	log.Printf("Job %s %s finished: %d games analysed.", job.user, job.month.Format("2006-01"), analysed)
+}
+
+// analyseSingleGame fetches the game of a job if it was given by URL, analyses it and keeps the
+// result for GET /api/analysis/{id}.
+func (s *gameServer) analyseSingleGame(job *gameJob) {
+	s.mu.Lock()
+	job.status = "running"
+	job.notify()
+	game := job.game
+	s.mu.Unlock()
+	var encoded bytes.Buffer
+	err := func() error {
+		if job.url != "" {
+			var err error
+			if _, game, err = lookupGame(job.url); err != nil {
+				return fmt.Errorf("failed to fetch the game: %w", err)
+			}
+		}
+		moves, result := s.analyser.AnalyseGameStream(game)
+		for move := range moves {
+			s.mu.Lock()
+			job.moves = append(job.moves, report.MoveObject(move.Index+1, move.FENBefore, move.Move))
+			job.notify()
+			s.mu.Unlock()
+		}
+		analysed := <-result
+		if analysed.Err != nil {
+			return analysed.Err
+		}
+		if !analysed.Analysis.IsValid() {
+			return fmt.Errorf("invalid analysis: %s", strings.Join(analysed.Analysis.Issues, "; "))
+		}
+		return report.NewJSONLWriter(&encoded).WriteGame(game, analysed.Analysis)
+	}()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	defer job.notify()
+	job.status, job.finished = "done", time.Now()
+	if err != nil {
+		job.status, job.err = "failed", err.Error()
+		log.Printf("Game analysis %s of %s failed: %v", job.id, job.user, err)
+		return
+	}
+	job.analysis = bytes.TrimSpace(encoded.Bytes())
+}
+
+// hashPassword returns a salted PBKDF2-SHA256 hash of a password as
+// "pbkdf2-sha256$<iterations>$<salt>$<key>", with salt and key in unpadded base64.
+func hashPassword(password string) (string, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key, err := pbkdf2.Key(sha256.New, password, salt, passwordIterations, 32)
+	if err != nil {
+		return "", err
+	}
+	encoding := base64.RawStdEncoding
+	return fmt.Sprintf("pbkdf2-sha256$%d$%s$%s", passwordIterations, encoding.EncodeToString(salt),
+		encoding.EncodeToString(key)), nil
+}
+
+// dummyPasswordHash is the hash the passwords of unknown users are checked against, made like those
+// of registered users.
+var dummyPasswordHash = sync.OnceValue(func() string {
+	hash, err := hashPassword("")
+	if err != nil {
+		log.Fatalf("Error hashing the dummy password: %v", err)
+	}
+	return hash
+})
+
+// checkPassword reports whether password matches a hash made by hashPassword. An empty hash, of an
+// unknown user, matches nothing.
+func checkPassword(hash, password string) bool {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 4 || parts[0] != "pbkdf2-sha256" {
+		return false
+	}
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil || iterations <= 0 {
+		return false
+	}
+	encoding := base64.RawStdEncoding
+	salt, err := encoding.DecodeString(parts[2])
+	if err != nil {
+		return false
+	}
+	want, err := encoding.DecodeString(parts[3])
+	if err != nil {
+		return false
+	}
+	key, err := pbkdf2.Key(sha256.New, password, salt, iterations, len(want))
+	return err == nil && subtle.ConstantTimeCompare(key, want) == 1
+}
+
+// readJSON decodes a JSON request body into v, answering with an error if it cannot.
+func readJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return false
+	}
+	return true
+}
+
+// writeJSON answers with v as JSON.
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError answers with {"error": message}.
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
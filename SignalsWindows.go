@@ -0,0 +1,8 @@
+//go:build windows
+
+package main
+
+// listenSignals is a no-op on Windows, which has no user signals; use standard input instead.
+func (c *batchControl) listenSignals() func() {
+	return func() {}
+}
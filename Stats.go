@@ -0,0 +1,201 @@
+package main
+
+import (
+	"chessAnalyserFree/api"
+	gameengine "chessAnalyserFree/gameEngine"
+	"chessAnalyserFree/query"
+	"chessAnalyserFree/stats"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// statsCommand carries out the game list's stats command: the results summary of the games shown
+// without arguments, or the statistics named by the first argument. statsPlayer is the player of
+// fetched games, whose results the summary takes; player is the one given with --user, whom the
+// other statistics are about. The accuracy trend, the centipawn loss breakdown, the weakness
+// report, the endgame conversion, the first blunders, the recurring mistakes, the blunders in time
+// trouble and by playing time, the final positions by termination and the accuracy in sessions take
+// the games analysed in the session or before, as found in dataset and earlier.
+func statsCommand(args []string, games []api.Game, statsPlayer, player string, policy stats.Policy, dataset *moveDataset, earlier map[string]*gameengine.GameAnalysis) {
+	if len(args) == 0 {
+		stats.Summarize(games, statsPlayer, policy).Write(os.Stdout)
+		return
+	}
+	switch args[0] {
+	case "repertoire":
+		if player == "" {
+			fmt.Println("The repertoire needs a single player; give --user.")
+			return
+		}
+		colour, plies := "", stats.DefaultRepertoirePlies
+		for _, arg := range args[1:] {
+			if n, err := strconv.Atoi(arg); err == nil && n > 0 {
+				plies = n
+			} else if arg == "white" || arg == "black" {
+				colour = arg
+			} else {
+				fmt.Println("Usage: stats repertoire [white|black] [plies]")
+				return
+			}
+		}
+		stats.BuildRepertoire(games, player, plies, policy).Write(os.Stdout, colour)
+	case "openings":
+		if player == "" {
+			fmt.Println("The openings table needs a single player; give --user.")
+			return
+		}
+		order := ""
+		if len(args) > 1 {
+			order = args[1]
+		}
+		table, err := stats.Openings(games, player, policy, order)
+		if err != nil {
+			fmt.Printf("Invalid order: %v\n", err)
+			return
+		}
+		table.Write(os.Stdout)
+	case "vs":
+		if player == "" || len(args) != 2 {
+			fmt.Println("Usage: stats vs <opponent>, with the player given with --user.")
+			return
+		}
+		stats.HeadToHeadGames(games, player, args[1], policy).Write(os.Stdout)
+	case "upsets":
+		if player == "" {
+			fmt.Println("The upsets need a single player; give --user.")
+			return
+		}
+		gap := stats.DefaultUpsetGap
+		if len(args) > 2 {
+			fmt.Println("Usage: stats upsets [rating gap]")
+			return
+		}
+		if len(args) == 2 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n <= 0 {
+				fmt.Println("Usage: stats upsets [rating gap]")
+				return
+			}
+			gap = n
+		}
+		stats.FindUpsets(games, player, gap, policy).Write(os.Stdout)
+	case "accuracy":
+		if player == "" {
+			fmt.Println("The accuracy trend needs a single player; give --user.")
+			return
+		}
+		stats.BuildAccuracyTrend(dataset.analysed(games, earlier), player).Write(os.Stdout)
+	case "acpl":
+		if player == "" {
+			fmt.Println("The centipawn loss breakdown needs a single player; give --user.")
+			return
+		}
+		stats.BuildACPLBreakdown(dataset.analysed(games, earlier), player).Write(os.Stdout)
+	case "phases":
+		if player == "" {
+			fmt.Println("The weakness report needs a single player; give --user.")
+			return
+		}
+		query.Weaknesses(dataset.analysed(games, earlier), player).Write(os.Stdout)
+	case "clock":
+		if player == "" {
+			fmt.Println("The time-trouble report needs a single player; give --user.")
+			return
+		}
+		query.TimeTrouble(dataset.analysed(games, earlier), player).Write(os.Stdout)
+	case "conversion":
+		if player == "" {
+			fmt.Println("The endgame conversion needs a single player; give --user.")
+			return
+		}
+		query.EndgameConversion(dataset.analysed(games, earlier), player).Write(os.Stdout)
+	case "firstblunder":
+		if player == "" {
+			fmt.Println("The first blunders need a single player; give --user.")
+			return
+		}
+		query.FirstBlunderDistribution(dataset.analysed(games, earlier), player).Write(os.Stdout)
+	case "recurring":
+		if player == "" {
+			fmt.Println("The recurring mistakes need a single player; give --user.")
+			return
+		}
+		query.RecurringMistakes(dataset.analysed(games, earlier), player).Write(os.Stdout)
+	case "colours", "colors":
+		if player == "" {
+			fmt.Println("The results by colour need a single player; give --user.")
+			return
+		}
+		stats.BuildColourStats(games, player, policy).Write(os.Stdout)
+	case "terminations":
+		if player == "" {
+			fmt.Println("The terminations need a single player; give --user.")
+			return
+		}
+		stats.BuildTerminationStats(games, dataset.analysed(games, earlier), player, policy).Write(os.Stdout)
+	case "streaks":
+		if player == "" {
+			fmt.Println("The streaks need a single player; give --user.")
+			return
+		}
+		stats.BuildTiltStats(games, dataset.analysed(games, earlier), player, policy).Write(os.Stdout)
+	case "times":
+		if player == "" {
+			fmt.Println("The playing times need a single player; give --user.")
+			return
+		}
+		stats.BuildPlayingTimes(games, dataset.analysed(games, earlier), player, policy).Write(os.Stdout)
+	default:
+		fmt.Println("Unknown statistics; try 'stats', 'stats repertoire [white|black] [plies]', 'stats openings [frequency|worst]', 'stats vs <opponent>', 'stats upsets [rating gap]', 'stats colours', 'stats accuracy', 'stats acpl', 'stats phases', 'stats conversion', 'stats firstblunder', 'stats recurring', 'stats clock', 'stats terminations', 'stats streaks' or 'stats times'.")
+	}
+}
+
+// exportStats writes the player's statistics over the games, those over analysed games taking the
+// analyses of dataset and earlier, to dir as stats.json and one CSV file per table.
+func exportStats(dir string, games []api.Game, player string, policy stats.Policy, dataset *moveDataset, earlier map[string]*gameengine.GameAnalysis) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	analysed := dataset.analysed(games, earlier)
+	openings, err := stats.Openings(games, player, policy, "frequency")
+	if err != nil {
+		return err
+	}
+	var tables []stats.Table
+	for _, statistic := range []interface{ Tables() []stats.Table }{
+		stats.Summarize(games, player, policy),
+		openings,
+		stats.BuildColourStats(games, player, policy),
+		stats.BuildTerminationStats(games, analysed, player, policy),
+		stats.BuildPlayingTimes(games, analysed, player, policy),
+		stats.BuildTiltStats(games, analysed, player, policy),
+		stats.BuildAccuracyTrend(analysed, player),
+		stats.BuildACPLBreakdown(analysed, player),
+		query.Weaknesses(analysed, player),
+		query.EndgameConversion(analysed, player),
+		query.FirstBlunderDistribution(analysed, player),
+		query.TimeTrouble(analysed, player),
+	} {
+		tables = append(tables, statistic.Tables()...)
+	}
+
+	path := filepath.Join(dir, "stats.json")
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := stats.WriteTablesJSON(file, player, policy, tables); err != nil {
+		file.Close()
+		return fmt.Errorf("error writing %s: %w", path, err)
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	if err := stats.WriteTablesCSV(dir, tables); err != nil {
+		return err
+	}
+	fmt.Printf("Statistics of %s written to %s (stats.json and %d CSV files).\n", player, dir, len(tables))
+	return nil
+}
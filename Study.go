@@ -0,0 +1,47 @@
+package main
+
+import (
+	"chessAnalyserFree/api"
+	gameengine "chessAnalyserFree/gameEngine"
+	"chessAnalyserFree/lichess"
+	"chessAnalyserFree/report"
+	"fmt"
+	"time"
+)
+
+// studyExport pushes analysed games as annotated chapters into a Lichess study.
+type studyExport struct {
+	client  *lichess.Client
+	studyID string
+}
+
+// newStudyExport prepares the export to a study given by ID or URL. It returns nil when no study is set.
+func newStudyExport(study, token string) (*studyExport, error) {
+	if study == "" {
+		return nil, nil
+	}
+	studyID, err := lichess.ParseStudyID(study)
+	if err != nil {
+		return nil, err
+	}
+	if token == "" {
+		return nil, fmt.Errorf("exporting to a study needs a Lichess API token with the study:write scope (--lichess-token or LICHESS_TOKEN)")
+	}
+	client := lichess.NewClient()
+	client.Token = token
+	return &studyExport{client: client, studyID: studyID}, nil
+}
+
+// push adds the annotated game as a new chapter of the study.
+func (e *studyExport) push(game api.Game, analysis *gameengine.GameAnalysis) error {
+	pgn, err := report.AnnotatedPGN(game, analysis)
+	if err != nil {
+		return fmt.Errorf("failed to annotate game: %w", err)
+	}
+	chapter := fmt.Sprintf("%s - %s, %s", game.White.Username, game.Black.Username, time.Unix(game.EndTime, 0).Format("2006-01-02"))
+	if err := e.client.ImportToStudy(e.studyID, chapter, pgn); err != nil {
+		return err
+	}
+	fmt.Printf("Added to https://lichess.org/study/%s\n", e.studyID)
+	return nil
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"chessAnalyserFree/api"
+	gamedb "chessAnalyserFree/gameDB"
+	gameengine "chessAnalyserFree/gameEngine"
+	"chessAnalyserFree/report"
+	"chessAnalyserFree/source"
+	"cmp"
+	"flag"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+)
+
+// runSync implements the sync subcommand, which fetches the games a player finished since the last
+// sync and queues them for a batch analysis. The newest game fetched is remembered in the game
+// database and only moves on once the batch is done, so an interrupted sync is picked up by the
+// next one; running it weekly reviews exactly the week's games.
+func runSync(arguments []string, defaults *userConfig) {
+	flags := flag.NewFlagSet("sync", flag.ExitOnError)
+	user := flags.String("user", defaults.User, "username whose games are synced")
+	sourceName := flags.String("source", "chesscom", "game source: chesscom or lichess")
+	dbPath := flags.String("db", "games.db", "game database remembering the last sync: a bbolt file, an SQLite file (.sqlite) or a postgres:// URL")
+	days := flags.Int("days", 7, "on the first sync of a player, fetch the games of this many days")
+	fetchOnly := flags.Bool("fetch-only", false, "store the new games and move the sync on without analysing them")
+	presetName := flags.String("preset", gameengine.DefaultPresetName, "analysis preset: "+strings.Join(gameengine.PresetNames(), ", "))
+	depth := flags.Int("depth", 0, "search every position to this depth instead of the preset's limit")
+	perf := addPerformanceFlags(flags, true)
+	collectionPath := flags.String("collection", "", "append analysed games with their annotations to this PGN file")
+	templatesDir := flags.String("templates", "", "directory with report template overrides")
+	reportDir := flags.String("report-dir", "", "directory reports are written to (default: the current directory)")
+	reportFormats := flags.String("report-formats", "markdown,html", "comma-separated report formats: markdown, html, pdf")
+	flags.Parse(arguments)
+	if err := defaults.applyFlags(flags); err != nil {
+		log.Fatalf("Error in configuration: %v", err)
+	}
+	enginePath, ok := defaults.engineArg(flags)
+	if *fetchOnly {
+		ok = flags.NArg() == 0
+	}
+	if *user == "" || !ok {
+		fmt.Println("Usage: go run . sync [--user <username>] [--source chesscom] [--db games.db] [--days 7] [--preset standard] [<path_to_stockfish>]")
+		fmt.Println("       go run . sync --fetch-only [--user <username>] [--source chesscom] [--db games.db]")
+		flags.PrintDefaults()
+		return
+	}
+
+	gameSource, err := source.OpenClient(*sourceName, source.DefaultOptions())
+	if err != nil {
+		log.Fatalf("Error selecting game source: %v", err)
+	}
+	db, err := gamedb.Open(*dbPath)
+	if err != nil {
+		log.Fatalf("Error opening game database: %v", err)
+	}
+	defer db.Close()
+
+	now := time.Now()
+	mark, err := db.SyncMark(gameSource.Name(), *user)
+	if err != nil {
+		log.Fatalf("Error reading the last sync: %v", err)
+	}
+	from := now.AddDate(0, 0, -*days)
+	if mark != nil {
+		from = time.Unix(mark.EndTime, 0)
+		fmt.Printf("Last sync on %s; fetching %s's games since %s.\n", mark.SyncedAt.Format("2006-01-02 15:04"), *user, from.Format("2006-01-02 15:04"))
+	} else {
+		fmt.Printf("First sync of %s; fetching the games of the last %d days.\n", *user, *days)
+	}
+
+	cached := &gamedb.CachedSource{Source: gameSource, DB: db}
+	fetched, err := cached.FetchGames(*user, from, now)
+	if err != nil {
+		fatal(exitNetwork, "Error fetching games: %v", err)
+	}
+	games := gamesAfterMark(fetched, mark)
+	if len(games) == 0 {
+		fmt.Println("No new games.")
+		return
+	}
+	api.TagBots(games)
+	fmt.Printf("%d new games.\n", len(games))
+	newMark := nextSyncMark(games, mark, now)
+
+	if !*fetchOnly {
+		syncAnalyse(games, enginePath, *presetName, *depth, perf.resolve(true), db, *collectionPath, *templatesDir, *reportDir, *reportFormats, *sourceName, *user)
+	}
+	if err := db.SaveSyncMark(gameSource.Name(), *user, newMark); err != nil {
+		log.Fatalf("Error saving the sync: %v", err)
+	}
+	fmt.Printf("Synced up to the game ended %s.\n", time.Unix(newMark.EndTime, 0).Format("2006-01-02 15:04"))
+}
+
+// gamesAfterMark returns the games that ended after the sync mark, in the order they ended.
+func gamesAfterMark(games []api.Game, mark *gamedb.SyncMark) []api.Game {
+	var after []api.Game
+	for _, game := range games {
+		if mark == nil || game.EndTime > mark.EndTime ||
+			game.EndTime == mark.EndTime && !slices.Contains(mark.URLs, api.GameKey(game.URL)) {
+			after = append(after, game)
+		}
+	}
+	slices.SortStableFunc(after, func(a, b api.Game) int { return cmp.Compare(a.EndTime, b.EndTime) })
+	return after
+}
+
+// nextSyncMark returns the sync mark after the new games, which are in the order they ended. Games
+// can end in the same second, so the mark keeps all the games that ended with the newest one.
+func nextSyncMark(games []api.Game, mark *gamedb.SyncMark, now time.Time) gamedb.SyncMark {
+	next := gamedb.SyncMark{EndTime: games[len(games)-1].EndTime, SyncedAt: now}
+	if mark != nil && mark.EndTime == next.EndTime {
+		next.URLs = mark.URLs
+	}
+	for _, game := range games {
+		if game.EndTime == next.EndTime {
+			next.URLs = append(next.URLs, api.GameKey(game.URL))
+		}
+	}
+	return next
+}
+
+// syncAnalyse analyses the synced games in a batch run, keeping the analyses in the game database.
+func syncAnalyse(games []api.Game, enginePath, presetName string, depth int, settings performance, db gamedb.Store,
+	collectionPath, templatesDir, reportDir, reportFormats, sourceName, user string) {
+	renderer, err := report.NewRenderer(templatesDir)
+	if err != nil {
+		log.Fatalf("Error loading report templates: %v", err)
+	}
+	formats, err := parseReportFormats(reportFormats)
+	if err != nil {
+		log.Fatalf("Error in --report-formats: %v", err)
+	}
+	output := &reportOutput{renderer: renderer, dir: reportDir, formats: formats}
+	var collection *report.PGNCollection
+	if collectionPath != "" {
+		if collection, err = report.OpenPGNCollection(collectionPath); err != nil {
+			log.Fatalf("Error opening PGN collection: %v", err)
+		}
+		defer collection.Close()
+	}
+
+	preset, err := gameengine.LookupPreset(presetName)
+	if err != nil {
+		log.Fatalf("Error selecting preset: %v", err)
+	}
+	if depth > 0 {
+		preset = preset.WithDepth(depth)
+	}
+	calibration, err := gameengine.LoadCalibration()
+	if err != nil {
+		log.Printf("Ignoring benchmark calibration: %v", err)
+	}
+	analyser, err := settings.startEngine(enginePath, preset)
+	if err != nil {
+		fatal(exitEngine, "Error starting Stockfish analyser: %v", err)
+	}
+	defer analyser.Close()
+	fmt.Printf("Performance: %s.\n", settings)
+	analyser.SetAnalysisStore(db)
+	analyser.CachePositions(db)
+
+	run := batchRun{Games: "sync " + user, Source: sourceName, Preset: preset.Name}
+	runBatch(analyser, settings.Workers, output, nil, collection, newMoveDataset(user), nil, run, games, preset, calibration)
+}
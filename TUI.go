@@ -0,0 +1,440 @@
+package main
+
+import (
+	"chessAnalyserFree/api"
+	"chessAnalyserFree/board"
+	gameengine "chessAnalyserFree/gameEngine"
+	"chessAnalyserFree/report"
+	"chessAnalyserFree/stats"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// tuiHelp lists the keys of the full-screen interface.
+const tuiHelp = "↑/↓ game  Enter/a analyse  ←/→ move  Home/End start/end  f flip  r report  c copy FEN  p copy PGN  Tab focus  q quit"
+
+// tuiClassColors are the colours of annotated moves in the move list.
+var tuiClassColors = map[string]string{
+	gameengine.ClassSacrifice:  "aqua",
+	gameengine.ClassInaccuracy: "yellow",
+	gameengine.ClassMistake:    "orange",
+	gameengine.ClassBlunder:    "red",
+}
+
+// gameTUI is the full-screen interface of the analyse command: the game list, the board with an
+// eval bar, and the move list with the engine's annotations, in panes of one screen.
+type gameTUI struct {
+	app      *tview.Application
+	analyser *gameengine.StockfishAnalyser
+	output   *reportOutput
+	dataset  *moveDataset
+	games    []api.Game
+	style    board.Style
+
+	gameList  *tview.List
+	boardView *tview.TextView
+	evalBar   *tview.TextView
+	moveList  *tview.TextView
+	status    *tview.TextView
+
+	selected  int // Index of the game shown.
+	positions []gameengine.PlyPosition
+	finalFEN  string
+	ply       int // Moves played in the position shown; 0 is the starting position.
+	flipped   bool
+	analyses  map[int]*gameengine.GameAnalysis // By game index.
+	analysing int                              // Index of the game being analysed, or -1.
+	done      chan struct{}                    // Closed when the running analysis returns.
+	message   string                           // Last log line or notice.
+}
+
+// runTUI runs the full-screen interface over the games until the user quits. Analyses run in the
+// background, so the board and move list stay usable meanwhile; the log goes to the status line.
+// analyses holds existing analyses of the games by URL, e.g. imported ones; it may be nil.
+func runTUI(analyser *gameengine.StockfishAnalyser, output *reportOutput, dataset *moveDataset, games []api.Game, analyses map[string]*gameengine.GameAnalysis, style board.Style) error {
+	t := &gameTUI{
+		app:       tview.NewApplication(),
+		analyser:  analyser,
+		output:    output,
+		dataset:   dataset,
+		games:     games,
+		style:     style,
+		analyses:  make(map[int]*gameengine.GameAnalysis),
+		analysing: -1,
+	}
+	for index, game := range games {
+		if analysis := analyses[game.URL]; analysis != nil {
+			t.analyses[index] = analysis
+			dataset.add(game, analysis)
+		}
+	}
+	t.layout()
+
+	previous := log.Writer()
+	log.SetOutput(tuiLog{t})
+	defer log.SetOutput(previous)
+
+	t.load(0)
+	err := t.app.Run()
+	if t.analysing >= 0 {
+		t.analyser.Skip()
+		<-t.done
+	}
+	return err
+}
+
+// layout builds the panes and binds the keys.
+func (t *gameTUI) layout() {
+	t.gameList = tview.NewList().ShowSecondaryText(false).SetHighlightFullLine(true)
+	for i, game := range t.games {
+		endTime := time.Unix(game.EndTime, 0)
+		t.gameList.AddItem(tview.Escape(fmt.Sprintf("%d. %s vs %s %s%s", i+1, game.White.Username,
+			game.Black.Username, endTime.Format("2006-01-02"), listingNotes(game))), "", 0, nil)
+	}
+	t.gameList.SetBorder(true).SetTitle(fmt.Sprintf(" Games (%d) ", len(t.games)))
+	t.gameList.SetChangedFunc(func(index int, _, _ string, _ rune) { t.load(index) })
+	t.gameList.SetSelectedFunc(func(index int, _, _ string, _ rune) { t.analyse(index) })
+
+	t.boardView = tview.NewTextView()
+	t.evalBar = tview.NewTextView().SetDynamicColors(true)
+	t.moveList = tview.NewTextView().SetDynamicColors(true).SetRegions(true).SetWrap(false)
+	t.moveList.SetBorder(true).SetTitle(" Moves ")
+	t.status = tview.NewTextView().SetDynamicColors(true)
+
+	boardPane := tview.NewFlex().
+		AddItem(t.evalBar, 6, 0, false).
+		AddItem(t.boardView, 0, 1, false)
+	boardPane.SetBorder(true).SetTitle(" Board ")
+	panes := tview.NewFlex().
+		AddItem(t.gameList, 0, 2, true).
+		AddItem(boardPane, 32, 0, false).
+		AddItem(t.moveList, 0, 2, false)
+	root := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(panes, 0, 1, true).
+		AddItem(t.status, 2, 0, false)
+
+	t.app.SetRoot(root, true).SetInputCapture(t.handleKey)
+}
+
+// handleKey handles the keys that work whichever pane has the focus.
+func (t *gameTUI) handleKey(event *tcell.EventKey) *tcell.EventKey {
+	switch event.Key() {
+	case tcell.KeyLeft:
+		t.step(t.ply - 1)
+	case tcell.KeyRight:
+		t.step(t.ply + 1)
+	case tcell.KeyHome:
+		t.step(0)
+	case tcell.KeyEnd:
+		t.step(len(t.positions))
+	case tcell.KeyTab:
+		if t.gameList.HasFocus() {
+			t.app.SetFocus(t.moveList)
+		} else {
+			t.app.SetFocus(t.gameList)
+		}
+	case tcell.KeyRune:
+		switch event.Rune() {
+		case 'q':
+			t.app.Stop()
+		case 'a':
+			t.analyse(t.selected)
+		case 'f':
+			t.flipped = !t.flipped
+			t.refresh()
+		case 'r':
+			t.writeReports()
+		case 'c':
+			if t.finalFEN != "" {
+				fen := t.finalFEN
+				if t.ply < len(t.positions) {
+					fen = t.positions[t.ply].FEN
+				}
+				t.copy("FEN", fen)
+			}
+		case 'p':
+			t.copyPGN()
+		default:
+			return event
+		}
+	default:
+		return event
+	}
+	return nil
+}
+
+// load shows a game from its starting position.
+func (t *gameTUI) load(index int) {
+	if index < 0 || index >= len(t.games) {
+		return
+	}
+	t.selected, t.ply, t.positions, t.finalFEN = index, 0, nil, ""
+	game := t.games[index]
+	positions, err := gameengine.ReplayPositions(game.PGN)
+	if err != nil {
+		t.message = fmt.Sprintf("Error reading game %d: %v", index+1, err)
+	} else if t.finalFEN, err = gameengine.FinalFEN(game.PGN); err != nil {
+		t.message = fmt.Sprintf("Error reading game %d: %v", index+1, err)
+	} else {
+		t.positions = positions
+	}
+	t.refresh()
+}
+
+// step shows the position after the given number of moves.
+func (t *gameTUI) step(ply int) {
+	if ply < 0 || ply > len(t.positions) || t.finalFEN == "" {
+		return
+	}
+	t.ply = ply
+	t.refresh()
+}
+
+// analyse starts the analysis of a game in the background, unless it is analysed already or
+// another analysis is running.
+func (t *gameTUI) analyse(index int) {
+	switch {
+	case t.analyses[index] != nil:
+		t.message = fmt.Sprintf("Game %d is analysed.", index+1)
+	case t.analysing >= 0:
+		t.message = fmt.Sprintf("Game %d is being analysed; wait for it to finish.", t.analysing+1)
+	default:
+		game := t.games[index]
+		total := gameengine.EstimateWorkload([]api.Game{game}, t.analyser.Preset(), nil).Positions
+		done := make(chan struct{})
+		t.analysing, t.done = index, done
+		t.message = fmt.Sprintf("Analysing game %d...", index+1)
+		var positions atomic.Int64
+		t.analyser.OnPositionAnalysed(func() { positions.Add(1) })
+		moves, result := t.analyser.AnalyseGameStream(game)
+		go func() {
+			// The status line follows the analysis move by move.
+			for move := range moves {
+				searched := positions.Load()
+				t.update(func() {
+					if t.analysing == index {
+						t.message = fmt.Sprintf("Analysing game %d: %d of %d positions, %s %s %s", index+1, searched, total,
+							move.Label(), move.SAN(), move.Move.EvaluationText)
+					}
+				})
+			}
+			analysed := <-result
+			t.analyser.OnPositionAnalysed(nil)
+			close(done)
+			t.update(func() {
+				t.analysing = -1
+				t.finishAnalysis(index, analysed.Analysis, analysed.Err)
+			})
+		}()
+	}
+	t.refreshStatus()
+}
+
+// finishAnalysis records the outcome of a game's analysis.
+func (t *gameTUI) finishAnalysis(index int, analysis *gameengine.GameAnalysis, err error) {
+	switch {
+	case errors.Is(err, gameengine.ErrAnalysisSkipped):
+		t.message = fmt.Sprintf("Analysis of game %d stopped.", index+1)
+	case err != nil:
+		t.message = fmt.Sprintf("Error analysing game %d: %v", index+1, err)
+	case !analysis.IsValid():
+		t.message = fmt.Sprintf("The analysis of game %d failed the sanity checks: %s", index+1, strings.Join(analysis.Issues, "; "))
+	default:
+		t.analyses[index] = analysis
+		t.dataset.add(t.games[index], analysis)
+		white, black := gameengine.GameAccuracy(t.games[index].PGN, analysis)
+		t.message = fmt.Sprintf("Game %d analysed (preset: %s): accuracy %.1f for White, %.1f for Black.",
+			index+1, analysis.Preset, white.Accuracy, black.Accuracy)
+	}
+	if index == t.selected {
+		t.refresh()
+	} else {
+		t.refreshStatus()
+	}
+}
+
+// writeReports writes the reports of the game shown.
+func (t *gameTUI) writeReports() {
+	analysis := t.analyses[t.selected]
+	if analysis == nil {
+		t.message = "Analyse the game first (a)."
+	} else if paths := saveGameReports(t.output, t.games[t.selected], analysis, t.selected+1); len(paths) > 0 {
+		t.message = "Reports written to " + strings.Join(paths, ", ")
+	}
+	t.refreshStatus()
+}
+
+// copyPGN copies the PGN of the game shown, annotated once the game is analysed.
+func (t *gameTUI) copyPGN() {
+	game, analysis := t.games[t.selected], t.analyses[t.selected]
+	if analysis == nil {
+		t.copy("PGN", game.PGN)
+		return
+	}
+	pgn, err := report.AnnotatedPGN(game, analysis)
+	if err != nil {
+		t.message = fmt.Sprintf("Error annotating the PGN: %v", err)
+		t.refreshStatus()
+		return
+	}
+	t.copy("annotated PGN", pgn)
+}
+
+// copy places text on the clipboard and says so on the status line.
+func (t *gameTUI) copy(what, text string) {
+	if via, err := copyToClipboard(text); err != nil {
+		t.message = fmt.Sprintf("Error copying the %s: %v", what, err)
+	} else {
+		t.message = fmt.Sprintf("Copied the %s to the clipboard (via %s).", what, via)
+	}
+	t.refreshStatus()
+}
+
+// refresh redraws the board, the eval bar, the move list and the status line.
+func (t *gameTUI) refresh() {
+	t.refreshBoard()
+	t.refreshMoves()
+	t.refreshStatus()
+}
+
+// refreshBoard draws the position shown and its eval bar.
+func (t *gameTUI) refreshBoard() {
+	t.boardView.Clear()
+	t.evalBar.Clear()
+	if t.finalFEN == "" {
+		return
+	}
+	fen := t.finalFEN
+	if t.ply < len(t.positions) {
+		fen = t.positions[t.ply].FEN
+	}
+	text, err := board.Render(fen, t.style, t.flipped)
+	if err != nil {
+		fmt.Fprintf(t.boardView, "Error drawing the board: %v", err)
+		return
+	}
+	fmt.Fprint(t.boardView, text)
+	if t.ply > 0 {
+		played := t.positions[t.ply-1]
+		fmt.Fprintf(t.boardView, "\n%s %s", moveLabel(played), played.SAN)
+	}
+
+	centipawns, label, ok := t.positionEval()
+	if !ok {
+		return
+	}
+	// The bar fills from White's side by White's winning chances, a row per rank.
+	whiteRows := int(math.Round(gameengine.WinPercent(centipawns) * 8 / 100))
+	var bar strings.Builder
+	bar.WriteString("\n")
+	for row := 0; row < 8; row++ {
+		fromWhite := 7 - row
+		if t.flipped {
+			fromWhite = row
+		}
+		if fromWhite < whiteRows {
+			bar.WriteString("  [white]██[-]\n")
+		} else {
+			bar.WriteString("  [gray]██[-]\n")
+		}
+	}
+	fmt.Fprintf(&bar, "\n%s", tview.Escape(label))
+	t.evalBar.SetText(bar.String())
+}
+
+// positionEval returns the evaluation of the position shown in centipawns from White's point of
+// view, with its label, e.g. "+0.35", "#-2" or the result at the end of the game.
+func (t *gameTUI) positionEval() (int, string, bool) {
+	if t.ply == len(t.positions) {
+		outcome, ok := stats.Outcome(t.games[t.selected], "")
+		if !ok {
+			return 0, "", false
+		}
+		return outcome * 10000, map[int]string{1: "1-0", 0: "½-½", -1: "0-1"}[outcome], true
+	}
+	analysis := t.analyses[t.selected]
+	if analysis == nil || t.ply >= len(analysis.Moves) || analysis.Moves[t.ply].Classification == gameengine.ClassBook {
+		return 0, "", false
+	}
+	// Evaluations are from the side to move's point of view.
+	move, sign := analysis.Moves[t.ply], 1
+	if !strings.Contains(t.positions[t.ply].FEN, " w ") {
+		sign = -1
+	}
+	label := fmt.Sprintf("%+.2f", float64(sign)*move.Evaluation)
+	if mate, isMate := strings.CutPrefix(move.EvaluationText, "#"); isMate {
+		if n, err := strconv.Atoi(mate); err == nil {
+			label = fmt.Sprintf("#%d", sign*n)
+		}
+	}
+	return sign * int(move.Evaluation*100), label, true
+}
+
+// refreshMoves writes the move list, with the annotations of an analysed game, and highlights the
+// move just played.
+func (t *gameTUI) refreshMoves() {
+	game := t.games[t.selected]
+	t.moveList.SetTitle(tview.Escape(fmt.Sprintf(" %s vs %s ", game.White.Username, game.Black.Username)))
+	analysis := t.analyses[t.selected]
+	var b strings.Builder
+	for i, position := range t.positions {
+		whiteMove := strings.Contains(position.FEN, " w ")
+		if whiteMove || i == 0 {
+			if i > 0 {
+				b.WriteString("\n")
+			}
+			fmt.Fprintf(&b, "%4s ", moveLabel(position))
+		}
+		san, color := position.SAN, ""
+		if analysis != nil && i < len(analysis.Moves) {
+			san += annotationSymbol(analysis.Moves[i].Classification)
+			color = tuiClassColors[analysis.Moves[i].Classification]
+		}
+		text := fmt.Sprintf("%-9s", san)
+		if color != "" {
+			text = "[" + color + "]" + text + "[-]"
+		}
+		fmt.Fprintf(&b, `["%d"]%s[""]`, i+1, text)
+	}
+	t.moveList.SetText(b.String())
+	if t.ply > 0 {
+		t.moveList.Highlight(strconv.Itoa(t.ply)).ScrollToHighlight()
+	} else {
+		t.moveList.Highlight().ScrollToBeginning()
+	}
+}
+
+// refreshStatus writes the help and the last message.
+func (t *gameTUI) refreshStatus() {
+	where := fmt.Sprintf("Game %d/%d, move %d/%d", t.selected+1, len(t.games), t.ply, len(t.positions))
+	t.status.SetText(fmt.Sprintf("[yellow]%s[-]  %s\n%s", where, tuiHelp, tview.Escape(t.message)))
+}
+
+// tuiLog shows log lines in the status line while the interface runs.
+type tuiLog struct {
+	t *gameTUI
+}
+
+func (l tuiLog) Write(p []byte) (int, error) {
+	message := strings.TrimSpace(string(p))
+	l.t.update(func() { l.t.message = message })
+	return len(p), nil
+}
+
+// update changes the interface from any goroutine and redraws it, with the status line. It never
+// waits, so it is safe from the event loop itself and once the interface has stopped.
+func (t *gameTUI) update(f func()) {
+	go t.app.QueueUpdateDraw(func() {
+		f()
+		t.refreshStatus()
+	})
+}
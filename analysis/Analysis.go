@@ -0,0 +1,133 @@
+// Package analysis analyses games move by move with an engine and sums up the analyses. It is the
+// analysis part of the public API of the analyser, together with packages source and engine:
+//
+//	games, errs := source.Fetch(chesscom, []string{"hikaru"}, from, to, 1, nil)
+//	sf, err := engine.Start("/usr/local/bin/stockfish", engine.DefaultOptions())
+//	result, err := analysis.Analyse(sf, games[0])
+//	white, black := analysis.Accuracy(games[0], result)
+//
+// Engines of package engine analyse games with a UCI engine, be it a process, one served over TCP or
+// the in-memory mock engine. Other backends, e.g. a cloud evaluation service, implement Analyser.
+package analysis
+
+import (
+	gameengine "chessAnalyserFree/gameEngine"
+	"chessAnalyserFree/source"
+)
+
+// Classifications of analysed moves by the centipawns they lost; opening moves the preset skips are
+// book moves.
+const (
+	ClassBook       = gameengine.ClassBook
+	ClassGood       = gameengine.ClassGood
+	ClassInaccuracy = gameengine.ClassInaccuracy
+	ClassMistake    = gameengine.ClassMistake
+	ClassBlunder    = gameengine.ClassBlunder
+	// ClassSacrifice marks a move that gives up material without losing evaluation.
+	ClassSacrifice = gameengine.ClassSacrifice
+)
+
+// Result is the analysis of a game: the analysis of every move and the preset that produced it.
+type Result struct {
+	Preset string   // Name of the preset, e.g. "standard".
+	Engine string   // Name the engine reported, e.g. "Stockfish 16".
+	Moves  []Move   // The moves in the order they were played.
+	Issues []string // Sanity check failures; a result with issues is invalid.
+}
+
+// Valid reports whether the analysis passed the sanity checks.
+func (r *Result) Valid() bool {
+	return len(r.Issues) == 0
+}
+
+// Move is the analysis of a move: the evaluation before it, from the mover's point of view, the
+// centipawns it lost, its classification and the engine's best move.
+type Move struct {
+	Number         int     // Move number, as in the PGN; both moves of a turn share it.
+	Move           string  // The move played, in UCI notation, e.g. "e2e4".
+	Evaluation     float64 // Evaluation in pawns before the move, from the mover's point of view.
+	EvaluationText string  // e.g. "+1.23", "-0.54" or "#3"
+	CentipawnLoss  int     // Evaluation lost by the move, from the mover's point of view.
+	Classification string  // One of the Class* constants.
+	MaterialLoss   int     // Material in pawns the move gives up once the following captures are played out.
+	Depth          int     // Search depth of the evaluation; 0 for book moves.
+	BestMove       string  // Engine's best move before the move, in UCI notation; empty for book moves.
+}
+
+// SideAccuracy sums up the moves of one side of a game: average centipawn loss, accuracy and the
+// counts of inaccuracies, mistakes and blunders.
+type SideAccuracy struct {
+	Moves        int     // Analysed moves; book moves are left out.
+	ACPL         float64 // Average centipawn loss.
+	Accuracy     float64 // 0 to 100, from the winning chances the moves lost.
+	Inaccuracies int
+	Mistakes     int
+	Blunders     int
+	BestMoves    int   // Analysed moves that were the engine's best move.
+	Losses       []int // Centipawn losses of the analysed moves, in order.
+}
+
+// Errors of games that cannot be analysed; the analysis of other games goes on.
+var (
+	// ErrUnsupportedVariant is returned for games of variants such as crazyhouse or bughouse.
+	ErrUnsupportedVariant = gameengine.ErrUnsupportedVariant
+	// ErrNotAnalysable is returned for aborted games and other games without moves.
+	ErrNotAnalysable = gameengine.ErrNotAnalysable
+	// ErrSkipped is returned for a game whose analysis was interrupted with Skip.
+	ErrSkipped = gameengine.ErrAnalysisSkipped
+)
+
+// Analyser analyses a game move by move. *engine.Engine is an Analyser.
+type Analyser interface {
+	AnalyseGame(game source.Game) (*Result, error)
+}
+
+// ParallelAnalyser is an Analyser that can analyse several games at once, such as *engine.Engine.
+type ParallelAnalyser interface {
+	Analyser
+	// AnalyseAll analyses the games on up to workers analysers, as the function AnalyseAll does.
+	AnalyseAll(workers int, games []source.Game, done func(i int, result *Result, err error)) error
+}
+
+// Analyse analyses every move of a game with the analyser; an engine searches with its preset.
+func Analyse(a Analyser, game source.Game) (*Result, error) {
+	return a.AnalyseGame(game)
+}
+
+// AnalyseAll analyses the games with the analyser. done is called on the calling goroutine for every
+// game, in the order of the games whatever order they finish in, with the game's analysis or the
+// error it failed with.
+//
+// A ParallelAnalyser analyses the games on workers analysers; an *engine.Engine uses itself and
+// workers-1 engines spawned from it with its settings, which are closed before AnalyseAll returns.
+// Other analysers are not assumed to be safe for concurrent use and analyse one game at a time,
+// whatever workers is; wrap them in a ParallelAnalyser of their own to spread the games.
+func AnalyseAll(a Analyser, workers int, games []source.Game, done func(i int, result *Result, err error)) error {
+	if parallel, ok := a.(ParallelAnalyser); ok {
+		return parallel.AnalyseAll(workers, games, done)
+	}
+	for i, game := range games {
+		result, err := a.AnalyseGame(game)
+		done(i, result, err)
+	}
+	return nil
+}
+
+// Accuracy sums up the analysed moves of each side of a game.
+func Accuracy(game source.Game, result *Result) (white, black SideAccuracy) {
+	w, b := gameengine.GameAccuracy(game.PGN, result.gameAnalysis())
+	return SideAccuracy(w), SideAccuracy(b)
+}
+
+// gameAnalysis returns the result as an analysis of package gameEngine, which sums it up.
+func (r *Result) gameAnalysis() *gameengine.GameAnalysis {
+	analysis := &gameengine.GameAnalysis{Preset: r.Preset, Engine: r.Engine, Issues: r.Issues}
+	for _, move := range r.Moves {
+		analysis.Moves = append(analysis.Moves, gameengine.MoveAnalysis{
+			MoveNumber: move.Number, Move: move.Move, Evaluation: move.Evaluation, EvaluationText: move.EvaluationText,
+			CentipawnLoss: move.CentipawnLoss, Classification: move.Classification, MaterialLoss: move.MaterialLoss,
+			Depth: move.Depth, BestMove: move.BestMove,
+		})
+	}
+	return analysis
+}
@@ -0,0 +1,224 @@
+package analysis_test
+
+import (
+	"chessAnalyserFree/analysis"
+	"chessAnalyserFree/engine"
+	gameengine "chessAnalyserFree/gameEngine"
+	"chessAnalyserFree/source"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// scandinavian is a game whose captures the mock engine, which counts material only, sees.
+var scandinavian = source.Game{
+	URL:   "https://example.com/game/1",
+	Rules: "chess",
+	PGN: `[Event "Test"]
+[White "white"]
+[Black "black"]
+[Result "*"]
+
+1. e4 d5 2. exd5 Qxd5 3. Nc3 *`,
+}
+
+// noMoves is a game that cannot be analysed.
+var noMoves = source.Game{URL: "https://example.com/game/2", Rules: "chess", PGN: `[Event "Test"]
+[Result "*"]
+
+*`}
+
+// testOptions search every move at depth 1, without skipping the opening.
+func testOptions() engine.Options {
+	preset := engine.Preset{Name: "test", Depth: 1, MultiPV: 1, Thresholds: engine.Thresholds{Inaccuracy: 50, Mistake: 100, Blunder: 300}}
+	return engine.Options{Preset: preset, Timeout: time.Second}
+}
+
+// wantScandinavian are the move numbers, moves, evaluations, best moves and centipawn losses the mock
+// engine gives the moves of scandinavian. It looks a move ahead only, so 2. exd5 loses the pawn back to
+// 2... Qxd5 and 3. Nc3 the a-pawn to 3... Qxa2.
+var wantScandinavian = []analysis.Move{
+	{Number: 1, Move: "e2e4", EvaluationText: "+0.00", BestMove: "a2a3"},
+	{Number: 1, Move: "d7d5", EvaluationText: "+0.00", BestMove: "a7a5", CentipawnLoss: 100},
+	{Number: 2, Move: "e4d5", EvaluationText: "+1.00", BestMove: "e4d5", CentipawnLoss: 100},
+	{Number: 2, Move: "d8d5", EvaluationText: "+0.00", BestMove: "d8d5"},
+	{Number: 3, Move: "b1c3", EvaluationText: "+0.00", BestMove: "a2a3", CentipawnLoss: 100},
+}
+
+// checkScandinavian reports how a result differs from wantScandinavian.
+func checkScandinavian(t *testing.T, result *analysis.Result) {
+	t.Helper()
+	if len(result.Moves) != len(wantScandinavian) {
+		t.Fatalf("got %d moves, want %d", len(result.Moves), len(wantScandinavian))
+	}
+	for i, want := range wantScandinavian {
+		got := result.Moves[i]
+		if got.Number != want.Number || got.Move != want.Move || got.EvaluationText != want.EvaluationText ||
+			got.BestMove != want.BestMove || got.CentipawnLoss != want.CentipawnLoss {
+			t.Errorf("move %d: got %d %s eval %s best %s loss %d, want %d %s eval %s best %s loss %d", i+1,
+				got.Number, got.Move, got.EvaluationText, got.BestMove, got.CentipawnLoss,
+				want.Number, want.Move, want.EvaluationText, want.BestMove, want.CentipawnLoss)
+		}
+	}
+	if !result.Valid() {
+		t.Errorf("analysis failed the sanity checks: %v", result.Issues)
+	}
+}
+
+func TestAnalyse(t *testing.T) {
+	mock, err := engine.StartMock(testOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mock.Close()
+
+	result, err := analysis.Analyse(mock, scandinavian)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkScandinavian(t, result)
+	if result.Preset != "test" || result.Engine != "Mock engine" {
+		t.Errorf("got preset %q of engine %q, want test of Mock engine", result.Preset, result.Engine)
+	}
+	if result.Moves[1].Classification != analysis.ClassMistake {
+		t.Errorf("1... d5 classified %s, want %s", result.Moves[1].Classification, analysis.ClassMistake)
+	}
+	white, black := analysis.Accuracy(scandinavian, result)
+	if white.Moves != 3 || white.Mistakes != 2 || white.BestMoves != 1 || black.Moves != 2 || black.Mistakes != 1 || black.ACPL != 50 {
+		t.Errorf("got white %+v and black %+v", white, black)
+	}
+
+	if _, err := analysis.Analyse(mock, noMoves); !errors.Is(err, analysis.ErrNotAnalysable) {
+		t.Errorf("game without moves: got error %v, want %v", err, analysis.ErrNotAnalysable)
+	}
+}
+
+func TestAnalyseAll(t *testing.T) {
+	mock, err := engine.StartMock(testOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mock.Close()
+
+	games := []source.Game{scandinavian, noMoves, scandinavian, scandinavian}
+	var order []int
+	err = analysis.AnalyseAll(mock, 3, games, func(i int, result *analysis.Result, err error) {
+		order = append(order, i)
+		if i == 1 {
+			if !errors.Is(err, analysis.ErrNotAnalysable) {
+				t.Errorf("game without moves: got error %v, want %v", err, analysis.ErrNotAnalysable)
+			}
+			return
+		}
+		if err != nil {
+			t.Fatalf("game %d: %v", i, err)
+		}
+		checkScandinavian(t, result)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(order) != len(games) || order[0] != 0 || order[1] != 1 || order[2] != 2 || order[3] != 3 {
+		t.Errorf("done called for games %v, want them in order", order)
+	}
+}
+
+// serialAnalyser analyses games with an engine, counting the analyses running at once.
+type serialAnalyser struct {
+	engine          *engine.Engine
+	running, maxRun int
+}
+
+func (a *serialAnalyser) AnalyseGame(game source.Game) (*analysis.Result, error) {
+	a.running++
+	a.maxRun = max(a.maxRun, a.running)
+	defer func() { a.running-- }()
+	return a.engine.AnalyseGame(game)
+}
+
+func TestAnalyseAllSerial(t *testing.T) {
+	mock, err := engine.StartMock(testOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mock.Close()
+
+	analyser := &serialAnalyser{engine: mock}
+	analysed := 0
+	err = analysis.AnalyseAll(analyser, 4, []source.Game{scandinavian, scandinavian}, func(i int, result *analysis.Result, err error) {
+		if i != analysed {
+			t.Errorf("done called for game %d, want %d", i, analysed)
+		}
+		analysed++
+		if err != nil {
+			t.Fatalf("game %d: %v", i, err)
+		}
+		checkScandinavian(t, result)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if analysed != 2 || analyser.maxRun != 1 {
+		t.Errorf("analysed %d games, %d at once; want 2, one at a time", analysed, analyser.maxRun)
+	}
+}
+
+// silentConnection is a connection to the mock engine that stops passing commands on from the go
+// command of the given search: all of them when deaf, which the engine must be restarted for, or
+// only that go command, which stop and isready recover from.
+type silentConnection struct {
+	io.ReadWriteCloser
+	searches *int // Searches started on every connection, shared by the connector.
+	silentAt int
+	deaf     bool
+	silent   bool
+}
+
+func (c *silentConnection) Write(command []byte) (int, error) {
+	if strings.HasPrefix(string(command), "go ") {
+		*c.searches++
+		if *c.searches == c.silentAt {
+			c.silent = true
+			return len(command), nil
+		}
+	}
+	if c.silent && c.deaf {
+		return len(command), nil
+	}
+	return c.ReadWriteCloser.Write(command)
+}
+
+func TestAnalyseTimeout(t *testing.T) {
+	for _, deaf := range []bool{false, true} {
+		searches := 0
+		connect := func() (io.ReadWriteCloser, error) {
+			conn, err := gameengine.ConnectMock()
+			return &silentConnection{ReadWriteCloser: conn, searches: &searches, silentAt: 3, deaf: deaf}, err
+		}
+		options := testOptions()
+		options.Timeout = 100 * time.Millisecond
+		mock, err := engine.Connect(connect, options)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = analysis.Analyse(mock, scandinavian)
+		var timeout *gameengine.EngineTimeoutError
+		if !errors.As(err, &timeout) {
+			t.Fatalf("deaf %v: got error %v, want an engine timeout", deaf, err)
+		}
+		if timeout.Waiting != "bestmove" || timeout.Restarted != deaf || timeout.RestartErr != nil {
+			t.Errorf("deaf %v: got %+v", deaf, timeout)
+		}
+
+		// The engine, stopped or restarted, analyses the next game as if nothing happened.
+		result, err := analysis.Analyse(mock, scandinavian)
+		if err != nil {
+			t.Fatalf("deaf %v: after the timeout: %v", deaf, err)
+		}
+		checkScandinavian(t, result)
+		mock.Close()
+	}
+}
@@ -0,0 +1,64 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ArchivesResponse lists the monthly archive URLs of a player, oldest first.
+type ArchivesResponse struct {
+	Archives []string `json:"archives"`
+}
+
+// FetchArchives returns the months a player has games in, as "YYYY/MM" strings, oldest first.
+func (c *Client) FetchArchives(username string) ([]string, error) {
+	url := fmt.Sprintf("%s/player/%s/games/archives", baseURL, strings.ToLower(username))
+
+	var archives ArchivesResponse
+	err := c.getJSON(url, &archives)
+	if errors.Is(err, ErrNotFound) {
+		return nil, fmt.Errorf("no player named %s: %w", username, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	months := make([]string, 0, len(archives.Archives))
+	for _, archive := range archives.Archives {
+		// Archive URLs end in ".../games/YYYY/MM".
+		parts := strings.Split(strings.TrimRight(archive, "/"), "/")
+		if len(parts) < 2 {
+			continue
+		}
+		months = append(months, parts[len(parts)-2]+"/"+parts[len(parts)-1])
+	}
+	return months, nil
+}
+
+// DownloadMonthPGN streams a player's monthly archive in PGN format to w without buffering it.
+// The year should be in YYYY format and the month in MM format.
+func (c *Client) DownloadMonthPGN(username, year, month string, w io.Writer) (int64, error) {
+	archive, err := c.openMonthPGN(username, year, month)
+	if err != nil {
+		return 0, err
+	}
+	defer archive.Close()
+
+	written, err := io.Copy(w, archive)
+	if err != nil {
+		return written, fmt.Errorf("failed to read response body: %w", err)
+	}
+	return written, nil
+}
+
+// openMonthPGN requests a player's monthly archive in PGN format and returns the response body
+// for the caller to read and close.
+func (c *Client) openMonthPGN(username, year, month string) (io.ReadCloser, error) {
+	return c.get(monthPGNURL(username, year, month))
+}
+
+// monthPGNURL returns the URL of a player's monthly archive in PGN format.
+func monthPGNURL(username, year, month string) string {
+	return fmt.Sprintf("%s/player/%s/games/%s/%s/pgn", baseURL, strings.ToLower(username), year, month)
+}
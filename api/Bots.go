@@ -0,0 +1,101 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// botUsernamePattern matches usernames of engine and bot accounts, such as "stockfish15",
+// "komodo-dragon", "mittens_bot" or "BOT_Nelson".
+var botUsernamePattern = regexp.MustCompile(`(?i)^(stockfish|komodo|leela|lc0|fritz|houdini|rybka)([-_]|\d|bot|$)|(^|[-_])bot[-_]?\d*$|^bot[-_]`)
+
+// IsBotUsername reports whether a username follows the naming patterns of bot and engine accounts.
+func IsBotUsername(username string) bool {
+	return botUsernamePattern.MatchString(username)
+}
+
+// PlayerProfile is the public profile of a Chess.com member.
+type PlayerProfile struct {
+	ID       string `json:"@id"`
+	Username string `json:"username"`
+	Title    string `json:"title"`
+	Status   string `json:"status"` // e.g. "basic", "premium", "closed:fair_play_violations"
+	Joined   int64  `json:"joined"`
+}
+
+// IsEngineAccount reports whether the account was closed for fair-play (computer assistance) violations.
+func (p PlayerProfile) IsEngineAccount() bool {
+	return p.Status == "closed:fair_play_violations"
+}
+
+// FetchPlayer fetches the public profile of a player.
+func (c *Client) FetchPlayer(username string) (*PlayerProfile, error) {
+	url := fmt.Sprintf("%s/player/%s", baseURL, strings.ToLower(username))
+
+	var profile PlayerProfile
+	if err := c.getJSON(url, &profile); err != nil {
+		return nil, err
+	}
+	return &profile, nil
+}
+
+// TagBots marks the players of every game whose username follows a bot naming pattern. Players
+// already marked by their game source stay marked.
+func TagBots(games []Game) {
+	for i := range games {
+		games[i].White.Bot = games[i].White.Bot || IsBotUsername(games[i].White.Username)
+		games[i].Black.Bot = games[i].Black.Bot || IsBotUsername(games[i].Black.Username)
+	}
+}
+
+// TagEngineAccounts looks up the profile of every distinct player and marks accounts closed for
+// fair-play violations as computer opponents. Profiles that cannot be fetched are reported in the
+// returned error and leave the player untagged.
+func (c *Client) TagEngineAccounts(games []Game) error {
+	engine := make(map[string]bool)
+	var errs []error
+	for i := range games {
+		for _, player := range []*Player{&games[i].White, &games[i].Black} {
+			name := strings.ToLower(player.Username)
+			if name == "" {
+				continue
+			}
+			isEngine, seen := engine[name]
+			if !seen {
+				if len(engine) > 0 {
+					// Be gentle with the public API.
+					time.Sleep(250 * time.Millisecond)
+				}
+				profile, err := c.FetchPlayer(name)
+				if err != nil {
+					errs = append(errs, fmt.Errorf("profile of %s: %w", player.Username, err))
+				} else {
+					isEngine = profile.IsEngineAccount()
+				}
+				engine[name] = isEngine
+			}
+			player.Bot = player.Bot || isEngine
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// AgainstBot reports whether either player of the game is a bot or computer opponent.
+func (g Game) AgainstBot() bool {
+	return g.White.Bot || g.Black.Bot
+}
+
+// WithoutBots returns the games in which neither player is a bot or computer opponent, for
+// statistics that should only reflect games between humans.
+func WithoutBots(games []Game) []Game {
+	var humans []Game
+	for _, game := range games {
+		if !game.AgainstBot() {
+			humans = append(humans, game)
+		}
+	}
+	return humans
+}
@@ -1,19 +1,34 @@
 package api
 
 import (
+	"compress/gzip"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 	"time"
 )
 
 // baseURL is the base URL for the Chess.com public data API.
 const baseURL = "https://api.chess.com/pub"
 
+// ErrRateLimited is returned when Chess.com refuses a request because too many were made.
+var ErrRateLimited = errors.New("rate limited by chess.com")
+
 // Client is a client for the Chess.com API.
 type Client struct {
 	HTTPClient *http.Client
+	// PGNDecoder, if set, makes FetchGames download the monthly archives in PGN format, which is
+	// smaller and needs no JSON parsing, and turns them into games with this function (e.g.
+	// pgnimport.ReadGames). Games then only carry what the PGN tags tell.
+	PGNDecoder func(r io.Reader, origin string) ([]Game, error)
+	// Retries is the retry budget: how many times in all, over every request of the client, a
+	// request failing with a server error or a network error is tried again. 0 turns retries off.
+	Retries int
+
+	retries retryBudget
 }
 
 // NewClient creates a new Chess.com API client.
@@ -22,6 +37,7 @@ func NewClient() *Client {
 		HTTPClient: &http.Client{
 			Timeout: 10 * time.Second,
 		},
+		Retries: DefaultRetries,
 	}
 }
 
@@ -31,6 +47,8 @@ type Player struct {
 	Result   string `json:"result"`
 	ID       string `json:"@id"`
 	Username string `json:"username"`
+	// Bot marks engine and bot accounts; it is set by TagBots and the game sources, not by Chess.com.
+	Bot bool `json:"bot,omitempty"`
 }
 
 // Game represents a single game played on Chess.com.
@@ -59,40 +77,135 @@ func (c *Client) FetchPlayerGamesByMonth(username, year, month string) (*GamesRe
 	// Construct the request URL.
 	url := fmt.Sprintf("%s/player/%s/games/%s/%s", baseURL, username, year, month)
 
-	// Create a new HTTP request.
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+	var gamesResponse GamesResponse
+	if err := c.getJSON(url, &gamesResponse); err != nil {
+		return nil, err
 	}
 
-	// It's good practice to set a User-Agent header.
-	req.Header.Set("User-Agent", "Go-Chess.com-API-Client/1.0 (your-contact-info)")
+	return &gamesResponse, nil
+}
 
-	// Execute the request.
-	resp, err := c.HTTPClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to execute request: %w", err)
+// Name returns the identifier of the Chess.com game source.
+func (c *Client) Name() string {
+	return "chesscom"
+}
+
+// FetchGames fetches the monthly archives covering from..to and returns the games in them.
+// Months that fail to download are skipped and reported in the returned error.
+func (c *Client) FetchGames(username string, from, to time.Time) ([]Game, error) {
+	var games []Game
+	var errs []error
+	start := time.Date(from.Year(), from.Month(), 1, 0, 0, 0, 0, time.UTC)
+	for d := start; !d.After(to); d = d.AddDate(0, 1, 0) {
+		year := d.Format("2006")
+		month := d.Format("01")
+		monthGames, err := c.fetchMonth(username, year, month)
+		if errors.Is(err, ErrAccountClosed) || errors.Is(err, ErrMaintenance) {
+			// The other months would fail the same way.
+			errs = append(errs, fmt.Errorf("could not fetch games of %s: %w", username, err))
+			break
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("could not fetch games for %s/%s: %w", month, year, err))
+			continue
+		}
+		games = append(games, monthGames...)
+		// Be polite to the API between archive requests.
+		time.Sleep(250 * time.Millisecond)
+	}
+	return games, errors.Join(errs...)
+}
+
+// fetchMonth fetches the games of one monthly archive in the client's archive format. A month the
+// API has no archive of has no games.
+func (c *Client) fetchMonth(username, year, month string) ([]Game, error) {
+	if c.PGNDecoder == nil {
+		gamesResponse, err := c.FetchPlayerGamesByMonth(username, year, month)
+		if errors.Is(err, ErrNotFound) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		return gamesResponse.Games, nil
 	}
-	defer resp.Body.Close()
 
-	// Check for a successful status code.
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("received non-200 status code: %d", resp.StatusCode)
+	// The archive is decoded within the request, so that one cut short is downloaded again.
+	var games []Game
+	err := c.retry(func() error {
+		archive, err := c.open(monthPGNURL(username, year, month))
+		if err != nil {
+			return err
+		}
+		defer archive.Close()
+		origin := fmt.Sprintf("%s/player/%s/games/%s/%s/pgn", baseURL, username, year, month)
+		games, err = c.PGNDecoder(archive, origin)
+		return err
+	})
+	if errors.Is(err, ErrNotFound) {
+		return nil, nil
 	}
+	return games, err
+}
 
-	// Read the response body.
-	body, err := io.ReadAll(resp.Body)
+// getJSON performs a GET request against the API and decodes the JSON body into v as it arrives,
+// without reading it into memory first. A body cut short is requested again, as retry does.
+func (c *Client) getJSON(url string, v interface{}) error {
+	return c.retry(func() error {
+		body, err := c.open(url)
+		if err != nil {
+			return err
+		}
+		defer body.Close()
+
+		if err := json.NewDecoder(body).Decode(v); err != nil {
+			return fmt.Errorf("failed to decode json response: %w", err)
+		}
+		return nil
+	})
+}
+
+// get performs a GET request against the API, retrying it as retry does, and returns the response
+// body for the caller to read and close. Errors reading the body are not retried.
+func (c *Client) get(url string) (io.ReadCloser, error) {
+	var body io.ReadCloser
+	err := c.retry(func() (err error) {
+		body, err = c.open(url)
+		return err
+	})
+	return body, err
+}
+
+// open sends one GET request against the API and returns the response body for the caller to read
+// and close. The body is asked for gzip-compressed, which shrinks the monthly archives several
+// times over, and is unpacked as it is read.
+func (c *Client) open(url string) (io.ReadCloser, error) {
+	resp, err := c.try(url)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %w", err)
+		return nil, err
 	}
 
-	// Unmarshal the JSON response into our struct.
-	var gamesResponse GamesResponse
-	if err := json.Unmarshal(body, &gamesResponse); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal json response: %w", err)
+	if !strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+		return resp.Body, nil
 	}
+	gz, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		resp.Body.Close()
+		return nil, fmt.Errorf("failed to decompress response body: %w", err)
+	}
+	return gzipBody{gz, resp.Body}, nil
+}
 
-	return &gamesResponse, nil
+// gzipBody is a gzip-compressed response body, unpacked as it is read.
+type gzipBody struct {
+	*gzip.Reader
+	body io.ReadCloser
+}
+
+// Close closes the decompressor and the response body.
+func (g gzipBody) Close() error {
+	g.Reader.Close()
+	return g.body.Close()
 }
 
 // Example usage:
@@ -0,0 +1,65 @@
+package api
+
+import "fmt"
+
+// Club holds the public profile of a Chess.com club.
+type Club struct {
+	ID                 string   `json:"@id"`
+	Name               string   `json:"name"`
+	ClubID             int      `json:"club_id"`
+	Icon               string   `json:"icon"`
+	Country            string   `json:"country"`
+	AverageDailyRating int      `json:"average_daily_rating"`
+	MembersCount       int      `json:"members_count"`
+	Created            int64    `json:"created"`
+	LastActivity       int64    `json:"last_activity"`
+	Visibility         string   `json:"visibility"`
+	JoinRequest        string   `json:"join_request"`
+	Admins             []string `json:"admin"`
+	Description        string   `json:"description"`
+}
+
+// ClubMember is a single entry in a club's member list.
+type ClubMember struct {
+	Username string `json:"username"`
+	Joined   int64  `json:"joined"`
+}
+
+// ClubMembers groups a club's members by how recently they were active.
+type ClubMembers struct {
+	Weekly  []ClubMember `json:"weekly"`
+	Monthly []ClubMember `json:"monthly"`
+	AllTime []ClubMember `json:"all_time"`
+}
+
+// All returns every member of the club regardless of activity group.
+func (m *ClubMembers) All() []ClubMember {
+	all := make([]ClubMember, 0, len(m.Weekly)+len(m.Monthly)+len(m.AllTime))
+	all = append(all, m.Weekly...)
+	all = append(all, m.Monthly...)
+	all = append(all, m.AllTime...)
+	return all
+}
+
+// FetchClub fetches the profile of a club.
+// The clubID is the URL identifier of the club (e.g., "chess-com-developer-community").
+func (c *Client) FetchClub(clubID string) (*Club, error) {
+	url := fmt.Sprintf("%s/club/%s", baseURL, clubID)
+
+	var club Club
+	if err := c.getJSON(url, &club); err != nil {
+		return nil, err
+	}
+	return &club, nil
+}
+
+// FetchClubMembers fetches the member list of a club, grouped by activity.
+func (c *Client) FetchClubMembers(clubID string) (*ClubMembers, error) {
+	url := fmt.Sprintf("%s/club/%s/members", baseURL, clubID)
+
+	var members ClubMembers
+	if err := c.getJSON(url, &members); err != nil {
+		return nil, err
+	}
+	return &members, nil
+}
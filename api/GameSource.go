@@ -0,0 +1,13 @@
+package api
+
+import "time"
+
+// GameSource is a provider of a player's games over a date range.
+// Implementations convert their site's data into the Game structure used throughout the tool.
+type GameSource interface {
+	// Name returns a short identifier of the source (e.g., "chesscom").
+	Name() string
+	// FetchGames returns the games a player finished between from and to, inclusive.
+	// A partial result may be returned together with an error.
+	FetchGames(username string, from, to time.Time) ([]Game, error)
+}
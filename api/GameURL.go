@@ -0,0 +1,99 @@
+package api
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// callbackURL is the base URL of the Chess.com website endpoints that describe a single game.
+const callbackURL = "https://www.chess.com/callback"
+
+// gameURLRegex matches the game URL formats used by Chess.com, e.g. /game/live/123, /live/game/123,
+// /game/daily/123 and /analysis/game/live/123.
+var gameURLRegex = regexp.MustCompile(`/(?:analysis/)?(?:game/(live|daily)|(live|daily)/game)/(\d+)`)
+
+// callbackGame is the part of the website's game description needed to locate the game in an archive.
+type callbackGame struct {
+	Game struct {
+		EndTime    int64                  `json:"endTime"`
+		PGNHeaders map[string]interface{} `json:"pgnHeaders"`
+	} `json:"game"`
+}
+
+// ParseGameURL extracts the game type ("live" or "daily") and ID from a Chess.com game URL.
+func ParseGameURL(gameURL string) (string, string, error) {
+	parsed, err := url.Parse(gameURL)
+	if err != nil || !strings.HasSuffix(parsed.Host, "chess.com") {
+		return "", "", fmt.Errorf("not a chess.com game URL: %s", gameURL)
+	}
+	matches := gameURLRegex.FindStringSubmatch(parsed.Path)
+	if matches == nil {
+		return "", "", fmt.Errorf("not a chess.com game URL: %s", gameURL)
+	}
+	kind := matches[1]
+	if kind == "" {
+		kind = matches[2]
+	}
+	return kind, matches[3], nil
+}
+
+// lichessGameRegex matches the path of a Lichess game URL, e.g. /abcdefgh, /abcdefgh/black or the
+// 12-character /abcdefghijkl of a player's view; the first 8 characters are the game ID.
+var lichessGameRegex = regexp.MustCompile(`^/([a-zA-Z0-9]{8})(?:[a-zA-Z0-9]{4})?(?:/(?:white|black))?/?$`)
+
+// GameKey returns a stable identity for a game URL: the different URL forms of a Chess.com or Lichess
+// game map to the same key. Other URLs are their own keys.
+func GameKey(gameURL string) string {
+	if kind, id, err := ParseGameURL(gameURL); err == nil {
+		return "chesscom/" + kind + "/" + id
+	}
+	if parsed, err := url.Parse(gameURL); err == nil && strings.HasSuffix(parsed.Host, "lichess.org") {
+		if matches := lichessGameRegex.FindStringSubmatch(parsed.Path); matches != nil {
+			return "lichess/" + matches[1]
+		}
+	}
+	return gameURL
+}
+
+// FetchGameByURL resolves a Chess.com game URL to the full game, including its PGN.
+// The game is looked up in the white player's monthly archive for the month it ended in.
+func (c *Client) FetchGameByURL(gameURL string) (*Game, error) {
+	kind, id, err := ParseGameURL(gameURL)
+	if err != nil {
+		return nil, err
+	}
+
+	// Find out who played the game and when.
+	var description callbackGame
+	if err := c.getJSON(fmt.Sprintf("%s/%s/game/%s", callbackURL, kind, id), &description); err != nil {
+		return nil, fmt.Errorf("failed to look up game %s: %w", id, err)
+	}
+	white, _ := description.Game.PGNHeaders["White"].(string)
+	if white == "" {
+		return nil, fmt.Errorf("game %s has no white player", id)
+	}
+	ended := time.Unix(description.Game.EndTime, 0).UTC()
+	if description.Game.EndTime == 0 {
+		date, _ := description.Game.PGNHeaders["Date"].(string)
+		if ended, err = time.Parse("2006.01.02", date); err != nil {
+			return nil, fmt.Errorf("game %s has no usable date", id)
+		}
+	}
+
+	// Search the archive of that month, then the following one in case the game ended after midnight.
+	for _, month := range []time.Time{ended, ended.AddDate(0, 1, 0)} {
+		gamesResponse, err := c.FetchPlayerGamesByMonth(white, month.Format("2006"), month.Format("01"))
+		if err != nil {
+			return nil, err
+		}
+		for i := range gamesResponse.Games {
+			if strings.HasSuffix(gamesResponse.Games[i].URL, "/"+id) {
+				return &gamesResponse.Games[i], nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("game %s was not found in %s's archive", id, white)
+}
@@ -0,0 +1,103 @@
+package api
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Titles are the chess titles accepted by the titled players endpoint.
+var Titles = []string{"GM", "WGM", "IM", "WIM", "FM", "WFM", "NM", "WNM", "CM", "WCM"}
+
+// LeaderboardTrend describes how a player's score or rank has changed recently.
+type LeaderboardTrend struct {
+	Direction int `json:"direction"`
+	Delta     int `json:"delta"`
+}
+
+// LeaderboardEntry is a single player on a leaderboard.
+type LeaderboardEntry struct {
+	PlayerID   int              `json:"player_id"`
+	ID         string           `json:"@id"`
+	URL        string           `json:"url"`
+	Username   string           `json:"username"`
+	Score      int              `json:"score"`
+	Rank       int              `json:"rank"`
+	Country    string           `json:"country"`
+	Title      string           `json:"title"`
+	Name       string           `json:"name"`
+	Status     string           `json:"status"`
+	TrendScore LeaderboardTrend `json:"trend_score"`
+	TrendRank  LeaderboardTrend `json:"trend_rank"`
+	WinCount   int              `json:"win_count"`
+	LossCount  int              `json:"loss_count"`
+	DrawCount  int              `json:"draw_count"`
+}
+
+// Leaderboards maps a category (e.g., "live_blitz", "daily") to its top players, ordered by rank.
+type Leaderboards map[string][]LeaderboardEntry
+
+// Categories returns the available leaderboard categories in alphabetical order.
+func (l Leaderboards) Categories() []string {
+	categories := make([]string, 0, len(l))
+	for category := range l {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+	return categories
+}
+
+// TopUsernames returns the usernames of the top n players of a category.
+func (l Leaderboards) TopUsernames(category string, n int) ([]string, error) {
+	entries, ok := l[category]
+	if !ok {
+		return nil, fmt.Errorf("unknown leaderboard %q (available: %s)", category, strings.Join(l.Categories(), ", "))
+	}
+	if n > len(entries) {
+		n = len(entries)
+	}
+	usernames := make([]string, 0, n)
+	for _, entry := range entries[:n] {
+		usernames = append(usernames, entry.Username)
+	}
+	return usernames, nil
+}
+
+// titledPlayersResponse is the structure of the JSON response for the titled players endpoint.
+type titledPlayersResponse struct {
+	Players []string `json:"players"`
+}
+
+// FetchLeaderboards fetches the current leaderboards of every category.
+func (c *Client) FetchLeaderboards() (Leaderboards, error) {
+	url := fmt.Sprintf("%s/leaderboards", baseURL)
+
+	var leaderboards Leaderboards
+	if err := c.getJSON(url, &leaderboards); err != nil {
+		return nil, err
+	}
+	return leaderboards, nil
+}
+
+// FetchTitledPlayers fetches the usernames of all players holding a title (e.g., "GM").
+func (c *Client) FetchTitledPlayers(title string) ([]string, error) {
+	title = strings.ToUpper(title)
+	valid := false
+	for _, t := range Titles {
+		if t == title {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return nil, fmt.Errorf("unknown title %q (available: %s)", title, strings.Join(Titles, ", "))
+	}
+
+	url := fmt.Sprintf("%s/titled/%s", baseURL, title)
+
+	var response titledPlayersResponse
+	if err := c.getJSON(url, &response); err != nil {
+		return nil, err
+	}
+	return response.Players, nil
+}
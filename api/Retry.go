@@ -0,0 +1,106 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultRetries is the retry budget of a new client: how many times in all its requests that fail
+// with a server error or a network error, or whose response is cut short, are tried again.
+const DefaultRetries = 10
+
+const (
+	// maxRequestRetries is how many times a single request is tried again, so that one request
+	// failing over and over does not use up the whole budget.
+	maxRequestRetries = 3
+	// firstRetryDelay is the wait before the first retry of a request; it doubles with every retry.
+	firstRetryDelay = time.Second
+)
+
+// errServer is returned by checkStatus for a 5xx response other than a maintenance page, which a
+// retry may get past.
+var errServer = errors.New("server error")
+
+// retryBudget counts the retries a client has made, shared between its concurrent requests.
+type retryBudget struct {
+	used atomic.Int64
+}
+
+// take uses up one retry of a budget of limit, reporting whether there was one left.
+func (b *retryBudget) take(limit int) bool {
+	if b.used.Add(1) <= int64(limit) {
+		return true
+	}
+	b.used.Add(-1)
+	return false
+}
+
+// retry runs request, which sends a GET request and reads its response, trying it again with an
+// exponential backoff while it fails as isRetryable says and the client's retry budget lasts.
+func (c *Client) retry(request func() error) error {
+	delay := firstRetryDelay
+	for retry := 0; ; retry++ {
+		err := request()
+		if err == nil || !isRetryable(err) {
+			return err
+		}
+		if retry == maxRequestRetries || !c.retries.take(c.Retries) {
+			if retry > 0 {
+				return fmt.Errorf("%w (gave up after %d retries)", err, retry)
+			}
+			return err
+		}
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// try sends one GET request for url, returning the response if it holds the data asked for, or the
+// error checkStatus finds in it.
+func (c *Client) try(url string) (*http.Response, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	// It's good practice to set a User-Agent header.
+	req.Header.Set("User-Agent", "Go-Chess.com-API-Client/1.0 (your-contact-info)")
+	// Asking for gzip explicitly turns off the transport's own decompression, so that it works the
+	// same with any HTTPClient.
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, &networkError{err}
+	}
+	if err := checkStatus(resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// networkError is a request that got no response: the connection failed, was cut or timed out.
+type networkError struct {
+	err error
+}
+
+func (e *networkError) Error() string {
+	return "failed to execute request: " + e.err.Error()
+}
+
+func (e *networkError) Unwrap() error {
+	return e.err
+}
+
+// isRetryable reports whether a request may succeed if it is sent again: it failed with a server
+// error, got no response, or lost the connection while its body was being read, which cuts the body
+// short or fails the read with a network error.
+func isRetryable(err error) bool {
+	var network *networkError
+	var netErr net.Error
+	return errors.Is(err, errServer) || errors.As(err, &network) || errors.Is(err, io.ErrUnexpectedEOF) || errors.As(err, &netErr)
+}
@@ -0,0 +1,70 @@
+package api
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+var (
+	// ErrNotFound is returned for something Chess.com does not have: a player, club or tournament
+	// that does not exist, or a monthly archive of a month without games.
+	ErrNotFound = errors.New("not found on chess.com")
+	// ErrAccountClosed is returned when Chess.com refuses to give out a player's data because the
+	// account is closed or restricted.
+	ErrAccountClosed = errors.New("the chess.com account is closed or restricted")
+	// ErrMaintenance is returned when Chess.com answers with its maintenance page instead of data.
+	ErrMaintenance = errors.New("chess.com is down for maintenance, try again later")
+)
+
+// maintenancePageLimit is how much of an HTML page is searched for a maintenance notice.
+const maintenancePageLimit = 64 << 10
+
+// checkStatus returns the error a response stands for, closing its body, or nil for a response
+// holding the data asked for. The API answers in JSON or PGN, so an HTML page in its place, whatever
+// its status code, is checked for a maintenance notice.
+func checkStatus(resp *http.Response) error {
+	html := strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html")
+	if html && isMaintenancePage(resp) {
+		resp.Body.Close()
+		return fmt.Errorf("%w (status code %d)", ErrMaintenance, resp.StatusCode)
+	}
+	var err error
+	switch code := resp.StatusCode; {
+	case code == http.StatusOK && html:
+		err = errors.New("received a web page instead of data")
+	case code == http.StatusOK:
+		return nil
+	case code == http.StatusNotFound:
+		err = ErrNotFound
+	case code == http.StatusForbidden || code == http.StatusGone:
+		err = fmt.Errorf("%w (status code %d)", ErrAccountClosed, code)
+	case code == http.StatusTooManyRequests:
+		err = ErrRateLimited
+	case code >= http.StatusInternalServerError:
+		err = fmt.Errorf("%w: received status code %d", errServer, code)
+	default:
+		err = fmt.Errorf("received non-200 status code: %d", code)
+	}
+	resp.Body.Close()
+	return err
+}
+
+// isMaintenancePage reports whether an HTML response is a maintenance notice, reading the start of
+// its body.
+func isMaintenancePage(resp *http.Response) bool {
+	var body io.Reader = resp.Body
+	if strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+		gz, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return false
+		}
+		defer gz.Close()
+		body = gz
+	}
+	page, _ := io.ReadAll(io.LimitReader(body, maintenancePageLimit))
+	return strings.Contains(strings.ToLower(string(page)), "maintenance")
+}
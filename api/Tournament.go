@@ -0,0 +1,117 @@
+package api
+
+import "fmt"
+
+// TournamentPlayer is a player registered in a tournament.
+type TournamentPlayer struct {
+	Username string `json:"username"`
+	Status   string `json:"status"`
+}
+
+// TournamentSettings holds the configuration of a tournament.
+type TournamentSettings struct {
+	Type             string `json:"type"`
+	Rules            string `json:"rules"`
+	TimeClass        string `json:"time_class"`
+	TimeControl      string `json:"time_control"`
+	IsRated          bool   `json:"is_rated"`
+	IsOfficial       bool   `json:"is_official"`
+	IsInviteOnly     bool   `json:"is_invite_only"`
+	MinRating        int    `json:"min_rating"`
+	MaxRating        int    `json:"max_rating"`
+	TotalRounds      int    `json:"total_rounds"`
+	InitialGroupSize int    `json:"initial_group_size"`
+}
+
+// Tournament holds the details of a Chess.com tournament.
+type Tournament struct {
+	Name        string             `json:"name"`
+	URL         string             `json:"url"`
+	Description string             `json:"description"`
+	Creator     string             `json:"creator"`
+	Status      string             `json:"status"`
+	FinishTime  int64              `json:"finish_time"`
+	Settings    TournamentSettings `json:"settings"`
+	Players     []TournamentPlayer `json:"players"`
+	Rounds      []string           `json:"rounds"`
+}
+
+// TournamentRound lists the players and group URLs of a single tournament round.
+type TournamentRound struct {
+	Players []TournamentPlayer `json:"players"`
+	Groups  []string           `json:"groups"`
+}
+
+// TournamentGroupPlayer holds a player's standing within a round group.
+type TournamentGroupPlayer struct {
+	Username    string  `json:"username"`
+	Points      float64 `json:"points"`
+	TieBreak    float64 `json:"tie_break"`
+	IsAdvancing bool    `json:"is_advancing"`
+}
+
+// TournamentGroup holds the games and standings of a group within a round.
+type TournamentGroup struct {
+	FairPlayRemovals []string                `json:"fair_play_removals"`
+	Games            []Game                  `json:"games"`
+	Players          []TournamentGroupPlayer `json:"players"`
+}
+
+// FetchTournament fetches the details of a tournament.
+// The tournamentID is the URL identifier of the tournament (e.g., "-33rd-chesscom-quick-knockouts-1401-1600").
+func (c *Client) FetchTournament(tournamentID string) (*Tournament, error) {
+	url := fmt.Sprintf("%s/tournament/%s", baseURL, tournamentID)
+
+	var tournament Tournament
+	if err := c.getJSON(url, &tournament); err != nil {
+		return nil, err
+	}
+	return &tournament, nil
+}
+
+// FetchTournamentRound fetches a single round of a tournament. Rounds are numbered from 1.
+func (c *Client) FetchTournamentRound(tournamentID string, round int) (*TournamentRound, error) {
+	url := fmt.Sprintf("%s/tournament/%s/%d", baseURL, tournamentID, round)
+
+	var tournamentRound TournamentRound
+	if err := c.getJSON(url, &tournamentRound); err != nil {
+		return nil, err
+	}
+	return &tournamentRound, nil
+}
+
+// FetchTournamentGroup fetches the games and standings of a group within a tournament round.
+// Groups are numbered from 1.
+func (c *Client) FetchTournamentGroup(tournamentID string, round, group int) (*TournamentGroup, error) {
+	url := fmt.Sprintf("%s/tournament/%s/%d/%d", baseURL, tournamentID, round, group)
+
+	var tournamentGroup TournamentGroup
+	if err := c.getJSON(url, &tournamentGroup); err != nil {
+		return nil, err
+	}
+	return &tournamentGroup, nil
+}
+
+// FetchTournamentGames collects the games of every group in every round of a tournament.
+func (c *Client) FetchTournamentGames(tournamentID string) ([]Game, error) {
+	tournament, err := c.FetchTournament(tournamentID)
+	if err != nil {
+		return nil, err
+	}
+
+	var games []Game
+	for round := 1; round <= len(tournament.Rounds); round++ {
+		tournamentRound, err := c.FetchTournamentRound(tournamentID, round)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch round %d: %w", round, err)
+		}
+		for group := 1; group <= len(tournamentRound.Groups); group++ {
+			tournamentGroup, err := c.FetchTournamentGroup(tournamentID, round, group)
+			if err != nil {
+				return nil, fmt.Errorf("failed to fetch round %d group %d: %w", round, group, err)
+			}
+			games = append(games, tournamentGroup.Games...)
+		}
+	}
+	return games, nil
+}
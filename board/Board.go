@@ -0,0 +1,112 @@
+// Package board renders chess positions as text boards for the terminal.
+package board
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Style is the character set a board is drawn with.
+type Style int
+
+const (
+	// Unicode draws the pieces as chess symbols and the frame with box-drawing characters.
+	Unicode Style = iota
+	// ASCII draws the pieces as FEN letters, for terminals and fonts without the chess symbols.
+	ASCII
+)
+
+// unicodePieces maps FEN piece letters onto the Unicode chess symbols.
+var unicodePieces = map[rune]string{
+	'K': "♔", 'Q': "♕", 'R': "♖", 'B': "♗", 'N': "♘", 'P': "♙",
+	'k': "♚", 'q': "♛", 'r': "♜", 'b': "♝", 'n': "♞", 'p': "♟",
+}
+
+// ParseStyle returns the style with the given name: "unicode" or "ascii".
+func ParseStyle(name string) (Style, error) {
+	switch strings.ToLower(name) {
+	case "unicode":
+		return Unicode, nil
+	case "ascii":
+		return ASCII, nil
+	default:
+		return 0, fmt.Errorf("unknown board style %q (available: unicode, ascii)", name)
+	}
+}
+
+// Render draws the position of a FEN as a board with rank and file labels, White at the bottom
+// unless flipped.
+func Render(fen string, style Style, flipped bool) (string, error) {
+	squares, err := parsePlacement(fen)
+	if err != nil {
+		return "", err
+	}
+	top, side, bottom, empty := "┌─────────────────┐", "│", "└─────────────────┘", "·"
+	if style == ASCII {
+		top, side, bottom, empty = "+-----------------+", "|", "+-----------------+", "."
+	}
+	files := "a b c d e f g h"
+	if flipped {
+		files = "h g f e d c b a"
+	}
+
+	var b strings.Builder
+	b.WriteString("  " + top + "\n")
+	for row := 0; row < 8; row++ {
+		rank := row
+		if flipped {
+			rank = 7 - row
+		}
+		fmt.Fprintf(&b, "%d %s", 8-rank, side)
+		for column := 0; column < 8; column++ {
+			file := column
+			if flipped {
+				file = 7 - column
+			}
+			piece := squares[rank][file]
+			symbol := empty
+			switch {
+			case piece != 0 && style == ASCII:
+				symbol = string(piece)
+			case piece != 0:
+				symbol = unicodePieces[piece]
+			}
+			b.WriteString(" " + symbol)
+		}
+		b.WriteString(" " + side + "\n")
+	}
+	b.WriteString("  " + bottom + "\n")
+	b.WriteString("    " + files + "\n")
+	return b.String(), nil
+}
+
+// parsePlacement reads the piece placement field of a FEN into ranks from the 8th down, files from
+// a to h; empty squares are 0.
+func parsePlacement(fen string) ([8][8]rune, error) {
+	var squares [8][8]rune
+	placement, _, _ := strings.Cut(strings.TrimSpace(fen), " ")
+	ranks := strings.Split(placement, "/")
+	if len(ranks) != 8 {
+		return squares, fmt.Errorf("invalid FEN %q: %d ranks", fen, len(ranks))
+	}
+	for rank, row := range ranks {
+		file := 0
+		for _, r := range row {
+			switch {
+			case r >= '1' && r <= '8':
+				file += int(r - '0')
+			case unicodePieces[r] != "":
+				if file < 8 {
+					squares[rank][file] = r
+				}
+				file++
+			default:
+				return squares, fmt.Errorf("invalid FEN %q: unexpected %q", fen, r)
+			}
+		}
+		if file != 8 {
+			return squares, fmt.Errorf("invalid FEN %q: rank %d has %d squares", fen, 8-rank, file)
+		}
+	}
+	return squares, nil
+}
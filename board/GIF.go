@@ -0,0 +1,116 @@
+package board
+
+import (
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"io"
+	"math"
+)
+
+// Layout and timing of animated GIFs.
+const (
+	evalBarWidth = 14  // Width in pixels of the eval bar left of the board.
+	evalBarClamp = 5.0 // Evaluation in pawns that fills the bar.
+	frameDelay   = 100 // Time a position is shown, in hundredths of a second.
+	lastDelay    = 400 // Time the final position is shown before the animation restarts.
+)
+
+// Colours of the eval bar: White's share at the bottom, Black's at the top.
+var (
+	barWhite = color.RGBA{0xf4, 0xf4, 0xf4, 0xff}
+	barBlack = color.RGBA{0x40, 0x40, 0x40, 0xff}
+)
+
+// Frame is a position of an animated GIF.
+type Frame struct {
+	FEN    string
+	Played string   // Move that led to the position in UCI notation, tinted; empty for the first.
+	Eval   *float64 // Evaluation in pawns from White's point of view, for the eval bar; nil if unknown.
+}
+
+// AnimatedGIF writes the positions of frames as an animated GIF, one position a second and the
+// last one longer, White at the bottom unless flipped. With evalBar, an eval bar left of the board
+// shows each position's evaluation; positions without one keep the bar as it was.
+func AnimatedGIF(w io.Writer, frames []Frame, flipped, evalBar bool) error {
+	animation := &gif.GIF{}
+	var images []*image.RGBA
+	eval := 0.0
+	for _, frame := range frames {
+		boardImage, err := drawBoard(frame.FEN, flipped, frame.Played, "")
+		if err != nil {
+			return err
+		}
+		if !evalBar {
+			images = append(images, boardImage)
+			continue
+		}
+		if frame.Eval != nil {
+			eval = *frame.Eval
+		}
+		size := boardImage.Bounds().Dx()
+		img := image.NewRGBA(image.Rect(0, 0, evalBarWidth+size, size))
+		draw.Draw(img, image.Rect(evalBarWidth, 0, evalBarWidth+size, size), boardImage, image.Point{}, draw.Src)
+		drawEvalBar(img, size, eval, flipped)
+		images = append(images, img)
+	}
+
+	colours := framePalette(images)
+	for i, img := range images {
+		paletted := image.NewPaletted(img.Bounds(), colours)
+		draw.Draw(paletted, img.Bounds(), img, image.Point{}, draw.Src)
+		delay := frameDelay
+		if i == len(images)-1 {
+			delay = lastDelay
+		}
+		animation.Image = append(animation.Image, paletted)
+		animation.Delay = append(animation.Delay, delay)
+	}
+	return gif.EncodeAll(w, animation)
+}
+
+// drawEvalBar fills the eval bar of a frame of the given height: the side of the player at the
+// bottom of the board grows with their advantage.
+func drawEvalBar(img *image.RGBA, height int, eval float64, flipped bool) {
+	share := 0.5 + math.Max(-evalBarClamp, math.Min(evalBarClamp, eval))/(2*evalBarClamp)
+	split := int(math.Round(float64(height) * (1 - share))) // Rows above are Black's.
+	top, bottom := barBlack, barWhite
+	if flipped {
+		split = height - split
+		top, bottom = barWhite, barBlack
+	}
+	for y := 0; y < height; y++ {
+		c := bottom
+		if y < split {
+			c = top
+		}
+		for x := 0; x < evalBarWidth; x++ {
+			img.SetRGBA(x, y, c)
+		}
+	}
+}
+
+// framePalette returns the colours of the frames when they fit in a GIF palette, so that they are
+// shown exactly; otherwise a general palette.
+func framePalette(images []*image.RGBA) color.Palette {
+	seen := make(map[color.RGBA]bool)
+	var colours color.Palette
+	for _, img := range images {
+		bounds := img.Bounds()
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				c := img.RGBAAt(x, y)
+				if !seen[c] {
+					if len(colours) == 256 {
+						return palette.Plan9
+					}
+					seen[c] = true
+					colours = append(colours, c)
+				}
+			}
+		}
+	}
+	return colours
+}
@@ -0,0 +1,287 @@
+package board
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"strings"
+	"unicode"
+)
+
+// pieceMasks are the silhouettes of the pieces in PNG diagrams, drawn on a grid of maskSize by
+// maskSize cells that is scaled up to a square.
+var pieceMasks = map[rune][]string{
+	'p': {
+		"...............",
+		"...............",
+		"......###......",
+		".....#####.....",
+		".....#####.....",
+		"......###......",
+		".....#####.....",
+		"......###......",
+		"......###......",
+		".....#####.....",
+		"....#######....",
+		"...#########...",
+		"...#########...",
+		"...............",
+		"...............",
+	},
+	'r': {
+		"...............",
+		"...............",
+		"...##.###.##...",
+		"...#########...",
+		"...#########...",
+		"....#######....",
+		".....#####.....",
+		".....#####.....",
+		".....#####.....",
+		".....#####.....",
+		"....#######....",
+		"...#########...",
+		"...#########...",
+		"...............",
+		"...............",
+	},
+	'n': {
+		"...............",
+		".......#.......",
+		"......####.....",
+		".....######....",
+		"....###.####...",
+		"...#########...",
+		"...###..####...",
+		"........####...",
+		".......#####...",
+		"......#####....",
+		".....######....",
+		"....########...",
+		"...#########...",
+		"...............",
+		"...............",
+	},
+	'b': {
+		"...............",
+		".......#.......",
+		"......###......",
+		".....##.##.....",
+		".....#.###.....",
+		".....#####.....",
+		"......###......",
+		"......###......",
+		".....#####.....",
+		"......###......",
+		"....#######....",
+		"...#########...",
+		"...#########...",
+		"...............",
+		"...............",
+	},
+	'q': {
+		"...............",
+		"..#...#.#...#..",
+		"..#..##.##..#..",
+		"..##.#####.##..",
+		"..###########..",
+		"...#########...",
+		"....#######....",
+		".....#####.....",
+		".....#####.....",
+		"....#######....",
+		"...#########...",
+		"..###########..",
+		"..###########..",
+		"...............",
+		"...............",
+	},
+	'k': {
+		".......#.......",
+		"......###......",
+		".......#.......",
+		"......###......",
+		"..###.###.###..",
+		".#############.",
+		".#############.",
+		"..###########..",
+		"...#########...",
+		"....#######....",
+		"....#######....",
+		"...#########...",
+		"..###########..",
+		"...............",
+		"...............",
+	},
+}
+
+// maskSize is the number of cells across a piece mask.
+const maskSize = 15
+
+// Colours of the PNG diagrams that the SVG ones set as strings.
+var (
+	lightRGBA      = color.RGBA{0xf0, 0xd9, 0xb5, 0xff}
+	darkRGBA       = color.RGBA{0xb5, 0x88, 0x63, 0xff}
+	playedRGBA     = color.RGBA{0xe0, 0x50, 0x50, 0xff}
+	bestRGBA       = color.RGBA{0x3a, 0x9a, 0x50, 0xff}
+	whitePieceFill = color.RGBA{0xff, 0xff, 0xff, 0xff}
+	blackPieceFill = color.RGBA{0x20, 0x20, 0x20, 0xff}
+)
+
+// PNG draws the position of a FEN as a PNG image, like SVG: White at the bottom unless flipped,
+// the squares of played tinted and best drawn as an arrow. As PNG viewers have no chess font, the
+// pieces are drawn as silhouettes.
+func PNG(fen string, flipped bool, played, best string) ([]byte, error) {
+	img, err := drawBoard(fen, flipped, played, best)
+	if err != nil {
+		return nil, err
+	}
+	var out bytes.Buffer
+	if err := png.Encode(&out, img); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// drawBoard draws the image of PNG.
+func drawBoard(fen string, flipped bool, played, best string) (*image.RGBA, error) {
+	squares, err := parsePlacement(fen)
+	if err != nil {
+		return nil, err
+	}
+	// origin returns the top left corner of a square given as e.g. "e4", and whether it is one.
+	origin := func(square string) (int, int, bool) {
+		if len(square) != 2 || square[0] < 'a' || square[0] > 'h' || square[1] < '1' || square[1] > '8' {
+			return 0, 0, false
+		}
+		column, row := int(square[0]-'a'), int('8'-square[1])
+		if flipped {
+			column, row = 7-column, 7-row
+		}
+		return column * squareSize, row * squareSize, true
+	}
+
+	size := 8 * squareSize
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			if (x/squareSize+y/squareSize)%2 == 1 {
+				img.SetRGBA(x, y, darkRGBA)
+			} else {
+				img.SetRGBA(x, y, lightRGBA)
+			}
+		}
+	}
+	if len(played) >= 4 {
+		for _, square := range []string{played[0:2], played[2:4]} {
+			if left, top, ok := origin(square); ok {
+				for y := top; y < top+squareSize; y++ {
+					for x := left; x < left+squareSize; x++ {
+						blend(img, x, y, playedRGBA, 0.45)
+					}
+				}
+			}
+		}
+	}
+	for rank := 0; rank < 8; rank++ {
+		for file := 0; file < 8; file++ {
+			if piece := squares[rank][file]; piece != 0 {
+				left, top, _ := origin(string([]rune{rune('a' + file), rune('8' - rank)}))
+				drawPiece(img, left, top, piece)
+			}
+		}
+	}
+	if len(best) >= 4 {
+		x1, y1, ok1 := origin(best[0:2])
+		x2, y2, ok2 := origin(best[2:4])
+		if ok1 && ok2 {
+			half := float64(squareSize) / 2
+			drawArrow(img, float64(x1)+half, float64(y1)+half, float64(x2)+half, float64(y2)+half)
+		}
+	}
+	return img, nil
+}
+
+// drawPiece draws a piece, given by its FEN letter, on the square with the top left corner at
+// left, top: its silhouette filled in the piece's colour and outlined in the other.
+func drawPiece(img *image.RGBA, left, top int, piece rune) {
+	mask := pieceMasks[unicode.ToLower(piece)]
+	fill, outline := whitePieceFill, blackPieceFill
+	if unicode.IsLower(piece) {
+		fill, outline = blackPieceFill, whitePieceFill
+	}
+	// The mask is centred in the square; cells are a whole number of pixels.
+	cell := squareSize / maskSize
+	offset := (squareSize - cell*maskSize) / 2
+	inside := func(x, y int) bool {
+		if x < 0 || y < 0 || x >= cell*maskSize || y >= cell*maskSize {
+			return false
+		}
+		return mask[y/cell][x/cell] == '#'
+	}
+	for y := 0; y < cell*maskSize; y++ {
+		for x := 0; x < cell*maskSize; x++ {
+			if !inside(x, y) {
+				continue
+			}
+			c := fill
+			if !inside(x-1, y) || !inside(x+1, y) || !inside(x, y-1) || !inside(x, y+1) {
+				c = outline
+			}
+			img.SetRGBA(left+offset+x, top+offset+y, c)
+		}
+	}
+}
+
+// drawArrow draws the engine's best move as an arrow from one square centre to another.
+func drawArrow(img *image.RGBA, x1, y1, x2, y2 float64) {
+	const width, headLength, headWidth = 4.0, 16.0, 11.0
+	length := math.Hypot(x2-x1, y2-y1)
+	if length == 0 {
+		return
+	}
+	// Unit vectors along the arrow and across it.
+	ux, uy := (x2-x1)/length, (y2-y1)/length
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			px, py := float64(x)+0.5-x1, float64(y)+0.5-y1
+			along, across := px*ux+py*uy, math.Abs(-px*uy+py*ux)
+			shaft := along >= 0 && along <= length-headLength && across <= width
+			head := along > length-headLength && along <= length && across <= headWidth*(length-along)/headLength
+			if shaft || head {
+				blend(img, x, y, bestRGBA, 0.8)
+			}
+		}
+	}
+}
+
+// blend paints a pixel with a colour of the given opacity over what is there.
+func blend(img *image.RGBA, x, y int, c color.RGBA, opacity float64) {
+	under := img.RGBAAt(x, y)
+	mix := func(a, b uint8) uint8 {
+		return uint8(math.Round(float64(a)*(1-opacity) + float64(b)*opacity))
+	}
+	img.SetRGBA(x, y, color.RGBA{mix(under.R, c.R), mix(under.G, c.G), mix(under.B, c.B), 0xff})
+}
+
+// ParseDiagramFormat checks the name of a diagram image format: "svg" or "png".
+func ParseDiagramFormat(name string) (string, error) {
+	switch format := strings.ToLower(name); format {
+	case "svg", "png":
+		return format, nil
+	}
+	return "", fmt.Errorf("unknown diagram format %q (available: svg, png)", name)
+}
+
+// Diagram draws a position as an image in the given format, "svg" or "png"; see SVG and PNG.
+func Diagram(format, fen string, flipped bool, played, best string) ([]byte, error) {
+	if format == "png" {
+		return PNG(fen, flipped, played, best)
+	}
+	svg, err := SVG(fen, flipped, played, best)
+	return []byte(svg), err
+}
@@ -0,0 +1,81 @@
+package board
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Colours of the SVG diagrams.
+const (
+	lightSquare  = "#f0d9b5"
+	darkSquare   = "#b58863"
+	playedColour = "#e05050" // Squares of the move played.
+	bestColour   = "#3a9a50" // Arrow of the engine's best move.
+	squareSize   = 45
+)
+
+// SVG draws the position of a FEN as an SVG image, White at the bottom unless flipped. The squares
+// of played, a move in UCI notation, are tinted and best, another, is drawn as an arrow; either can
+// be empty. Pieces are the Unicode chess symbols, which every browser can show.
+func SVG(fen string, flipped bool, played, best string) (string, error) {
+	squares, err := parsePlacement(fen)
+	if err != nil {
+		return "", err
+	}
+	// center returns the centre of a square given as e.g. "e4", and whether it is one.
+	center := func(square string) (float64, float64, bool) {
+		if len(square) != 2 || square[0] < 'a' || square[0] > 'h' || square[1] < '1' || square[1] > '8' {
+			return 0, 0, false
+		}
+		column, row := int(square[0]-'a'), int('8'-square[1])
+		if flipped {
+			column, row = 7-column, 7-row
+		}
+		return (float64(column) + 0.5) * squareSize, (float64(row) + 0.5) * squareSize, true
+	}
+
+	size := 8 * squareSize
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`+"\n", size, size, size, size)
+	fmt.Fprintf(&b, `<defs><marker id="head" markerWidth="4" markerHeight="4" refX="2" refY="2" orient="auto">`+
+		`<path d="M0,0 L4,2 L0,4 z" fill="%s"/></marker></defs>`+"\n", bestColour)
+	for row := 0; row < 8; row++ {
+		for column := 0; column < 8; column++ {
+			colour := lightSquare
+			if (row+column)%2 == 1 {
+				colour = darkSquare
+			}
+			fmt.Fprintf(&b, `<rect x="%d" y="%d" width="%d" height="%d" fill="%s"/>`+"\n",
+				column*squareSize, row*squareSize, squareSize, squareSize, colour)
+		}
+	}
+	if len(played) >= 4 {
+		for _, square := range []string{played[0:2], played[2:4]} {
+			if x, y, ok := center(square); ok {
+				fmt.Fprintf(&b, `<rect x="%g" y="%g" width="%d" height="%d" fill="%s" fill-opacity="0.45"/>`+"\n",
+					x-squareSize/2.0, y-squareSize/2.0, squareSize, squareSize, playedColour)
+			}
+		}
+	}
+	for rank := 0; rank < 8; rank++ {
+		for file := 0; file < 8; file++ {
+			piece := squares[rank][file]
+			if piece == 0 {
+				continue
+			}
+			x, y, _ := center(fmt.Sprintf("%c%d", 'a'+file, 8-rank))
+			fmt.Fprintf(&b, `<text x="%g" y="%g" font-size="%d" text-anchor="middle" dominant-baseline="central">%s</text>`+"\n",
+				x, y, squareSize*4/5, unicodePieces[piece])
+		}
+	}
+	if len(best) >= 4 {
+		x1, y1, ok1 := center(best[0:2])
+		x2, y2, ok2 := center(best[2:4])
+		if ok1 && ok2 {
+			fmt.Fprintf(&b, `<line x1="%g" y1="%g" x2="%g" y2="%g" stroke="%s" stroke-width="8" stroke-opacity="0.8" marker-end="url(#head)"/>`+"\n",
+				x1, y1, x2, y2, bestColour)
+		}
+	}
+	b.WriteString("</svg>\n")
+	return b.String(), nil
+}
@@ -0,0 +1,238 @@
+// Package engine starts the UCI engine that analyses games, such as Stockfish, with the search
+// limits and resources it is to use. It is the engine part of the public API of the analyser,
+// together with packages source and analysis.
+package engine
+
+import (
+	"chessAnalyserFree/analysis"
+	"chessAnalyserFree/api"
+	gameengine "chessAnalyserFree/gameEngine"
+	"chessAnalyserFree/source"
+	"io"
+	"time"
+)
+
+// Engine is a running engine, analysing one game at a time. Close ends it.
+type Engine struct {
+	analyser *gameengine.StockfishAnalyser
+}
+
+// Connector starts a UCI engine, or connects to one, for Connect. It is called again for every
+// engine spawned for parallel analysis and to restart an engine that stopped answering.
+type Connector func() (io.ReadWriteCloser, error)
+
+// Preset is a named set of search limits, e.g. "standard".
+type Preset struct {
+	Name          string        // Name recorded with analyses, e.g. "standard" or "standard, depth 18".
+	Key           string        // Name of the preset it derives from, e.g. "standard"; the Name if empty.
+	MoveTime      time.Duration // Search time per position; 0 means no time limit.
+	Depth         int           // Search depth per position; 0 means no depth limit.
+	MultiPV       int           // Number of principal variations the engine reports.
+	SkipBookPlies int           // Number of opening plies that are not analysed.
+	Thresholds    Thresholds
+}
+
+// Thresholds are the centipawn losses from which a move is an inaccuracy, a mistake or a blunder.
+type Thresholds struct {
+	Inaccuracy int
+	Mistake    int
+	Blunder    int
+}
+
+// DefaultTimeout is how long an engine may print nothing while it is expected to answer.
+const DefaultTimeout = gameengine.DefaultResponseTimeout
+
+// Options are the settings of an engine started by Start. The zero value searches with the
+// standard preset and the engine's own threads and hash size, and waits forever for answers.
+type Options struct {
+	Preset  Preset        // Search limits; the standard preset if it has no name.
+	Threads int           // Search threads; 0 for the engine's default.
+	Hash    int           // Hash table size in MB; 0 for the engine's default.
+	Timeout time.Duration // How long the engine may stay silent before it is stopped; 0 waits forever.
+}
+
+// DefaultOptions returns the options the command line starts engines with unless told otherwise.
+func DefaultOptions() Options {
+	return Options{Preset: newPreset(gameengine.Presets[gameengine.DefaultPresetName]), Timeout: DefaultTimeout}
+}
+
+// LookupPreset returns the preset with the given name, e.g. quick, standard or deep.
+func LookupPreset(name string) (Preset, error) {
+	preset, err := gameengine.LookupPreset(name)
+	return newPreset(preset), err
+}
+
+// PresetNames returns the names of the built-in presets in alphabetical order.
+func PresetNames() []string {
+	return gameengine.PresetNames()
+}
+
+// Start starts the engine at path and applies the options. An engine that cannot be configured is
+// closed again.
+func Start(path string, options Options) (*Engine, error) {
+	return Connect(Connector(gameengine.ProcessConnector(path)), options)
+}
+
+// Dial connects to a UCI engine served over TCP at address ("host:port") and applies the options.
+func Dial(address string, options Options) (*Engine, error) {
+	return Connect(Connector(gameengine.TCPConnector(address)), options)
+}
+
+// StartMock starts the in-memory mock engine and applies the options. Its analyses are instant and
+// deterministic, ranking moves by material only, which suits tests of code built on the analysis.
+func StartMock(options Options) (*Engine, error) {
+	return Connect(gameengine.ConnectMock, options)
+}
+
+// Connect starts an engine with connect and applies the options. An engine that cannot be
+// configured is closed again.
+func Connect(connect Connector, options Options) (*Engine, error) {
+	analyser, err := gameengine.NewUCIAnalyser(gameengine.Connector(connect))
+	if err != nil {
+		return nil, err
+	}
+	engine := &Engine{analyser: analyser}
+	if err := engine.Configure(options); err != nil {
+		engine.Close()
+		return nil, err
+	}
+	return engine, nil
+}
+
+// Configure applies the options to the running engine. Engines it spawns, e.g. for parallel
+// analysis, take them over.
+func (e *Engine) Configure(options Options) error {
+	if options.Preset.Name == "" {
+		options.Preset = newPreset(gameengine.Presets[gameengine.DefaultPresetName])
+	}
+	e.analyser.SetResponseTimeout(options.Timeout)
+	if err := e.SetPreset(options.Preset); err != nil {
+		return err
+	}
+	if options.Threads > 0 {
+		if err := e.analyser.SetThreads(options.Threads); err != nil {
+			return err
+		}
+	}
+	if options.Hash > 0 {
+		if err := e.analyser.SetHash(options.Hash); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Name returns the name the engine reported, e.g. "Stockfish 16".
+func (e *Engine) Name() string {
+	return e.analyser.EngineName()
+}
+
+// Preset returns the preset the engine searches with.
+func (e *Engine) Preset() Preset {
+	return newPreset(e.analyser.Preset())
+}
+
+// SetPreset changes the preset of the analyses that follow.
+func (e *Engine) SetPreset(preset Preset) error {
+	return e.analyser.SetPreset(preset.gameEngine())
+}
+
+// AnalyseGame analyses every move of a game with the engine's preset. Games of variants and games
+// without moves fail with analysis.ErrUnsupportedVariant and analysis.ErrNotAnalysable.
+func (e *Engine) AnalyseGame(game source.Game) (*analysis.Result, error) {
+	result, err := e.analyser.AnalyseGame(apiGame(game))
+	if err != nil {
+		return nil, err
+	}
+	return newResult(result), nil
+}
+
+// AnalyseAll analyses the games on workers engines, the engine and workers-1 spawned from it with its
+// settings, which are closed before AnalyseAll returns. done is called on the calling goroutine for
+// every game, in the order of the games, with the game's analysis or the error it failed with.
+func (e *Engine) AnalyseAll(workers int, games []source.Game, done func(i int, result *analysis.Result, err error)) error {
+	pool, err := gameengine.NewPool(e.analyser, max(workers, 1))
+	if err != nil {
+		return err
+	}
+	defer pool.Close()
+	apiGames := make([]api.Game, len(games))
+	for i, game := range games {
+		apiGames[i] = apiGame(game)
+	}
+	analyse := func(worker *gameengine.StockfishAnalyser, i int, game api.Game) gameengine.GameResult {
+		result, err := worker.AnalyseGame(game)
+		return gameengine.GameResult{Analysis: result, Err: err}
+	}
+	pool.AnalyseGames(apiGames, analyse, func(i int, result gameengine.GameResult) {
+		if result.Err != nil {
+			done(i, nil, result.Err)
+			return
+		}
+		done(i, newResult(result.Analysis), nil)
+	})
+	return nil
+}
+
+// Benchmark searches a fixed set of opening, middlegame and endgame positions with a preset and
+// returns the average time per position. The engine's own preset is left as it was.
+func (e *Engine) Benchmark(preset Preset) (time.Duration, error) {
+	return e.analyser.Benchmark(preset.gameEngine())
+}
+
+// Skip interrupts the game being analysed, which fails with analysis.ErrSkipped. It is safe to call
+// from another goroutine.
+func (e *Engine) Skip() {
+	e.analyser.Skip()
+}
+
+// Close ends the engine.
+func (e *Engine) Close() {
+	e.analyser.Close()
+}
+
+// newPreset returns a preset of package gameEngine as a Preset.
+func newPreset(preset gameengine.Preset) Preset {
+	return Preset{
+		Name: preset.Name, Key: preset.Key, MoveTime: preset.MoveTime, Depth: preset.Depth, MultiPV: preset.MultiPV,
+		SkipBookPlies: preset.SkipBookPlies, Thresholds: Thresholds(preset.Thresholds),
+	}
+}
+
+// gameEngine returns the preset as a preset of package gameEngine.
+func (p Preset) gameEngine() gameengine.Preset {
+	key := p.Key
+	if key == "" {
+		key = p.Name
+	}
+	return gameengine.Preset{
+		Name: p.Name, Key: key, MoveTime: p.MoveTime, Depth: p.Depth, MultiPV: p.MultiPV,
+		SkipBookPlies: p.SkipBookPlies, Thresholds: gameengine.Thresholds(p.Thresholds),
+	}
+}
+
+// apiGame returns a game as a game of package api, which the analyser takes.
+func apiGame(game source.Game) api.Game {
+	return api.Game{
+		URL: game.URL, PGN: game.PGN, TimeControl: game.TimeControl, EndTime: game.EndTime, Rated: game.Rated,
+		FEN: game.FEN, TimeClass: game.TimeClass, Rules: game.Rules, White: apiPlayer(game.White), Black: apiPlayer(game.Black),
+	}
+}
+
+// apiPlayer returns a player as a player of package api.
+func apiPlayer(player source.Player) api.Player {
+	return api.Player{Username: player.Username, Rating: player.Rating, Result: player.Result, Bot: player.Bot}
+}
+
+// newResult returns an analysis of package gameEngine as an *analysis.Result.
+func newResult(gameAnalysis *gameengine.GameAnalysis) *analysis.Result {
+	result := &analysis.Result{Preset: gameAnalysis.Preset, Engine: gameAnalysis.Engine, Issues: gameAnalysis.Issues}
+	for _, move := range gameAnalysis.Moves {
+		result.Moves = append(result.Moves, analysis.Move{
+			Number: move.MoveNumber, Move: move.Move, Evaluation: move.Evaluation, EvaluationText: move.EvaluationText,
+			CentipawnLoss: move.CentipawnLoss, Classification: move.Classification, MaterialLoss: move.MaterialLoss,
+			Depth: move.Depth, BestMove: move.BestMove,
+		})
+	}
+	return result
+}
@@ -0,0 +1,299 @@
+package gamedb
+
+import (
+	"bytes"
+	"chessAnalyserFree/api"
+	gameengine "chessAnalyserFree/gameEngine"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	gamesBucket  = []byte("games")  // game URL -> api.Game as JSON
+	monthsBucket = []byte("months") // "source/username/YYYY-MM" -> MonthRecord as JSON
+	// analysesBucket maps game URL + "\x00" + analyser settings to a gameengine.GameAnalysis as JSON.
+	analysesBucket = []byte("analyses")
+	hashesBucket   = []byte("pgn-hashes") // game URL -> SHA-256 of the PGN stored with the game, hex
+	// evalsBucket maps search settings + position to a gameengine.PositionEval as JSON.
+	evalsBucket = []byte("position-evals")
+	syncBucket  = []byte("sync-marks") // "source/username" -> SyncMark as JSON
+)
+
+// boltStore is the embedded backend, a single bbolt file.
+type boltStore struct {
+	bolt *bolt.DB
+}
+
+// openBolt opens the bbolt database file at path, creating it if needed. Only one process can have
+// the database open at a time.
+func openBolt(path string) (*boltStore, error) {
+	db, err := bolt.Open(path, 0o644, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open game database %s: %w", path, err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{gamesBucket, monthsBucket, analysesBucket, hashesBucket, evalsBucket, syncBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialise game database %s: %w", path, err)
+	}
+	return &boltStore{bolt: db}, nil
+}
+
+// Close closes the database.
+func (d *boltStore) Close() error {
+	return d.bolt.Close()
+}
+
+// Month implements Store.
+func (d *boltStore) Month(source, username string, month time.Time) (*MonthRecord, error) {
+	var record *MonthRecord
+	err := d.bolt.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(monthsBucket).Get([]byte(monthKey(source, username, month)))
+		if data == nil {
+			return nil
+		}
+		record = &MonthRecord{}
+		return json.Unmarshal(data, record)
+	})
+	return record, err
+}
+
+// SaveMonth implements Store.
+func (d *boltStore) SaveMonth(source, username string, month time.Time, games []api.Game, fetchedAt time.Time) error {
+	record := newMonthRecord(month, games, fetchedAt)
+	return d.bolt.Update(func(tx *bolt.Tx) error {
+		for _, game := range games {
+			if game.URL == "" {
+				continue
+			}
+			if err := putGame(tx, game); err != nil {
+				return err
+			}
+			record.URLs = append(record.URLs, game.URL)
+		}
+		data, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		return tx.Bucket(monthsBucket).Put([]byte(monthKey(source, username, month)), data)
+	})
+}
+
+// putGame stores a game together with the hash of its PGN, which db verify checks it against.
+func putGame(tx *bolt.Tx, game api.Game) error {
+	data, err := json.Marshal(game)
+	if err != nil {
+		return err
+	}
+	if err := tx.Bucket(gamesBucket).Put([]byte(game.URL), data); err != nil {
+		return err
+	}
+	return tx.Bucket(hashesBucket).Put([]byte(game.URL), []byte(pgnHash(game.PGN)))
+}
+
+// GamesByURL implements Store.
+func (d *boltStore) GamesByURL(urls []string) ([]api.Game, error) {
+	var games []api.Game
+	err := d.bolt.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(gamesBucket)
+		for _, url := range urls {
+			data := bucket.Get([]byte(url))
+			if data == nil {
+				continue
+			}
+			var game api.Game
+			if err := json.Unmarshal(data, &game); err != nil {
+				return fmt.Errorf("stored game %s: %w", url, err)
+			}
+			games = append(games, game)
+		}
+		return nil
+	})
+	return games, err
+}
+
+// PlayerGames implements Store. It reads every stored game.
+func (d *boltStore) PlayerGames(username string, from, to time.Time) ([]api.Game, error) {
+	var games []api.Game
+	err := d.bolt.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(gamesBucket).ForEach(func(key, data []byte) error {
+			var game api.Game
+			if err := json.Unmarshal(data, &game); err != nil {
+				return fmt.Errorf("stored game %s: %w", key, err)
+			}
+			if !strings.EqualFold(game.White.Username, username) && !strings.EqualFold(game.Black.Username, username) {
+				return nil
+			}
+			ended := time.Unix(game.EndTime, 0)
+			if ended.Before(from) || ended.After(to) {
+				return nil
+			}
+			games = append(games, game)
+			return nil
+		})
+	})
+	sort.SliceStable(games, func(i, j int) bool { return games[i].EndTime < games[j].EndTime })
+	return games, err
+}
+
+// analysisKey returns the key of a game's analysis with the given analyser settings.
+func analysisKey(gameURL, settings string) []byte {
+	return []byte(gameURL + "\x00" + settings)
+}
+
+// LoadAnalysis implements gameengine.AnalysisStore.
+func (d *boltStore) LoadAnalysis(gameURL, settings string) (*gameengine.GameAnalysis, error) {
+	var analysis *gameengine.GameAnalysis
+	err := d.bolt.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(analysesBucket).Get(analysisKey(gameURL, settings))
+		if data == nil {
+			return nil
+		}
+		analysis = &gameengine.GameAnalysis{}
+		return json.Unmarshal(data, analysis)
+	})
+	return analysis, err
+}
+
+// SaveAnalysis implements gameengine.AnalysisStore. The game is stored too, so that every stored
+// analysis can be checked against its game, including games read from PGN files.
+func (d *boltStore) SaveAnalysis(game api.Game, settings string, analysis *gameengine.GameAnalysis) error {
+	data, err := json.Marshal(analysis)
+	if err != nil {
+		return err
+	}
+	return d.bolt.Update(func(tx *bolt.Tx) error {
+		if err := putGame(tx, game); err != nil {
+			return err
+		}
+		return tx.Bucket(analysesBucket).Put(analysisKey(game.URL, settings), data)
+	})
+}
+
+// SyncMark implements Store.
+func (d *boltStore) SyncMark(source, username string) (*SyncMark, error) {
+	var mark *SyncMark
+	err := d.bolt.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(syncBucket).Get([]byte(syncKey(source, username)))
+		if data == nil {
+			return nil
+		}
+		mark = &SyncMark{}
+		return json.Unmarshal(data, mark)
+	})
+	return mark, err
+}
+
+// SaveSyncMark implements Store.
+func (d *boltStore) SaveSyncMark(source, username string, mark SyncMark) error {
+	data, err := json.Marshal(mark)
+	if err != nil {
+		return err
+	}
+	return d.bolt.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(syncBucket).Put([]byte(syncKey(source, username)), data)
+	})
+}
+
+// LoadPositionEval implements gameengine.EvalStore.
+func (d *boltStore) LoadPositionEval(key string) (*gameengine.PositionEval, error) {
+	var eval *gameengine.PositionEval
+	err := d.bolt.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(evalsBucket).Get([]byte(key))
+		if data == nil {
+			return nil
+		}
+		eval = &gameengine.PositionEval{}
+		return json.Unmarshal(data, eval)
+	})
+	return eval, err
+}
+
+// SavePositionEval implements gameengine.EvalStore.
+func (d *boltStore) SavePositionEval(key string, eval gameengine.PositionEval) error {
+	data, err := json.Marshal(eval)
+	if err != nil {
+		return err
+	}
+	return d.bolt.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(evalsBucket).Put([]byte(key), data)
+	})
+}
+
+// Verify implements Store.
+func (d *boltStore) Verify(repair bool) (*VerifyReport, error) {
+	return verify(d, repair)
+}
+
+// eachGame implements records.
+func (d *boltStore) eachGame(fn func(url string, data []byte, hash string) error) error {
+	return d.bolt.View(func(tx *bolt.Tx) error {
+		hashes := tx.Bucket(hashesBucket)
+		return tx.Bucket(gamesBucket).ForEach(func(key, data []byte) error {
+			return fn(string(key), data, string(hashes.Get(key)))
+		})
+	})
+}
+
+// eachMonth implements records.
+func (d *boltStore) eachMonth(fn func(key string, data []byte) error) error {
+	return d.bolt.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(monthsBucket).ForEach(func(key, data []byte) error {
+			return fn(string(key), data)
+		})
+	})
+}
+
+// eachAnalysis implements records. A malformed key without settings, which SaveAnalysis never
+// writes, is passed on as a game URL with empty settings.
+func (d *boltStore) eachAnalysis(fn func(url, settings string, data []byte) error) error {
+	return d.bolt.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(analysesBucket).ForEach(func(key, data []byte) error {
+			url, settings, _ := bytes.Cut(key, []byte{0})
+			return fn(string(url), string(settings), data)
+		})
+	})
+}
+
+// applyRepairs implements records.
+func (d *boltStore) applyRepairs(repairs []repair) error {
+	return d.bolt.Update(func(tx *bolt.Tx) error {
+		for _, change := range repairs {
+			key := []byte(change.key)
+			var err error
+			switch change.kind {
+			case repairDeleteGame:
+				if err = tx.Bucket(gamesBucket).Delete(key); err == nil {
+					err = tx.Bucket(hashesBucket).Delete(key)
+				}
+			case repairSetHash:
+				err = tx.Bucket(hashesBucket).Put(key, change.value)
+			case repairDeleteMonth:
+				err = tx.Bucket(monthsBucket).Delete(key)
+			case repairPutMonth:
+				err = tx.Bucket(monthsBucket).Put(key, change.value)
+			case repairDeleteAnalysis:
+				// Delete both forms, so that malformed keys without settings are removed too.
+				if err = tx.Bucket(analysesBucket).Delete(analysisKey(change.key, change.settings)); err == nil && change.settings == "" {
+					err = tx.Bucket(analysesBucket).Delete(key)
+				}
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
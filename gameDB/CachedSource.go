@@ -0,0 +1,75 @@
+package gamedb
+
+import (
+	"chessAnalyserFree/api"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// CachedSource is a game source that serves months already fetched completely from the database
+// and fetches the others, month by month, from the wrapped source, storing what it gets.
+type CachedSource struct {
+	Source api.GameSource
+	DB     Store
+	// Offline serves every month from the database without fetching, however incomplete.
+	Offline bool
+	// Hits and Misses count the months served from the database and fetched from the source. Several
+	// months may be fetched at once.
+	Hits, Misses atomic.Int64
+}
+
+// Name returns the name of the wrapped source.
+func (c *CachedSource) Name() string {
+	return c.Source.Name()
+}
+
+// FetchGames returns the games a player finished between from and to, inclusive. A month that
+// cannot be fetched is reported in the returned error and left unrecorded, so that it is fetched
+// again next time.
+func (c *CachedSource) FetchGames(username string, from, to time.Time) ([]api.Game, error) {
+	if c.Offline {
+		return c.DB.PlayerGames(username, from, to)
+	}
+
+	var games []api.Game
+	var errs []error
+	for month := time.Date(from.Year(), from.Month(), 1, 0, 0, 0, 0, time.UTC); !month.After(to); month = month.AddDate(0, 1, 0) {
+		monthGames, err := c.fetchMonth(username, month)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", month.Format("2006-01"), err))
+		}
+		for _, game := range monthGames {
+			ended := time.Unix(game.EndTime, 0)
+			if !ended.Before(from) && !ended.After(to) {
+				games = append(games, game)
+			}
+		}
+	}
+	return games, errors.Join(errs...)
+}
+
+// fetchMonth returns a month of a player's games, from the database if the month was fetched
+// completely before.
+func (c *CachedSource) fetchMonth(username string, month time.Time) ([]api.Game, error) {
+	record, err := c.DB.Month(c.Source.Name(), username, month)
+	if err != nil {
+		return nil, err
+	}
+	if record != nil && record.Complete {
+		c.Hits.Add(1)
+		return c.DB.GamesByURL(record.URLs)
+	}
+
+	c.Misses.Add(1)
+	fetchedAt := time.Now()
+	games, err := c.Source.FetchGames(username, month, month.AddDate(0, 1, 0).Add(-time.Second))
+	if err != nil {
+		return games, err
+	}
+	if err := c.DB.SaveMonth(c.Source.Name(), username, month, games, fetchedAt); err != nil {
+		return games, fmt.Errorf("failed to store games: %w", err)
+	}
+	return games, nil
+}
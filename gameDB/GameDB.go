@@ -0,0 +1,122 @@
+package gamedb
+
+import (
+	"chessAnalyserFree/api"
+	gameengine "chessAnalyserFree/gameEngine"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Store is a local database of fetched games, keyed by game URL, that remembers which months of a
+// player's games have been fetched from which source. It also stores finished analyses as a
+// gameengine.AnalysisStore, and engine evaluations of positions as a gameengine.EvalStore. Open
+// selects the backend.
+type Store interface {
+	// Month returns the record of a fetched month, or nil if the month has not been fetched.
+	Month(source, username string, month time.Time) (*MonthRecord, error)
+	// SaveMonth stores the games of a fetched month and records the month. Games without a URL
+	// cannot be keyed and are not stored.
+	SaveMonth(source, username string, month time.Time, games []api.Game, fetchedAt time.Time) error
+	// GamesByURL returns the stored games with the given URLs, skipping URLs that are not stored.
+	GamesByURL(urls []string) ([]api.Game, error)
+	// PlayerGames returns the stored games of a player that ended between from and to, inclusive, in
+	// the order they ended. It works offline, whichever source the games came from.
+	PlayerGames(username string, from, to time.Time) ([]api.Game, error)
+	// SyncMark returns where the last sync of a player's games from a source stopped, or nil if the
+	// player was never synced.
+	SyncMark(source, username string) (*SyncMark, error)
+	// SaveSyncMark records where a sync of a player's games stopped.
+	SaveSyncMark(source, username string, mark SyncMark) error
+	gameengine.AnalysisStore
+	gameengine.EvalStore
+	// Verify audits the database and, with repair, fixes what it can; see verify.
+	Verify(repair bool) (*VerifyReport, error)
+	Close() error
+}
+
+// Open opens the game database at location, creating it if needed. The location selects the backend:
+//   - "postgres://..." or "postgresql://...": a PostgreSQL database, which several users can share;
+//   - "sqlite:<file>", or a file ending in .sqlite, .sqlite3 or .sqlite.db: an SQLite database file;
+//   - any other file: an embedded bbolt database, which only one process can have open at a time.
+func Open(location string) (Store, error) {
+	switch {
+	case strings.HasPrefix(location, "postgres://"), strings.HasPrefix(location, "postgresql://"):
+		return openSQL("postgres", location)
+	case strings.HasPrefix(location, "sqlite:"):
+		return openSQL("sqlite3", strings.TrimPrefix(location, "sqlite:"))
+	case strings.HasSuffix(location, ".sqlite"), strings.HasSuffix(location, ".sqlite3"), strings.HasSuffix(location, ".sqlite.db"):
+		return openSQL("sqlite3", location)
+	default:
+		return openBolt(location)
+	}
+}
+
+// MonthRecord describes a fetched month of a player's games.
+type MonthRecord struct {
+	FetchedAt time.Time `json:"fetched_at"`
+	// Complete is set when the month had ended before it was fetched, so no games can be missing.
+	Complete bool     `json:"complete"`
+	Archived int      `json:"archived"` // Number of games the source returned for the month.
+	URLs     []string `json:"urls"`
+}
+
+// SyncMark is where a sync of a player's games stopped: the end time of the newest game fetched, and
+// the games that ended then. The next sync fetches the games that ended after them.
+type SyncMark struct {
+	EndTime  int64     `json:"end_time"` // Unix seconds.
+	URLs     []string  `json:"urls"`
+	SyncedAt time.Time `json:"synced_at"`
+}
+
+// syncKey returns the key of the sync mark of a player's games from a source.
+func syncKey(source, username string) string {
+	return fmt.Sprintf("%s/%s", source, strings.ToLower(username))
+}
+
+// newMonthRecord returns the record of a month fetched at fetchedAt, without its URLs.
+func newMonthRecord(month time.Time, games []api.Game, fetchedAt time.Time) MonthRecord {
+	return MonthRecord{
+		FetchedAt: fetchedAt,
+		Complete:  !fetchedAt.Before(month.AddDate(0, 1, 0)),
+		Archived:  len(games),
+	}
+}
+
+// monthKey returns the key of a player's month of games from a source.
+func monthKey(source, username string, month time.Time) string {
+	return fmt.Sprintf("%s/%s/%s", source, strings.ToLower(username), month.Format("2006-01"))
+}
+
+// pgnHash returns the SHA-256 of a PGN in hex.
+func pgnHash(pgn string) string {
+	sum := sha256.Sum256([]byte(pgn))
+	return hex.EncodeToString(sum[:])
+}
+
+// MonthCheck compares the number of games a source returned for a month with the games stored.
+type MonthCheck struct {
+	Recorded bool // Whether the month has been fetched at all.
+	Archived int
+	Stored   int
+}
+
+// OK reports whether the month was fetched and all its games are stored.
+func (c MonthCheck) OK() bool {
+	return c.Recorded && c.Stored == c.Archived
+}
+
+// CheckMonth verifies that every game of a fetched month is in the database.
+func CheckMonth(store Store, source, username string, month time.Time) (MonthCheck, error) {
+	record, err := store.Month(source, username, month)
+	if err != nil || record == nil {
+		return MonthCheck{}, err
+	}
+	games, err := store.GamesByURL(record.URLs)
+	if err != nil {
+		return MonthCheck{}, err
+	}
+	return MonthCheck{Recorded: true, Archived: record.Archived, Stored: len(games)}, nil
+}
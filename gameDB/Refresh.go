@@ -0,0 +1,68 @@
+package gamedb
+
+import (
+	"chessAnalyserFree/api"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// FetchNewGames re-fetches the months of a player that are still in progress, and returns the games
+// that were not in the database's record of those months. These are the current month and, until it
+// has been fetched once after it ended, the previous month, so that games finished just before
+// midnight are not missed. The previous month is only re-fetched if it was fetched before, so the
+// first call reports the current month's games only.
+func (c *CachedSource) FetchNewGames(username string, now time.Time) ([]api.Game, error) {
+	now = now.UTC()
+	current := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	var newGames []api.Game
+	var errs []error
+	for _, month := range []time.Time{current.AddDate(0, -1, 0), current} {
+		record, err := c.DB.Month(c.Source.Name(), username, month)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", month.Format("2006-01"), err))
+			continue
+		}
+		if month.Before(current) && (record == nil || record.Complete) {
+			continue
+		}
+		var known []string
+		if record != nil {
+			known = record.URLs
+		}
+
+		c.Misses.Add(1)
+		fetchedAt := time.Now()
+		games, err := c.Source.FetchGames(username, month, month.AddDate(0, 1, 0).Add(-time.Second))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", month.Format("2006-01"), err))
+			continue
+		}
+		if err := c.DB.SaveMonth(c.Source.Name(), username, month, games, fetchedAt); err != nil {
+			errs = append(errs, fmt.Errorf("%s: failed to store games: %w", month.Format("2006-01"), err))
+			continue
+		}
+		newGames = append(newGames, NewGames(known, games)...)
+	}
+	return newGames, errors.Join(errs...)
+}
+
+// NewGames returns the games whose URLs are not among known, in their order. Games are compared by
+// api.GameKey, so a game listed under another form of its URL is not new. Games without a URL cannot
+// be told apart and are left out.
+func NewGames(known []string, games []api.Game) []api.Game {
+	seen := make(map[string]bool, len(known))
+	for _, url := range known {
+		seen[api.GameKey(url)] = true
+	}
+	var added []api.Game
+	for _, game := range games {
+		key := api.GameKey(game.URL)
+		if game.URL == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		added = append(added, game)
+	}
+	return added
+}
@@ -0,0 +1,385 @@
+package gamedb
+
+import (
+	"chessAnalyserFree/api"
+	gameengine "chessAnalyserFree/gameEngine"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "github.com/lib/pq"           // PostgreSQL driver, registered as "postgres".
+	_ "github.com/mattn/go-sqlite3" // SQLite driver, registered as "sqlite3"; needs cgo.
+)
+
+// sqlSchema creates the tables of the SQL backends. Games, month records and analyses are stored as
+// JSON like in the bbolt backend; the players and end time of a game are also kept in columns so
+// that a player's games can be found without reading every game.
+var sqlSchema = []string{
+	`CREATE TABLE IF NOT EXISTS games (
+		url      TEXT PRIMARY KEY,
+		white    TEXT NOT NULL,
+		black    TEXT NOT NULL,
+		end_time BIGINT NOT NULL,
+		pgn_hash TEXT,
+		data     TEXT NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS games_white ON games (white, end_time)`,
+	`CREATE INDEX IF NOT EXISTS games_black ON games (black, end_time)`,
+	`CREATE TABLE IF NOT EXISTS months (
+		month_key TEXT PRIMARY KEY,
+		data      TEXT NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS analyses (
+		url      TEXT NOT NULL,
+		settings TEXT NOT NULL,
+		data     TEXT NOT NULL,
+		PRIMARY KEY (url, settings)
+	)`,
+	`CREATE TABLE IF NOT EXISTS position_evals (
+		eval_key TEXT PRIMARY KEY,
+		data     TEXT NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS sync_marks (
+		sync_key TEXT PRIMARY KEY,
+		data     TEXT NOT NULL
+	)`,
+}
+
+// sqlStore is the backend for SQL databases: SQLite for a single file that other tools can read,
+// and PostgreSQL for a server several users share.
+type sqlStore struct {
+	db     *sql.DB
+	driver string // "sqlite3" or "postgres"
+}
+
+// openSQL opens the database with the given driver and data source, creating the tables if needed.
+func openSQL(driver, source string) (*sqlStore, error) {
+	name := source
+	if driver == "sqlite3" {
+		// Wait for other processes' writes instead of failing at once.
+		separator := "?"
+		if strings.Contains(source, "?") {
+			separator = "&"
+		}
+		source += separator + "_busy_timeout=5000"
+	} else if u, err := url.Parse(source); err == nil {
+		// Keep passwords out of error messages.
+		name = u.Redacted()
+	}
+	db, err := sql.Open(driver, source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open game database %s: %w", name, err)
+	}
+	for _, statement := range slices.Concat(sqlSchema, usersSchema) {
+		if _, err := db.Exec(statement); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to initialise game database %s: %w", name, err)
+		}
+	}
+	return &sqlStore{db: db, driver: driver}, nil
+}
+
+// Close closes the database.
+func (d *sqlStore) Close() error {
+	return d.db.Close()
+}
+
+// query rewrites a statement written with ? placeholders for the driver: PostgreSQL numbers them.
+func (d *sqlStore) query(statement string) string {
+	if d.driver != "postgres" {
+		return statement
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range statement {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// sqlExecer is implemented by both *sql.DB and *sql.Tx.
+type sqlExecer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+// Month implements Store.
+func (d *sqlStore) Month(source, username string, month time.Time) (*MonthRecord, error) {
+	var data string
+	err := d.db.QueryRow(d.query(`SELECT data FROM months WHERE month_key = ?`), monthKey(source, username, month)).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	record := &MonthRecord{}
+	return record, json.Unmarshal([]byte(data), record)
+}
+
+// SaveMonth implements Store.
+func (d *sqlStore) SaveMonth(source, username string, month time.Time, games []api.Game, fetchedAt time.Time) error {
+	record := newMonthRecord(month, games, fetchedAt)
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, game := range games {
+		if game.URL == "" {
+			continue
+		}
+		if err := d.putGame(tx, game); err != nil {
+			return err
+		}
+		record.URLs = append(record.URLs, game.URL)
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(d.query(`INSERT INTO months (month_key, data) VALUES (?, ?)
+		ON CONFLICT (month_key) DO UPDATE SET data = excluded.data`), monthKey(source, username, month), string(data))
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// putGame stores a game together with the hash of its PGN, which db verify checks it against.
+func (d *sqlStore) putGame(tx sqlExecer, game api.Game) error {
+	data, err := json.Marshal(game)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(d.query(`INSERT INTO games (url, white, black, end_time, pgn_hash, data) VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (url) DO UPDATE SET white = excluded.white, black = excluded.black,
+			end_time = excluded.end_time, pgn_hash = excluded.pgn_hash, data = excluded.data`),
+		game.URL, strings.ToLower(game.White.Username), strings.ToLower(game.Black.Username), game.EndTime,
+		pgnHash(game.PGN), string(data))
+	return err
+}
+
+// GamesByURL implements Store.
+func (d *sqlStore) GamesByURL(urls []string) ([]api.Game, error) {
+	statement, err := d.db.Prepare(d.query(`SELECT data FROM games WHERE url = ?`))
+	if err != nil {
+		return nil, err
+	}
+	defer statement.Close()
+	var games []api.Game
+	for _, url := range urls {
+		var data string
+		err := statement.QueryRow(url).Scan(&data)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return games, err
+		}
+		var game api.Game
+		if err := json.Unmarshal([]byte(data), &game); err != nil {
+			return games, fmt.Errorf("stored game %s: %w", url, err)
+		}
+		games = append(games, game)
+	}
+	return games, nil
+}
+
+// PlayerGames implements Store.
+func (d *sqlStore) PlayerGames(username string, from, to time.Time) ([]api.Game, error) {
+	username = strings.ToLower(username)
+	rows, err := d.db.Query(d.query(`SELECT url, data FROM games
+		WHERE (white = ? OR black = ?) AND end_time BETWEEN ? AND ? ORDER BY end_time`),
+		username, username, from.Unix(), to.Unix())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var games []api.Game
+	for rows.Next() {
+		var url, data string
+		if err := rows.Scan(&url, &data); err != nil {
+			return games, err
+		}
+		var game api.Game
+		if err := json.Unmarshal([]byte(data), &game); err != nil {
+			return games, fmt.Errorf("stored game %s: %w", url, err)
+		}
+		games = append(games, game)
+	}
+	return games, rows.Err()
+}
+
+// LoadAnalysis implements gameengine.AnalysisStore.
+func (d *sqlStore) LoadAnalysis(gameURL, settings string) (*gameengine.GameAnalysis, error) {
+	var data string
+	err := d.db.QueryRow(d.query(`SELECT data FROM analyses WHERE url = ? AND settings = ?`), gameURL, settings).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	analysis := &gameengine.GameAnalysis{}
+	return analysis, json.Unmarshal([]byte(data), analysis)
+}
+
+// SaveAnalysis implements gameengine.AnalysisStore. The game is stored too, so that every stored
+// analysis can be checked against its game, including games read from PGN files.
+func (d *sqlStore) SaveAnalysis(game api.Game, settings string, analysis *gameengine.GameAnalysis) error {
+	data, err := json.Marshal(analysis)
+	if err != nil {
+		return err
+	}
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := d.putGame(tx, game); err != nil {
+		return err
+	}
+	_, err = tx.Exec(d.query(`INSERT INTO analyses (url, settings, data) VALUES (?, ?, ?)
+		ON CONFLICT (url, settings) DO UPDATE SET data = excluded.data`), game.URL, settings, string(data))
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// SyncMark implements Store.
+func (d *sqlStore) SyncMark(source, username string) (*SyncMark, error) {
+	var data string
+	err := d.db.QueryRow(d.query(`SELECT data FROM sync_marks WHERE sync_key = ?`), syncKey(source, username)).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	mark := &SyncMark{}
+	return mark, json.Unmarshal([]byte(data), mark)
+}
+
+// SaveSyncMark implements Store.
+func (d *sqlStore) SaveSyncMark(source, username string, mark SyncMark) error {
+	data, err := json.Marshal(mark)
+	if err != nil {
+		return err
+	}
+	_, err = d.db.Exec(d.query(`INSERT INTO sync_marks (sync_key, data) VALUES (?, ?)
+		ON CONFLICT (sync_key) DO UPDATE SET data = excluded.data`), syncKey(source, username), string(data))
+	return err
+}
+
+// LoadPositionEval implements gameengine.EvalStore.
+func (d *sqlStore) LoadPositionEval(key string) (*gameengine.PositionEval, error) {
+	var data string
+	err := d.db.QueryRow(d.query(`SELECT data FROM position_evals WHERE eval_key = ?`), key).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	eval := &gameengine.PositionEval{}
+	return eval, json.Unmarshal([]byte(data), eval)
+}
+
+// SavePositionEval implements gameengine.EvalStore.
+func (d *sqlStore) SavePositionEval(key string, eval gameengine.PositionEval) error {
+	data, err := json.Marshal(eval)
+	if err != nil {
+		return err
+	}
+	_, err = d.db.Exec(d.query(`INSERT INTO position_evals (eval_key, data) VALUES (?, ?)
+		ON CONFLICT (eval_key) DO UPDATE SET data = excluded.data`), key, string(data))
+	return err
+}
+
+// Verify implements Store.
+func (d *sqlStore) Verify(repair bool) (*VerifyReport, error) {
+	return verify(d, repair)
+}
+
+// each calls fn with the columns of every row a query returns, all scanned as strings.
+func (d *sqlStore) each(statement string, columns int, fn func(values []string) error) error {
+	rows, err := d.db.Query(statement)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	values := make([]string, columns)
+	pointers := make([]any, columns)
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(pointers...); err != nil {
+			return err
+		}
+		if err := fn(values); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// eachGame implements records.
+func (d *sqlStore) eachGame(fn func(url string, data []byte, hash string) error) error {
+	return d.each(`SELECT url, data, COALESCE(pgn_hash, '') FROM games`, 3, func(values []string) error {
+		return fn(values[0], []byte(values[1]), values[2])
+	})
+}
+
+// eachMonth implements records.
+func (d *sqlStore) eachMonth(fn func(key string, data []byte) error) error {
+	return d.each(`SELECT month_key, data FROM months`, 2, func(values []string) error {
+		return fn(values[0], []byte(values[1]))
+	})
+}
+
+// eachAnalysis implements records.
+func (d *sqlStore) eachAnalysis(fn func(url, settings string, data []byte) error) error {
+	return d.each(`SELECT url, settings, data FROM analyses`, 3, func(values []string) error {
+		return fn(values[0], values[1], []byte(values[2]))
+	})
+}
+
+// applyRepairs implements records.
+func (d *sqlStore) applyRepairs(repairs []repair) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, change := range repairs {
+		switch change.kind {
+		case repairDeleteGame:
+			_, err = tx.Exec(d.query(`DELETE FROM games WHERE url = ?`), change.key)
+		case repairSetHash:
+			_, err = tx.Exec(d.query(`UPDATE games SET pgn_hash = ? WHERE url = ?`), string(change.value), change.key)
+		case repairDeleteMonth:
+			_, err = tx.Exec(d.query(`DELETE FROM months WHERE month_key = ?`), change.key)
+		case repairPutMonth:
+			_, err = tx.Exec(d.query(`UPDATE months SET data = ? WHERE month_key = ?`), string(change.value), change.key)
+		case repairDeleteAnalysis:
+			_, err = tx.Exec(d.query(`DELETE FROM analyses WHERE url = ? AND settings = ?`), change.key, change.settings)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,176 @@
+package gamedb
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+)
+
+// ErrUserExists is returned by CreateUser when the name is taken.
+var ErrUserExists = errors.New("user already exists")
+
+// Users keeps the registered users of the server: their linked game accounts and the games analysed
+// for them. Stored games and analyses are shared, since they only depend on the game and the
+// analyser settings; which of them a user can see is recorded per user. Only the SQL backends
+// implement it.
+type Users interface {
+	// CreateUser registers a user with a password hash; see ErrUserExists.
+	CreateUser(name, passwordHash string) error
+	// PasswordHash returns the password hash of a user, or "" if there is no such user.
+	PasswordHash(name string) (string, error)
+	// LinkAccount links a game account to a user. Linking an account twice is not an error.
+	LinkAccount(user string, account Account) error
+	// UnlinkAccount removes a linked account; it reports whether the account was linked.
+	UnlinkAccount(user string, account Account) (bool, error)
+	// Accounts returns the accounts linked to a user.
+	Accounts(user string) ([]Account, error)
+	// AddUserGame records that a game was analysed for a user with the given analyser settings,
+	// replacing an earlier analysis of the game for the user.
+	AddUserGame(user, gameURL, settings string, analysedAt time.Time) error
+	// UserGames returns the games analysed for a user, most recently analysed first.
+	UserGames(user string) ([]UserGame, error)
+	// UserGame returns a game analysed for a user, or nil if the user has no analysis of it.
+	UserGame(user, gameURL string) (*UserGame, error)
+}
+
+// Account is a game account on a source, e.g. {"chesscom", "hikaru"}.
+type Account struct {
+	Source   string `json:"source"`
+	Username string `json:"username"`
+}
+
+// UserGame is a game analysed for a user. Its analysis is stored under the game URL and Settings.
+type UserGame struct {
+	URL        string    `json:"url"`
+	Settings   string    `json:"settings"`
+	AnalysedAt time.Time `json:"analysed_at"`
+}
+
+// usersSchema creates the tables of the users of the SQL backends.
+var usersSchema = []string{
+	`CREATE TABLE IF NOT EXISTS users (
+		name          TEXT PRIMARY KEY,
+		password_hash TEXT NOT NULL,
+		created_at    BIGINT NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS user_accounts (
+		user_name TEXT NOT NULL REFERENCES users (name) ON DELETE CASCADE,
+		source    TEXT NOT NULL,
+		username  TEXT NOT NULL,
+		PRIMARY KEY (user_name, source, username)
+	)`,
+	`CREATE TABLE IF NOT EXISTS user_games (
+		user_name   TEXT NOT NULL REFERENCES users (name) ON DELETE CASCADE,
+		url         TEXT NOT NULL,
+		settings    TEXT NOT NULL,
+		analysed_at BIGINT NOT NULL,
+		PRIMARY KEY (user_name, url)
+	)`,
+}
+
+// CreateUser implements Users.
+func (d *sqlStore) CreateUser(name, passwordHash string) error {
+	result, err := d.db.Exec(d.query(`INSERT INTO users (name, password_hash, created_at) VALUES (?, ?, ?)
+		ON CONFLICT (name) DO NOTHING`), strings.ToLower(name), passwordHash, time.Now().Unix())
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrUserExists
+	}
+	return nil
+}
+
+// PasswordHash implements Users.
+func (d *sqlStore) PasswordHash(name string) (string, error) {
+	var hash string
+	err := d.db.QueryRow(d.query(`SELECT password_hash FROM users WHERE name = ?`), strings.ToLower(name)).Scan(&hash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return hash, err
+}
+
+// LinkAccount implements Users.
+func (d *sqlStore) LinkAccount(user string, account Account) error {
+	_, err := d.db.Exec(d.query(`INSERT INTO user_accounts (user_name, source, username) VALUES (?, ?, ?)
+		ON CONFLICT (user_name, source, username) DO NOTHING`),
+		strings.ToLower(user), account.Source, strings.ToLower(account.Username))
+	return err
+}
+
+// UnlinkAccount implements Users.
+func (d *sqlStore) UnlinkAccount(user string, account Account) (bool, error) {
+	result, err := d.db.Exec(d.query(`DELETE FROM user_accounts WHERE user_name = ? AND source = ? AND username = ?`),
+		strings.ToLower(user), account.Source, strings.ToLower(account.Username))
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// Accounts implements Users.
+func (d *sqlStore) Accounts(user string) ([]Account, error) {
+	rows, err := d.db.Query(d.query(`SELECT source, username FROM user_accounts WHERE user_name = ? ORDER BY source, username`),
+		strings.ToLower(user))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var accounts []Account
+	for rows.Next() {
+		var account Account
+		if err := rows.Scan(&account.Source, &account.Username); err != nil {
+			return accounts, err
+		}
+		accounts = append(accounts, account)
+	}
+	return accounts, rows.Err()
+}
+
+// AddUserGame implements Users.
+func (d *sqlStore) AddUserGame(user, gameURL, settings string, analysedAt time.Time) error {
+	_, err := d.db.Exec(d.query(`INSERT INTO user_games (user_name, url, settings, analysed_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT (user_name, url) DO UPDATE SET settings = excluded.settings, analysed_at = excluded.analysed_at`),
+		strings.ToLower(user), gameURL, settings, analysedAt.Unix())
+	return err
+}
+
+// UserGames implements Users.
+func (d *sqlStore) UserGames(user string) ([]UserGame, error) {
+	rows, err := d.db.Query(d.query(`SELECT url, settings, analysed_at FROM user_games WHERE user_name = ?
+		ORDER BY analysed_at DESC, url`), strings.ToLower(user))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var games []UserGame
+	for rows.Next() {
+		var game UserGame
+		var analysedAt int64
+		if err := rows.Scan(&game.URL, &game.Settings, &analysedAt); err != nil {
+			return games, err
+		}
+		game.AnalysedAt = time.Unix(analysedAt, 0)
+		games = append(games, game)
+	}
+	return games, rows.Err()
+}
+
+// UserGame implements Users.
+func (d *sqlStore) UserGame(user, gameURL string) (*UserGame, error) {
+	game := &UserGame{URL: gameURL}
+	var analysedAt int64
+	err := d.db.QueryRow(d.query(`SELECT settings, analysed_at FROM user_games WHERE user_name = ? AND url = ?`),
+		strings.ToLower(user), gameURL).Scan(&game.Settings, &analysedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	game.AnalysedAt = time.Unix(analysedAt, 0)
+	return game, nil
+}
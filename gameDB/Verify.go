@@ -0,0 +1,160 @@
+package gamedb
+
+import (
+	"chessAnalyserFree/api"
+	gameengine "chessAnalyserFree/gameEngine"
+	"encoding/json"
+	"fmt"
+)
+
+// Problem is an inconsistency found by Verify.
+type Problem struct {
+	Key    string // Game URL, month key or analysis key the problem was found at.
+	Detail string
+	// Repair describes what Verify does, or did when repairing, about the problem; empty when
+	// nothing can be done.
+	Repair string
+}
+
+// VerifyReport summarises a Verify run.
+type VerifyReport struct {
+	Games, Months, Analyses int
+	Problems                []Problem
+	Repaired                bool // Whether the repairs of the problems were applied.
+}
+
+// records is the raw access to stored records that verify needs from a backend.
+type records interface {
+	// eachGame calls fn with every stored game and its recorded PGN hash, "" if none.
+	eachGame(fn func(url string, data []byte, hash string) error) error
+	// eachMonth calls fn with every month record and its key, see monthKey.
+	eachMonth(fn func(key string, data []byte) error) error
+	// eachAnalysis calls fn with every stored analysis.
+	eachAnalysis(fn func(url, settings string, data []byte) error) error
+	// applyRepairs applies all repairs at once, in a single transaction.
+	applyRepairs(repairs []repair) error
+}
+
+// repairKind is a change verify makes to fix a problem.
+type repairKind int
+
+const (
+	repairDeleteGame     repairKind = iota // Delete game key and its hash.
+	repairSetHash                          // Record value as the PGN hash of game key.
+	repairDeleteMonth                      // Delete month record key.
+	repairPutMonth                         // Replace month record key with value.
+	repairDeleteAnalysis                   // Delete the analysis of game key with settings.
+)
+
+// repair is a change to one record.
+type repair struct {
+	kind     repairKind
+	key      string
+	settings string
+	value    []byte
+}
+
+// verify checks the whole database: it re-hashes every stored PGN, checks that every month's games
+// are stored, and checks every analysis against its game. With fixProblems, it fixes what it can:
+//   - games that are corrupt or no longer match their hash are deleted, and the months listing them
+//     are marked incomplete so that the next fetch downloads them again;
+//   - games stored before hashes were recorded get their hash;
+//   - corrupt month records are deleted, so the month is fetched again;
+//   - corrupt analyses, analyses of games not in the database and analyses that do not cover their
+//     game's moves are deleted, so the games are analysed again.
+func verify(r records, fixProblems bool) (*VerifyReport, error) {
+	report := &VerifyReport{}
+	var repairs []repair
+	fix := func(key, detail, action string, change *repair) {
+		report.Problems = append(report.Problems, Problem{Key: key, Detail: detail, Repair: action})
+		if change != nil {
+			repairs = append(repairs, *change)
+		}
+	}
+
+	// Plies of the games that pass the checks, by URL. Only counts are kept, so that large
+	// databases can be verified.
+	plies := make(map[string]int)
+	err := r.eachGame(func(url string, data []byte, stored string) error {
+		report.Games++
+		var game api.Game
+		if err := json.Unmarshal(data, &game); err != nil {
+			fix(url, "corrupt game: "+err.Error(), "delete the game", &repair{kind: repairDeleteGame, key: url})
+			return nil
+		}
+		hash := pgnHash(game.PGN)
+		switch {
+		case stored == "":
+			fix(url, "no PGN hash recorded", "record the hash", &repair{kind: repairSetHash, key: url, value: []byte(hash)})
+		case stored != hash:
+			fix(url, "PGN does not match its hash", "delete the game", &repair{kind: repairDeleteGame, key: url})
+			return nil
+		}
+		plies[url] = gameengine.CountMovetextPlies(game.PGN)
+		if plies[url] == 0 {
+			fix(url, "PGN has no moves", "", nil)
+		}
+		return nil
+	})
+	if err != nil {
+		return report, err
+	}
+
+	err = r.eachMonth(func(key string, data []byte) error {
+		report.Months++
+		var record MonthRecord
+		if err := json.Unmarshal(data, &record); err != nil {
+			fix(key, "corrupt month record: "+err.Error(), "delete the record", &repair{kind: repairDeleteMonth, key: key})
+			return nil
+		}
+		missing := 0
+		for _, url := range record.URLs {
+			if _, ok := plies[url]; !ok {
+				missing++
+			}
+		}
+		if missing > 0 && record.Complete {
+			record.Complete = false
+			data, err := json.Marshal(record)
+			if err != nil {
+				return err
+			}
+			fix(key, fmt.Sprintf("%d of %d games missing", missing, len(record.URLs)), "mark the month incomplete",
+				&repair{kind: repairPutMonth, key: key, value: data})
+		}
+		return nil
+	})
+	if err != nil {
+		return report, err
+	}
+
+	err = r.eachAnalysis(func(url, settings string, data []byte) error {
+		report.Analyses++
+		name := url + " @ " + settings
+		remove := &repair{kind: repairDeleteAnalysis, key: url, settings: settings}
+		var analysis gameengine.GameAnalysis
+		if err := json.Unmarshal(data, &analysis); err != nil {
+			fix(name, "corrupt analysis: "+err.Error(), "delete the analysis", remove)
+			return nil
+		}
+		gamePlies, ok := plies[url]
+		if !ok {
+			fix(name, "orphaned analysis: its game is missing or corrupt", "delete the analysis", remove)
+			return nil
+		}
+		if gamePlies != len(analysis.Moves) {
+			fix(name, fmt.Sprintf("analysis covers %d moves but the game has %d", len(analysis.Moves), gamePlies),
+				"delete the analysis", remove)
+		}
+		return nil
+	})
+	if err != nil || !fixProblems || len(repairs) == 0 {
+		return report, err
+	}
+
+	if err := r.applyRepairs(repairs); err != nil {
+		return report, fmt.Errorf("failed to repair the game database: %w", err)
+	}
+	report.Repaired = true
+	return report, nil
+}
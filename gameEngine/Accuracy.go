@@ -0,0 +1,99 @@
+package gameengine
+
+import (
+	"math"
+	"strings"
+)
+
+// SideAccuracy summarises the analysed moves of one side of a game.
+type SideAccuracy struct {
+	Moves        int     // Analysed moves; book moves are left out.
+	ACPL         float64 // Average centipawn loss.
+	Accuracy     float64 // 0 to 100, from the winning chances the moves lost.
+	Inaccuracies int
+	Mistakes     int
+	Blunders     int
+	BestMoves    int   // Analysed moves that were the engine's best move.
+	Losses       []int // Centipawn losses of the analysed moves, in order.
+}
+
+// GameAccuracy returns the average centipawn loss, accuracy and error counts of both sides of an
+// analysed game. Accuracy follows the Lichess model: every move scores 0 to 100 from the winning
+// chances it lost, and the game's accuracy is the average of the arithmetic and the harmonic mean
+// of the move scores. Lichess weights the arithmetic mean by how volatile the position was, which
+// is left out here, so sharp games can differ by a few points; the calibrate command measures it.
+func GameAccuracy(pgn string, analysis *GameAnalysis) (white, black SideAccuracy) {
+	whiteFirst := strings.Fields(StartFEN(pgn))[1] == "w"
+	var scores [2][]float64
+	sides := [2]*SideAccuracy{&white, &black}
+	for i, move := range analysis.Moves {
+		if move.Classification == ClassBook {
+			continue
+		}
+		side := i % 2
+		if !whiteFirst {
+			side = 1 - side
+		}
+		s := sides[side]
+		s.Moves++
+		s.ACPL += float64(move.CentipawnLoss)
+		s.Losses = append(s.Losses, move.CentipawnLoss)
+		if move.BestMove != "" && move.Move == move.BestMove {
+			s.BestMoves++
+		}
+		switch move.Classification {
+		case ClassInaccuracy:
+			s.Inaccuracies++
+		case ClassMistake:
+			s.Mistakes++
+		case ClassBlunder:
+			s.Blunders++
+		}
+		// Evaluations are from the mover's point of view, so the position after the move is
+		// worth the evaluation before it less the centipawn loss.
+		before := clampEval(int(math.Round(move.Evaluation * 100)))
+		scores[side] = append(scores[side], MoveAccuracy(before, before-move.CentipawnLoss))
+	}
+	for side, s := range sides {
+		if s.Moves == 0 {
+			continue
+		}
+		s.ACPL /= float64(s.Moves)
+		s.Accuracy = (mean(scores[side]) + harmonicMean(scores[side])) / 2
+	}
+	return white, black
+}
+
+// WinPercent converts an evaluation in centipawns, from a player's point of view, into the player's
+// winning chances in percent, with the curve Lichess fitted to the results of rated games.
+func WinPercent(centipawns int) float64 {
+	return 50 + 50*(2/(1+math.Exp(-0.00368208*float64(centipawns)))-1)
+}
+
+// MoveAccuracy scores a move from 0 to 100 by the winning chances it lost, given the mover's
+// evaluation before and after it, using Lichess's formula and its one point bonus for the
+// uncertainty of the analysis.
+func MoveAccuracy(before, after int) float64 {
+	lost := max(WinPercent(before)-WinPercent(after), 0)
+	accuracy := 103.1668100711649*math.Exp(-0.04354415386753951*lost) - 3.166924740191411 + 1
+	return min(max(accuracy, 0), 100)
+}
+
+// mean returns the arithmetic mean of values.
+func mean(values []float64) float64 {
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
+
+// harmonicMean returns the harmonic mean of values. Values are counted as at least 1, so a single
+// move that threw the game away does not make the mean 0.
+func harmonicMean(values []float64) float64 {
+	sum := 0.0
+	for _, v := range values {
+		sum += 1 / max(v, 1)
+	}
+	return float64(len(values)) / sum
+}
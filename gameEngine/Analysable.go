@@ -0,0 +1,40 @@
+package gameengine
+
+import (
+	"chessAnalyserFree/api"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrNotAnalysable is returned for games with no moves to analyse, such as games aborted before
+// the first move or archive entries holding only a result.
+var ErrNotAnalysable = errors.New("not analysable")
+
+// NotAnalysableReason says why a game has nothing to analyse: "aborted" for a game that ended
+// without a move being played, "no moves" for any other game without moves, and "" when the game
+// has moves.
+func NotAnalysableReason(game api.Game) string {
+	movetext := ParseMovetext(game.PGN)
+	if len(movetext.Moves) > 0 {
+		return ""
+	}
+	if game.White.Result == "abandoned" || game.Black.Result == "abandoned" {
+		return "aborted"
+	}
+	for _, tag := range movetext.Tags {
+		matches := pgnTagRegex.FindStringSubmatch(tag)
+		if matches != nil && matches[1] == "Termination" && strings.Contains(strings.ToLower(matches[2]), "abort") {
+			return "aborted"
+		}
+	}
+	return "no moves"
+}
+
+// checkAnalysable returns an error wrapping ErrNotAnalysable if the game has no moves.
+func checkAnalysable(game api.Game) error {
+	if reason := NotAnalysableReason(game); reason != "" {
+		return fmt.Errorf("%w: %s", ErrNotAnalysable, reason)
+	}
+	return nil
+}
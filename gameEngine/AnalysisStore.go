@@ -0,0 +1,70 @@
+package gameengine
+
+import (
+	"chessAnalyserFree/api"
+	"fmt"
+)
+
+// AnalysisStore keeps finished analyses, keyed by game URL and the settings that produced them.
+type AnalysisStore interface {
+	// LoadAnalysis returns the stored analysis of a game, or nil if there is none.
+	LoadAnalysis(gameURL, settings string) (*GameAnalysis, error)
+	// SaveAnalysis stores the analysis of a game.
+	SaveAnalysis(game api.Game, settings string, analysis *GameAnalysis) error
+}
+
+// ImportedSettings is the settings key analyses imported from exports are stored under. No analyser
+// has it, so AnalyseGame never returns an imported analysis in place of its own.
+const ImportedSettings = "imported"
+
+// SetAnalysisStore makes AnalyseGame return stored analyses of games analysed before with the same
+// settings, and store the valid analyses it completes. A nil store disables caching.
+func (s *StockfishAnalyser) SetAnalysisStore(store AnalysisStore) {
+	s.analysisStore = store
+}
+
+// SettingsKey identifies everything that affects the result of an analysis: the engine and its
+// version, the search limits, the classification thresholds, the external evaluation source and
+// the practical chances playouts, and a two-pass analysis.
+func (s *StockfishAnalyser) SettingsKey() string {
+	p := s.preset
+	externalDepth := 0
+	if s.evalSource != nil {
+		externalDepth = s.evalSourceMinDepth
+	}
+	key := fmt.Sprintf("%s|depth=%d|movetime=%s|multipv=%d|book=%d|thresholds=%d/%d/%d|external=%d|playouts=%d",
+		s.engineName, p.Depth, p.MoveTime, p.MultiPV, p.SkipBookPlies,
+		p.Thresholds.Inaccuracy, p.Thresholds.Mistake, p.Thresholds.Blunder,
+		externalDepth, s.practicalPlayouts)
+	// Keys of single-pass analyses predate two-pass ones and are kept as they were.
+	if s.twoPass {
+		key += fmt.Sprintf("|two-pass=%d", ScanDepth)
+	}
+	return key
+}
+
+// storedAnalysis returns the stored analysis of a game, if there is one that still matches its PGN.
+// Store failures are not fatal: the game is simply analysed again.
+func (s *StockfishAnalyser) storedAnalysis(game api.Game) *GameAnalysis {
+	if s.analysisStore == nil || game.URL == "" {
+		return nil
+	}
+	analysis, err := s.analysisStore.LoadAnalysis(game.URL, s.SettingsKey())
+	if err != nil || analysis == nil {
+		return nil
+	}
+	if len(ValidateAnalysis(game, analysis)) > 0 {
+		return nil
+	}
+	analysis.Cached = true
+	return analysis
+}
+
+// storeAnalysis saves a valid analysis of a game in the store, if any. Like lookup failures, store
+// failures are not fatal: the game is analysed again next time.
+func (s *StockfishAnalyser) storeAnalysis(game api.Game, analysis *GameAnalysis) {
+	if s.analysisStore == nil || game.URL == "" || !analysis.IsValid() {
+		return
+	}
+	_ = s.analysisStore.SaveAnalysis(game, s.SettingsKey(), analysis)
+}
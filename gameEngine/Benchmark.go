@@ -0,0 +1,34 @@
+package gameengine
+
+import (
+	"fmt"
+	"time"
+)
+
+// benchmarkPositions are representative opening, middlegame and endgame positions used to
+// measure how long the engine takes per position on this machine.
+var benchmarkPositions = []string{
+	"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1",
+	"r1bqkb1r/pppp1ppp/2n2n2/4p3/2B1P3/5N2/PPPP1PPP/RNBQK2R w KQkq - 4 4",
+	"r2q1rk1/pp2bppp/2n1pn2/3p4/2PP4/2N1PN2/PP3PPP/R2QKB1R w KQ - 0 9",
+	"2rq1rk1/pb1nbppp/1p2pn2/3p4/2PP4/1PN1PN2/PB2BPPP/2RQ1RK1 w - - 4 12",
+	"8/5pk1/6p1/3R4/7P/6P1/5PK1/3r4 w - - 0 45",
+	"8/8/4k3/3p4/3P4/4K3/8/8 w - - 0 60",
+}
+
+// Benchmark searches the benchmark positions with the preset and returns the average time per position.
+func (s *StockfishAnalyser) Benchmark(preset Preset) (time.Duration, error) {
+	previous := s.preset
+	if err := s.SetPreset(preset); err != nil {
+		return 0, err
+	}
+	defer s.SetPreset(previous)
+
+	start := time.Now()
+	for _, fen := range benchmarkPositions {
+		if _, err := s.evaluate(fen); err != nil {
+			return 0, fmt.Errorf("benchmark failed: %w", err)
+		}
+	}
+	return time.Since(start) / time.Duration(len(benchmarkPositions)), nil
+}
@@ -0,0 +1,146 @@
+package gameengine
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/notnil/chess"
+)
+
+// EngineLine is a principal variation of a search.
+type EngineLine struct {
+	Moves []string // Moves in SAN.
+	Score string   // Score from the side to move's point of view, e.g. "+0.35" or "#3".
+}
+
+// TopLines searches a position with the current preset, reporting count principal variations, and
+// returns the engine's lines best first, each at most maxMoves moves long. The position is always
+// searched locally: neither the position cache nor external evaluations keep lines.
+func (s *StockfishAnalyser) TopLines(fen string, count, maxMoves int) ([]EngineLine, error) {
+	if err := s.setMultiPV(count); err != nil {
+		return nil, err
+	}
+	defer s.setMultiPV(s.preset.MultiPV)
+	if err := s.sendCommand(fmt.Sprintf("position fen %s", fen)); err != nil {
+		return nil, fmt.Errorf("error writing to stockfish: %w", err)
+	}
+	if err := s.sendCommand(s.preset.goCommand()); err != nil {
+		return nil, fmt.Errorf("error writing to stockfish: %w", err)
+	}
+	output, err := s.readUntil("bestmove")
+	if err != nil {
+		return nil, fmt.Errorf("error reading from stockfish: %w", err)
+	}
+	byRank := parseLines(output)
+	if len(byRank[1].pv) == 0 {
+		// Engines may report a best move without a variation.
+		if best := parseBestMove(output); best != "" && best != "(none)" {
+			byRank[1] = searchLine{pv: []string{best}, score: byRank[1].score}
+		}
+	}
+	var lines []EngineLine
+	for rank := 1; rank <= count; rank++ {
+		line, ok := byRank[rank]
+		if !ok || len(line.pv) == 0 {
+			break
+		}
+		lines = append(lines, EngineLine{Moves: lineSAN(fen, line.pv, maxMoves), Score: line.score.String()})
+	}
+	return lines, nil
+}
+
+// searchLine is a principal variation of a search in UCI notation, with its score.
+type searchLine struct {
+	pv    []string
+	score engineScore
+}
+
+// parseLines returns the principal variations of a search and their scores by rank, 1 being the
+// best. Later lines come from deeper iterations and replace earlier ones.
+func parseLines(output string) map[int]searchLine {
+	lines := make(map[int]searchLine)
+	for _, text := range strings.Split(output, "\n") {
+		fields := strings.Fields(text)
+		if len(fields) == 0 || fields[0] != "info" {
+			continue
+		}
+		rank := 1
+		var pv []string
+		var score *engineScore
+		for j := 1; j < len(fields); j++ {
+			switch fields[j] {
+			case "multipv":
+				if j+1 < len(fields) {
+					rank, _ = strconv.Atoi(fields[j+1])
+				}
+			case "score":
+				if j+2 >= len(fields) {
+					continue
+				}
+				if value, err := strconv.Atoi(fields[j+2]); err == nil {
+					switch fields[j+1] {
+					case "cp":
+						score = &engineScore{Centipawns: value}
+					case "mate":
+						score = &engineScore{IsMate: true, MateIn: value}
+					}
+				}
+			case "pv":
+				// The variation runs to the end of the line.
+				pv = fields[j+1:]
+				j = len(fields)
+			}
+		}
+		line := lines[rank]
+		if score != nil {
+			line.score = *score
+		}
+		if len(pv) > 0 {
+			line.pv = pv
+		}
+		if score != nil || len(pv) > 0 {
+			lines[rank] = line
+		}
+	}
+	return lines
+}
+
+// lineSAN plays a line of UCI moves from fen and returns up to maxMoves of them in SAN. The line
+// stops at the first move that is not legal in its position.
+func lineSAN(fen string, uci []string, maxMoves int) []string {
+	option, err := chess.FEN(fen)
+	if err != nil {
+		return nil
+	}
+	game := chess.NewGame(option)
+	var san []string
+	for _, text := range uci {
+		if len(san) == maxMoves {
+			break
+		}
+		var played *chess.Move
+		for _, candidate := range game.ValidMoves() {
+			if candidate.String() == text {
+				played = candidate
+				break
+			}
+		}
+		if played == nil {
+			break
+		}
+		san = append(san, chess.AlgebraicNotation{}.Encode(game.Position(), played))
+		if err := game.Move(played); err != nil {
+			break
+		}
+	}
+	return san
+}
+
+// MoveSAN returns a UCI move played from fen in SAN, or the UCI move itself if it is not legal there.
+func MoveSAN(fen, uci string) string {
+	if san := lineSAN(fen, []string{uci}, 1); len(san) == 1 {
+		return san[0]
+	}
+	return uci
+}
@@ -0,0 +1,70 @@
+package gameengine
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os/exec"
+)
+
+// Connector starts a UCI engine, or connects to one, and returns the connection to it: commands are
+// written to it, the engine's output is read from it, and closing it ends the engine at once. The
+// analyser works the same with an engine process on this machine, an engine served on another
+// machine and the in-memory mock engine. It connects again for every engine spawned from it and to
+// restart an engine that stopped answering.
+type Connector func() (io.ReadWriteCloser, error)
+
+// ProcessConnector returns a Connector starting the engine executable at path.
+func ProcessConnector(path string) Connector {
+	return func() (io.ReadWriteCloser, error) {
+		cmd := exec.Command(path)
+		stdin, err := cmd.StdinPipe()
+		if err != nil {
+			return nil, err
+		}
+		stdout, err := cmd.StdoutPipe()
+		if err != nil {
+			return nil, err
+		}
+		if err := cmd.Start(); err != nil {
+			return nil, fmt.Errorf("failed to start stockfish: %w. Is the path correct?", err)
+		}
+		return &engineProcess{cmd: cmd, stdin: stdin, stdout: stdout}, nil
+	}
+}
+
+// TCPConnector returns a Connector to a UCI engine served over TCP at address ("host:port"), such as
+// a Stockfish on a stronger machine exposed with a tool like socat.
+func TCPConnector(address string) Connector {
+	return func() (io.ReadWriteCloser, error) {
+		conn, err := net.Dial("tcp", address)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to the engine at %s: %w", address, err)
+		}
+		return conn, nil
+	}
+}
+
+// engineProcess is the connection to an engine process through its standard input and output.
+type engineProcess struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout io.ReadCloser
+}
+
+func (p *engineProcess) Read(b []byte) (int, error) {
+	return p.stdout.Read(b)
+}
+
+func (p *engineProcess) Write(b []byte) (int, error) {
+	return p.stdin.Write(b)
+}
+
+// Close kills the process, unless it has exited already, and waits for it.
+func (p *engineProcess) Close() error {
+	p.cmd.Process.Kill()
+	p.cmd.Wait()
+	p.stdin.Close()
+	p.stdout.Close()
+	return nil
+}
@@ -0,0 +1,49 @@
+package gameengine
+
+import "fmt"
+
+// DepthRange is the search depth reached over the positions of an analysis that were searched, by
+// the engine or by the external source.
+type DepthRange struct {
+	Positions int
+	Min, Max  int
+	total     int
+}
+
+// SearchDepths returns the depth range of the moves' evaluations. Book moves have none.
+func SearchDepths(moves []MoveAnalysis) DepthRange {
+	var r DepthRange
+	for _, move := range moves {
+		if move.Depth <= 0 {
+			continue
+		}
+		if r.Positions == 0 || move.Depth < r.Min {
+			r.Min = move.Depth
+		}
+		r.Max = max(r.Max, move.Depth)
+		r.total += move.Depth
+		r.Positions++
+	}
+	return r
+}
+
+// Average returns the mean depth reached, or 0 without searched positions.
+func (r DepthRange) Average() float64 {
+	if r.Positions == 0 {
+		return 0
+	}
+	return float64(r.total) / float64(r.Positions)
+}
+
+// String describes the range, e.g. "18" when every position reached the same depth or "12-20
+// (average 15.4)"; empty without searched positions.
+func (r DepthRange) String() string {
+	switch {
+	case r.Positions == 0:
+		return ""
+	case r.Min == r.Max:
+		return fmt.Sprint(r.Min)
+	default:
+		return fmt.Sprintf("%d-%d (average %.1f)", r.Min, r.Max, r.Average())
+	}
+}
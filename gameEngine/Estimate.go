@@ -0,0 +1,205 @@
+package gameengine
+
+import (
+	"chessAnalyserFree/api"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Workload summarises the engine work needed to analyse a set of games.
+type Workload struct {
+	Games         int
+	Positions     int
+	Variants      int // Games of unsupported variants; not included in Positions.
+	Unparseable   int // Games whose PGN could not be read; not included in Positions.
+	NotAnalysable int // Aborted games and others without moves; not included in Positions.
+	TimePerSearch time.Duration
+	EngineTime    time.Duration
+}
+
+// EstimatedSearchTime returns the expected time the engine spends on a single position.
+// For depth-limited presets without a time limit this is a rough approximation.
+func (p Preset) EstimatedSearchTime() time.Duration {
+	if p.MoveTime > 0 {
+		return p.MoveTime
+	}
+	if p.Depth > 0 {
+		// Search time grows quickly with depth; this is a ballpark for a modern desktop CPU.
+		return time.Duration(p.Depth*p.Depth) * 5 * time.Millisecond
+	}
+	return 500 * time.Millisecond
+}
+
+// PositionCount returns the number of positions AnalyseGame will search for a game with the preset.
+func (p Preset) PositionCount(game api.Game) (int, error) {
+	if err := checkVariant(game); err != nil {
+		return 0, err
+	}
+	if err := checkAnalysable(game); err != nil {
+		return 0, err
+	}
+	parsedGame, _, err := parseGame(game.PGN)
+	if err != nil {
+		return 0, err
+	}
+	plies := len(parsedGame.Moves())
+	if plies <= p.SkipBookPlies {
+		return 0, nil
+	}
+	// Every analysed move plus the final position.
+	return plies - p.SkipBookPlies + 1, nil
+}
+
+// EstimateWorkload estimates the engine work of analysing every game with the preset,
+// using benchmarked search times from the calibration where available.
+func EstimateWorkload(games []api.Game, preset Preset, calibration Calibration) Workload {
+	workload := Workload{
+		Games:         len(games),
+		TimePerSearch: calibration.TimePerSearch(preset),
+	}
+	for _, game := range games {
+		positions, err := preset.PositionCount(game)
+		if errors.Is(err, ErrUnsupportedVariant) {
+			workload.Variants++
+			continue
+		}
+		if errors.Is(err, ErrNotAnalysable) {
+			workload.NotAnalysable++
+			continue
+		}
+		if err != nil {
+			workload.Unparseable++
+			continue
+		}
+		workload.Positions += positions
+	}
+	workload.EngineTime = time.Duration(workload.Positions) * workload.TimePerSearch
+	return workload
+}
+
+// Calibration holds measured search times per preset, produced by the benchmark command.
+type Calibration map[string]time.Duration
+
+// calibrationFile is the file name of the saved calibration in the user's config directory.
+const calibrationFile = "calibration.json"
+
+// calibrationPath returns the location of the saved calibration.
+func calibrationPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "chessanalyser", calibrationFile), nil
+}
+
+// LoadCalibration reads the saved calibration. A missing file yields an empty calibration.
+func LoadCalibration() (Calibration, error) {
+	path, err := calibrationPath()
+	if err != nil {
+		return Calibration{}, err
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return Calibration{}, nil
+	}
+	if err != nil {
+		return Calibration{}, fmt.Errorf("failed to read calibration: %w", err)
+	}
+	calibration := Calibration{}
+	if err := json.Unmarshal(data, &calibration); err != nil {
+		return Calibration{}, fmt.Errorf("failed to parse calibration: %w", err)
+	}
+	return calibration, nil
+}
+
+// Save writes the calibration to the user's config directory.
+func (c Calibration) Save() error {
+	path, err := calibrationPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// TimePerSearch returns the measured search time for the preset, or the preset's own estimate
+// when it has not been benchmarked.
+func (c Calibration) TimePerSearch(preset Preset) time.Duration {
+	if measured, ok := c[preset.Name]; ok && measured > 0 {
+		return measured
+	}
+	return preset.EstimatedSearchTime()
+}
+
+// etaBarWidth is the number of cells of the progress bar of an ETA.
+const etaBarWidth = 20
+
+// ETA tracks progress through a known number of positions and predicts the remaining time.
+// The initial per-position estimate is replaced by the observed average as positions complete.
+type ETA struct {
+	total    int
+	done     int
+	unit     string
+	estimate time.Duration
+	start    time.Time
+}
+
+// NewETA creates an ETA for totalPositions, starting the clock now.
+func NewETA(totalPositions int, perPosition time.Duration) *ETA {
+	return NewETAOf(totalPositions, "positions", perPosition)
+}
+
+// NewETAOf creates an ETA for total items of another unit than positions, e.g. "months". Without
+// an estimate per item, no time is predicted until the first item completes.
+func NewETAOf(total int, unit string, perItem time.Duration) *ETA {
+	return &ETA{total: total, unit: unit, estimate: perItem, start: time.Now()}
+}
+
+// Advance records n completed positions, or items of the ETA's unit.
+func (e *ETA) Advance(n int) {
+	e.done += n
+	if e.done > e.total {
+		// Games can need slightly more searches than estimated; keep the ETA sensible.
+		e.total = e.done
+	}
+}
+
+// Remaining returns the predicted time until all positions, or items, are done.
+func (e *ETA) Remaining() time.Duration {
+	perPosition := e.estimate
+	if e.done > 0 {
+		perPosition = time.Since(e.start) / time.Duration(e.done)
+	}
+	return time.Duration(e.total-e.done) * perPosition
+}
+
+// String formats the progress as a bar, e.g. "[#####---------------] 120/480 positions (25%), ETA 3m0s".
+func (e *ETA) String() string {
+	percent := 100
+	if e.total > 0 {
+		percent = e.done * 100 / e.total
+	}
+	filled := percent * etaBarWidth / 100
+	text := fmt.Sprintf("[%s%s] %d/%d %s (%d%%)", strings.Repeat("#", filled), strings.Repeat("-", etaBarWidth-filled),
+		e.done, e.total, e.unit, percent)
+	if e.done > 0 || e.estimate > 0 {
+		text += fmt.Sprintf(", ETA %s", e.Remaining().Round(time.Second))
+	}
+	return text
+}
+
+// Elapsed returns the time since the ETA was created.
+func (e *ETA) Elapsed() time.Duration {
+	return time.Since(e.start)
+}
@@ -0,0 +1,55 @@
+package gameengine
+
+import "strings"
+
+// MinExternalDepth is the shallowest external evaluation accepted in place of a local search.
+const MinExternalDepth = 25
+
+// ExternalEval is an evaluation obtained outside the local engine, from White's point of view.
+type ExternalEval struct {
+	Centipawns int
+	Mate       int // Moves until mate, positive when White mates; 0 when not a mate score.
+	Depth      int
+	BestMove   string // First move of the principal variation in UCI notation; empty if unknown.
+}
+
+// EvalSource provides evaluations from outside the local engine, such as a cloud cache.
+type EvalSource interface {
+	// LookupEval returns the evaluation of a position, or nil if none is available.
+	LookupEval(fen string) (*ExternalEval, error)
+}
+
+// SetEvalSource makes the analyser consult source before searching a position locally.
+// Evaluations shallower than minDepth are ignored. A nil source disables the lookup.
+func (s *StockfishAnalyser) SetEvalSource(source EvalSource, minDepth int) {
+	s.evalSource = source
+	s.evalSourceMinDepth = minDepth
+}
+
+// ExternalHits returns how many positions were evaluated by the external source instead of the engine.
+func (s *StockfishAnalyser) ExternalHits() int {
+	return s.externalHits
+}
+
+// lookupExternal returns the external evaluation of a position from the side to move's point of view.
+// Lookup failures are not fatal: the position is simply searched locally.
+func (s *StockfishAnalyser) lookupExternal(fen string) (engineScore, bool) {
+	if s.evalSource == nil {
+		return engineScore{}, false
+	}
+	eval, err := s.evalSource.LookupEval(fen)
+	if err != nil || eval == nil || eval.Depth < s.evalSourceMinDepth {
+		return engineScore{}, false
+	}
+
+	// Convert from White's point of view to the side to move's.
+	sign := 1
+	if fields := strings.Fields(fen); len(fields) > 1 && fields[1] == "b" {
+		sign = -1
+	}
+	s.externalHits++
+	if eval.Mate != 0 {
+		return engineScore{IsMate: true, MateIn: sign * eval.Mate, Depth: eval.Depth, External: true, BestMove: eval.BestMove}, true
+	}
+	return engineScore{Centipawns: sign * eval.Centipawns, Depth: eval.Depth, External: true, BestMove: eval.BestMove}, true
+}
@@ -0,0 +1,71 @@
+package gameengine
+
+import "strings"
+
+// KindMissedWin marks a key move that threw away a winning position; the other key moves are
+// marked by their classification.
+const KindMissedWin = "missed win"
+
+// Evaluations, in pawns from the mover's point of view, of a winning position and of one that is
+// no longer winning: a move from the first to the second missed a win.
+const (
+	WinningEval    = 2.0
+	notWinningEval = 1.0
+)
+
+// keyClasses are the classifications of the moves that are key moves in their own right.
+var keyClasses = map[string]bool{
+	ClassSacrifice: true,
+	ClassMistake:   true,
+	ClassBlunder:   true,
+}
+
+// KeyMove is a move worth a diagram of the position before it.
+type KeyMove struct {
+	Index int    // Index of the move in the analysis.
+	Kind  string // KindMissedWin, or the move's classification.
+}
+
+// KeyMoves returns the key moves of an analysis in game order: the sacrifices, mistakes and blunders,
+// and the moves that missed a win, leaving a position in which the mover was winning for one in
+// which they no longer are. A move that did both is a missed win.
+func KeyMoves(moves []MoveAnalysis) []KeyMove {
+	var key []KeyMove
+	for i, move := range moves {
+		if missedWin(moves, i) {
+			key = append(key, KeyMove{Index: i, Kind: KindMissedWin})
+		} else if keyClasses[move.Classification] {
+			key = append(key, KeyMove{Index: i, Kind: move.Classification})
+		}
+	}
+	return key
+}
+
+// missedWin reports whether move i left a winning position for one that is not. Evaluations are
+// from the point of view of the side to move, so the mover's evaluation after the move is minus
+// that of the next move.
+func missedWin(moves []MoveAnalysis, i int) bool {
+	if i+1 >= len(moves) || moves[i].Classification == ClassBook || moves[i+1].Classification == ClassBook {
+		return false
+	}
+	return moves[i].Evaluation >= WinningEval && -moves[i+1].Evaluation <= notWinningEval
+}
+
+// FinalEvaluation returns the evaluation of the position a game ended in, in pawns from White's
+// point of view: the evaluation before the last move less what the move lost. It returns false when
+// the analysis is empty or its last move is a book move.
+func FinalEvaluation(pgn string, analysis *GameAnalysis) (float64, bool) {
+	if len(analysis.Moves) == 0 {
+		return 0, false
+	}
+	last := analysis.Moves[len(analysis.Moves)-1]
+	if last.Classification == ClassBook {
+		return 0, false
+	}
+	evaluation := last.Evaluation - float64(last.CentipawnLoss)/100
+	whiteFirst := strings.Fields(StartFEN(pgn))[1] == "w"
+	if whiteMovedLast := (len(analysis.Moves)%2 == 1) == whiteFirst; !whiteMovedLast {
+		evaluation = -evaluation
+	}
+	return evaluation, true
+}
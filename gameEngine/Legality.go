@@ -0,0 +1,212 @@
+package gameengine
+
+import (
+	"fmt"
+	"strings"
+)
+
+// IllegalMoveError reports a move of a PGN that cannot be played in the position it occurs in.
+type IllegalMoveError struct {
+	Ply    int    // 1-based ply number of the move
+	Move   string // The move in UCI notation
+	FEN    string // Position before the move
+	Reason string
+}
+
+// Error formats the error with the move number, e.g. "illegal move 12... e7e5 (ply 24)".
+func (e *IllegalMoveError) Error() string {
+	moveNumber := fmt.Sprintf("%d.", (e.Ply+1)/2)
+	if e.Ply%2 == 0 {
+		moveNumber += ".."
+	}
+	return fmt.Sprintf("illegal move %s %s (ply %d) in position %s: %s", moveNumber, e.Move, e.Ply, e.FEN, e.Reason)
+}
+
+// fenBoard is a minimal board representation parsed directly from a FEN, independent of the chess
+// library, used to double-check the moves the PGN parser produced.
+type fenBoard struct {
+	squares     [8][8]byte // [rank][file], 0 for empty; uppercase is white
+	whiteToMove bool
+	castling    string
+	enPassant   string
+}
+
+// parseFENBoard parses the board, side to move, castling and en passant fields of a FEN.
+func parseFENBoard(fen string) (*fenBoard, error) {
+	fields := strings.Fields(fen)
+	if len(fields) < 4 {
+		return nil, fmt.Errorf("incomplete FEN")
+	}
+	board := &fenBoard{whiteToMove: fields[1] == "w", castling: fields[2], enPassant: fields[3]}
+	ranks := strings.Split(fields[0], "/")
+	if len(ranks) != 8 {
+		return nil, fmt.Errorf("FEN board must have 8 ranks")
+	}
+	for i, rankText := range ranks {
+		rank := 7 - i
+		file := 0
+		for _, c := range rankText {
+			switch {
+			case c >= '1' && c <= '8':
+				file += int(c - '0')
+			case strings.ContainsRune("pnbrqkPNBRQK", c):
+				if file > 7 {
+					return nil, fmt.Errorf("FEN rank %d is too long", rank+1)
+				}
+				board.squares[rank][file] = byte(c)
+				file++
+			default:
+				return nil, fmt.Errorf("invalid FEN character %q", c)
+			}
+		}
+		if file != 8 {
+			return nil, fmt.Errorf("FEN rank %d does not have 8 files", rank+1)
+		}
+	}
+	return board, nil
+}
+
+// parseSquare converts a square name such as "e4" into file and rank indexes.
+func parseSquare(name string) (int, int, bool) {
+	if len(name) != 2 || name[0] < 'a' || name[0] > 'h' || name[1] < '1' || name[1] > '8' {
+		return 0, 0, false
+	}
+	return int(name[0] - 'a'), int(name[1] - '1'), true
+}
+
+// isOwn reports whether a piece belongs to the side to move.
+func (b *fenBoard) isOwn(piece byte) bool {
+	if piece == 0 {
+		return false
+	}
+	return (piece >= 'A' && piece <= 'Z') == b.whiteToMove
+}
+
+// pathClear reports whether all squares strictly between two squares on a line are empty.
+func (b *fenBoard) pathClear(fromFile, fromRank, toFile, toRank int) bool {
+	stepFile, stepRank := sign(toFile-fromFile), sign(toRank-fromRank)
+	file, rank := fromFile+stepFile, fromRank+stepRank
+	for file != toFile || rank != toRank {
+		if b.squares[rank][file] != 0 {
+			return false
+		}
+		file, rank = file+stepFile, rank+stepRank
+	}
+	return true
+}
+
+// checkPseudoLegal verifies that a UCI move obeys the movement rules of the piece on its from-square.
+// It does not check whether the move leaves the king in check; the chess library covers that.
+func (b *fenBoard) checkPseudoLegal(uci string) error {
+	if len(uci) < 4 || len(uci) > 5 {
+		return fmt.Errorf("malformed move")
+	}
+	fromFile, fromRank, ok1 := parseSquare(uci[0:2])
+	toFile, toRank, ok2 := parseSquare(uci[2:4])
+	if !ok1 || !ok2 {
+		return fmt.Errorf("malformed move")
+	}
+	piece := b.squares[fromRank][fromFile]
+	if piece == 0 {
+		return fmt.Errorf("no piece on %s", uci[0:2])
+	}
+	if !b.isOwn(piece) {
+		return fmt.Errorf("the piece on %s belongs to the side not to move", uci[0:2])
+	}
+	if b.isOwn(b.squares[toRank][toFile]) {
+		return fmt.Errorf("%s is occupied by a piece of the side to move", uci[2:4])
+	}
+
+	df, dr := toFile-fromFile, toRank-fromRank
+	adf, adr := abs(df), abs(dr)
+	switch strings.ToLower(string(piece)) {
+	case "n":
+		if !(adf == 1 && adr == 2 || adf == 2 && adr == 1) {
+			return fmt.Errorf("knights cannot move from %s to %s", uci[0:2], uci[2:4])
+		}
+	case "b":
+		if adf != adr || !b.pathClear(fromFile, fromRank, toFile, toRank) {
+			return fmt.Errorf("bishop path from %s to %s is not a clear diagonal", uci[0:2], uci[2:4])
+		}
+	case "r":
+		if (df != 0 && dr != 0) || !b.pathClear(fromFile, fromRank, toFile, toRank) {
+			return fmt.Errorf("rook path from %s to %s is not a clear line", uci[0:2], uci[2:4])
+		}
+	case "q":
+		if !(adf == adr || df == 0 || dr == 0) || !b.pathClear(fromFile, fromRank, toFile, toRank) {
+			return fmt.Errorf("queen path from %s to %s is not clear", uci[0:2], uci[2:4])
+		}
+	case "k":
+		if adf <= 1 && adr <= 1 {
+			break
+		}
+		// Castling: two files sideways along the back rank, with the right still available.
+		right := map[bool]map[int]byte{true: {2: 'K', -2: 'Q'}, false: {2: 'k', -2: 'q'}}[b.whiteToMove][df]
+		if dr != 0 || right == 0 || !strings.ContainsRune(b.castling, rune(right)) {
+			return fmt.Errorf("kings cannot move from %s to %s", uci[0:2], uci[2:4])
+		}
+		rookFile := 7
+		if df < 0 {
+			rookFile = 0
+		}
+		if !b.pathClear(fromFile, fromRank, rookFile, fromRank) {
+			return fmt.Errorf("castling path is blocked")
+		}
+	case "p":
+		forward := 1
+		startRank, lastRank := 1, 7
+		if !b.whiteToMove {
+			forward, startRank, lastRank = -1, 6, 0
+		}
+		switch {
+		case df == 0 && dr == forward && b.squares[toRank][toFile] == 0:
+		case df == 0 && dr == 2*forward && fromRank == startRank && b.squares[toRank][toFile] == 0 && b.squares[fromRank+forward][fromFile] == 0:
+		case adf == 1 && dr == forward && (b.squares[toRank][toFile] != 0 || uci[2:4] == b.enPassant):
+		default:
+			return fmt.Errorf("pawns cannot move from %s to %s", uci[0:2], uci[2:4])
+		}
+		if (toRank == lastRank) != (len(uci) == 5) {
+			return fmt.Errorf("promotion does not match the destination rank")
+		}
+	}
+	return nil
+}
+
+// crossCheckMove independently verifies a move decoded by the PGN parser against the position it is
+// played in, and that the resulting position has the other side to move.
+func crossCheckMove(ply int, fenBefore, move, fenAfter string) error {
+	board, err := parseFENBoard(fenBefore)
+	if err != nil {
+		return &IllegalMoveError{Ply: ply, Move: move, FEN: fenBefore, Reason: "unreadable position: " + err.Error()}
+	}
+	if err := board.checkPseudoLegal(move); err != nil {
+		return &IllegalMoveError{Ply: ply, Move: move, FEN: fenBefore, Reason: err.Error()}
+	}
+	after, err := parseFENBoard(fenAfter)
+	if err != nil {
+		return &IllegalMoveError{Ply: ply, Move: move, FEN: fenBefore, Reason: "unreadable resulting position: " + err.Error()}
+	}
+	if after.whiteToMove == board.whiteToMove {
+		// Evaluations would silently switch point of view from here on.
+		return &IllegalMoveError{Ply: ply, Move: move, FEN: fenBefore, Reason: "side to move did not change"}
+	}
+	return nil
+}
+
+func sign(n int) int {
+	switch {
+	case n > 0:
+		return 1
+	case n < 0:
+		return -1
+	default:
+		return 0
+	}
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,302 @@
+package gameengine
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/notnil/chess"
+)
+
+// defaultMatchMaxPlies is the ply limit of match games when MatchConfig.MaxPlies is not set.
+const defaultMatchMaxPlies = 300
+
+// matchCandidates is the number of moves considered during the random opening plies of a match game.
+const matchCandidates = 4
+
+// TerminationMoveLimit is the termination of match games adjudicated as draws at the ply limit.
+const TerminationMoveLimit = "MoveLimit"
+
+// MatchConfig describes a series of engine games played from one position.
+type MatchConfig struct {
+	StartFEN     string        // Position the games start from; empty for the standard starting position.
+	Games        int           // Number of games to play.
+	MoveTime     time.Duration // Search time per move.
+	Depth        int           // Search depth per move; takes precedence when MoveTime is 0.
+	MaxPlies     int           // Games still running after this many plies are adjudicated as draws.
+	RandomPlies  int           // Opening plies chosen at random among near-best moves, so that games differ.
+	RandomMargin int           // Centipawns a randomly chosen move may be worse than the best one.
+}
+
+// MatchGame is the outcome of one game of a match.
+type MatchGame struct {
+	Outcome     chess.Outcome
+	Termination string   // How the game ended, e.g. "Checkmate", "ThreefoldRepetition" or TerminationMoveLimit.
+	FirstStarts bool     // Whether the first engine played the side to move in the start position.
+	Moves       []string // Moves in UCI notation.
+}
+
+// MatchResult summarises a match. Wins, Draws and Losses are from the point of view of the side to
+// move in the start position, whichever engine played it.
+type MatchResult struct {
+	Games        []MatchGame
+	Wins         int
+	Draws        int
+	Losses       int
+	FirstPoints  float64 // Points scored by the first engine.
+	Terminations map[string]int
+}
+
+// Score returns the points of the side to move in the start position as a percentage.
+func (r MatchResult) Score() float64 {
+	if len(r.Games) == 0 {
+		return 0
+	}
+	return (float64(r.Wins) + float64(r.Draws)/2) * 100 / float64(len(r.Games))
+}
+
+// RunMatch plays config.Games games from the start position between two engines, alternating which
+// engine plays the side to move. If second is nil, the first engine plays against itself. onGame,
+// if not nil, is called after every game with its 1-based number.
+func RunMatch(first, second *StockfishAnalyser, config MatchConfig, onGame func(int, MatchGame)) (*MatchResult, error) {
+	if second == nil {
+		second = first
+	}
+	startFEN := config.StartFEN
+	if startFEN == "" {
+		startFEN = StandardStartFEN
+	}
+	start, err := chess.FEN(normalizeStartFEN(startFEN))
+	if err != nil {
+		return nil, fmt.Errorf("invalid start position: %w", err)
+	}
+	if config.MaxPlies <= 0 {
+		config.MaxPlies = defaultMatchMaxPlies
+	}
+	goCommand := Preset{MoveTime: config.MoveTime, Depth: config.Depth}.goCommand()
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+
+	engines := []*StockfishAnalyser{first, second}
+	if second == first {
+		engines = engines[:1]
+	}
+	// Restore the engines' analysis settings afterwards.
+	defer func() {
+		for _, engine := range engines {
+			engine.setMultiPV(engine.preset.MultiPV)
+		}
+	}()
+
+	result := &MatchResult{Terminations: make(map[string]int)}
+	for i := 0; i < config.Games; i++ {
+		game := chess.NewGame(start)
+		startSide := game.Position().Turn()
+		played := MatchGame{FirstStarts: i%2 == 0}
+		for _, engine := range engines {
+			if err := engine.newGame(); err != nil {
+				return nil, err
+			}
+		}
+
+		for ply := 0; game.Outcome() == chess.NoOutcome; ply++ {
+			if ply >= config.MaxPlies {
+				played.Termination = TerminationMoveLimit
+				break
+			}
+			if claimDraw(game) {
+				break
+			}
+			engine := second
+			if (game.Position().Turn() == startSide) == played.FirstStarts {
+				engine = first
+			}
+			random := ply < config.RandomPlies
+			uci, err := engine.chooseMove(game.FEN(), goCommand, random, config.RandomMargin, rng)
+			if err != nil {
+				return nil, err
+			}
+			move := findValidMove(game.Position(), uci)
+			if move == nil {
+				return nil, fmt.Errorf("engine played illegal move %q in %s", uci, game.FEN())
+			}
+			if err := game.Move(move); err != nil {
+				return nil, err
+			}
+			played.Moves = append(played.Moves, uci)
+		}
+
+		played.Outcome = game.Outcome()
+		if played.Termination == "" {
+			played.Termination = game.Method().String()
+		}
+		if played.Outcome == chess.NoOutcome {
+			played.Outcome = chess.Draw
+		}
+		result.add(played, startSide)
+		if onGame != nil {
+			onGame(i+1, played)
+		}
+	}
+	return result, nil
+}
+
+// add counts a finished game.
+func (r *MatchResult) add(game MatchGame, startSide chess.Color) {
+	r.Games = append(r.Games, game)
+	r.Terminations[game.Termination]++
+	points := 0.5
+	switch {
+	case game.Outcome == chess.Draw:
+		r.Draws++
+	case (game.Outcome == chess.WhiteWon) == (startSide == chess.White):
+		r.Wins++
+		points = 1
+	default:
+		r.Losses++
+		points = 0
+	}
+	if !game.FirstStarts {
+		points = 1 - points
+	}
+	r.FirstPoints += points
+}
+
+// claimDraw ends the game as a draw if a threefold repetition or the fifty-move rule can be claimed.
+func claimDraw(game *chess.Game) bool {
+	for _, method := range game.EligibleDraws() {
+		if method == chess.ThreefoldRepetition || method == chess.FiftyMoveRule {
+			return game.Draw(method) == nil
+		}
+	}
+	return false
+}
+
+// findValidMove returns the legal move with the given UCI notation, or nil.
+func findValidMove(position *chess.Position, uci string) *chess.Move {
+	for _, move := range position.ValidMoves() {
+		if move.String() == uci {
+			return move
+		}
+	}
+	return nil
+}
+
+// newGame tells the engine that the next search belongs to a new game.
+func (s *StockfishAnalyser) newGame() error {
+	if err := s.sendCommand("ucinewgame"); err != nil {
+		return err
+	}
+	if err := s.sendCommand("isready"); err != nil {
+		return err
+	}
+	_, err := s.readUntil("readyok")
+	return err
+}
+
+// chooseMove searches a position and returns the move to play in UCI notation. With random set,
+// the move is picked at random among the candidates scoring within margin centipawns of the best.
+func (s *StockfishAnalyser) chooseMove(fen, goCommand string, random bool, margin int, rng *rand.Rand) (string, error) {
+	multiPV := 1
+	if random {
+		multiPV = matchCandidates
+	}
+	if err := s.setMultiPV(multiPV); err != nil {
+		return "", err
+	}
+	if err := s.sendCommand(fmt.Sprintf("position fen %s", fen)); err != nil {
+		return "", fmt.Errorf("error writing to stockfish: %w", err)
+	}
+	if err := s.sendCommand(goCommand); err != nil {
+		return "", fmt.Errorf("error writing to stockfish: %w", err)
+	}
+	output, err := s.readUntil("bestmove")
+	if err != nil {
+		return "", fmt.Errorf("error reading from stockfish: %w", err)
+	}
+
+	bestMove := parseBestMove(output)
+	if random {
+		candidates := parseCandidates(output)
+		if len(candidates) > 0 {
+			var near []string
+			for _, candidate := range candidates {
+				if candidate.score >= candidates[0].score-margin {
+					near = append(near, candidate.move)
+				}
+			}
+			bestMove = near[rng.Intn(len(near))]
+		}
+	}
+	if bestMove == "" || bestMove == "(none)" {
+		return "", fmt.Errorf("engine returned no move for %s", fen)
+	}
+	return bestMove, nil
+}
+
+// parseBestMove returns the move of the "bestmove" line of a search.
+func parseBestMove(output string) string {
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 2 && fields[0] == "bestmove" {
+			return fields[1]
+		}
+	}
+	return ""
+}
+
+// moveCandidate is the first move of a principal variation with its score.
+type moveCandidate struct {
+	move  string
+	score int
+}
+
+// parseCandidates returns the first move and final score of every principal variation of a search,
+// best first.
+func parseCandidates(output string) []moveCandidate {
+	byRank := make(map[int]moveCandidate)
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || fields[0] != "info" {
+			continue
+		}
+		rank := 1
+		var candidate moveCandidate
+		hasScore := false
+		for j := 1; j < len(fields)-1; j++ {
+			switch fields[j] {
+			case "multipv":
+				rank, _ = strconv.Atoi(fields[j+1])
+			case "score":
+				if j+2 < len(fields) {
+					if value, err := strconv.Atoi(fields[j+2]); err == nil {
+						score := engineScore{Centipawns: value}
+						if fields[j+1] == "mate" {
+							score = engineScore{IsMate: true, MateIn: value}
+						}
+						candidate.score, hasScore = score.value(), true
+					}
+				}
+			case "pv":
+				candidate.move = fields[j+1]
+			}
+		}
+		// Later lines come from deeper iterations and replace earlier ones.
+		if hasScore && candidate.move != "" {
+			byRank[rank] = candidate
+		}
+	}
+
+	ranks := make([]int, 0, len(byRank))
+	for rank := range byRank {
+		ranks = append(ranks, rank)
+	}
+	sort.Ints(ranks)
+	candidates := make([]moveCandidate, 0, len(ranks))
+	for _, rank := range ranks {
+		candidates = append(candidates, byRank[rank])
+	}
+	return candidates
+}
@@ -0,0 +1,76 @@
+package gameengine
+
+import (
+	"chessAnalyserFree/api"
+	"fmt"
+	"strings"
+)
+
+// MergeAnalyses combines several analyses of the same game into one, taking every move from the
+// analysis that searched its position deepest. Each merged move keeps the Depth and Source of the
+// analysis it came from; on equal depth the earlier analysis wins. Analyses with issues are skipped
+// unless none is valid.
+func MergeAnalyses(game api.Game, analyses ...*GameAnalysis) (*GameAnalysis, error) {
+	var candidates []*GameAnalysis
+	for _, analysis := range analyses {
+		if analysis != nil && analysis.IsValid() {
+			candidates = append(candidates, analysis)
+		}
+	}
+	if len(candidates) == 0 {
+		for _, analysis := range analyses {
+			if analysis != nil {
+				candidates = append(candidates, analysis)
+			}
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("no analyses to merge")
+	}
+
+	first := candidates[0]
+	for _, analysis := range candidates[1:] {
+		if len(analysis.Moves) != len(first.Moves) {
+			return nil, fmt.Errorf("analyses cover %d and %d moves", len(first.Moves), len(analysis.Moves))
+		}
+		for i, move := range analysis.Moves {
+			if move.Move != first.Moves[i].Move {
+				return nil, fmt.Errorf("analyses differ at ply %d: %s and %s", i+1, first.Moves[i].Move, move.Move)
+			}
+		}
+	}
+
+	merged := &GameAnalysis{Moves: make([]MoveAnalysis, len(first.Moves))}
+	var presets, engines []string
+	for _, analysis := range candidates {
+		presets = appendUnique(presets, analysis.Preset)
+		engines = appendUnique(engines, analysis.Engine)
+	}
+	merged.Preset = "merged: " + strings.Join(presets, ", ")
+	merged.Engine = strings.Join(engines, ", ")
+
+	for i := range merged.Moves {
+		best := first.Moves[i]
+		for _, analysis := range candidates[1:] {
+			if analysis.Moves[i].Depth > best.Depth {
+				best = analysis.Moves[i]
+			}
+		}
+		merged.Moves[i] = best
+	}
+	merged.Issues = ValidateAnalysis(game, merged)
+	return merged, nil
+}
+
+// appendUnique appends a non-empty value unless the slice already holds it.
+func appendUnique(values []string, value string) []string {
+	if value == "" {
+		return values
+	}
+	for _, existing := range values {
+		if existing == value {
+			return values
+		}
+	}
+	return append(values, value)
+}
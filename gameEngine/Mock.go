@@ -0,0 +1,161 @@
+package gameengine
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/notnil/chess"
+)
+
+// mockPieceValues are the material values, in centipawns, the mock engine evaluates positions by.
+var mockPieceValues = map[chess.PieceType]int{chess.Pawn: 100, chess.Knight: 300, chess.Bishop: 300, chess.Rook: 500, chess.Queen: 900}
+
+// ConnectMock is a Connector to an in-memory UCI engine for tests and for trying the analysis out
+// without Stockfish. Its searches are deterministic and instant: it plays every legal move and ranks
+// them by the material the side to move has afterwards, a move that mates first and equal moves in
+// UCI order, whatever depth or time it is given. It answers uci, isready, setoption (only MultiPV
+// matters), ucinewgame, position fen, position startpos, go, stop and quit.
+func ConnectMock() (io.ReadWriteCloser, error) {
+	commands, commandWriter := io.Pipe()
+	outputReader, output := io.Pipe()
+	go runMockEngine(commands, output)
+	return &mockConnection{Reader: outputReader, Writer: commandWriter, output: outputReader, commands: commandWriter}, nil
+}
+
+// NewMockAnalyser returns an analyser of the mock engine of ConnectMock.
+func NewMockAnalyser() (*StockfishAnalyser, error) {
+	return NewUCIAnalyser(ConnectMock)
+}
+
+// mockConnection is the analyser's end of the pipes to the mock engine.
+type mockConnection struct {
+	io.Reader
+	io.Writer
+	output   *io.PipeReader
+	commands *io.PipeWriter
+}
+
+// Close ends the mock engine, which stops at the end of its commands.
+func (c *mockConnection) Close() error {
+	c.commands.Close()
+	c.output.Close()
+	return nil
+}
+
+// runMockEngine answers the UCI commands read from commands until quit or the end of the commands.
+func runMockEngine(commands *io.PipeReader, output *io.PipeWriter) {
+	defer commands.Close()
+	defer output.Close()
+	position := chess.StartingPosition()
+	multiPV := 1
+	scanner := bufio.NewScanner(commands)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		var reply []string
+		switch fields[0] {
+		case "uci":
+			reply = []string{"id name Mock engine", "id author chessAnalyserFree", "uciok"}
+		case "isready":
+			reply = []string{"readyok"}
+		case "setoption":
+			// setoption name MultiPV value N
+			if len(fields) == 5 && fields[2] == "MultiPV" {
+				if n, err := strconv.Atoi(fields[4]); err == nil && n > 0 {
+					multiPV = n
+				}
+			}
+		case "position":
+			if len(fields) > 1 && fields[1] == "startpos" {
+				position = chess.StartingPosition()
+			} else if len(fields) >= 3 && fields[1] == "fen" {
+				if start, err := chess.FEN(strings.Join(fields[2:min(len(fields), 8)], " ")); err == nil {
+					position = chess.NewGame(start).Position()
+				}
+			}
+		case "go":
+			depth := 1
+			for i := 1; i < len(fields)-1; i++ {
+				if fields[i] == "depth" {
+					if n, err := strconv.Atoi(fields[i+1]); err == nil && n > 0 {
+						depth = n
+					}
+				}
+			}
+			reply = mockSearch(position, depth, multiPV)
+		case "quit":
+			return
+		}
+		// ucinewgame and stop need no answer; unknown commands are ignored, as UCI engines do.
+		for _, line := range reply {
+			if _, err := fmt.Fprintln(output, line); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// mockMove is a legal move with the score the mock engine gives it.
+type mockMove struct {
+	uci   string
+	score string // e.g. "cp 100" or "mate 1"
+	value int    // Score in centipawns, mates mapped to mateValue
+}
+
+// mockSearch returns the output of a search of position: an info line for each of the multiPV best
+// moves, at the given depth, and the bestmove line.
+func mockSearch(position *chess.Position, depth, multiPV int) []string {
+	var moves []mockMove
+	for _, move := range position.ValidMoves() {
+		after := position.Update(move)
+		candidate := mockMove{uci: chess.UCINotation{}.Encode(position, move)}
+		switch after.Status() {
+		case chess.Checkmate:
+			candidate.score, candidate.value = "mate 1", mateValue
+		case chess.Stalemate:
+			candidate.score = "cp 0"
+		default:
+			candidate.value = mockMaterial(after, position.Turn())
+			candidate.score = fmt.Sprintf("cp %d", candidate.value)
+		}
+		moves = append(moves, candidate)
+	}
+	if len(moves) == 0 {
+		score := "cp 0"
+		if position.Status() == chess.Checkmate {
+			score = "mate 0"
+		}
+		return []string{fmt.Sprintf("info depth 0 score %s", score), "bestmove (none)"}
+	}
+
+	sort.Slice(moves, func(i, j int) bool {
+		if moves[i].value != moves[j].value {
+			return moves[i].value > moves[j].value
+		}
+		return moves[i].uci < moves[j].uci
+	})
+	var lines []string
+	for i, move := range moves[:min(multiPV, len(moves))] {
+		lines = append(lines, fmt.Sprintf("info depth %d seldepth %d multipv %d score %s nodes %d pv %s", depth, depth, i+1, move.score, len(moves), move.uci))
+	}
+	return append(lines, "bestmove "+moves[0].uci)
+}
+
+// mockMaterial returns the material balance of a position in centipawns from side's point of view.
+func mockMaterial(position *chess.Position, side chess.Color) int {
+	balance := 0
+	for _, piece := range position.Board().SquareMap() {
+		if piece.Color() == side {
+			balance += mockPieceValues[piece.Type()]
+		} else {
+			balance -= mockPieceValues[piece.Type()]
+		}
+	}
+	return balance
+}
@@ -0,0 +1,171 @@
+package gameengine
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+var (
+	// moveNumberPrefixRegex matches the move number in front of a move ("12.", "12...", "12.e4").
+	moveNumberPrefixRegex = regexp.MustCompile(`^\d+\.+`)
+	// zeroCastlingRegex matches castling written with zeros ("0-0", "0-0-0+").
+	zeroCastlingRegex = regexp.MustCompile(`^0-0(-0)?`)
+	// commentEscaper replaces the characters of a comment the chess library would take for syntax.
+	commentEscaper = strings.NewReplacer(`"`, "'", "{", "(")
+)
+
+// MovetextMove is a mainline move of a PGN as written, with the comments that follow it.
+type MovetextMove struct {
+	SAN          string   // The move without move number or annotation suffix, e.g. "Nf3" or "O-O"
+	Comments     []string // Text of the {} and ; comments after the move, e.g. "[%clk 0:02:59.9]"
+	Line, Column int      // 1-based position of the move as written in the PGN text
+}
+
+// Movetext is the mainline of a PGN game, with the annotations that do not describe it removed.
+type Movetext struct {
+	Tags   []string       // Tag pair lines, as written
+	Moves  []MovetextMove // Mainline moves, in order
+	Result string         // Game termination marker ("1-0", "0-1", "1/2-1/2" or "*"), or empty
+}
+
+// ParseMovetext splits a PGN game into its tags and mainline moves without a chess parser. Variations,
+// at any depth, NAGs ("$1"), annotation glyphs ("!?", "+-"), move numbers and "%" escape lines are
+// dropped; castling written with zeros and en passant suffixes ("exd6 e.p.") are normalised; and
+// comments are kept with the move they follow, except for those before the first move. Unbalanced
+// parentheses and an unterminated comment are tolerated, so annotation noise never stops a game
+// from being read; only the moves themselves are left to be checked when they are replayed.
+func ParseMovetext(pgn string) Movetext {
+	var movetext Movetext
+	lines := strings.Split(pgn, "\n")
+	body := 0
+	for ; body < len(lines); body++ {
+		trimmed := strings.TrimSpace(lines[body])
+		if trimmed != "" && !strings.HasPrefix(trimmed, "[") {
+			break
+		}
+		if trimmed != "" {
+			movetext.Tags = append(movetext.Tags, trimmed)
+		}
+	}
+
+	var token, comment strings.Builder
+	depth := 0           // Nesting of the variation being read.
+	inComment := byte(0) // '{' or ';' in a comment.
+	line, column := 0, 0 // Position of the token being read.
+	endToken := func() {
+		if depth == 0 && movetext.Result == "" {
+			movetext.addToken(token.String(), line, column)
+		}
+		token.Reset()
+	}
+	endComment := func() {
+		text := strings.Join(strings.Fields(comment.String()), " ")
+		comment.Reset()
+		inComment = 0
+		if depth == 0 && movetext.Result == "" && text != "" && len(movetext.Moves) > 0 {
+			last := &movetext.Moves[len(movetext.Moves)-1]
+			last.Comments = append(last.Comments, text)
+		}
+	}
+	for n, text := range lines[body:] {
+		if strings.HasPrefix(text, "%") && inComment != '{' {
+			continue
+		}
+		for i, r := range []rune(text) {
+			switch {
+			case inComment == '{' && r == '}':
+				endComment()
+			case inComment != 0:
+				comment.WriteRune(r)
+			case r == '{' || r == ';':
+				endToken()
+				inComment = byte(r)
+			case r == '(':
+				endToken()
+				depth++
+			case r == ')':
+				endToken()
+				depth = max(depth-1, 0)
+			case unicode.IsSpace(r):
+				endToken()
+			default:
+				if token.Len() == 0 {
+					line, column = body+n+1, i+1
+				}
+				token.WriteRune(r)
+			}
+		}
+		switch inComment {
+		case ';':
+			endComment()
+		case '{':
+			comment.WriteRune(' ')
+		default:
+			endToken()
+		}
+	}
+	if inComment != 0 {
+		endComment()
+	}
+	return movetext
+}
+
+// addToken adds a mainline token of the movetext: a move, the result, or an annotation to drop.
+func (m *Movetext) addToken(token string, line, column int) {
+	switch token {
+	case "":
+		return
+	case "1-0", "0-1", "1/2-1/2", "*":
+		m.Result = token
+		return
+	case "½-½":
+		m.Result = "1/2-1/2"
+		return
+	}
+	if strings.HasPrefix(token, "$") {
+		return
+	}
+	if number := moveNumberPrefixRegex.FindString(token); number != "" {
+		token, column = token[len(number):], column+len(number)
+	}
+	token = zeroCastlingRegex.ReplaceAllStringFunc(token, func(castling string) string {
+		return strings.ReplaceAll(castling, "0", "O")
+	})
+	token = strings.TrimSuffix(strings.TrimRight(token, "!?"), "e.p.")
+	if token == "--" || strings.IndexFunc(token, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }) >= 0 {
+		m.Moves = append(m.Moves, MovetextMove{SAN: token, Line: line, Column: column})
+	}
+	// Anything else is an annotation glyph ("!", "+-", "=", "∞") or a bare move number.
+}
+
+// String writes the movetext back as a PGN game the chess library parses without surprises: the tags,
+// then the moves and their comments on a single line, without move numbers, and the result.
+// Comments are written without double quotes or opening braces.
+func (m Movetext) String() string {
+	var b strings.Builder
+	for _, tag := range m.Tags {
+		b.WriteString(tag)
+		b.WriteString("\n")
+	}
+	b.WriteString("\n")
+	for i, move := range m.Moves {
+		if i > 0 {
+			b.WriteString(" ")
+		}
+		b.WriteString(move.SAN)
+		for _, comment := range move.Comments {
+			b.WriteString(" {")
+			b.WriteString(commentEscaper.Replace(comment))
+			b.WriteString("}")
+		}
+	}
+	result := m.Result
+	if result == "" {
+		result = "*"
+	}
+	b.WriteString(" ")
+	b.WriteString(result)
+	b.WriteString("\n")
+	return b.String()
+}
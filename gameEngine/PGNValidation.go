@@ -0,0 +1,213 @@
+package gameengine
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/notnil/chess"
+)
+
+var (
+	// pgnTagRegex matches a tag pair line such as [White "hikaru"].
+	pgnTagRegex = regexp.MustCompile(`^\[(\w+)\s+"(.*)"\]$`)
+	// sanRegex matches a move in algebraic notation, short or long, capturing the piece letter and
+	// the destination square.
+	sanRegex = regexp.MustCompile(`^([KQRBN])?[a-h]?[1-8]?[-x]?([a-h][1-8])(?:=?[QRBNqrbn])?[+#]?$`)
+	// castlingRegex matches a castling move.
+	castlingRegex = regexp.MustCompile(`^O-O(?:-O)?[+#]?$`)
+)
+
+// pieceNames names the pieces by the letter of their moves in algebraic notation.
+var pieceNames = map[string]string{"": "pawn", "K": "king", "Q": "queen", "R": "rook", "B": "bishop", "N": "knight"}
+
+// PGNError reports the tag or move that keeps a PGN from being analysed, and where it is in the text.
+type PGNError struct {
+	Line, Column int    // 1-based position in the PGN text; Column is 0 for a tag, which is a line of its own
+	Tag          string // The tag as written; empty for a move
+	Move         string // The move as written, with its number, e.g. "12... Nf9"; empty for a tag
+	Reason       string
+}
+
+// Error formats the error with its position, e.g. "line 14, column 9: move 12... Nf9: not a move".
+func (e *PGNError) Error() string {
+	where := fmt.Sprintf("line %d", e.Line)
+	if e.Column > 0 {
+		where += fmt.Sprintf(", column %d", e.Column)
+	}
+	if e.Tag != "" {
+		return fmt.Sprintf("%s: tag %s: %s", where, e.Tag, e.Reason)
+	}
+	return fmt.Sprintf("%s: move %s: %s", where, e.Move, e.Reason)
+}
+
+// ValidatePGN checks a PGN game before it is analysed: that its tags are well-formed, that it starts
+// from a legal position, and that every mainline move, as ParseMovetext reads it, can be played in
+// turn, double-checking every position the moves lead to. The first problem found is returned as a
+// *PGNError.
+func ValidatePGN(pgn string) error {
+	fenLine := 0
+	for i, line := range strings.Split(pgn, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			continue
+		}
+		if !strings.HasPrefix(trimmed, "[") {
+			break
+		}
+		matches := pgnTagRegex.FindStringSubmatch(trimmed)
+		if matches == nil {
+			return &PGNError{Line: i + 1, Tag: trimmed, Reason: `malformed tag pair, expected [Name "value"]`}
+		}
+		if matches[1] == "FEN" && fenLine == 0 {
+			fenLine = i + 1
+		}
+	}
+
+	startFEN := StartFEN(pgn)
+	start, err := checkStartFEN(startFEN)
+	if err != nil {
+		return &PGNError{Line: fenLine, Tag: fmt.Sprintf(`[FEN "%s"]`, startFEN), Reason: err.Error()}
+	}
+	game := chess.NewGame(start)
+	chess960 := IsChess960(pgn)
+	for i, written := range ParseMovetext(pgn).Moves {
+		fenBefore := game.FEN()
+		moveError := func(reason string) error {
+			return &PGNError{Line: written.Line, Column: written.Column, Move: moveLabel(fenBefore, written.SAN), Reason: reason}
+		}
+		move, err := decodeMove(game.Position(), written.SAN)
+		if err != nil {
+			if chess960 && castlingRegex.MatchString(written.SAN) {
+				return moveError("castling in Chess960 games is not supported")
+			}
+			return moveError(err.Error())
+		}
+		if err := game.Move(move); err != nil {
+			return moveError(err.Error())
+		}
+		if err := crossCheckMove(i+1, fenBefore, move.String(), game.FEN()); err != nil {
+			var illegal *IllegalMoveError
+			if errors.As(err, &illegal) {
+				return moveError(illegal.Reason)
+			}
+			return moveError(err.Error())
+		}
+	}
+	return nil
+}
+
+// checkStartFEN checks that a start position is complete and could occur in a game: one king a
+// side, and no pawns on the first or last rank.
+func checkStartFEN(fen string) (func(*chess.Game), error) {
+	board, err := parseFENBoard(fen)
+	if err != nil {
+		return nil, err
+	}
+	kings := map[byte]int{}
+	for rank, row := range board.squares {
+		for _, piece := range row {
+			switch {
+			case piece == 'K' || piece == 'k':
+				kings[piece]++
+			case (piece == 'P' || piece == 'p') && (rank == 0 || rank == 7):
+				return nil, fmt.Errorf("a pawn stands on rank %d", rank+1)
+			}
+		}
+	}
+	if kings['K'] != 1 || kings['k'] != 1 {
+		return nil, fmt.Errorf("each side needs exactly one king, White has %d and Black %d", kings['K'], kings['k'])
+	}
+	start, err := chess.FEN(fen)
+	if err != nil {
+		return nil, fmt.Errorf("invalid start position: %w", err)
+	}
+	return start, nil
+}
+
+// decodeMove finds the legal move written in algebraic notation, short or long, or in UCI notation,
+// or says why there is none.
+func decodeMove(position *chess.Position, written string) (*chess.Move, error) {
+	if written == "--" || written == "Z0" {
+		return nil, errors.New("null moves cannot be analysed")
+	}
+	for _, notation := range []chess.Decoder{chess.AlgebraicNotation{}, chess.LongAlgebraicNotation{}, chess.UCINotation{}} {
+		if move, err := notation.Decode(position, written); err == nil {
+			return move, nil
+		}
+	}
+	return nil, errors.New(diagnoseMove(position, written))
+}
+
+// diagnoseMove explains why a written move cannot be played in a position: it is not a move at all,
+// it is one for the other side, or no piece of its kind can reach its square the way it says.
+func diagnoseMove(position *chess.Position, written string) string {
+	turn := position.Turn()
+	if flipped, err := chess.FEN(flipSideToMove(position.String())); err == nil {
+		if _, err := (chess.AlgebraicNotation{}).Decode(chess.NewGame(flipped).Position(), written); err == nil {
+			return fmt.Sprintf("it is %s to move, but this is a move for %s: is a move missing or repeated before it?", turn.Name(), turn.Other().Name())
+		}
+	}
+	if castlingRegex.MatchString(written) {
+		return fmt.Sprintf("%s cannot castle that way here", turn.Name())
+	}
+	matches := sanRegex.FindStringSubmatch(written)
+	if matches == nil {
+		return "not a move in algebraic notation"
+	}
+
+	piece, destination := pieceNames[matches[1]], matches[2]
+	var candidates []string
+	for _, move := range position.ValidMoves() {
+		if move.S2().String() == destination && pieceNames[pieceLetter(position, move)] == piece {
+			candidates = append(candidates, chess.AlgebraicNotation{}.Encode(position, move))
+		}
+	}
+	if len(candidates) == 0 {
+		return fmt.Sprintf("no %s of %s can move to %s", piece, turn.Name(), destination)
+	}
+	return fmt.Sprintf("does not match the %s moves to %s that %s can play: %s", piece, destination, turn.Name(), strings.Join(candidates, ", "))
+}
+
+// pieceLetter returns the letter algebraic notation gives the piece making a move, empty for a pawn.
+func pieceLetter(position *chess.Position, move *chess.Move) string {
+	switch position.Board().Piece(move.S1()).Type() {
+	case chess.King:
+		return "K"
+	case chess.Queen:
+		return "Q"
+	case chess.Rook:
+		return "R"
+	case chess.Bishop:
+		return "B"
+	case chess.Knight:
+		return "N"
+	}
+	return ""
+}
+
+// flipSideToMove returns a FEN with the other side to move and no en passant square.
+func flipSideToMove(fen string) string {
+	fields := strings.Fields(fen)
+	if len(fields) < 4 {
+		return fen
+	}
+	if fields[1] == "w" {
+		fields[1] = "b"
+	} else {
+		fields[1] = "w"
+	}
+	fields[3] = "-"
+	return strings.Join(fields, " ")
+}
+
+// moveLabel returns a move as written with its number in the position it is played in, e.g. "12. e4"
+// or "12... e5".
+func moveLabel(fen, written string) string {
+	dots := "."
+	if fields := strings.Fields(fen); len(fields) > 1 && fields[1] == "b" {
+		dots = "..."
+	}
+	return fmt.Sprintf("%d%s %s", FullMoveNumber(fen), dots, written)
+}
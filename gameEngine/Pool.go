@@ -0,0 +1,149 @@
+package gameengine
+
+import (
+	"chessAnalyserFree/api"
+	"fmt"
+	"sync"
+)
+
+// Spawn starts another process of the same engine with the analyser's settings: the preset, the
+// threads, the hash size, the response timeout, two-pass analysis, the practical chances, the
+// evaluation source, the analysis store and the position cache. The two share the position cache, so
+// a position one of them searched is not searched again by the other. Progress callbacks are not copied.
+func (s *StockfishAnalyser) Spawn() (*StockfishAnalyser, error) {
+	spawned, err := NewUCIAnalyser(s.connect)
+	if err != nil {
+		return nil, err
+	}
+	if err := spawned.SetPreset(s.preset); err != nil {
+		spawned.Close()
+		return nil, err
+	}
+	if s.threads > 0 {
+		if err := spawned.SetThreads(s.threads); err != nil {
+			spawned.Close()
+			return nil, err
+		}
+	}
+	if s.hash > 0 {
+		if err := spawned.SetHash(s.hash); err != nil {
+			spawned.Close()
+			return nil, err
+		}
+	}
+	spawned.responseTimeout = s.responseTimeout
+	spawned.twoPass = s.twoPass
+	spawned.practicalPlayouts = s.practicalPlayouts
+	spawned.SetEvalSource(s.evalSource, s.evalSourceMinDepth)
+	spawned.analysisStore = s.analysisStore
+	spawned.positions = s.positions
+	return spawned, nil
+}
+
+// Pool is a set of engine processes analysing games in parallel, each one game at a time.
+type Pool struct {
+	workers []*StockfishAnalyser // The first is the analyser the pool was made from.
+}
+
+// NewPool makes a pool of size workers: the analyser and size-1 processes spawned from it. If one
+// fails to start, those already started are closed.
+func NewPool(analyser *StockfishAnalyser, size int) (*Pool, error) {
+	pool := &Pool{workers: []*StockfishAnalyser{analyser}}
+	for len(pool.workers) < size {
+		worker, err := analyser.Spawn()
+		if err != nil {
+			pool.Close()
+			return nil, fmt.Errorf("failed to start engine worker %d: %w", len(pool.workers)+1, err)
+		}
+		pool.workers = append(pool.workers, worker)
+	}
+	return pool, nil
+}
+
+// Workers returns the analysers of the pool.
+func (p *Pool) Workers() []*StockfishAnalyser {
+	return p.workers
+}
+
+// Close terminates the spawned engine processes. The analyser the pool was made from is left
+// running for its owner to close.
+func (p *Pool) Close() {
+	for _, worker := range p.workers[1:] {
+		worker.Close()
+	}
+	p.workers = p.workers[:1]
+}
+
+// OnPositionAnalysed registers fn with every worker. It is called from the workers' goroutines
+// while AnalyseGames runs, so it must be safe for concurrent use.
+func (p *Pool) OnPositionAnalysed(fn func()) {
+	for _, worker := range p.workers {
+		worker.OnPositionAnalysed(fn)
+	}
+}
+
+// PositionCacheHits returns the positions the workers took from their position caches.
+func (p *Pool) PositionCacheHits() int {
+	hits := 0
+	for _, worker := range p.workers {
+		hits += worker.PositionCacheHits()
+	}
+	return hits
+}
+
+// ExternalHits returns the positions the workers took from the external evaluation source.
+func (p *Pool) ExternalHits() int {
+	hits := 0
+	for _, worker := range p.workers {
+		hits += worker.ExternalHits()
+	}
+	return hits
+}
+
+// AnalyseGames analyses the games on the pool's workers, every worker taking the next game as soon
+// as it has finished its last. analyse runs on the worker's goroutine and analyses a game with the
+// worker's analyser, e.g. with AnalyseGame. done is called on the calling goroutine with the result
+// of every game, in the order of the games whatever order they finish in, and AnalyseGames returns
+// once done has seen them all.
+func (p *Pool) AnalyseGames(games []api.Game, analyse func(worker *StockfishAnalyser, i int, game api.Game) GameResult, done func(i int, result GameResult)) {
+	jobs := make(chan int)
+	type finished struct {
+		i      int
+		result GameResult
+	}
+	results := make(chan finished, len(p.workers))
+	var wg sync.WaitGroup
+	for _, worker := range p.workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results <- finished{i, analyse(worker, i, games[i])}
+			}
+		}()
+	}
+	go func() {
+		for i := range games {
+			jobs <- i
+		}
+		close(jobs)
+		wg.Wait()
+		close(results)
+	}()
+
+	// Results arriving ahead of their turn wait until the games before them are done.
+	pending := make(map[int]GameResult)
+	next := 0
+	for f := range results {
+		pending[f.i] = f.result
+		for {
+			result, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			done(next, result)
+			next++
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package gameengine
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// maxCachedPositions bounds the positions kept in memory. When the cache is full it starts over,
+// which keeps a long batch run's memory flat while the common positions are soon cached again.
+const maxCachedPositions = 1 << 20
+
+// PositionEval is an engine evaluation of a position, from the side to move's point of view.
+type PositionEval struct {
+	Centipawns int    `json:"cp"`
+	IsMate     bool   `json:"is_mate,omitempty"`
+	MateIn     int    `json:"mate_in,omitempty"` // Negative when the side to move is being mated.
+	Depth      int    `json:"depth"`
+	BestMove   string `json:"best_move,omitempty"`
+}
+
+// EvalStore keeps position evaluations between runs, keyed by the search settings and the position.
+type EvalStore interface {
+	// LoadPositionEval returns the stored evaluation with the given key, or nil if there is none.
+	LoadPositionEval(key string) (*PositionEval, error)
+	// SavePositionEval stores an evaluation.
+	SavePositionEval(key string, eval PositionEval) error
+}
+
+// positionCache holds the evaluations of searched positions in memory, backed by store if that is
+// set. The analysers of a pool share one, so it is safe for concurrent use.
+type positionCache struct {
+	mu    sync.Mutex
+	evals map[string]engineScore
+	store EvalStore
+}
+
+// CachePositions makes the analyser remember the evaluation of every position it searches, so that a
+// position reached again, by a repetition or a transposition in the same game or in another one such
+// as a common opening position, is not searched twice. With a non-nil store, evaluations are also
+// kept between runs. Evaluations are keyed by the engine and the search limits too, so changing the
+// preset is safe.
+func (s *StockfishAnalyser) CachePositions(store EvalStore) {
+	s.positions = &positionCache{evals: make(map[string]engineScore), store: store}
+}
+
+// PositionCacheHits returns how many positions were taken from the position cache instead of searched.
+func (s *StockfishAnalyser) PositionCacheHits() int {
+	return s.positionCacheHits
+}
+
+// positionCacheKey returns the cache key of a position searched with the search limits of limits.
+func (s *StockfishAnalyser) positionCacheKey(limits Preset, fen string) string {
+	p := limits
+	return fmt.Sprintf("%s|depth=%d|movetime=%s|multipv=%d|chess960=%t|%s",
+		s.engineName, p.Depth, p.MoveTime, p.MultiPV, s.chess960, normalizedPosition(fen))
+}
+
+// normalizedPosition returns the fields of a FEN that decide the evaluation: the pieces, the side to
+// move, the castling rights and the en passant square. The move counters are left out, and so is the
+// en passant square unless a pawn stands ready to take on it: FENs name the square after every
+// double pawn push, which would keep a position reached with one apart from the same position
+// reached without.
+func normalizedPosition(fen string) string {
+	fields := strings.Fields(fen)
+	if len(fields) > 4 {
+		fields = fields[:4]
+	}
+	if len(fields) == 4 && fields[3] != "-" && !enPassantPossible(fields[0], fields[1], fields[3]) {
+		fields[3] = "-"
+	}
+	return strings.Join(fields, " ")
+}
+
+// enPassantPossible reports whether a pawn of the side to move stands beside the pawn that has just
+// passed square, so that it could take en passant. A pinned pawn counts as ready too, which at worst
+// keeps two equal positions apart.
+func enPassantPossible(placement, toMove, square string) bool {
+	if len(square) != 2 || square[0] < 'a' || square[0] > 'h' {
+		return true
+	}
+	file := int(square[0] - 'a')
+	// The capturing pawn stands on the rank the passing pawn moved to.
+	rank, pawn := 5, byte('P')
+	if toMove == "b" {
+		rank, pawn = 4, 'p'
+	}
+	rows := strings.Split(placement, "/")
+	if len(rows) != 8 {
+		return true
+	}
+	var squares []byte
+	for _, c := range []byte(rows[8-rank]) {
+		if c >= '1' && c <= '8' {
+			squares = append(squares, strings.Repeat(".", int(c-'0'))...)
+		} else {
+			squares = append(squares, c)
+		}
+	}
+	for _, f := range []int{file - 1, file + 1} {
+		if f >= 0 && f < len(squares) && squares[f] == pawn {
+			return true
+		}
+	}
+	return false
+}
+
+// cachedEval returns the cached evaluation of a position, if the position cache is enabled and has
+// one. Store failures are not fatal: the position is simply searched.
+func (s *StockfishAnalyser) cachedEval(key string) (engineScore, bool) {
+	cache := s.positions
+	if cache == nil {
+		return engineScore{}, false
+	}
+	cache.mu.Lock()
+	score, ok := cache.evals[key]
+	cache.mu.Unlock()
+	if ok {
+		s.positionCacheHits++
+		return score, true
+	}
+	if cache.store == nil {
+		return engineScore{}, false
+	}
+	eval, err := cache.store.LoadPositionEval(key)
+	if err != nil || eval == nil {
+		return engineScore{}, false
+	}
+	score = engineScore{Centipawns: eval.Centipawns, IsMate: eval.IsMate, MateIn: eval.MateIn, Depth: eval.Depth, BestMove: eval.BestMove}
+	s.rememberEval(key, score, false)
+	s.positionCacheHits++
+	return score, true
+}
+
+// rememberEval adds a searched position to the position cache, and to the store if persist is set.
+func (s *StockfishAnalyser) rememberEval(key string, score engineScore, persist bool) {
+	cache := s.positions
+	if cache == nil {
+		return
+	}
+	cache.mu.Lock()
+	if len(cache.evals) >= maxCachedPositions {
+		clear(cache.evals)
+	}
+	cache.evals[key] = score
+	cache.mu.Unlock()
+	if persist && cache.store != nil {
+		_ = cache.store.SavePositionEval(key, PositionEval{
+			Centipawns: score.Centipawns,
+			IsMate:     score.IsMate,
+			MateIn:     score.MateIn,
+			Depth:      score.Depth,
+			BestMove:   score.BestMove,
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package gameengine
+
+import (
+	"fmt"
+	"strings"
+)
+
+// practicalChancesDepth is the search depth of practical-chances playouts. It is low on purpose:
+// the playouts should resemble fallible human play rather than perfect defence.
+const practicalChancesDepth = 6
+
+// PracticalChances are the results of fast self-play playouts from a position, from the point of
+// view of the side to move. In messy positions they often differ from the engine evaluation.
+type PracticalChances struct {
+	SideToMove string // "White" or "Black"
+	Playouts   int
+	Win        float64 // Percentage of playouts won by the side to move
+	Draw       float64
+	Loss       float64
+}
+
+// String formats the chances, e.g. "White: 41% win, 30% draw, 29% loss (20 playouts)".
+func (p PracticalChances) String() string {
+	return fmt.Sprintf("%s: %.0f%% win, %.0f%% draw, %.0f%% loss (%d playouts)", p.SideToMove, p.Win, p.Draw, p.Loss, p.Playouts)
+}
+
+// SetPracticalChances makes AnalyseGame estimate practical chances with the given number of playouts
+// for every critical position, i.e. every position in which a mistake or blunder was played.
+// Zero turns the estimate off.
+func (s *StockfishAnalyser) SetPracticalChances(playouts int) {
+	s.practicalPlayouts = playouts
+}
+
+// EstimatePracticalChances plays fast, slightly randomised self-play games from a position.
+func (s *StockfishAnalyser) EstimatePracticalChances(fen string, playouts int) (*PracticalChances, error) {
+	result, err := RunMatch(s, nil, MatchConfig{
+		StartFEN:     fen,
+		Games:        playouts,
+		Depth:        practicalChancesDepth,
+		MaxPlies:     200,
+		RandomPlies:  2,
+		RandomMargin: 50,
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+	side := "White"
+	if fields := strings.Fields(fen); len(fields) > 1 && fields[1] == "b" {
+		side = "Black"
+	}
+	games := float64(len(result.Games))
+	return &PracticalChances{
+		SideToMove: side,
+		Playouts:   len(result.Games),
+		Win:        float64(result.Wins) * 100 / games,
+		Draw:       float64(result.Draws) * 100 / games,
+		Loss:       float64(result.Losses) * 100 / games,
+	}, nil
+}
+
+// addPracticalChances estimates the practical chances of the critical positions of an analysed
+// game. fens holds the position before every move.
+func (s *StockfishAnalyser) addPracticalChances(analysis *GameAnalysis, fens []string) error {
+	for i := range analysis.Moves {
+		class := analysis.Moves[i].Classification
+		if class != ClassMistake && class != ClassBlunder {
+			continue
+		}
+		if s.skipRequested.Swap(false) {
+			return ErrAnalysisSkipped
+		}
+		chances, err := s.EstimatePracticalChances(fens[i], s.practicalPlayouts)
+		if err != nil {
+			return fmt.Errorf("practical chances of move %d: %w", analysis.Moves[i].MoveNumber, err)
+		}
+		analysis.Moves[i].PracticalChances = chances
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package gameengine
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Thresholds are the centipawn losses at which a move is classified as an inaccuracy, mistake or blunder.
+type Thresholds struct {
+	Inaccuracy int
+	Mistake    int
+	Blunder    int
+}
+
+// Preset bundles the engine settings used for an analysis run.
+type Preset struct {
+	Name          string        // Name recorded with analyses, e.g. "standard" or "standard, depth 18".
+	Key           string        // Name the preset is looked up and downgraded by, e.g. "standard".
+	MoveTime      time.Duration // Search time per position; 0 means no time limit.
+	Depth         int           // Search depth per position; 0 means no depth limit.
+	MultiPV       int           // Number of principal variations the engine reports.
+	SkipBookPlies int           // Number of opening plies that are not analysed.
+	Thresholds    Thresholds
+}
+
+// defaultThresholds are the classification thresholds shared by the built-in presets.
+var defaultThresholds = Thresholds{Inaccuracy: 50, Mistake: 100, Blunder: 300}
+
+// Presets are the analysis presets, keyed by name: the built-in ones and those added with AddPreset.
+var Presets = map[string]Preset{
+	"quick": {
+		Name:          "quick",
+		Key:           "quick",
+		MoveTime:      100 * time.Millisecond,
+		MultiPV:       1,
+		SkipBookPlies: 8,
+		Thresholds:    defaultThresholds,
+	},
+	"standard": {
+		Name:       "standard",
+		Key:        "standard",
+		MoveTime:   500 * time.Millisecond,
+		MultiPV:    1,
+		Thresholds: defaultThresholds,
+	},
+	"deep": {
+		Name:       "deep",
+		Key:        "deep",
+		Depth:      22,
+		MultiPV:    3,
+		Thresholds: defaultThresholds,
+	},
+}
+
+// DefaultPresetName is the preset used when none is selected.
+const DefaultPresetName = "standard"
+
+// presetOrder lists the built-in presets from cheapest to most expensive.
+var presetOrder = []string{"quick", "standard", "deep"}
+
+// DowngradePreset returns the next cheaper built-in preset, or false if preset is already the cheapest.
+// Presets added with AddPreset fall back to the cheapest built-in one. A depth set with WithDepth is
+// kept, so the cheaper preset still searches to it.
+func DowngradePreset(preset Preset) (Preset, bool) {
+	lower := Presets[presetOrder[0]]
+	for i, name := range presetOrder {
+		if name == preset.Key {
+			if i == 0 {
+				return Preset{}, false
+			}
+			lower = Presets[presetOrder[i-1]]
+		}
+	}
+	if preset.Key == lower.Key {
+		return Preset{}, false
+	}
+	if preset.Name != preset.Key && preset.DepthLimited() {
+		lower = lower.WithDepth(preset.Depth)
+	}
+	return lower, true
+}
+
+// WithDepth returns the preset searching every position to depth instead of its own limits. The name
+// records the change, so reports show it; the key stays the same.
+func (p Preset) WithDepth(depth int) Preset {
+	p.Name = fmt.Sprintf("%s, depth %d", p.Key, depth)
+	p.Depth = depth
+	p.MoveTime = 0
+	return p
+}
+
+// AddPreset adds a preset to Presets under its key, or replaces the built-in preset of that name. Its
+// key is lower-cased and its name set to it.
+func AddPreset(preset Preset) error {
+	preset.Key = strings.ToLower(strings.TrimSpace(preset.Key))
+	switch {
+	case preset.Key == "":
+		return errors.New("a preset needs a name")
+	case preset.Depth < 0 || preset.MoveTime < 0 || preset.MultiPV < 0 || preset.SkipBookPlies < 0:
+		return fmt.Errorf("preset %s: limits cannot be negative", preset.Key)
+	case preset.Depth == 0 && preset.MoveTime == 0:
+		return fmt.Errorf("preset %s: needs a depth or a movetime", preset.Key)
+	case preset.Thresholds.Inaccuracy <= 0 || preset.Thresholds.Mistake < preset.Thresholds.Inaccuracy || preset.Thresholds.Blunder < preset.Thresholds.Mistake:
+		return fmt.Errorf("preset %s: thresholds must be positive and rise from inaccuracy to mistake to blunder", preset.Key)
+	}
+	preset.Name = preset.Key
+	preset.MultiPV = max(preset.MultiPV, 1)
+	Presets[preset.Key] = preset
+	return nil
+}
+
+// LookupPreset returns the preset with the given name.
+func LookupPreset(name string) (Preset, error) {
+	preset, ok := Presets[strings.ToLower(name)]
+	if !ok {
+		return Preset{}, fmt.Errorf("unknown preset %q (available: %s)", name, strings.Join(PresetNames(), ", "))
+	}
+	return preset, nil
+}
+
+// PresetNames returns the names of all available presets in alphabetical order.
+func PresetNames() []string {
+	names := make([]string, 0, len(Presets))
+	for name := range Presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// DepthLimited reports whether the preset searches to a fixed depth without a time limit, so that
+// its evaluations do not depend on the speed of the machine.
+func (p Preset) DepthLimited() bool {
+	return p.Depth > 0 && p.MoveTime == 0
+}
+
+// goCommand builds the UCI "go" command for the preset's search limits.
+func (p Preset) goCommand() string {
+	command := "go"
+	if p.Depth > 0 {
+		command += fmt.Sprintf(" depth %d", p.Depth)
+	}
+	if p.MoveTime > 0 {
+		command += fmt.Sprintf(" movetime %d", p.MoveTime.Milliseconds())
+	}
+	if command == "go" {
+		// Never start an unbounded search.
+		command += " movetime 500"
+	}
+	return command
+}
+
+// classify returns the classification of a move given its centipawn loss.
+func (t Thresholds) classify(centipawnLoss int) string {
+	switch {
+	case centipawnLoss >= t.Blunder:
+		return ClassBlunder
+	case centipawnLoss >= t.Mistake:
+		return ClassMistake
+	case centipawnLoss >= t.Inaccuracy:
+		return ClassInaccuracy
+	default:
+		return ClassGood
+	}
+}
@@ -0,0 +1,173 @@
+package gameengine
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// DefaultResponseTimeout is how long the engine may print nothing while a command waits for its
+// answer before it is taken to be hung. A searching engine reports on its progress every few
+// seconds, so only an engine that is stuck stays silent this long.
+const DefaultResponseTimeout = 30 * time.Second
+
+// closeTimeout is how long Close waits for the engine to quit before killing it.
+const closeTimeout = 5 * time.Second
+
+// errEngineSilent is returned by readLines when the engine printed nothing for the response timeout.
+var errEngineSilent = errors.New("engine stopped responding")
+
+// EngineTimeoutError reports an answer the engine did not give in time, and how it was recovered.
+// The analyser can be used again unless restarting the engine failed.
+type EngineTimeoutError struct {
+	Waiting    string        // The answer waited for, e.g. "bestmove"
+	Timeout    time.Duration // How long the engine was silent
+	Restarted  bool          // Whether the engine ignored stop and isready, and was restarted
+	RestartErr error         // Why the engine could not be restarted; nil if it was, or did not need to be
+}
+
+// Error describes the timeout and the recovery, e.g. "the engine printed nothing for 30s while
+// waiting for bestmove; it ignored stop and was restarted".
+func (e *EngineTimeoutError) Error() string {
+	message := fmt.Sprintf("the engine printed nothing for %s while waiting for %s", e.Timeout, e.Waiting)
+	switch {
+	case e.RestartErr != nil:
+		return fmt.Sprintf("%s; it ignored stop and could not be restarted: %v", message, e.RestartErr)
+	case e.Restarted:
+		return message + "; it ignored stop and was restarted"
+	}
+	return message + "; it was stopped and is ready again"
+}
+
+// engineOutput is the output of an engine process, read line by line in the background so that
+// reading it can time out.
+type engineOutput struct {
+	lines chan string
+	err   error // Why the output ended; set before lines is closed.
+	done  chan struct{}
+}
+
+// readEngineOutput starts reading an engine's stdout in the background. The reading stops at the
+// end of the output or when done is closed.
+func readEngineOutput(stdout io.Reader) *engineOutput {
+	output := &engineOutput{lines: make(chan string, 256), done: make(chan struct{})}
+	go func() {
+		defer close(output.lines)
+		reader := bufio.NewReader(stdout)
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				output.err = err
+				return
+			}
+			select {
+			case output.lines <- line:
+			case <-output.done:
+				output.err = io.ErrClosedPipe
+				return
+			}
+		}
+	}()
+	return output
+}
+
+// SetResponseTimeout sets how long the engine may print nothing while a command waits for its answer
+// before it is stopped, and if need be restarted. 0 waits forever.
+func (s *StockfishAnalyser) SetResponseTimeout(timeout time.Duration) {
+	s.responseTimeout = timeout
+}
+
+// readLines reads the engine's output until a line containing contains, returning errEngineSilent
+// if the engine prints nothing for the response timeout.
+func (s *StockfishAnalyser) readLines(contains string) (string, error) {
+	var output strings.Builder
+	var timer *time.Timer
+	var timeout <-chan time.Time
+	if s.responseTimeout > 0 {
+		timer = time.NewTimer(s.responseTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	for {
+		select {
+		case line, ok := <-s.output.lines:
+			if !ok {
+				return "", s.output.err
+			}
+			output.WriteString(line)
+			if strings.Contains(line, contains) {
+				return output.String(), nil
+			}
+			if timer != nil {
+				timer.Reset(s.responseTimeout)
+			}
+		case <-timeout:
+			return "", errEngineSilent
+		}
+	}
+}
+
+// recoverEngine brings an engine that stopped answering back to a known state: it is told to stop
+// and must then answer isready within the response timeout, or it is killed and started again with
+// the same settings. It returns the *EngineTimeoutError describing what happened.
+func (s *StockfishAnalyser) recoverEngine(waiting string) error {
+	timeoutErr := &EngineTimeoutError{Waiting: waiting, Timeout: s.responseTimeout}
+	// The next search starts a game afresh, as the stopped search may have left anything in the hash.
+	s.gameStarting = true
+	if s.sendCommand("stop") == nil && s.sendCommand("isready") == nil {
+		if _, err := s.readLines("readyok"); err == nil {
+			return timeoutErr
+		}
+	}
+	timeoutErr.Restarted = true
+	timeoutErr.RestartErr = s.restart()
+	return timeoutErr
+}
+
+// restart kills the engine process and starts a new one with the analyser's threads, hash size,
+// MultiPV and Chess960 settings. A new process that stops answering as well is not restarted again.
+func (s *StockfishAnalyser) restart() error {
+	s.kill()
+	fresh, err := NewUCIAnalyser(s.connect)
+	if err != nil {
+		return err
+	}
+	s.conn, s.output = fresh.conn, fresh.output
+
+	s.restarting = true
+	defer func() { s.restarting = false }()
+	if s.threads > 0 {
+		if err := s.SetThreads(s.threads); err != nil {
+			return err
+		}
+	}
+	if s.hash > 0 {
+		if err := s.SetHash(s.hash); err != nil {
+			return err
+		}
+	}
+	multiPV, chess960 := s.multiPV, s.chess960
+	s.multiPV, s.chess960 = 1, false
+	if err := s.setMultiPV(multiPV); err != nil {
+		return err
+	}
+	return s.setChess960(chess960)
+}
+
+// kill ends the engine process without waiting for it to quit.
+func (s *StockfishAnalyser) kill() {
+	s.stopReading()
+	s.conn.Close()
+}
+
+// stopReading stops reading the engine's output, which is never read again.
+func (s *StockfishAnalyser) stopReading() {
+	select {
+	case <-s.output.done:
+	default:
+		close(s.output.done)
+	}
+}
@@ -0,0 +1,90 @@
+package gameengine
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/notnil/chess"
+)
+
+// clockCommentRegex matches the clock time of a [%clk h:mm:ss] comment, as written by Chess.com and Lichess.
+var clockCommentRegex = regexp.MustCompile(`\[%clk\s+([0-9:.]+)\]`)
+
+// PlyPosition is a position of a game together with the move played from it.
+type PlyPosition struct {
+	Ply   int    // 1-based ply number of the move
+	FEN   string // Position before the move
+	Move  string // Move played, in UCI notation
+	SAN   string // Move played, in standard algebraic notation with check and mate suffixes
+	Clock string // Clock time left after the move from a [%clk] comment, e.g. "0:02:59.9"; empty if none
+}
+
+// ReplayPositions replays a PGN and returns the position before every move.
+func ReplayPositions(pgn string) ([]PlyPosition, error) {
+	parsedGame, gameLogic, err := parseGame(pgn)
+	if err != nil {
+		return nil, err
+	}
+	comments := parsedGame.Comments()
+	var positions []PlyPosition
+	for i, move := range parsedGame.Moves() {
+		fenBefore := gameLogic.FEN()
+		position := PlyPosition{Ply: i + 1, FEN: fenBefore, Move: move.String()}
+		// Take the move from the legal moves, which carry the check and mate tags SAN needs.
+		for _, candidate := range gameLogic.ValidMoves() {
+			if candidate.String() == position.Move {
+				position.SAN = chess.AlgebraicNotation{}.Encode(gameLogic.Position(), candidate)
+				break
+			}
+		}
+		if i < len(comments) {
+			for _, comment := range comments[i] {
+				if matches := clockCommentRegex.FindStringSubmatch(comment); matches != nil {
+					position.Clock = matches[1]
+				}
+			}
+		}
+		positions = append(positions, position)
+
+		if err := gameLogic.Move(move); err != nil {
+			return nil, &IllegalMoveError{Ply: i + 1, Move: move.String(), FEN: fenBefore, Reason: err.Error()}
+		}
+		if err := crossCheckMove(i+1, fenBefore, move.String(), gameLogic.FEN()); err != nil {
+			return nil, err
+		}
+	}
+	return positions, nil
+}
+
+// ClockSeconds converts a clock time of a [%clk] comment, e.g. "0:02:59.9" or "2:59", to seconds.
+// It returns false for an empty or malformed time.
+func ClockSeconds(clock string) (float64, bool) {
+	if clock == "" {
+		return 0, false
+	}
+	seconds := 0.0
+	for _, part := range strings.Split(clock, ":") {
+		value, err := strconv.ParseFloat(part, 64)
+		if err != nil || value < 0 {
+			return 0, false
+		}
+		seconds = seconds*60 + value
+	}
+	return seconds, true
+}
+
+// FinalFEN replays a PGN and returns the position after its last move.
+func FinalFEN(pgn string) (string, error) {
+	parsedGame, gameLogic, err := parseGame(pgn)
+	if err != nil {
+		return "", err
+	}
+	for i, move := range parsedGame.Moves() {
+		fenBefore := gameLogic.FEN()
+		if err := gameLogic.Move(move); err != nil {
+			return "", &IllegalMoveError{Ply: i + 1, Move: move.String(), FEN: fenBefore, Reason: err.Error()}
+		}
+	}
+	return gameLogic.FEN(), nil
+}
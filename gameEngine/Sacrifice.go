@@ -0,0 +1,72 @@
+package gameengine
+
+import (
+	"strings"
+	"unicode"
+)
+
+// pieceValues are the conventional material values of the pieces, in pawns.
+var pieceValues = map[rune]int{'p': 1, 'n': 3, 'b': 3, 'r': 5, 'q': 9}
+
+// materialBalance returns White's material minus Black's, in pawns, from the placement field of a FEN.
+func materialBalance(fen string) int {
+	placement, _, _ := strings.Cut(fen, " ")
+	balance := 0
+	for _, r := range placement {
+		value := pieceValues[unicode.ToLower(r)]
+		if unicode.IsUpper(r) {
+			balance += value
+		} else {
+			balance -= value
+		}
+	}
+	return balance
+}
+
+// pieceCount returns the number of pieces on the board of a FEN, kings and pawns included.
+func pieceCount(fen string) int {
+	placement, _, _ := strings.Cut(fen, " ")
+	count := 0
+	for _, r := range placement {
+		if unicode.IsLetter(r) {
+			count++
+		}
+	}
+	return count
+}
+
+// materialLoss returns the material, in pawns, the move at ply gives up once the captures that
+// follow it in the game have been played out, so exchanges cancel out and a sacrifice taken at
+// once counts. fens holds the position before every move and the final position.
+func materialLoss(fens []string, ply int) int {
+	whiteMoves := strings.Contains(fens[ply], " w ")
+	quiet := ply + 1
+	// Follow the game while the next move captures.
+	for quiet+1 < len(fens) && pieceCount(fens[quiet+1]) < pieceCount(fens[quiet]) {
+		quiet++
+	}
+	loss := materialBalance(fens[ply]) - materialBalance(fens[quiet])
+	if !whiteMoves {
+		loss = -loss
+	}
+	return max(loss, 0)
+}
+
+// markSacrifices records the material every analysed move gives up and reclassifies the moves that
+// give up material without losing evaluation as sacrifices, so a gambit or a sound sacrifice is
+// not mistaken for a blunder. Moves played from a decided position are left alone, as the clamped
+// evaluations no longer tell a sacrifice from a lost piece. scores holds the evaluation of every
+// position from the side to move's point of view.
+func (s *StockfishAnalyser) markSacrifices(analysis *GameAnalysis, fens []string, scores []engineScore) {
+	for i := range analysis.Moves {
+		if i < s.preset.SkipBookPlies {
+			continue
+		}
+		move := &analysis.Moves[i]
+		move.MaterialLoss = materialLoss(fens, i)
+		if move.MaterialLoss > 0 && move.Classification == ClassGood &&
+			clampEval(scores[i].value()) > -evalClamp && clampEval(scores[i].value()) < evalClamp {
+			move.Classification = ClassSacrifice
+		}
+	}
+}
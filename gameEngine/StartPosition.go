@@ -0,0 +1,117 @@
+package gameengine
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/notnil/chess"
+)
+
+// StandardStartFEN is the starting position of standard chess.
+const StandardStartFEN = "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"
+
+var (
+	fenTagRegex     = regexp.MustCompile(`(?m)^[ \t]*\[FEN\s+"([^"]*)"\]`)
+	variantTagRegex = regexp.MustCompile(`(?m)^[ \t]*\[Variant\s+"([^"]*)"\]`)
+)
+
+// IsChess960 reports whether a PGN's Variant tag marks it as a Chess960 game.
+func IsChess960(pgn string) bool {
+	matches := variantTagRegex.FindStringSubmatch(pgn)
+	if matches == nil {
+		return false
+	}
+	variant := strings.ToLower(strings.ReplaceAll(matches[1], " ", ""))
+	return variant == "chess960" || variant == "fischerandom" || variant == "fischerrandom"
+}
+
+// StartFEN returns the position a PGN starts from: its FEN tag (used by odds games, Chess960 and
+// games set up from a position), or the standard starting position.
+func StartFEN(pgn string) string {
+	if matches := fenTagRegex.FindStringSubmatch(pgn); matches != nil && strings.TrimSpace(matches[1]) != "" {
+		return normalizeStartFEN(matches[1])
+	}
+	return StandardStartFEN
+}
+
+// FullMoveNumber returns the move number field of a FEN, or 1 if it is missing or invalid.
+func FullMoveNumber(fen string) int {
+	fields := strings.Fields(fen)
+	if len(fields) < 6 {
+		return 1
+	}
+	n, err := strconv.Atoi(fields[5])
+	if err != nil || n < 1 {
+		return 1
+	}
+	return n
+}
+
+// normalizeStartFEN completes a FEN without move counters and restricts the castling rights to
+// those the chess library can play: king on the e-file and rook in its original corner. Shredder
+// castling letters ("HAha") are translated, and Chess960 rights the library cannot represent are
+// dropped, so a castling move in such a game is reported instead of being replayed wrongly.
+func normalizeStartFEN(fen string) string {
+	fields := strings.Fields(fen)
+	for len(fields) < 6 {
+		fields = append(fields, []string{"w", "-", "-", "0", "1"}[len(fields)-1])
+	}
+	board, err := parseFENBoard(strings.Join(fields, " "))
+	if err != nil {
+		// Leave the error to the PGN parser, which reports it with context.
+		return strings.Join(fields, " ")
+	}
+
+	castling := ""
+	for _, right := range []struct {
+		symbol, shredder byte
+		rank, rookFile   int
+		king, rook       byte
+	}{
+		{'K', 'H', 0, 7, 'K', 'R'},
+		{'Q', 'A', 0, 0, 'K', 'R'},
+		{'k', 'h', 7, 7, 'k', 'r'},
+		{'q', 'a', 7, 0, 'k', 'r'},
+	} {
+		if !strings.ContainsAny(fields[2], string([]byte{right.symbol, right.shredder})) {
+			continue
+		}
+		if board.squares[right.rank][4] == right.king && board.squares[right.rank][right.rookFile] == right.rook {
+			castling += string(right.symbol)
+		}
+	}
+	if castling == "" {
+		castling = "-"
+	}
+	fields[2] = castling
+	return strings.Join(fields[:6], " ")
+}
+
+// parseGame parses a PGN and returns the game together with a fresh game at the same start
+// position, on which the moves are replayed and double-checked. The chess library is given the
+// mainline as ParseMovetext reads it, so comments, variations and NAGs never trip it up; the
+// comments of the mainline moves, with their clock times, are kept.
+func parseGame(pgn string) (*chess.Game, *chess.Game, error) {
+	// Validating first says exactly which tag or move is wrong, where the library would not.
+	if err := ValidatePGN(pgn); err != nil {
+		return nil, nil, err
+	}
+	startFEN := StartFEN(pgn)
+	if startFEN != StandardStartFEN {
+		pgn = fenTagRegex.ReplaceAllLiteralString(pgn, fmt.Sprintf(`[FEN "%s"]`, startFEN))
+	}
+
+	pgnParser, err := chess.PGN(strings.NewReader(ParseMovetext(pgn).String()))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create PGN parser: %w", err)
+	}
+	parsedGame := chess.NewGame(pgnParser)
+
+	start, err := chess.FEN(startFEN)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid start position %q: %w", startFEN, err)
+	}
+	return parsedGame, chess.NewGame(start), nil
+}
@@ -1,164 +1,592 @@
 package gameengine
 
 import (
-	"bufio"
 	"chessAnalyserFree/api"
+	"errors"
 	"fmt"
 	"io"
-	"os/exec"
-	"regexp"
 	"strconv"
 	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/notnil/chess"
 )
 
+// Move classifications derived from the preset thresholds.
+const (
+	ClassBook       = "book"
+	ClassGood       = "good"
+	ClassInaccuracy = "inaccuracy"
+	ClassMistake    = "mistake"
+	ClassBlunder    = "blunder"
+	// ClassSacrifice marks a move that gives up material without losing evaluation.
+	ClassSacrifice = "sacrifice"
+)
+
+// mateValue is the centipawn value used in place of a forced mate score.
+const mateValue = 10000
+
+// evalClamp caps evaluations before computing centipawn loss, so that e.g. choosing
+// a winning line over a longer mate is not counted as a blunder.
+const evalClamp = 1000
+
+// ErrAnalysisSkipped is returned by AnalyseGame when the analysis was interrupted with Skip.
+var ErrAnalysisSkipped = errors.New("analysis skipped")
+
 // MoveAnalysis holds the evaluation for a single move.
 type MoveAnalysis struct {
 	MoveNumber     int
 	Move           string
-	Evaluation     float64 // Evaluation in pawns (+ for white, - for black)
-	EvaluationText string  // e.g., "+1.23" or "-0.54"
+	Evaluation     float64 // Evaluation in pawns before the move, from the mover's point of view
+	EvaluationText string  // e.g., "+1.23", "-0.54" or "#3"
+	CentipawnLoss  int     // Evaluation lost by the move, from the mover's point of view
+	Classification string  // One of the Class* constants
+	MaterialLoss   int     // Material in pawns the move gives up once the following captures are played out
+	Depth          int     // Search depth of the evaluation; 0 for book moves
+	Source         string  // Where the evaluation came from, e.g. "Stockfish 16 (deep)" or "external"
+	BestMove       string  // Engine's best move in the position before the move, in UCI notation; empty for book moves
+	// PracticalChances of the side to move before the move; only set for critical positions.
+	PracticalChances *PracticalChances
+}
+
+// setScore sets the evaluation of the position before the move from an engine score, searched
+// locally with source or taken from the external evaluation source.
+func (m *MoveAnalysis) setScore(score engineScore, source string) {
+	m.Evaluation = float64(score.value()) / 100.0
+	m.EvaluationText = score.String()
+	m.Depth = score.Depth
+	m.Source = source
+	m.BestMove = score.BestMove
+	if score.External {
+		m.Source = "external"
+	}
+}
+
+// GameAnalysis holds the per-move analysis of a game along with the preset that produced it.
+type GameAnalysis struct {
+	Preset string
+	Engine string // Name the engine reported, e.g. "Stockfish 16"
+	Moves  []MoveAnalysis
+	Issues []string // Problems found by ValidateAnalysis; an analysis with issues is invalid.
+	Cached bool     `json:"-"` // Whether the analysis was read from the analysis store.
+}
+
+// GameResult is the outcome of analysing a game: the analysis, or the error that stopped it.
+type GameResult struct {
+	Analysis *GameAnalysis
+	Err      error
 }
 
 // StockfishAnalyser manages the communication with the Stockfish engine.
 type StockfishAnalyser struct {
-	cmd    *exec.Cmd
-	stdin  io.WriteCloser
-	stdout io.ReadCloser
-	reader *bufio.Reader
+	conn   io.ReadWriteCloser
+	output *engineOutput
+	preset Preset
+	// connect starts the engine; Spawn starts further engines with it.
+	connect Connector
+	// threads is the engine's Threads setting, or 0 for the engine's default.
+	threads int
+	// hash is the engine's Hash setting in MB, or 0 for the engine's default.
+	hash int
+	// responseTimeout is how long the engine may stay silent while a command waits for its answer;
+	// 0 waits forever.
+	responseTimeout time.Duration
+	// restarting is set while a restarted engine is configured, which is not recovered again.
+	restarting bool
+	// engineName is the name the engine reported in the UCI handshake.
+	engineName string
+	// chess960 and multiPV are the engine's current UCI_Chess960 and MultiPV settings.
+	chess960 bool
+	multiPV  int
+	// gameStarting is set at the start of a game and cleared by its first search, which clears the
+	// engine's hash table first.
+	gameStarting bool
+	// onPosition is called after every completed search, e.g. to update progress output.
+	onPosition func()
+	// onMove is called with every move as soon as the position before it is evaluated; it feeds
+	// AnalyseGameStream.
+	onMove func(index int, fenBefore string, move MoveAnalysis)
+	// evalSource is consulted before searching a position locally.
+	evalSource         EvalSource
+	evalSourceMinDepth int
+	externalHits       int
+	// analysisStore, if set, caches finished analyses.
+	analysisStore AnalysisStore
+	// positions caches searched positions when not nil; analysers spawned from this one share it.
+	positions         *positionCache
+	positionCacheHits int
+	// twoPass scans every position at ScanDepth and searches only those around suspicious moves
+	// with the preset.
+	twoPass bool
+	// practicalPlayouts is the number of playouts per critical position; 0 disables them.
+	practicalPlayouts int
+	// skipRequested interrupts the game being analysed; it may be set from another goroutine.
+	skipRequested atomic.Bool
 }
 
 // NewStockfishAnalyser starts the Stockfish process.
 // You must provide the path to the Stockfish executable.
 func NewStockfishAnalyser(stockfishPath string) (*StockfishAnalyser, error) {
-	cmd := exec.Command(stockfishPath)
-	stdin, err := cmd.StdinPipe()
-	if err != nil {
-		return nil, err
-	}
-	stdout, err := cmd.StdoutPipe()
+	return NewUCIAnalyser(ProcessConnector(stockfishPath))
+}
+
+// NewUCIAnalyser starts a UCI engine with connect, e.g. ProcessConnector, TCPConnector or
+// ConnectMock, and completes the UCI handshake.
+func NewUCIAnalyser(connect Connector) (*StockfishAnalyser, error) {
+	conn, err := connect()
 	if err != nil {
 		return nil, err
 	}
 
-	if err := cmd.Start(); err != nil {
-		return nil, fmt.Errorf("failed to start stockfish: %w. Is the path correct?", err)
-	}
-
 	analyser := &StockfishAnalyser{
-		cmd:    cmd,
-		stdin:  stdin,
-		stdout: stdout,
-		reader: bufio.NewReader(stdout),
+		conn:            conn,
+		output:          readEngineOutput(conn),
+		preset:          Presets[DefaultPresetName],
+		connect:         connect,
+		responseTimeout: DefaultResponseTimeout,
 	}
 
-	// Initialize UCI protocol
+	// Initialize UCI protocol. An engine that does not complete the handshake is not worth recovering.
 	if err := analyser.sendCommand("uci"); err != nil {
+		analyser.kill()
 		return nil, err
 	}
-	// Wait for 'uciok'
-	if _, err := analyser.readUntil("uciok"); err != nil {
-		return nil, err
+	// Wait for 'uciok', noting the engine's name on the way.
+	handshake, err := analyser.readLines("uciok")
+	if err != nil {
+		analyser.kill()
+		return nil, fmt.Errorf("no UCI handshake from the engine: %w", err)
+	}
+	analyser.engineName = "engine"
+	for _, line := range strings.Split(handshake, "\n") {
+		if name, ok := strings.CutPrefix(strings.TrimSpace(line), "id name "); ok {
+			analyser.engineName = name
+		}
 	}
 	// Wait for 'readyok'
 	if err := analyser.sendCommand("isready"); err != nil {
+		analyser.kill()
 		return nil, err
 	}
-	if _, err := analyser.readUntil("readyok"); err != nil {
-		return nil, err
+	if _, err := analyser.readLines("readyok"); err != nil {
+		analyser.kill()
+		return nil, fmt.Errorf("the engine is not ready: %w", err)
 	}
 
 	return analyser, nil
 }
 
+// SetPreset changes the engine settings used for subsequent analyses.
+func (s *StockfishAnalyser) SetPreset(preset Preset) error {
+	if err := s.setMultiPV(preset.MultiPV); err != nil {
+		return err
+	}
+	s.preset = preset
+	return nil
+}
+
+// SetThreads sets the number of threads the engine searches with.
+func (s *StockfishAnalyser) SetThreads(threads int) error {
+	if err := s.setOption("Threads", strconv.Itoa(threads)); err != nil {
+		return err
+	}
+	s.threads = threads
+	return nil
+}
+
+// SetHash sets the size of the engine's transposition table in MB.
+func (s *StockfishAnalyser) SetHash(mb int) error {
+	if err := s.setOption("Hash", strconv.Itoa(mb)); err != nil {
+		return err
+	}
+	s.hash = mb
+	return nil
+}
+
+// setOption sets a UCI option and waits until the engine has applied it.
+func (s *StockfishAnalyser) setOption(name, value string) error {
+	if err := s.sendCommand(fmt.Sprintf("setoption name %s value %s", name, value)); err != nil {
+		return err
+	}
+	if err := s.sendCommand("isready"); err != nil {
+		return err
+	}
+	_, err := s.readUntil("readyok")
+	return err
+}
+
+// setMultiPV sets the number of principal variations the engine reports.
+func (s *StockfishAnalyser) setMultiPV(multiPV int) error {
+	if multiPV < 1 {
+		multiPV = 1
+	}
+	if multiPV == s.multiPV {
+		return nil
+	}
+	if err := s.setOption("MultiPV", strconv.Itoa(multiPV)); err != nil {
+		return err
+	}
+	s.multiPV = multiPV
+	return nil
+}
+
+// setChess960 switches the engine's Chess960 mode, so it reads castling rights of shuffled start
+// positions correctly.
+func (s *StockfishAnalyser) setChess960(enabled bool) error {
+	if enabled == s.chess960 {
+		return nil
+	}
+	if err := s.setOption("UCI_Chess960", strconv.FormatBool(enabled)); err != nil {
+		return err
+	}
+	s.chess960 = enabled
+	return nil
+}
+
+// EngineName returns the name the engine reported, e.g. "Stockfish 16".
+func (s *StockfishAnalyser) EngineName() string {
+	return s.engineName
+}
+
+// Preset returns the engine settings currently in use.
+func (s *StockfishAnalyser) Preset() Preset {
+	return s.preset
+}
+
+// OnPositionAnalysed registers a function called after every position the engine searches.
+func (s *StockfishAnalyser) OnPositionAnalysed(fn func()) {
+	s.onPosition = fn
+}
+
+// Skip interrupts the game currently being analysed. AnalyseGame stops before the next position
+// and returns ErrAnalysisSkipped. It is safe to call from another goroutine.
+func (s *StockfishAnalyser) Skip() {
+	s.skipRequested.Store(true)
+}
+
 // sendCommand sends a command string to the Stockfish process.
 func (s *StockfishAnalyser) sendCommand(command string) error {
-	_, err := fmt.Fprintln(s.stdin, command)
+	_, err := fmt.Fprintln(s.conn, command)
 	return err
 }
 
-// readUntil reads from Stockfish's stdout until a line containing the specified text is found.
+// readUntil reads from Stockfish's stdout until a line containing the specified text is found. An
+// engine that prints nothing for the response timeout is stopped, or restarted, and the
+// *EngineTimeoutError saying which is returned.
 func (s *StockfishAnalyser) readUntil(contains string) (string, error) {
-	var output string
-	for {
-		line, err := s.reader.ReadString('\n')
-		if err != nil {
-			return "", err
-		}
-		output += line
-		if strings.Contains(line, contains) {
-			return output, nil
-		}
+	output, err := s.readLines(contains)
+	if errors.Is(err, errEngineSilent) && !s.restarting {
+		return "", s.recoverEngine(contains)
 	}
+	return output, err
 }
 
 // AnalyseGame takes a game object and returns an analysis for each move.
-func (s *StockfishAnalyser) AnalyseGame(game api.Game) ([]MoveAnalysis, error) {
-	// --- CORRECTED PGN PARSING LOGIC ---
-	// Use chess.PGN to create a parser, then pass it to chess.NewGame.
-	pgnReader := strings.NewReader(game.PGN)
-	pgnParser, err := chess.PGN(pgnReader)
+func (s *StockfishAnalyser) AnalyseGame(game api.Game) (*GameAnalysis, error) {
+	// Variant PGNs often parse as standard chess, so check the rules before trusting the moves.
+	if err := checkVariant(game); err != nil {
+		return nil, err
+	}
+	if err := checkAnalysable(game); err != nil {
+		return nil, err
+	}
+	if stored := s.storedAnalysis(game); stored != nil {
+		return stored, nil
+	}
+
+	// Parse the PGN, along with a separate game state at its start position to replay moves for analysis.
+	parsedGame, gameLogic, err := parseGame(game.PGN)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create PGN parser: %w", err)
+		return nil, err
+	}
+	if err := s.setChess960(IsChess960(game.PGN)); err != nil {
+		return nil, err
+	}
+	moves := parsedGame.Moves()
+	analysis := &GameAnalysis{Preset: s.preset.Name, Engine: s.engineName}
+	// limits are the search limits of the positions' first search: the preset's, or the scan's in
+	// a two-pass analysis.
+	limits := s.preset
+	if s.twoPass {
+		limits = s.scanPreset()
+		analysis.Preset += ", two-pass"
 	}
-	// Create a new game by applying the PGN data.
-	parsedGame := chess.NewGame(pgnParser)
-	// --- END OF CORRECTION ---
+	source := fmt.Sprintf("%s (%s)", s.engineName, limits.Name)
 
-	// Create a separate game state from the initial position to replay moves for analysis.
-	gameLogic := chess.NewGame()
-	var analysis []MoveAnalysis
+	// Scores of every position from the side to move's point of view, including the final one.
+	scores := make([]engineScore, len(moves)+1)
+	fens := make([]string, len(moves)+1)
 
-	// Regex to find the centipawn score from Stockfish's output.
-	scoreRegex := regexp.MustCompile(`score cp (-?\d+)`)
+	// A skip requested before this game started applied to the previous one.
+	s.skipRequested.Store(false)
+	s.gameStarting = true
 
 	// Iterate through all moves that were actually played in the game.
-	for i, move := range parsedGame.Moves() {
-		// Get the board state (FEN) *before* the current move is made.
-		fen := gameLogic.FEN()
+	for i, move := range moves {
+		if s.skipRequested.Swap(false) {
+			return nil, ErrAnalysisSkipped
+		}
 
-		// Tell Stockfish to analyze this position.
-		s.sendCommand(fmt.Sprintf("position fen %s", fen))
-		// Analyze for 500 milliseconds. Increase for better accuracy.
-		s.sendCommand("go movetime 500")
+		fenBefore := gameLogic.FEN()
+		fens[i] = fenBefore
+		entry := MoveAnalysis{
+			MoveNumber: FullMoveNumber(fenBefore),
+			Move:       move.String(),
+		}
 
-		// Find the line containing the evaluation score.
-		output, err := s.readUntil("bestmove")
-		if err != nil {
-			return nil, fmt.Errorf("error reading from stockfish: %w", err)
+		if i < s.preset.SkipBookPlies {
+			// Opening moves are not searched when the preset skips the book.
+			entry.Classification = ClassBook
+			entry.EvaluationText = ClassBook
+		} else {
+			// Analyse the board state (FEN) *before* the current move is made.
+			score, err := s.evaluateWith(limits, fenBefore)
+			if err != nil {
+				return nil, err
+			}
+			scores[i] = score
+			entry.setScore(score, source)
+		}
+		analysis.Moves = append(analysis.Moves, entry)
+		if s.onMove != nil {
+			s.onMove(i, fenBefore, entry)
 		}
 
-		var centipawns int
-		matches := scoreRegex.FindStringSubmatch(output)
-		if len(matches) > 1 {
-			cp, _ := strconv.Atoi(matches[1])
-			centipawns = cp
+		// Apply the move to our logical board to advance to the next position,
+		// double-checking it so a corrupted PGN cannot desynchronise the evaluations.
+		if err := gameLogic.Move(move); err != nil {
+			return nil, &IllegalMoveError{Ply: i + 1, Move: move.String(), FEN: fenBefore, Reason: err.Error()}
 		}
+		if err := crossCheckMove(i+1, fenBefore, move.String(), gameLogic.FEN()); err != nil {
+			return nil, err
+		}
+	}
 
-		// Convert centipawns to pawn units.
-		pawnEvaluation := float64(centipawns) / 100.0
+	fens[len(moves)] = gameLogic.FEN()
 
-		analysis = append(analysis, MoveAnalysis{
-			MoveNumber:     (i / 2) + 1,
-			Move:           move.String(),
-			Evaluation:     pawnEvaluation,
-			EvaluationText: fmt.Sprintf("%+.2f", pawnEvaluation),
-		})
+	// Score the final position so the last move can be classified too.
+	finalSearched := false
+	switch gameLogic.Method() {
+	case chess.Checkmate:
+		scores[len(moves)] = engineScore{IsMate: true}
+	case chess.Stalemate:
+		scores[len(moves)] = engineScore{}
+	default:
+		if len(moves) > s.preset.SkipBookPlies {
+			score, err := s.evaluateWith(limits, gameLogic.FEN())
+			if err != nil {
+				return nil, err
+			}
+			scores[len(moves)] = score
+			finalSearched = true
+		}
+	}
 
-		// Apply the move to our logical board to advance to the next position.
-		if err := gameLogic.Move(move); err != nil {
-			return nil, fmt.Errorf("invalid move found in PGN: %w", err)
+	if s.twoPass {
+		if err := s.deepen(analysis, fens, scores, finalSearched); err != nil {
+			return nil, err
+		}
+	}
+
+	for i := range analysis.Moves {
+		if i < s.preset.SkipBookPlies {
+			continue
 		}
+		// The next position is scored for the opponent, so the mover's score after the move is its negation.
+		loss := clampEval(scores[i].value()) + clampEval(scores[i+1].value())
+		if loss < 0 {
+			loss = 0
+		}
+		analysis.Moves[i].CentipawnLoss = loss
+		analysis.Moves[i].Classification = s.preset.Thresholds.classify(loss)
 	}
+	s.markSacrifices(analysis, fens, scores)
 
+	if s.practicalPlayouts > 0 {
+		if err := s.addPracticalChances(analysis, fens); err != nil {
+			return nil, err
+		}
+	}
+
+	ValidateAnalysis(game, analysis)
+	s.storeAnalysis(game, analysis)
 	return analysis, nil
 }
 
-// Close gracefully terminates the Stockfish process.
+// evaluate searches a position with the current preset and returns the score of the principal variation.
+// A sufficiently deep evaluation from the external source, if any, is used instead of a local search,
+// and so is the position cache's evaluation of a position searched before.
+func (s *StockfishAnalyser) evaluate(fen string) (engineScore, error) {
+	return s.evaluateWith(s.preset, fen)
+}
+
+// evaluateWith is evaluate with the search limits of limits instead of the preset's.
+func (s *StockfishAnalyser) evaluateWith(limits Preset, fen string) (engineScore, error) {
+	score, err := s.search(limits, fen)
+	if err == nil && s.onPosition != nil {
+		s.onPosition()
+	}
+	return score, err
+}
+
+// search evaluates a position like evaluateWith, without reporting it to the progress callback.
+func (s *StockfishAnalyser) search(limits Preset, fen string) (engineScore, error) {
+	if score, ok := s.lookupExternal(fen); ok {
+		return score, nil
+	}
+	// A run to a fixed depth takes nothing from the position cache: a cached position is not searched,
+	// which would change the hash table the next searches of the game start from depending on what the
+	// run had seen before, and with it their results.
+	cached := !s.preset.DepthLimited()
+	cacheKey := s.positionCacheKey(limits, fen)
+	if cached {
+		if score, ok := s.cachedEval(cacheKey); ok {
+			return score, nil
+		}
+	}
+
+	// The hash table is cleared once per game, before its first search, and kept from move to move,
+	// so that every search builds on those of the positions before it.
+	if s.gameStarting {
+		if err := s.newGame(); err != nil {
+			return engineScore{}, fmt.Errorf("error clearing the stockfish hash: %w", err)
+		}
+		s.gameStarting = false
+	}
+	// Tell Stockfish to analyze this position.
+	if err := s.sendCommand(fmt.Sprintf("position fen %s", fen)); err != nil {
+		return engineScore{}, fmt.Errorf("error writing to stockfish: %w", err)
+	}
+	if err := s.sendCommand(limits.goCommand()); err != nil {
+		return engineScore{}, fmt.Errorf("error writing to stockfish: %w", err)
+	}
+
+	// Collect the search output up to the best move.
+	output, err := s.readUntil("bestmove")
+	if err != nil {
+		return engineScore{}, fmt.Errorf("error reading from stockfish: %w", err)
+	}
+	score := parseScore(output)
+	score.BestMove = parseBestMove(output)
+	if cached {
+		s.rememberEval(cacheKey, score, true)
+	}
+	return score, nil
+}
+
+// engineScore is a Stockfish score from the side to move's point of view.
+type engineScore struct {
+	Centipawns int
+	IsMate     bool
+	MateIn     int    // Moves until mate; negative when the side to move is being mated.
+	Depth      int    // Search depth the score was reached at.
+	External   bool   // Whether the score came from the external evaluation source.
+	BestMove   string // First move of the principal variation in UCI notation, if known.
+}
+
+// value returns the score in centipawns, mapping forced mates to large values.
+func (e engineScore) value() int {
+	if !e.IsMate {
+		return e.Centipawns
+	}
+	if e.MateIn > 0 {
+		return mateValue - e.MateIn
+	}
+	// Mate against the side to move, including "mate 0" for an already mated position.
+	return -mateValue - e.MateIn
+}
+
+// String formats the score as pawns (e.g. "+1.23") or as a mate distance (e.g. "#-3").
+func (e engineScore) String() string {
+	if e.IsMate {
+		return fmt.Sprintf("#%d", e.MateIn)
+	}
+	return fmt.Sprintf("%+.2f", float64(e.Centipawns)/100.0)
+}
+
+// ParseEvaluation returns the MoveAnalysis.Evaluation of an evaluation text, e.g. "+1.23" or "#-3",
+// mapping mates to large values the way the analysis does.
+func ParseEvaluation(text string) (float64, bool) {
+	if mateText, isMate := strings.CutPrefix(text, "#"); isMate {
+		mateIn, err := strconv.Atoi(mateText)
+		if err != nil {
+			return 0, false
+		}
+		return float64(engineScore{IsMate: true, MateIn: mateIn}.value()) / 100.0, true
+	}
+	pawns, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return 0, false
+	}
+	return pawns, true
+}
+
+// parseScore extracts the final score of the first principal variation from Stockfish's search output.
+func parseScore(output string) engineScore {
+	var score engineScore
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || fields[0] != "info" {
+			continue
+		}
+		var candidate engineScore
+		found, primary, depth := false, true, 0
+		for j := 1; j < len(fields)-1; j++ {
+			switch fields[j] {
+			case "depth":
+				depth, _ = strconv.Atoi(fields[j+1])
+			case "multipv":
+				primary = fields[j+1] == "1"
+			case "score":
+				if j+2 >= len(fields) {
+					continue
+				}
+				value, err := strconv.Atoi(fields[j+2])
+				if err != nil {
+					continue
+				}
+				switch fields[j+1] {
+				case "cp":
+					candidate, found = engineScore{Centipawns: value}, true
+				case "mate":
+					candidate, found = engineScore{IsMate: true, MateIn: value}, true
+				}
+			}
+		}
+		// Later lines come from deeper iterations, so the last primary score wins.
+		if found && primary {
+			score = candidate
+			score.Depth = depth
+		}
+	}
+	return score
+}
+
+// clampEval limits a centipawn value to ±evalClamp.
+func clampEval(cp int) int {
+	if cp > evalClamp {
+		return evalClamp
+	}
+	if cp < -evalClamp {
+		return -evalClamp
+	}
+	return cp
+}
+
+// Close gracefully terminates the Stockfish process, killing it if it does not quit in time.
 func (s *StockfishAnalyser) Close() {
 	s.sendCommand("quit")
-	s.cmd.Wait()
-	s.stdin.Close()
-	s.stdout.Close()
+	// The output ends when the engine has quit; what it prints until then is of no more use.
+	timeout := time.After(closeTimeout)
+	for ended := false; !ended; {
+		select {
+		case _, ok := <-s.output.lines:
+			ended = !ok
+		case <-timeout:
+			ended = true
+		}
+	}
+	s.stopReading()
+	s.conn.Close()
 }
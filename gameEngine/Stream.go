@@ -0,0 +1,51 @@
+package gameengine
+
+import (
+	"chessAnalyserFree/api"
+	"fmt"
+	"strings"
+)
+
+// EvaluatedMove is a move of a game being analysed, sent as soon as the position before it is
+// evaluated. The move is not classified yet, except for book moves: its centipawn loss needs the
+// evaluation of the next position.
+type EvaluatedMove struct {
+	Index     int // Index of the move in the game, from 0.
+	FENBefore string
+	Move      MoveAnalysis
+}
+
+// AnalyseGameStream analyses a game as AnalyseGame does, sending every move on the first channel as
+// soon as the position before it is evaluated, so the analysis can be shown while it runs. The
+// moves channel is closed when the analysis ends; the result channel then yields the classified
+// analysis, or the error, and is closed. A stored analysis is returned without sending any moves.
+// The caller must receive the moves until the channel is closed, as the analysis waits for them;
+// the analyser must not be used otherwise until the result has arrived.
+func (s *StockfishAnalyser) AnalyseGameStream(game api.Game) (<-chan EvaluatedMove, <-chan GameResult) {
+	moves := make(chan EvaluatedMove, 16)
+	result := make(chan GameResult, 1)
+	s.onMove = func(index int, fenBefore string, move MoveAnalysis) {
+		moves <- EvaluatedMove{Index: index, FENBefore: fenBefore, Move: move}
+	}
+	go func() {
+		analysis, err := s.AnalyseGame(game)
+		s.onMove = nil
+		close(moves)
+		result <- GameResult{Analysis: analysis, Err: err}
+		close(result)
+	}()
+	return moves, result
+}
+
+// Label returns the move number as written before the move, e.g. "12." or "12..." for Black.
+func (m EvaluatedMove) Label() string {
+	if fields := strings.Fields(m.FENBefore); len(fields) > 1 && fields[1] == "b" {
+		return fmt.Sprintf("%d...", m.Move.MoveNumber)
+	}
+	return fmt.Sprintf("%d.", m.Move.MoveNumber)
+}
+
+// SAN returns the move in SAN, e.g. "Nf3" or "O-O".
+func (m EvaluatedMove) SAN() string {
+	return MoveSAN(m.FENBefore, m.Move.Move)
+}
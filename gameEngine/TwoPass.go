@@ -0,0 +1,59 @@
+package gameengine
+
+import "fmt"
+
+// ScanDepth is the search depth of the first pass of a two-pass analysis: a few milliseconds a
+// position, and deep enough to tell the moves that change the evaluation from those that do not.
+const ScanDepth = 10
+
+// SetTwoPass makes AnalyseGame analyse games in two passes. Every position is first scanned at
+// ScanDepth; then only the positions before and after the moves the scan finds losing the preset's
+// inaccuracy threshold or more are searched again with the preset. The other moves keep their scan
+// evaluations, so long batches take a fraction of the time, at the price of the odd move only a
+// deep search finds wanting.
+func (s *StockfishAnalyser) SetTwoPass(enabled bool) {
+	s.twoPass = enabled
+}
+
+// scanPreset returns the search limits of the first pass of a two-pass analysis.
+func (s *StockfishAnalyser) scanPreset() Preset {
+	scan := s.preset
+	scan.Name += " scan"
+	scan.Depth, scan.MoveTime = ScanDepth, 0
+	return scan
+}
+
+// deepen is the second pass of a two-pass analysis: it searches the positions around the moves the
+// scan found suspicious again with the preset and replaces their scores, and the evaluations of
+// the moves played from them. scores holds the scan's, the final position's included if finalSearched.
+// External evaluations are at least as deep as the preset's already and are kept.
+func (s *StockfishAnalyser) deepen(analysis *GameAnalysis, fens []string, scores []engineScore, finalSearched bool) error {
+	source := fmt.Sprintf("%s (%s)", s.engineName, s.preset.Name)
+	deep := make([]bool, len(scores))
+	for i := s.preset.SkipBookPlies; i < len(analysis.Moves); i++ {
+		// The next position is scored for the opponent, so the mover's score after the move is its negation.
+		if clampEval(scores[i].value())+clampEval(scores[i+1].value()) >= s.preset.Thresholds.Inaccuracy {
+			deep[i], deep[i+1] = true, true
+		}
+	}
+	if !finalSearched {
+		deep[len(analysis.Moves)] = false
+	}
+	for i, again := range deep {
+		if !again || scores[i].External {
+			continue
+		}
+		if s.skipRequested.Swap(false) {
+			return ErrAnalysisSkipped
+		}
+		score, err := s.search(s.preset, fens[i])
+		if err != nil {
+			return err
+		}
+		scores[i] = score
+		if i < len(analysis.Moves) {
+			analysis.Moves[i].setScore(score, source)
+		}
+	}
+	return nil
+}
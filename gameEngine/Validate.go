@@ -0,0 +1,98 @@
+package gameengine
+
+import (
+	"chessAnalyserFree/api"
+	"fmt"
+)
+
+// Sanity thresholds used by ValidateAnalysis.
+const (
+	// maxPlausibleGain is the largest eval improvement (in pawns) a side can get from its own move.
+	// Larger "gains" mean consecutive evaluations disagree about whose point of view they are from.
+	maxPlausibleGain = 3.0
+	// maxSignFlipShare is the share of moves with implausible gains tolerated before an analysis is rejected.
+	maxSignFlipShare = 0.1
+	// minMovesForZeroCheck is the number of analysed moves needed before all-zero evals are suspicious.
+	minMovesForZeroCheck = 6
+)
+
+// IsValid reports whether the analysis passed the sanity checks.
+func (a *GameAnalysis) IsValid() bool {
+	return len(a.Issues) == 0
+}
+
+// ValidateAnalysis checks an analysis for signs of parsing or engine failures, records the problems
+// found in analysis.Issues and returns them.
+func ValidateAnalysis(game api.Game, analysis *GameAnalysis) []string {
+	var issues []string
+
+	// Every move of the PGN must have been analysed.
+	if plies := CountMovetextPlies(game.PGN); plies != len(analysis.Moves) {
+		issues = append(issues, fmt.Sprintf("analysis has %d moves but the PGN has %d", len(analysis.Moves), plies))
+	}
+
+	// An engine whose output was not understood yields zero for every position.
+	searched, zero := 0, 0
+	for _, move := range analysis.Moves {
+		if move.Classification == ClassBook {
+			continue
+		}
+		searched++
+		if move.Evaluation == 0 {
+			zero++
+		}
+	}
+	if searched >= minMovesForZeroCheck && zero == searched {
+		issues = append(issues, "all evaluations are zero; the engine output was probably not parsed")
+	}
+
+	// Consecutive evaluations are from opposite points of view, so the mover's score after a move
+	// (the negated next evaluation) should never be far better than before it.
+	flips := 0
+	for i := 0; i+1 < len(analysis.Moves); i++ {
+		current, next := analysis.Moves[i], analysis.Moves[i+1]
+		if current.Classification == ClassBook || next.Classification == ClassBook {
+			continue
+		}
+		gain := -clampPawns(next.Evaluation) - clampPawns(current.Evaluation)
+		if gain > maxPlausibleGain {
+			flips++
+		}
+	}
+	if flips > 1 && float64(flips) > maxSignFlipShare*float64(searched) {
+		issues = append(issues, fmt.Sprintf("%d moves show implausible eval sign flips", flips))
+	}
+
+	analysis.Issues = issues
+	return issues
+}
+
+// CountMovetextPlies counts the moves in a PGN's movetext without a chess parser, ignoring tags,
+// comments, variations, NAGs, move numbers and the result.
+func CountMovetextPlies(pgn string) int {
+	return len(MovetextMoves(pgn))
+}
+
+// MovetextMoves returns the mainline moves of a PGN's movetext as written, without a chess parser,
+// ignoring tags, comments, variations, NAGs, move numbers and the result, as ParseMovetext does.
+// Annotation suffixes such as "!?" are dropped, so the moves of differently annotated copies of a
+// game compare equal.
+func MovetextMoves(pgn string) []string {
+	var moves []string
+	for _, move := range ParseMovetext(pgn).Moves {
+		moves = append(moves, move.SAN)
+	}
+	return moves
+}
+
+// clampPawns limits a pawn evaluation to ±evalClamp centipawns.
+func clampPawns(pawns float64) float64 {
+	limit := float64(evalClamp) / 100
+	if pawns > limit {
+		return limit
+	}
+	if pawns < -limit {
+		return -limit
+	}
+	return pawns
+}
@@ -0,0 +1,33 @@
+package gameengine
+
+import (
+	"chessAnalyserFree/api"
+	"errors"
+	"fmt"
+)
+
+// ErrUnsupportedVariant is returned for games played under rules the engine cannot analyse,
+// such as bughouse, crazyhouse or three-check.
+var ErrUnsupportedVariant = errors.New("unsupported variant")
+
+// supportedRules are the game rules that can be analysed; odds games and Chess960 use standard
+// rules from a different start position. Games without rules information are assumed to be chess.
+var supportedRules = map[string]bool{
+	"":          true,
+	"chess":     true,
+	"chess960":  true,
+	"oddschess": true,
+}
+
+// IsSupportedVariant reports whether games played under the given rules can be analysed.
+func IsSupportedVariant(rules string) bool {
+	return supportedRules[rules]
+}
+
+// checkVariant returns an error wrapping ErrUnsupportedVariant if the game cannot be analysed.
+func checkVariant(game api.Game) error {
+	if !IsSupportedVariant(game.Rules) {
+		return fmt.Errorf("%w: %s games cannot be analysed", ErrUnsupportedVariant, game.Rules)
+	}
+	return nil
+}
@@ -3,6 +3,7 @@ package gameFetch
 import (
 	"bufio"
 	"chessAnalyserFree/api"
+	"errors"
 	"fmt"
 	"os"
 	"strconv"
@@ -39,7 +40,7 @@ func (f *GameFetcher) fetchGamesForMonth() error {
 	monthlyGames, err := f.client.FetchPlayerGamesByMonth(f.username, year, month)
 	if err != nil {
 		// Don't treat a 404 as a fatal error, it just means no games for that month.
-		if strings.Contains(err.Error(), "status code: 404") {
+		if errors.Is(err, api.ErrNotFound) {
 			fmt.Printf("No games found for %s in %s/%s.\n", f.username, month, year)
 		} else {
 			return fmt.Errorf("error fetching games for %s/%s: %w", month, year, err)
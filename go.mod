@@ -2,4 +2,22 @@ module chessAnalyserFree
 
 go 1.24.0
 
-require github.com/notnil/chess v1.10.0
+require (
+	github.com/gdamore/tcell/v2 v2.8.1
+	github.com/lib/pq v1.9.0
+	github.com/mattn/go-sqlite3 v1.14.33
+	github.com/notnil/chess v1.10.0
+	github.com/rivo/tview v0.42.0
+	go.etcd.io/bbolt v1.4.3
+	golang.org/x/term v0.28.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
+	github.com/gdamore/encoding v1.0.1 // indirect
+	github.com/lucasb-eyer/go-colorful v1.2.0 // indirect
+	github.com/mattn/go-runewidth v0.0.16 // indirect
+	github.com/rivo/uniseg v0.4.7 // indirect
+	golang.org/x/sys v0.29.0 // indirect
+	golang.org/x/text v0.21.0 // indirect
+)
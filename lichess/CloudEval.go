@@ -0,0 +1,104 @@
+package lichess
+
+import (
+	"chessAnalyserFree/api"
+	gameengine "chessAnalyserFree/gameEngine"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// cloudEvalBackoff is how long cloud lookups are paused after Lichess rate limits us.
+const cloudEvalBackoff = time.Minute
+
+// CloudPV is a single principal variation of a cloud evaluation, from White's point of view.
+type CloudPV struct {
+	Moves string `json:"moves"`
+	CP    *int   `json:"cp"`
+	Mate  *int   `json:"mate"`
+}
+
+// CloudEvaluation is a cached evaluation from the Lichess cloud.
+type CloudEvaluation struct {
+	FEN    string    `json:"fen"`
+	KNodes int       `json:"knodes"`
+	Depth  int       `json:"depth"`
+	PVs    []CloudPV `json:"pvs"`
+}
+
+// CloudEval fetches the cached cloud evaluation of a position.
+// It returns nil without an error when Lichess has no evaluation for the position.
+func (c *Client) CloudEval(fen string, multiPV int) (*CloudEvaluation, error) {
+	if time.Now().UnixNano() < c.cloudEvalPausedUntil.Load() {
+		return nil, fmt.Errorf("cloud evaluation paused after rate limiting")
+	}
+
+	query := url.Values{}
+	query.Set("fen", fen)
+	query.Set("multiPv", fmt.Sprint(multiPV))
+	requestURL := fmt.Sprintf("%s/api/cloud-eval?%s", baseURL, query.Encode())
+
+	req, err := http.NewRequest("GET", requestURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("User-Agent", "Go-Chess.com-API-Client/1.0 (your-contact-info)")
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		// The position is not in the cloud cache.
+		return nil, nil
+	case http.StatusTooManyRequests:
+		c.cloudEvalPausedUntil.Store(time.Now().Add(cloudEvalBackoff).UnixNano())
+		return nil, fmt.Errorf("rate limited by lichess")
+	default:
+		return nil, fmt.Errorf("received non-200 status code: %d", resp.StatusCode)
+	}
+
+	var evaluation CloudEvaluation
+	if err := json.NewDecoder(resp.Body).Decode(&evaluation); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal json response: %w", err)
+	}
+	return &evaluation, nil
+}
+
+// LookupEval implements gameengine.EvalSource using the Lichess cloud evaluation cache.
+func (c *Client) LookupEval(fen string) (*gameengine.ExternalEval, error) {
+	evaluation, err := c.CloudEval(fen, 1)
+	if err != nil || evaluation == nil || len(evaluation.PVs) == 0 {
+		return nil, err
+	}
+	pv := evaluation.PVs[0]
+	eval := &gameengine.ExternalEval{Depth: evaluation.Depth}
+	if moves := strings.Fields(pv.Moves); len(moves) > 0 {
+		eval.BestMove = moves[0]
+	}
+	switch {
+	case pv.Mate != nil:
+		eval.Mate = *pv.Mate
+	case pv.CP != nil:
+		eval.Centipawns = *pv.CP
+	default:
+		return nil, nil
+	}
+	return eval, nil
+}
+
+// Ensure the client can be used wherever a game source or evaluation source is expected.
+var (
+	_ api.GameSource        = (*Client)(nil)
+	_ gameengine.EvalSource = (*Client)(nil)
+)
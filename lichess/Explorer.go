@@ -0,0 +1,113 @@
+package lichess
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// explorerURL is the base URL for the Lichess opening explorer.
+const explorerURL = "https://explorer.lichess.ovh"
+
+// Opening explorer databases.
+const (
+	ExplorerMasters = "masters"
+	ExplorerLichess = "lichess"
+)
+
+// ExplorerMove holds the statistics of one move from an explorer position.
+type ExplorerMove struct {
+	UCI           string `json:"uci"`
+	SAN           string `json:"san"`
+	AverageRating int    `json:"averageRating"`
+	White         int    `json:"white"`
+	Draws         int    `json:"draws"`
+	Black         int    `json:"black"`
+}
+
+// Total returns the number of games in which the move was played.
+func (m ExplorerMove) Total() int {
+	return m.White + m.Draws + m.Black
+}
+
+// Score returns the percentage scored by the side making the move.
+func (m ExplorerMove) Score(whiteToMove bool) float64 {
+	total := m.Total()
+	if total == 0 {
+		return 0
+	}
+	wins := m.White
+	if !whiteToMove {
+		wins = m.Black
+	}
+	return (float64(wins) + float64(m.Draws)/2) * 100 / float64(total)
+}
+
+// ExplorerResult holds the explorer statistics of a position.
+type ExplorerResult struct {
+	White   int            `json:"white"`
+	Draws   int            `json:"draws"`
+	Black   int            `json:"black"`
+	Moves   []ExplorerMove `json:"moves"`
+	Opening *struct {
+		ECO  string `json:"eco"`
+		Name string `json:"name"`
+	} `json:"opening"`
+}
+
+// Total returns the number of games that reached the position.
+func (r *ExplorerResult) Total() int {
+	return r.White + r.Draws + r.Black
+}
+
+// Find returns the statistics of a move given in UCI notation, if it was ever played.
+func (r *ExplorerResult) Find(uci string) (ExplorerMove, bool) {
+	for _, move := range r.Moves {
+		if move.UCI == uci {
+			return move, true
+		}
+	}
+	return ExplorerMove{}, false
+}
+
+// Explore fetches the opening explorer statistics of a position from the given database
+// (ExplorerMasters or ExplorerLichess).
+func (c *Client) Explore(database, fen string) (*ExplorerResult, error) {
+	query := url.Values{}
+	query.Set("fen", fen)
+	query.Set("moves", "12")
+	query.Set("topGames", "0")
+	if database == ExplorerLichess {
+		query.Set("recentGames", "0")
+	}
+	requestURL := fmt.Sprintf("%s/%s?%s", explorerURL, database, query.Encode())
+
+	req, err := http.NewRequest("GET", requestURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("User-Agent", "Go-Chess.com-API-Client/1.0 (your-contact-info)")
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return nil, fmt.Errorf("rate limited by the lichess explorer, wait a minute before retrying")
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("received non-200 status code: %d", resp.StatusCode)
+	}
+
+	var result ExplorerResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal json response: %w", err)
+	}
+	return &result, nil
+}
@@ -0,0 +1,334 @@
+package lichess
+
+import (
+	"bufio"
+	"chessAnalyserFree/api"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// baseURL is the base URL for the Lichess API.
+const baseURL = "https://lichess.org"
+
+// Client is a client for the Lichess API.
+type Client struct {
+	HTTPClient *http.Client
+	// Token is an optional personal API token, which raises the export rate limit.
+	Token string
+	// Evals includes engine evaluations as [%eval] comments for games analysed on Lichess.
+	Evals bool
+	// Clocks includes clock times as [%clk] comments.
+	Clocks bool
+
+	// cloudEvalPausedUntil suspends cloud evaluation lookups after a rate limit response, in Unix
+	// nanoseconds. Several engine workers may look up positions at once.
+	cloudEvalPausedUntil atomic.Int64
+}
+
+// NewClient creates a new Lichess API client that exports games with clocks.
+func NewClient() *Client {
+	return &Client{
+		// Exports are streamed and can take minutes for active players, so only the
+		// connection phase is bounded.
+		HTTPClient: &http.Client{
+			Transport: &http.Transport{
+				Proxy:                 http.ProxyFromEnvironment,
+				ResponseHeaderTimeout: 30 * time.Second,
+			},
+		},
+		Clocks: true,
+	}
+}
+
+// LichessUser is the user reference inside a game's player entry.
+type LichessUser struct {
+	Name  string `json:"name"`
+	ID    string `json:"id"`
+	Title string `json:"title"`
+}
+
+// LichessPlayer holds one side of an exported game.
+type LichessPlayer struct {
+	User       LichessUser `json:"user"`
+	Rating     int         `json:"rating"`
+	RatingDiff int         `json:"ratingDiff"`
+	AILevel    int         `json:"aiLevel"`
+	// Analysis holds Lichess's figures for the player's moves; nil for games without computer analysis.
+	Analysis *PlayerAnalysis `json:"analysis"`
+}
+
+// PlayerAnalysis is Lichess's summary of one side's moves in a game with computer analysis.
+type PlayerAnalysis struct {
+	Inaccuracy int `json:"inaccuracy"`
+	Mistake    int `json:"mistake"`
+	Blunder    int `json:"blunder"`
+	ACPL       int `json:"acpl"`
+	Accuracy   int `json:"accuracy"` // 0 when Lichess did not compute it, as for older games.
+}
+
+// LichessGame is a single game as returned by the NDJSON game export.
+type LichessGame struct {
+	ID         string `json:"id"`
+	Rated      bool   `json:"rated"`
+	Variant    string `json:"variant"`
+	Speed      string `json:"speed"`
+	CreatedAt  int64  `json:"createdAt"`
+	LastMoveAt int64  `json:"lastMoveAt"`
+	Status     string `json:"status"`
+	Winner     string `json:"winner"`
+	Players    struct {
+		White LichessPlayer `json:"white"`
+		Black LichessPlayer `json:"black"`
+	} `json:"players"`
+	Opening struct {
+		ECO  string `json:"eco"`
+		Name string `json:"name"`
+	} `json:"opening"`
+	Clock struct {
+		Initial   int `json:"initial"`
+		Increment int `json:"increment"`
+	} `json:"clock"`
+	DaysPerTurn int    `json:"daysPerTurn"`
+	LastFEN     string `json:"lastFen"`
+	PGN         string `json:"pgn"`
+}
+
+// Name returns the identifier of the Lichess game source.
+func (c *Client) Name() string {
+	return "lichess"
+}
+
+// FetchGames exports all games a player finished between from and to.
+func (c *Client) FetchGames(username string, from, to time.Time) ([]api.Game, error) {
+	var games []api.Game
+	err := c.StreamGames(username, from, to, func(game api.Game) error {
+		games = append(games, game)
+		return nil
+	})
+	return games, err
+}
+
+// StreamGames exports a player's games between from and to, calling fn for each game as it
+// arrives. The export is read line by line, so memory use does not grow with the number of games.
+func (c *Client) StreamGames(username string, from, to time.Time, fn func(api.Game) error) error {
+	query := url.Values{}
+	query.Set("since", strconv.FormatInt(from.UnixMilli(), 10))
+	query.Set("until", strconv.FormatInt(to.UnixMilli(), 10))
+	query.Set("pgnInJson", "true")
+	query.Set("opening", "true")
+	query.Set("lastFen", "true")
+	query.Set("evals", strconv.FormatBool(c.Evals))
+	query.Set("clocks", strconv.FormatBool(c.Clocks))
+	requestURL := fmt.Sprintf("%s/api/games/user/%s?%s", baseURL, url.PathEscape(username), query.Encode())
+
+	// Create a new HTTP request asking for newline-delimited JSON.
+	req, err := http.NewRequest("GET", requestURL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/x-ndjson")
+	req.Header.Set("User-Agent", "Go-Chess.com-API-Client/1.0 (your-contact-info)")
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+
+	// Execute the request.
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to execute request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Check for a successful status code.
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return fmt.Errorf("rate limited by lichess, wait a minute before retrying")
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("received non-200 status code: %d", resp.StatusCode)
+	}
+
+	// Decode one game per line.
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var lichessGame LichessGame
+		if err := json.Unmarshal([]byte(line), &lichessGame); err != nil {
+			return fmt.Errorf("failed to unmarshal game: %w", err)
+		}
+		if err := fn(lichessGame.ToGame()); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read game stream: %w", err)
+	}
+	return nil
+}
+
+// gameIDRegex extracts the game ID from a game ID or URL, e.g. "AbCdEfGh", "https://lichess.org/AbCdEfGh"
+// or the 12-character URL of a player's view, "https://lichess.org/AbCdEfGhIjKl".
+var gameIDRegex = regexp.MustCompile(`^(?:https?://lichess\.org/)?([A-Za-z0-9]{8})(?:[A-Za-z0-9]{4})?(?:/(?:white|black))?(?:[/?#].*)?$`)
+
+// ParseGameID returns the ID of a game given either the ID itself or a game URL.
+func ParseGameID(game string) (string, error) {
+	matches := gameIDRegex.FindStringSubmatch(strings.TrimSpace(game))
+	if matches == nil {
+		return "", fmt.Errorf("%q is not a Lichess game ID or URL", game)
+	}
+	return matches[1], nil
+}
+
+// ExportGame exports a single game, including each player's analysis figures and accuracy when the
+// game has computer analysis.
+func (c *Client) ExportGame(id string) (*LichessGame, error) {
+	query := url.Values{}
+	query.Set("pgnInJson", "true")
+	query.Set("opening", "true")
+	query.Set("accuracy", "true")
+	query.Set("evals", strconv.FormatBool(c.Evals))
+	query.Set("clocks", strconv.FormatBool(c.Clocks))
+	requestURL := fmt.Sprintf("%s/game/export/%s?%s", baseURL, url.PathEscape(id), query.Encode())
+
+	req, err := http.NewRequest("GET", requestURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", "Go-Chess.com-API-Client/1.0 (your-contact-info)")
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, fmt.Errorf("game %s not found", id)
+	case http.StatusTooManyRequests:
+		return nil, fmt.Errorf("rate limited by lichess, wait a minute before retrying")
+	default:
+		return nil, fmt.Errorf("received non-200 status code: %d", resp.StatusCode)
+	}
+
+	var game LichessGame
+	if err := json.NewDecoder(resp.Body).Decode(&game); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal json response: %w", err)
+	}
+	return &game, nil
+}
+
+// ToGame converts a Lichess game into the common game structure.
+func (g LichessGame) ToGame() api.Game {
+	white, black := resultCodes(g.Status, g.Winner)
+	return api.Game{
+		URL:         fmt.Sprintf("%s/%s", baseURL, g.ID),
+		PGN:         g.PGN,
+		TimeControl: g.timeControl(),
+		EndTime:     g.LastMoveAt / 1000,
+		Rated:       g.Rated,
+		FEN:         g.LastFEN,
+		TimeClass:   timeClass(g.Speed),
+		Rules:       rules(g.Variant),
+		White:       g.Players.White.toPlayer(white),
+		Black:       g.Players.Black.toPlayer(black),
+	}
+}
+
+// toPlayer converts a Lichess player entry, naming engine opponents after their level.
+func (p LichessPlayer) toPlayer(result string) api.Player {
+	username := p.User.Name
+	if username == "" && p.AILevel > 0 {
+		username = fmt.Sprintf("Stockfish level %d", p.AILevel)
+	}
+	player := api.Player{
+		Rating:   p.Rating,
+		Result:   result,
+		Username: username,
+	}
+	if p.User.Name != "" {
+		player.ID = fmt.Sprintf("%s/@/%s", baseURL, p.User.Name)
+	}
+	// Lichess marks bot accounts with the BOT title; AI games are against Stockfish.
+	player.Bot = p.User.Title == "BOT" || p.AILevel > 0
+	return player
+}
+
+// timeControl formats the clock in Chess.com's style ("180+2", "600", "1/259200").
+func (g LichessGame) timeControl() string {
+	if g.DaysPerTurn > 0 {
+		return fmt.Sprintf("1/%d", g.DaysPerTurn*24*60*60)
+	}
+	if g.Clock.Increment > 0 {
+		return fmt.Sprintf("%d+%d", g.Clock.Initial, g.Clock.Increment)
+	}
+	return strconv.Itoa(g.Clock.Initial)
+}
+
+// timeClass maps a Lichess speed onto the Chess.com time classes where one exists.
+func timeClass(speed string) string {
+	switch speed {
+	case "ultraBullet":
+		return "bullet"
+	case "correspondence":
+		return "daily"
+	default:
+		return speed
+	}
+}
+
+// rules maps a Lichess variant onto the Chess.com rules names.
+func rules(variant string) string {
+	switch variant {
+	case "standard", "fromPosition", "":
+		return "chess"
+	default:
+		return strings.ToLower(variant)
+	}
+}
+
+// resultCodes maps a Lichess status and winner onto Chess.com result codes for white and black.
+func resultCodes(status, winner string) (string, string) {
+	if winner == "" {
+		switch status {
+		case "stalemate":
+			return "stalemate", "stalemate"
+		case "aborted", "noStart":
+			return "abandoned", "abandoned"
+		default:
+			return "agreed", "agreed"
+		}
+	}
+
+	var loser string
+	switch status {
+	case "mate":
+		loser = "checkmated"
+	case "resign":
+		loser = "resigned"
+	case "outoftime":
+		loser = "timeout"
+	default:
+		// Includes "timeout" (left the game) and "cheat".
+		loser = "abandoned"
+	}
+	if winner == "white" {
+		return "win", loser
+	}
+	return loser, "win"
+}
@@ -0,0 +1,61 @@
+package lichess
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// studyIDRegex extracts the study ID from a study URL such as "https://lichess.org/study/AbCdEfGh".
+var studyIDRegex = regexp.MustCompile(`^(?:https?://lichess\.org/study/)?([A-Za-z0-9]{8})(?:[/?#].*)?$`)
+
+// ParseStudyID returns the ID of a study given either the ID itself or a study URL.
+func ParseStudyID(study string) (string, error) {
+	matches := studyIDRegex.FindStringSubmatch(strings.TrimSpace(study))
+	if matches == nil {
+		return "", fmt.Errorf("%q is not a Lichess study ID or URL", study)
+	}
+	return matches[1], nil
+}
+
+// ImportToStudy adds a PGN as a new chapter of a study. The client's Token must belong to a
+// member of the study with the study:write scope.
+func (c *Client) ImportToStudy(studyID, chapterName, pgn string) error {
+	if c.Token == "" {
+		return fmt.Errorf("a Lichess API token with the study:write scope is required")
+	}
+
+	form := url.Values{}
+	form.Set("pgn", pgn)
+	form.Set("name", chapterName)
+	requestURL := fmt.Sprintf("%s/api/study/%s/import-pgn", baseURL, url.PathEscape(studyID))
+
+	req, err := http.NewRequest("POST", requestURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("User-Agent", "Go-Chess.com-API-Client/1.0 (your-contact-info)")
+	req.Header.Set("Authorization", "Bearer "+c.Token)
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to execute request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return fmt.Errorf("lichess refused the import (status %d): check the token's study:write scope and study membership", resp.StatusCode)
+	case http.StatusNotFound:
+		return fmt.Errorf("study %s not found", studyID)
+	case http.StatusTooManyRequests:
+		return fmt.Errorf("rate limited by lichess, wait a minute before retrying")
+	default:
+		return fmt.Errorf("received non-200 status code: %d", resp.StatusCode)
+	}
+}
@@ -1,182 +1,99 @@
 package main
 
 import (
-	"bufio"
-	"chessAnalyserFree/api"
-	gameengine "chessAnalyserFree/gameEngine"
+	"flag"
 	"fmt"
 	"log"
 	"os"
-	"strconv"
-	"strings"
-	"time"
 )
 
-func main() {
-	// --- Argument Parsing ---
-	// Expected format: go run . <username> <start_YYYY-MM> <end_YYYY-MM> <path_to_stockfish>
-	if len(os.Args) != 5 {
-		fmt.Println("Usage: go run . <username> <start_YYYY-MM> <end_YYYY-MM> <path_to_stockfish>")
-		fmt.Println("Example: go run . hikaru 2022-10 2023-01 /usr/local/bin/stockfish")
-		return
-	}
-
-	username := os.Args[1]
-	startDateStr := os.Args[2]
-	endDateStr := os.Args[3]
-	stockfishPath := os.Args[4]
+// command is a subcommand of the program.
+type command struct {
+	name    string
+	args    string // Synopsis of the arguments, for the help.
+	summary string
+	run     func(arguments []string, defaults *userConfig)
+}
 
-	// --- Stockfish Analyser Initialization ---
-	analyser, err := gameengine.NewStockfishAnalyser(stockfishPath)
-	if err != nil {
-		log.Fatalf("Error starting Stockfish analyser: %v", err)
+// commands returns the subcommands in the order the help lists them.
+func commands() []command {
+	return []command{
+		{"fetch", "--user <username> --from YYYY-MM [--to YYYY-MM] [--out games.pgn]", "Fetch and list a player's games with their statistics, without analysing them", runFetch},
+		{"analyse", "--user <username> --from YYYY-MM [--to YYYY-MM] [--engine path] | --pgn <files> | --game <url> | --import <files>", "Analyse games, choosing them in an interactive menu", func(arguments []string, defaults *userConfig) {
+			runAnalyse("analyse", arguments, defaults)
+		}},
+		{"report", "--user <username> --from YYYY-MM [--to YYYY-MM] [--engine path] | --pgn <files>", "Analyse every game and write its reports, without the menu", func(arguments []string, defaults *userConfig) {
+			runAnalyse("report", arguments, defaults)
+		}},
+		{"sync", "[--user <username>] [--db games.db] [--days 7] [--preset standard] [<path_to_stockfish>]", "Analyse a player's games since the last sync", runSync},
+		{"serve", "--db <postgres://...> [--addr :8080] [--preset standard] [<path_to_stockfish>]", "Run the multi-user game review service", runServer},
+		{"daemon", "[--config daemon.json] [--once] [<path_to_stockfish>]", "Generate reports on cron schedules", runDaemon},
+		{"download", "[--out dir] [--from YYYY-MM] [--to YYYY-MM] [<username>]", "Save a player's monthly archives as PGN files", runDownload},
+		{"backfill", "[--user <username>] [--since YYYY[-MM]] [--db games.db] [--delay 3s]", "Store a player's whole history in the game database", runBackfill},
+		{"db", "verify [--db games.db] [--repair]", "Audit and repair the game database", runDatabase},
+		{"benchmark", "[--preset name] [<path_to_stockfish>]", "Measure the engine time per position of the presets", runBenchmark},
+		{"calibrate", "[--references file.pgn] [--preset standard] [<path_to_stockfish>]", "Compare accuracy figures with published reference games", runCalibrate},
+		{"rate", "[--user <username>] [--game <url>] [--games 50] [--preset quick] [<path_to_stockfish>]", "Rate the opponent of a lost game against their recent games", runRate},
+		{"match", "[--fen FEN] [--games n] [--movetime 100ms] [--engine2 path] [<path_to_stockfish>]", "Play engine games from a position", runMatch},
 	}
-	defer analyser.Close()
-	fmt.Println("Stockfish engine initialized successfully.")
+}
 
-	// --- Date Parsing ---
-	layout := "2006-01-02"
-	startDate, err := time.Parse(layout, startDateStr+"-01")
-	if err != nil {
-		log.Fatalf("Error parsing start date: %v. Please use YYYY-MM format.", err)
-	}
-	endDate, err := time.Parse(layout, endDateStr+"-01")
+func main() {
+	os.Args = takeNoColor(takeErrorsJSON(os.Args))
+	config, err := loadUserConfig()
 	if err != nil {
-		log.Fatalf("Error parsing end date: %v. Please use YYYY-MM format.", err)
-	}
-
-	if startDate.After(endDate) {
-		log.Fatal("Start date cannot be after the end date.")
-	}
-
-	// --- API Client Initialization ---
-	client := api.NewClient()
-	var allGames []api.Game
-	totalGamesFound := 0
-
-	fmt.Printf("Fetching games for user '%s' from %s to %s\n", username, startDate.Format("Jan 2006"), endDate.Format("Jan 2006"))
-
-	// --- Game Fetching Loop ---
-	for d := startDate; !d.After(endDate); d = d.AddDate(0, 1, 0) {
-		year := d.Format("2006")
-		month := d.Format("01")
-		fmt.Printf("... checking %s/%s\n", month, year)
-		gamesResponse, err := client.FetchPlayerGamesByMonth(username, year, month)
-		if err != nil {
-			log.Printf("Could not fetch games for %s/%s: %v", month, year, err)
-			continue
-		}
-		if gamesResponse != nil && len(gamesResponse.Games) > 0 {
-			allGames = append(allGames, gamesResponse.Games...)
-			totalGamesFound += len(gamesResponse.Games)
-		}
-		time.Sleep(250 * time.Millisecond)
+		log.Fatalf("Error loading configuration: %v", err)
 	}
 
-	// --- Display Results ---
-	fmt.Printf("\n--- Finished Fetching --- \n")
-	fmt.Printf("Found a total of %d games for %s.\n\n", totalGamesFound, username)
-	if totalGamesFound == 0 {
+	if len(os.Args) < 2 {
+		printUsage()
 		return
 	}
-	listGames(allGames)
-
-	// --- Interactive Game Selection ---
-	reader := bufio.NewReader(os.Stdin)
-	for {
-		fmt.Print("\nEnter a game number to select, or 'quit' to exit: ")
-		input, _ := reader.ReadString('\n')
-		input = strings.TrimSpace(input)
-
-		if strings.ToLower(input) == "quit" {
-			fmt.Println("Goodbye!")
-			break
-		}
-
-		gameNum, err := strconv.Atoi(input)
-		if err != nil || gameNum < 1 || gameNum > len(allGames) {
-			fmt.Println("Invalid number. Please enter a number from the list.")
-			continue
-		}
-
-		// Enter the sub-menu for the selected game
-		handleSelectedGame(reader, analyser, allGames[gameNum-1], gameNum)
-		listGames(allGames) // Re-list games after returning from sub-menu
+	switch os.Args[1] {
+	case "help", "-h", "-help", "--help":
+		printUsage()
+		return
+	case "analyze":
+		os.Args[1] = "analyse"
 	}
-}
-
-// listGames prints the list of fetched games.
-func listGames(games []api.Game) {
-	fmt.Println("--- Games Found ---")
-	for i, game := range games {
-		endTime := time.Unix(game.EndTime, 0)
-		fmt.Printf("[%d] %s vs %s (%s) - Played on %s\n",
-			i+1, game.White.Username, game.Black.Username, game.TimeClass, endTime.Format("2006-01-02"))
+	run := func(arguments []string, defaults *userConfig) {
+		// Without a subcommand, the arguments are those of analyse, including the older positional form
+		// [<username>] <start_YYYY-MM> <end_YYYY-MM> [<path_to_stockfish>].
+		runAnalyse("analyse", arguments, defaults)
 	}
-	fmt.Println("-------------------")
-}
-
-// handleSelectedGame provides options for a selected game (details, analyse).
-func handleSelectedGame(reader *bufio.Reader, analyser *gameengine.StockfishAnalyser, game api.Game, gameNum int) {
-	for {
-		fmt.Printf("\nSelected Game %d: %s vs %s\n", gameNum, game.White.Username, game.Black.Username)
-		fmt.Print("Enter command ('details', 'analyse', 'back'): ")
-		input, _ := reader.ReadString('\n')
-		input = strings.TrimSpace(strings.ToLower(input))
-
-		switch input {
-		case "details":
-			displayGameDetails(game, gameNum)
-		case "analyse":
-			analyseGameMoves(analyser, game)
-		case "back":
-			return
-		default:
-			fmt.Println("Invalid command.")
+	arguments := os.Args[1:]
+	for _, c := range commands() {
+		if c.name == os.Args[1] {
+			run, arguments = c.run, os.Args[2:]
 		}
 	}
+	run(arguments, config)
+	// Failures reported with fail set the exit code once the command has cleaned up.
+	os.Exit(exitStatus)
 }
 
-// displayGameDetails shows detailed information for a selected game.
-func displayGameDetails(game api.Game, index int) {
-	endTime := time.Unix(game.EndTime, 0)
-	fmt.Printf("\n--- Game Details (%d) ---\n", index)
-	fmt.Printf("URL: %s\n", game.URL)
-	fmt.Printf("Date: %s\n", endTime.Format("2006-01-02 15:04:05"))
-	fmt.Printf("Result: White: %s, Black: %s\n", game.White.Result, game.Black.Result)
-	fmt.Println("--- PGN ---")
-	fmt.Println(game.PGN)
-	fmt.Println("-------------")
-}
-
-// analyseGameMoves triggers the stockfish analysis and prints the results.
-func analyseGameMoves(analyser *gameengine.StockfishAnalyser, game api.Game) {
-	fmt.Println("\nAnalysing game... this may take a moment.")
-	analysis, err := analyser.AnalyseGame(game)
-	if err != nil {
-		log.Printf("Error during analysis: %v", err)
-		return
+// printUsage prints the subcommands.
+func printUsage() {
+	fmt.Println("Usage: go run . <command> [flags]")
+	fmt.Println("\nCommands:")
+	for _, c := range commands() {
+		fmt.Printf("  %-10s %s\n", c.name, c.summary)
 	}
+	fmt.Println("\nRun 'go run . <command> --help' for the flags of a command.")
+	fmt.Println("\nAdd --errors-json to any command to log errors to stderr as JSON objects.")
+	fmt.Println("Add --no-color to any command, or set NO_COLOR, to turn off coloured output.")
+	fmt.Println("Example: go run . analyse --user hikaru --from 2022-10 --to 2023-01 --engine /usr/local/bin/stockfish")
+	fmt.Printf("The username and engine path can be set in ~/%s (engine:, user:).\n", configFileName)
+}
 
-	fmt.Println("\n--- Move Analysis ---")
-	fmt.Println("Move | White              | Black              | Eval")
-	fmt.Println("-----------------------------------------------------")
-	for i := 0; i < len(analysis); i += 2 {
-		whiteMove := analysis[i]
-		var blackMoveStr string
-		if i+1 < len(analysis) {
-			blackMove := analysis[i+1]
-			blackMoveStr = fmt.Sprintf("%-20s", blackMove.Move)
-		} else {
-			blackMoveStr = fmt.Sprintf("%-20s", "")
+// usageFunc returns a flag set's usage function, printing the command's synopsis and its flags.
+func usageFunc(flags *flag.FlagSet) func() {
+	return func() {
+		for _, c := range commands() {
+			if c.name == flags.Name() {
+				fmt.Fprintf(flags.Output(), "Usage: go run . %s %s\n\n%s.\n\nFlags:\n", c.name, c.args, c.summary)
+			}
 		}
-
-		fmt.Printf("%-4d | %-20s | %s | %s\n",
-			whiteMove.MoveNumber,
-			whiteMove.Move,
-			blackMoveStr,
-			whiteMove.EvaluationText,
-		)
+		flags.PrintDefaults()
 	}
-	fmt.Println("---------------------")
 }
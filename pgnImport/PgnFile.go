@@ -0,0 +1,316 @@
+package pgnimport
+
+import (
+	"bufio"
+	"chessAnalyserFree/api"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// tagRegex matches a PGN tag pair such as [White "hikaru"].
+var tagRegex = regexp.MustCompile(`^\[(\w+)\s+"(.*)"\]\s*$`)
+
+// resultTokens are the game termination markers that end a game's movetext.
+var resultTokens = map[string]bool{"1-0": true, "0-1": true, "1/2-1/2": true, "*": true}
+
+// errStopped ends StreamGames when the loop over Games stops early.
+var errStopped = errors.New("stopped")
+
+// ReadFiles reads every game from one or more PGN files.
+func ReadFiles(paths []string) ([]api.Game, error) {
+	return collect(FileGames(paths))
+}
+
+// FileGames returns the games of one or more PGN files as an iterator, like Games. Each file is
+// opened when the loop reaches it and closed when the loop leaves it.
+func FileGames(paths []string) iter.Seq2[api.Game, error] {
+	return func(yield func(api.Game, error) bool) {
+		for _, path := range paths {
+			f, err := os.Open(path)
+			if err != nil {
+				yield(api.Game{}, fmt.Errorf("failed to open %s: %w", path, err))
+				return
+			}
+			for game, err := range Games(f, "file://"+path) {
+				if err != nil {
+					err = fmt.Errorf("failed to read %s: %w", path, err)
+				}
+				if !yield(game, err) || err != nil {
+					f.Close()
+					return
+				}
+			}
+			f.Close()
+		}
+	}
+}
+
+// ReadGames reads every game of a multi-game PGN stream. The origin identifies the stream and is
+// used to build a unique URL for games without a Link or Site URL tag (e.g., "file://games.pgn#3").
+func ReadGames(r io.Reader, origin string) ([]api.Game, error) {
+	return collect(Games(r, origin))
+}
+
+// Games returns the games of a multi-game PGN stream as an iterator. A game is only read from r when
+// the loop asks for it, so an archive of any size takes the memory of a single game, and a loop
+// that stops early reads no further. An error reading r is yielded with an empty game and ends the
+// loop. The origin is used as with ReadGames.
+func Games(r io.Reader, origin string) iter.Seq2[api.Game, error] {
+	return func(yield func(api.Game, error) bool) {
+		err := StreamGames(r, origin, func(game api.Game) error {
+			if !yield(game, nil) {
+				return errStopped
+			}
+			return nil
+		})
+		if err != nil && !errors.Is(err, errStopped) {
+			yield(api.Game{}, err)
+		}
+	}
+}
+
+// collect returns all the games of an iterator, or its first error.
+func collect(games iter.Seq2[api.Game, error]) ([]api.Game, error) {
+	var all []api.Game
+	for game, err := range games {
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, game)
+	}
+	return all, nil
+}
+
+// StreamGames reads a multi-game PGN stream like ReadGames, but calls fn with every game as soon as
+// it has been read, so games piped in from another program are handled while it is still writing.
+// A game is complete at the result that ends its movetext, without waiting for the next game's tags.
+// An error returned by fn stops the reading and is returned.
+func StreamGames(r io.Reader, origin string, fn func(api.Game) error) error {
+	var current strings.Builder
+	inMoves := false
+	comment := 0 // Nesting of the {} comment the movetext is in.
+	count := 0
+
+	flush := func() error {
+		text := strings.TrimSpace(current.String())
+		current.Reset()
+		inMoves = false
+		if text == "" {
+			return nil
+		}
+		count++
+		return fn(GameFromPGN(text, fmt.Sprintf("%s#%d", origin, count)))
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "[") && tagRegex.MatchString(trimmed) {
+			// A tag after movetext starts the next game.
+			if inMoves {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+		} else if trimmed != "" {
+			inMoves = true
+		}
+		current.WriteString(line)
+		current.WriteString("\n")
+		if inMoves {
+			comment += strings.Count(line, "{") - strings.Count(line, "}")
+			if fields := strings.Fields(trimmed); comment <= 0 && len(fields) > 0 && resultTokens[fields[len(fields)-1]] {
+				if err := flush(); err != nil {
+					return err
+				}
+				comment = 0
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return flush()
+}
+
+// ParseTags returns the tag pairs of a PGN game.
+func ParseTags(pgn string) map[string]string {
+	tags := make(map[string]string)
+	for _, line := range strings.Split(pgn, "\n") {
+		if matches := tagRegex.FindStringSubmatch(strings.TrimSpace(line)); matches != nil {
+			tags[matches[1]] = matches[2]
+		}
+	}
+	return tags
+}
+
+// GameFromPGN builds a Game from the text of a single PGN game, filling in what the tags provide.
+// fallbackURL is used when the game has no Link or Site URL tag.
+func GameFromPGN(pgn, fallbackURL string) api.Game {
+	tags := ParseTags(pgn)
+
+	url := fallbackURL
+	if link := tags["Link"]; link != "" {
+		url = link
+	} else if site := tags["Site"]; strings.HasPrefix(site, "http") {
+		url = site
+	}
+
+	whiteResult, blackResult := resultCodes(tags["Result"], tags["Termination"])
+	rules := strings.ToLower(strings.ReplaceAll(tags["Variant"], " ", ""))
+	if rules == "" || rules == "standard" || rules == "fromposition" {
+		rules = "chess"
+	}
+
+	return api.Game{
+		URL:         url,
+		PGN:         pgn,
+		TimeControl: tags["TimeControl"],
+		FEN:         tags["CurrentPosition"],
+		EndTime:     endTime(tags),
+		Rated:       isRated(tags["Event"]),
+		TimeClass:   TimeClass(tags["TimeControl"]),
+		Rules:       rules,
+		White:       api.Player{Username: tags["White"], Rating: atoi(tags["WhiteElo"]), Result: whiteResult, Bot: tags["WhiteTitle"] == "BOT"},
+		Black:       api.Player{Username: tags["Black"], Rating: atoi(tags["BlackElo"]), Result: blackResult, Bot: tags["BlackTitle"] == "BOT"},
+	}
+}
+
+// isRated reports whether a game counts as rated. Most PGN sources do not say, so games are rated
+// unless the event marks them as casual or unrated (e.g., Lichess's "Casual Blitz game").
+func isRated(event string) bool {
+	event = strings.ToLower(event)
+	return !strings.Contains(event, "casual") && !strings.Contains(event, "unrated")
+}
+
+// TimeClass derives the Chess.com time class from a PGN TimeControl tag, using the estimated
+// duration of a 40-move game.
+func TimeClass(timeControl string) string {
+	if timeControl == "" || timeControl == "-" || timeControl == "?" {
+		return ""
+	}
+	if strings.Contains(timeControl, "/") {
+		// Daily games ("1/259200") and classical move-count controls ("40/7200:3600").
+		if strings.HasPrefix(timeControl, "1/") {
+			return "daily"
+		}
+		return "classical"
+	}
+	parts := strings.SplitN(timeControl, "+", 2)
+	base := atoi(parts[0])
+	increment := 0
+	if len(parts) == 2 {
+		increment = atoi(parts[1])
+	}
+	switch total := base + 40*increment; {
+	case total < 180:
+		return "bullet"
+	case total < 600:
+		return "blitz"
+	case total < 3600:
+		return "rapid"
+	default:
+		return "classical"
+	}
+}
+
+// endTime returns the end of the game from the EndDate/EndTime, UTCDate/UTCTime or Date tags.
+func endTime(tags map[string]string) int64 {
+	for _, pair := range [][2]string{{"EndDate", "EndTime"}, {"UTCDate", "UTCTime"}, {"Date", ""}} {
+		date := tags[pair[0]]
+		if date == "" || strings.HasPrefix(date, "?") {
+			continue
+		}
+		// Partial dates ("2023.11.??") fall back to the first day of the known period.
+		date = strings.ReplaceAll(date, "??", "01")
+		clock := tags[pair[1]]
+		if clock == "" {
+			clock = "00:00:00"
+		}
+		if t, err := time.Parse("2006.01.02 15:04:05", date+" "+clock); err == nil {
+			return t.Unix()
+		}
+	}
+	return 0
+}
+
+// StartTime returns the start of a game from the UTCDate/UTCTime or Date/StartTime tags of its PGN,
+// as Chess.com and Lichess write them, or 0 if they are missing.
+func StartTime(pgn string) int64 {
+	tags := ParseTags(pgn)
+	for _, pair := range [][2]string{{"UTCDate", "UTCTime"}, {"Date", "StartTime"}} {
+		date, clock := tags[pair[0]], tags[pair[1]]
+		if date == "" || clock == "" {
+			continue
+		}
+		if t, err := time.Parse("2006.01.02 15:04:05", date+" "+clock); err == nil {
+			return t.Unix()
+		}
+	}
+	return 0
+}
+
+// resultCodes maps a PGN result and termination onto Chess.com result codes for white and black.
+func resultCodes(result, termination string) (string, string) {
+	termination = strings.ToLower(termination)
+	switch result {
+	case "1-0":
+		return "win", lossCode(termination)
+	case "0-1":
+		return lossCode(termination), "win"
+	case "1/2-1/2":
+		code := drawCode(termination)
+		return code, code
+	default:
+		return "", ""
+	}
+}
+
+// lossCode maps a termination description onto the Chess.com code for the losing side.
+func lossCode(termination string) string {
+	switch {
+	case strings.Contains(termination, "checkmate"):
+		return "checkmated"
+	case strings.Contains(termination, "resign"):
+		return "resigned"
+	case strings.Contains(termination, "abandon"):
+		return "abandoned"
+	case strings.Contains(termination, "time"):
+		return "timeout"
+	default:
+		return "lose"
+	}
+}
+
+// drawCode maps a termination description onto the Chess.com code for a draw.
+func drawCode(termination string) string {
+	switch {
+	case strings.Contains(termination, "repetition"):
+		return "repetition"
+	case strings.Contains(termination, "stalemate"):
+		return "stalemate"
+	case strings.Contains(termination, "timeout vs insufficient"):
+		return "timevsinsufficient"
+	case strings.Contains(termination, "insufficient"):
+		return "insufficient"
+	case strings.Contains(termination, "50"):
+		return "50move"
+	default:
+		return "agreed"
+	}
+}
+
+// atoi converts a tag value to an integer, returning 0 for missing or invalid values.
+func atoi(value string) int {
+	n, _ := strconv.Atoi(strings.TrimSpace(value))
+	return n
+}
@@ -0,0 +1,167 @@
+package pgnimport
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"chessAnalyserFree/api"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"iter"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// HTTPClient is used to download PGN files from URLs. Downloads are read as their games are used,
+// which can take long for big archives, so only the connection phase is bounded.
+var HTTPClient = &http.Client{
+	Transport: &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		ResponseHeaderTimeout: time.Minute,
+	},
+}
+
+// Load reads the games of every location, which may be a local file, a directory (all .pgn files
+// in it, in name order) or an http(s) URL.
+func Load(locations []string) ([]api.Game, error) {
+	return collect(LoadGames(locations))
+}
+
+// LoadGames returns the games of every location, as Load reads them, as an iterator: files and
+// downloads are read a game at a time as the loop goes on, like Games.
+func LoadGames(locations []string) iter.Seq2[api.Game, error] {
+	return func(yield func(api.Game, error) bool) {
+		for _, location := range locations {
+			var games iter.Seq2[api.Game, error]
+			if strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://") {
+				games = URLGames(location)
+			} else if info, err := os.Stat(location); err == nil && info.IsDir() {
+				paths, err := filepath.Glob(filepath.Join(location, "*.pgn"))
+				if err != nil {
+					yield(api.Game{}, err)
+					return
+				}
+				sort.Strings(paths)
+				games = FileGames(paths)
+			} else {
+				games = FileGames([]string{location})
+			}
+			for game, err := range games {
+				if !yield(game, err) || err != nil {
+					return
+				}
+			}
+		}
+	}
+}
+
+// FetchURL downloads a PGN file and reads its games. Zip archives (as published by TWIC) and
+// gzip-compressed files are unpacked; every .pgn file inside a zip archive is read.
+func FetchURL(url string) ([]api.Game, error) {
+	return collect(URLGames(url))
+}
+
+// URLGames downloads a PGN file and returns its games as an iterator, like Games, reading them from
+// the response as it arrives. Gzip-compressed files are unpacked on the fly; zip archives need
+// random access, so they are saved to a temporary file first, removed when the loop ends.
+func URLGames(url string) iter.Seq2[api.Game, error] {
+	return func(yield func(api.Game, error) bool) {
+		req, err := http.NewRequest("GET", url, nil)
+		if err != nil {
+			yield(api.Game{}, fmt.Errorf("failed to create request: %w", err))
+			return
+		}
+		req.Header.Set("User-Agent", "Go-Chess.com-API-Client/1.0 (your-contact-info)")
+
+		resp, err := HTTPClient.Do(req)
+		if err != nil {
+			yield(api.Game{}, fmt.Errorf("failed to execute request: %w", err))
+			return
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			yield(api.Game{}, fmt.Errorf("received non-200 status code: %d", resp.StatusCode))
+			return
+		}
+
+		body := bufio.NewReader(resp.Body)
+		// A short body is not an error here; it is read as PGN below.
+		magic, _ := body.Peek(4)
+		var games iter.Seq2[api.Game, error]
+		switch {
+		case bytes.HasPrefix(magic, []byte("PK\x03\x04")):
+			games = zipGames(body, url)
+		case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+			gz, err := gzip.NewReader(body)
+			if err != nil {
+				yield(api.Game{}, fmt.Errorf("failed to decompress %s: %w", url, err))
+				return
+			}
+			defer gz.Close()
+			games = Games(gz, url)
+		default:
+			games = Games(body, url)
+		}
+		for game, err := range games {
+			if !yield(game, err) || err != nil {
+				return
+			}
+		}
+	}
+}
+
+// zipGames returns the games of every .pgn file in a zip archive read from r, saving it to a
+// temporary file to open it.
+func zipGames(r io.Reader, origin string) iter.Seq2[api.Game, error] {
+	return func(yield func(api.Game, error) bool) {
+		file, err := os.CreateTemp("", "pgnimport-*.zip")
+		if err != nil {
+			yield(api.Game{}, fmt.Errorf("failed to save zip archive %s: %w", origin, err))
+			return
+		}
+		defer os.Remove(file.Name())
+		defer file.Close()
+		size, err := io.Copy(file, r)
+		if err != nil {
+			yield(api.Game{}, fmt.Errorf("failed to download %s: %w", origin, err))
+			return
+		}
+		archive, err := zip.NewReader(file, size)
+		if err != nil {
+			yield(api.Game{}, fmt.Errorf("failed to open zip archive %s: %w", origin, err))
+			return
+		}
+		found := false
+		for _, entry := range archive.File {
+			if !strings.EqualFold(path.Ext(entry.Name), ".pgn") {
+				continue
+			}
+			f, err := entry.Open()
+			if err != nil {
+				yield(api.Game{}, fmt.Errorf("failed to open %s in %s: %w", entry.Name, origin, err))
+				return
+			}
+			for game, err := range Games(f, origin+"!"+entry.Name) {
+				if err != nil {
+					err = fmt.Errorf("failed to read %s in %s: %w", entry.Name, origin, err)
+				}
+				found = found || err == nil
+				if !yield(game, err) || err != nil {
+					f.Close()
+					return
+				}
+			}
+			f.Close()
+		}
+		if !found {
+			yield(api.Game{}, fmt.Errorf("no PGN games found in zip archive %s", origin))
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package query
+
+import (
+	gameengine "chessAnalyserFree/gameEngine"
+	"chessAnalyserFree/stats"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ThrownEndgame is a game the player entered the endgame winning in and then drew or lost.
+type ThrownEndgame struct {
+	Game       stats.AnalysedGame
+	Result     string  // "draw" or "loss".
+	Move       int     // The move number the endgame began at.
+	Evaluation float64 // In pawns from the player's point of view, entering the endgame.
+	// Slip is the first mistake or blunder of the player's in the endgame, e.g. "41. Rxe5
+	// (blunder)", or empty if none.
+	Slip string
+}
+
+// Conversion is how often a player won the games they entered the endgame winning in.
+type Conversion struct {
+	Player   string
+	Endgames int // Analysed games of the player's that reached an endgame.
+	Winning  int // Those the player entered the endgame in with gameengine.WinningEval pawns or more.
+	Won      int
+	Drawn    int
+	Lost     int
+	Thrown   []ThrownEndgame // The drawn and lost winning endgames, oldest first.
+}
+
+// EndgameConversion finds the analysed games of the player's that reached the endgame with the
+// player winning, gameengine.WinningEval pawns up or more by the evaluation of the first endgame
+// position, and counts how they ended.
+func EndgameConversion(games []stats.AnalysedGame, player string) Conversion {
+	conversion := Conversion{Player: player}
+	for _, analysed := range games {
+		outcome, ok := stats.Outcome(analysed.Game, player)
+		if analysed.Analysis == nil || !analysed.Analysis.IsValid() || !ok {
+			continue
+		}
+		colour := "white"
+		if strings.EqualFold(analysed.Game.Black.Username, player) {
+			colour = "black"
+		} else if !strings.EqualFold(analysed.Game.White.Username, player) {
+			continue
+		}
+		rows, err := MoveRows(analysed.Game, analysed.Analysis, "")
+		if err != nil {
+			log.Printf("Game %s left out of the endgame conversion: %v", analysed.Game.URL, err)
+			continue
+		}
+		entry := -1
+		for i, row := range rows {
+			if row.Phase == "endgame" {
+				entry = i
+				break
+			}
+		}
+		if entry < 0 || rows[entry].Move.Classification == gameengine.ClassBook {
+			continue
+		}
+		first := rows[entry]
+		conversion.Endgames++
+		// Evaluations are from the side to move's point of view.
+		evaluation := first.Move.Evaluation
+		if first.Color != colour {
+			evaluation = -evaluation
+		}
+		if evaluation < gameengine.WinningEval {
+			continue
+		}
+		conversion.Winning++
+		thrown := ThrownEndgame{Game: analysed, Move: gameengine.FullMoveNumber(first.FEN), Evaluation: evaluation}
+		switch outcome {
+		case 1:
+			conversion.Won++
+			continue
+		case 0:
+			conversion.Drawn++
+			thrown.Result = "draw"
+		default:
+			conversion.Lost++
+			thrown.Result = "loss"
+		}
+		for _, row := range rows[entry:] {
+			if row.Color == colour && (row.Move.Classification == gameengine.ClassMistake || row.Move.Classification == gameengine.ClassBlunder) {
+				dots := "."
+				if colour == "black" {
+					dots = "..."
+				}
+				thrown.Slip = fmt.Sprintf("%d%s %s (%s)", gameengine.FullMoveNumber(row.FEN), dots, row.SAN, row.Move.Classification)
+				break
+			}
+		}
+		conversion.Thrown = append(conversion.Thrown, thrown)
+	}
+	sort.SliceStable(conversion.Thrown, func(i, j int) bool {
+		return conversion.Thrown[i].Game.Game.EndTime < conversion.Thrown[j].Game.Game.EndTime
+	})
+	return conversion
+}
+
+// Tables returns the conversion of winning endgames for export.
+func (c Conversion) Tables() []stats.Table {
+	return []stats.Table{{
+		Name:    "endgame_conversion",
+		Columns: []string{"endgames", "winning", "won", "drawn", "lost"},
+		Rows:    [][]any{{c.Endgames, c.Winning, c.Won, c.Drawn, c.Lost}},
+	}}
+}
+
+// Write prints the conversion rate and the winning endgames that were drawn or lost.
+func (c Conversion) Write(w io.Writer) {
+	fmt.Fprintf(w, "\n--- Endgame conversion of %s (%d analysed games reaching an endgame) ---\n", c.Player, c.Endgames)
+	if c.Winning == 0 {
+		fmt.Fprintf(w, "No endgame entered with %.0f pawns or more.\n", gameengine.WinningEval)
+		fmt.Fprintln(w, "---------------------")
+		return
+	}
+	percent := func(n int) float64 { return 100 * float64(n) / float64(c.Winning) }
+	fmt.Fprintf(w, "Endgames entered %.0f pawns or more up: %d\n", gameengine.WinningEval, c.Winning)
+	fmt.Fprintf(w, "Converted: %d (%.0f%%), drawn: %d (%.0f%%), lost: %d (%.0f%%)\n", c.Won, percent(c.Won),
+		c.Drawn, percent(c.Drawn), c.Lost, percent(c.Lost))
+	if len(c.Thrown) > 0 {
+		fmt.Fprintln(w, "\nWinning endgames thrown away:")
+		for _, thrown := range c.Thrown {
+			game := thrown.Game.Game
+			fmt.Fprintf(w, "%s %-6s %-4s endgame from move %d at %+.1f", time.Unix(game.EndTime, 0).Format("2006-01-02"),
+				game.TimeClass, thrown.Result, thrown.Move, thrown.Evaluation)
+			if thrown.Slip != "" {
+				fmt.Fprintf(w, ", first went wrong with %s", thrown.Slip)
+			}
+			fmt.Fprintln(w)
+			if game.URL != "" {
+				fmt.Fprintf(w, "    %s\n", game.URL)
+			}
+		}
+	}
+	fmt.Fprintln(w, "---------------------")
+}
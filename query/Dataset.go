@@ -0,0 +1,153 @@
+package query
+
+import (
+	"chessAnalyserFree/api"
+	gameengine "chessAnalyserFree/gameEngine"
+	"chessAnalyserFree/stats"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// openingMoves is the last move number counted as opening, unless the position is already an endgame.
+const openingMoves = 12
+
+// endgamePieces is the largest number of queens, rooks, bishops and knights left on the board, for
+// both sides together, at which a position counts as an endgame.
+const endgamePieces = 6
+
+// Row is one analysed move together with the game it was played in; the move-level dataset that
+// queries run over.
+type Row struct {
+	Game   api.Game
+	Move   gameengine.MoveAnalysis
+	Ply    int
+	Color  string // Side that played the move: "white" or "black".
+	Phase  string // "opening", "middlegame" or "endgame", from the position before the move.
+	FEN    string // Position before the move.
+	SAN    string // Move played, in SAN.
+	Result string // Result for the side that played the move: "win", "draw", "loss", or "" if unknown.
+	// Clock is the time left on the mover's clock before the move, in seconds, from the [%clk]
+	// comment of their previous move; negative when unknown, as for their first move.
+	Clock float64
+}
+
+// Player returns the player who made the move.
+func (r Row) Player() api.Player {
+	if r.Color == "black" {
+		return r.Game.Black
+	}
+	return r.Game.White
+}
+
+// Opponent returns the player who did not make the move.
+func (r Row) Opponent() api.Player {
+	if r.Color == "black" {
+		return r.Game.White
+	}
+	return r.Game.Black
+}
+
+// field reads one value of a row; exactly one of text and number is set.
+type field struct {
+	text   func(Row) string
+	number func(Row) float64
+}
+
+// fields are the names usable in queries.
+var fields = map[string]field{
+	"timeclass": {text: func(r Row) string { return r.Game.TimeClass }},
+	"rules":     {text: func(r Row) string { return r.Game.Rules }},
+	"rated":     {text: func(r Row) string { return strconv.FormatBool(r.Game.Rated) }},
+	"player":    {text: func(r Row) string { return r.Player().Username }},
+	"opponent":  {text: func(r Row) string { return r.Opponent().Username }},
+	"color":     {text: func(r Row) string { return r.Color }},
+	"result":    {text: func(r Row) string { return r.Result }},
+	"phase":     {text: func(r Row) string { return r.Phase }},
+	"class":     {text: func(r Row) string { return r.Move.Classification }},
+	"played":    {text: func(r Row) string { return r.Move.Move }},
+	"cploss":    {number: func(r Row) float64 { return float64(r.Move.CentipawnLoss) }},
+	"material":  {number: func(r Row) float64 { return float64(r.Move.MaterialLoss) }},
+	"eval":      {number: func(r Row) float64 { return r.Move.Evaluation }},
+	"depth":     {number: func(r Row) float64 { return float64(r.Move.Depth) }},
+	"move":      {number: func(r Row) float64 { return float64(r.Move.MoveNumber) }},
+	"ply":       {number: func(r Row) float64 { return float64(r.Ply) }},
+	"rating":    {number: func(r Row) float64 { return float64(r.Player().Rating) }},
+	"opprating": {number: func(r Row) float64 { return float64(r.Opponent().Rating) }},
+}
+
+// FieldNames returns the names of the fields usable in queries, sorted.
+func FieldNames() []string {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// MoveRows turns an analysed game into rows, one per move. With a player, only that player's
+// moves are included.
+func MoveRows(game api.Game, analysis *gameengine.GameAnalysis, player string) ([]Row, error) {
+	positions, err := gameengine.ReplayPositions(game.PGN)
+	if err != nil {
+		return nil, err
+	}
+	if len(positions) != len(analysis.Moves) {
+		return nil, fmt.Errorf("analysis covers %d moves but the game has %d", len(analysis.Moves), len(positions))
+	}
+
+	var rows []Row
+	for i, position := range positions {
+		row := Row{
+			Game:  game,
+			Move:  analysis.Moves[i],
+			Ply:   position.Ply,
+			Color: "white",
+			Phase: phase(position.FEN),
+			FEN:   position.FEN,
+			SAN:   position.SAN,
+			Clock: -1,
+		}
+		if i >= 2 {
+			if seconds, ok := gameengine.ClockSeconds(positions[i-2].Clock); ok {
+				row.Clock = seconds
+			}
+		}
+		if fenFields := strings.Fields(position.FEN); len(fenFields) > 1 && fenFields[1] == "b" {
+			row.Color = "black"
+		}
+		if player != "" && !strings.EqualFold(row.Player().Username, player) {
+			continue
+		}
+		if outcome, ok := stats.Outcome(game, row.Player().Username); ok {
+			row.Result = map[int]string{1: "win", 0: "draw", -1: "loss"}[outcome]
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// phase classifies a position: an endgame once few pieces are left, otherwise the opening up to
+// move openingMoves and the middlegame after it.
+func phase(fen string) string {
+	fenFields := strings.Fields(fen)
+	if len(fenFields) == 0 {
+		return ""
+	}
+	pieces := 0
+	for _, c := range fenFields[0] {
+		if strings.ContainsRune("QRBNqrbn", c) {
+			pieces++
+		}
+	}
+	switch {
+	case pieces <= endgamePieces:
+		return "endgame"
+	case gameengine.FullMoveNumber(fen) <= openingMoves:
+		return "opening"
+	default:
+		return "middlegame"
+	}
+}
@@ -0,0 +1,198 @@
+package query
+
+import (
+	gameengine "chessAnalyserFree/gameEngine"
+	"chessAnalyserFree/stats"
+	"fmt"
+	"io"
+	"log"
+	"slices"
+	"sort"
+)
+
+// firstBlunderBuckets are the move ranges of the first-blunder distribution, by their last move.
+var firstBlunderBuckets = []struct {
+	name string
+	last int
+}{
+	{"1-10", 10},
+	{"11-20", 20},
+	{"21-30", 30},
+	{"31-40", 40},
+	{"41+", 1 << 30},
+}
+
+// firstBlunderOpenings is the number of openings listed, the most played first.
+const firstBlunderOpenings = 10
+
+// SurvivalGroup is the move of the first blunder in a group of games: all, one time class or one
+// opening.
+type SurvivalGroup struct {
+	Name   string
+	Games  int
+	Clean  int   // Games without a blunder.
+	Firsts []int // The move numbers of the first blunders of the other games, sorted.
+}
+
+// add counts a game whose first blunder was at move, or 0 without one.
+func (g *SurvivalGroup) add(move int) {
+	g.Games++
+	if move == 0 {
+		g.Clean++
+		return
+	}
+	i, _ := slices.BinarySearch(g.Firsts, move)
+	g.Firsts = slices.Insert(g.Firsts, i, move)
+}
+
+// Median returns the median move of the first blunders, or 0 without any.
+func (g SurvivalGroup) Median() float64 {
+	n := len(g.Firsts)
+	switch {
+	case n == 0:
+		return 0
+	case n%2 == 1:
+		return float64(g.Firsts[n/2])
+	default:
+		return float64(g.Firsts[n/2-1]+g.Firsts[n/2]) / 2
+	}
+}
+
+// FirstBlunders is when the first blunder of a player's comes in their analysed games.
+type FirstBlunders struct {
+	Player      string
+	All         SurvivalGroup
+	TimeClasses []SurvivalGroup // By time class name.
+	Openings    []SurvivalGroup // The most played first.
+}
+
+// FirstBlunderDistribution finds the move number of the player's first blunder in each analysed
+// game and groups the games by time class and by opening.
+func FirstBlunderDistribution(games []stats.AnalysedGame, player string) FirstBlunders {
+	report := FirstBlunders{Player: player, All: SurvivalGroup{Name: "all"}}
+	timeClasses := make(map[string]*SurvivalGroup)
+	openings := make(map[string]*SurvivalGroup)
+	group := func(groups map[string]*SurvivalGroup, name string) *SurvivalGroup {
+		if groups[name] == nil {
+			groups[name] = &SurvivalGroup{Name: name}
+		}
+		return groups[name]
+	}
+	for _, analysed := range games {
+		if analysed.Analysis == nil || !analysed.Analysis.IsValid() {
+			continue
+		}
+		rows, err := MoveRows(analysed.Game, analysed.Analysis, player)
+		if err != nil {
+			log.Printf("Game %s left out of the first blunders: %v", analysed.Game.URL, err)
+			continue
+		}
+		if len(rows) == 0 {
+			continue
+		}
+		first := 0
+		for _, row := range rows {
+			if row.Move.Classification == gameengine.ClassBlunder {
+				first = gameengine.FullMoveNumber(row.FEN)
+				break
+			}
+		}
+		report.All.add(first)
+		timeClass := analysed.Game.TimeClass
+		if timeClass == "" {
+			timeClass = "unknown"
+		}
+		group(timeClasses, timeClass).add(first)
+		group(openings, stats.OpeningLabel(analysed.Game)).add(first)
+	}
+	for _, g := range timeClasses {
+		report.TimeClasses = append(report.TimeClasses, *g)
+	}
+	sort.Slice(report.TimeClasses, func(i, j int) bool { return report.TimeClasses[i].Name < report.TimeClasses[j].Name })
+	for _, g := range openings {
+		report.Openings = append(report.Openings, *g)
+	}
+	sort.Slice(report.Openings, func(i, j int) bool {
+		if report.Openings[i].Games != report.Openings[j].Games {
+			return report.Openings[i].Games > report.Openings[j].Games
+		}
+		return report.Openings[i].Name < report.Openings[j].Name
+	})
+	return report
+}
+
+// Tables returns the games without a blunder and the median first blunder overall, by time class
+// and by opening, and the first blunders by move range, for export.
+func (f FirstBlunders) Tables() []stats.Table {
+	groups := stats.Table{Name: "first_blunders", Columns: []string{"group", "name", "games", "no_blunder", "median_move"}}
+	for _, list := range []struct {
+		name   string
+		groups []SurvivalGroup
+	}{{"all", []SurvivalGroup{f.All}}, {"time_class", f.TimeClasses}, {"opening", f.Openings}} {
+		for _, g := range list.groups {
+			median := any(nil)
+			if len(g.Firsts) > 0 {
+				median = g.Median()
+			}
+			groups.Rows = append(groups.Rows, []any{list.name, g.Name, g.Games, g.Clean, median})
+		}
+	}
+	moves := stats.Table{Name: "first_blunder_moves", Columns: []string{"moves", "games"}}
+	counts := f.byMoveRange()
+	for i, bucket := range firstBlunderBuckets {
+		moves.Rows = append(moves.Rows, []any{bucket.name, counts[i]})
+	}
+	moves.Rows = append(moves.Rows, []any{"none", f.All.Clean})
+	return []stats.Table{groups, moves}
+}
+
+// Write prints the distribution of the first blunder's move over all games, then the clean games
+// and the median first blunder by time class and by opening.
+func (f FirstBlunders) Write(w io.Writer) {
+	fmt.Fprintf(w, "\n--- First blunders of %s (%d analysed games) ---\n", f.Player, f.All.Games)
+	if f.All.Games == 0 {
+		fmt.Fprintln(w, "No analysed games of the player.")
+		fmt.Fprintln(w, "---------------------")
+		return
+	}
+	counts := f.byMoveRange()
+	fmt.Fprintln(w, "First blunder | Games | Share")
+	for i, bucket := range firstBlunderBuckets {
+		fmt.Fprintf(w, "moves %-7s | %5d | %4.0f%%\n", bucket.name, counts[i], 100*float64(counts[i])/float64(f.All.Games))
+	}
+	fmt.Fprintf(w, "%-13s | %5d | %4.0f%%\n", "no blunder", f.All.Clean, 100*float64(f.All.Clean)/float64(f.All.Games))
+
+	writeSurvival(w, "Time class", f.TimeClasses)
+	openings := f.Openings[:min(firstBlunderOpenings, len(f.Openings))]
+	writeSurvival(w, "Opening", openings)
+	if len(f.Openings) > len(openings) {
+		fmt.Fprintf(w, "%d more openings not shown.\n", len(f.Openings)-len(openings))
+	}
+	fmt.Fprintln(w, "---------------------")
+}
+
+// byMoveRange counts the games whose first blunder falls in each of firstBlunderBuckets.
+func (f FirstBlunders) byMoveRange() []int {
+	counts := make([]int, len(firstBlunderBuckets))
+	for _, move := range f.All.Firsts {
+		for i, bucket := range firstBlunderBuckets {
+			if move <= bucket.last {
+				counts[i]++
+				break
+			}
+		}
+	}
+	return counts
+}
+
+// writeSurvival prints a table of groups with their clean games and median first blunder.
+func writeSurvival(w io.Writer, title string, groups []SurvivalGroup) {
+	fmt.Fprintf(w, "\n%-30s | Games | No blunder  | Median first blunder\n", title)
+	for _, g := range groups {
+		median := "-"
+		if len(g.Firsts) > 0 {
+			median = fmt.Sprintf("move %g", g.Median())
+		}
+		fmt.Fprintf(w, "%-30.30s | %5d | %5d (%3.0f%%) | %s\n", g.Name, g.Games, g.Clean, 100*float64(g.Clean)/float64(g.Games), median)
+	}
+}
@@ -0,0 +1,287 @@
+package query
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Query is a parsed filter expression such as
+// "timeclass=blitz and result=loss and cploss>150 and phase=endgame".
+//
+// Conditions compare a field with a value using =, !=, <, <=, > or >=, and are combined with and,
+// or, not and parentheses; and binds tighter than or. Text fields are compared case-insensitively
+// and only support = and !=. Values containing spaces or operator characters are written in double
+// quotes.
+type Query struct {
+	source string
+	root   node
+}
+
+// node is a part of a parsed expression.
+type node interface {
+	match(row Row) bool
+}
+
+type andNode struct{ left, right node }
+type orNode struct{ left, right node }
+type notNode struct{ operand node }
+
+// condition compares one field of a row with a value.
+type condition struct {
+	field  field
+	op     string
+	number float64
+	text   string
+}
+
+func (n andNode) match(row Row) bool { return n.left.match(row) && n.right.match(row) }
+func (n orNode) match(row Row) bool  { return n.left.match(row) || n.right.match(row) }
+func (n notNode) match(row Row) bool { return !n.operand.match(row) }
+
+func (c condition) match(row Row) bool {
+	if c.field.text != nil {
+		equal := strings.EqualFold(c.field.text(row), c.text)
+		return equal == (c.op == "=")
+	}
+	value := c.field.number(row)
+	switch c.op {
+	case "=":
+		return value == c.number
+	case "!=":
+		return value != c.number
+	case "<":
+		return value < c.number
+	case "<=":
+		return value <= c.number
+	case ">":
+		return value > c.number
+	default:
+		return value >= c.number
+	}
+}
+
+// Parse parses a filter expression. An empty expression matches every row.
+func Parse(expr string) (*Query, error) {
+	tokens, err := tokenize(expr)
+	if err != nil {
+		return nil, err
+	}
+	q := &Query{source: strings.TrimSpace(expr)}
+	if len(tokens) == 0 {
+		return q, nil
+	}
+	p := &parser{tokens: tokens}
+	q.root, err = p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q at position %d", p.tokens[p.pos].text, p.tokens[p.pos].offset+1)
+	}
+	return q, nil
+}
+
+// Match reports whether a row satisfies the query.
+func (q *Query) Match(row Row) bool {
+	return q.root == nil || q.root.match(row)
+}
+
+// Filter returns the rows that satisfy the query.
+func (q *Query) Filter(rows []Row) []Row {
+	var matched []Row
+	for _, row := range rows {
+		if q.Match(row) {
+			matched = append(matched, row)
+		}
+	}
+	return matched
+}
+
+// String returns the expression the query was parsed from.
+func (q *Query) String() string {
+	return q.source
+}
+
+// tokenKind distinguishes operators from words.
+type tokenKind int
+
+const (
+	tokenWord tokenKind = iota
+	tokenString
+	tokenOperator
+	tokenOpen
+	tokenClose
+)
+
+type token struct {
+	kind   tokenKind
+	text   string
+	offset int
+}
+
+// operatorChars are the characters that end a bare word.
+const operatorChars = "()=!<>\""
+
+// tokenize splits an expression into words, quoted strings, comparison operators and parentheses.
+func tokenize(expr string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(expr); {
+		c := expr[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+		case c == '(':
+			tokens = append(tokens, token{tokenOpen, "(", i})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{tokenClose, ")", i})
+			i++
+		case c == '"':
+			end := strings.IndexByte(expr[i+1:], '"')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated string at position %d", i+1)
+			}
+			tokens = append(tokens, token{tokenString, expr[i+1 : i+1+end], i})
+			i += end + 2
+		case strings.IndexByte("=!<>", c) >= 0:
+			op := string(c)
+			if i+1 < len(expr) && expr[i+1] == '=' {
+				op += "="
+			}
+			if op == "!" {
+				return nil, fmt.Errorf("expected != at position %d", i+1)
+			}
+			tokens = append(tokens, token{tokenOperator, op, i})
+			i += len(op)
+		default:
+			start := i
+			for i < len(expr) && !strings.ContainsRune(" \t\n"+operatorChars, rune(expr[i])) {
+				i++
+			}
+			tokens = append(tokens, token{tokenWord, expr[start:i], start})
+		}
+	}
+	return tokens, nil
+}
+
+// parser is a recursive descent parser over the tokens of an expression.
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+// keyword reports whether the next token is the given keyword, consuming it if so.
+func (p *parser) keyword(word string) bool {
+	if p.pos < len(p.tokens) && p.tokens[p.pos].kind == tokenWord && strings.EqualFold(p.tokens[p.pos].text, word) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// next returns the next token, or an error naming what was expected at the end of the expression.
+func (p *parser) next(expected string) (token, error) {
+	if p.pos >= len(p.tokens) {
+		return token{}, fmt.Errorf("expected %s at the end of the query", expected)
+	}
+	p.pos++
+	return p.tokens[p.pos-1], nil
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("or") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("and") {
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = andNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseNot() (node, error) {
+	if p.keyword("not") {
+		operand, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{operand}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	tok, err := p.next("a condition")
+	if err != nil {
+		return nil, err
+	}
+	if tok.kind == tokenOpen {
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		closing, err := p.next(")")
+		if err != nil {
+			return nil, err
+		}
+		if closing.kind != tokenClose {
+			return nil, fmt.Errorf("expected ) at position %d, found %q", closing.offset+1, closing.text)
+		}
+		return inner, nil
+	}
+	if tok.kind != tokenWord {
+		return nil, fmt.Errorf("expected a field name at position %d, found %q", tok.offset+1, tok.text)
+	}
+
+	f, ok := fields[strings.ToLower(tok.text)]
+	if !ok {
+		return nil, fmt.Errorf("unknown field %q at position %d (fields: %s)", tok.text, tok.offset+1, strings.Join(FieldNames(), ", "))
+	}
+	op, err := p.next("a comparison after " + tok.text)
+	if err != nil {
+		return nil, err
+	}
+	if op.kind != tokenOperator {
+		return nil, fmt.Errorf("expected a comparison after %s at position %d, found %q", tok.text, op.offset+1, op.text)
+	}
+	value, err := p.next("a value after " + tok.text + op.text)
+	if err != nil {
+		return nil, err
+	}
+	if value.kind != tokenWord && value.kind != tokenString {
+		return nil, fmt.Errorf("expected a value at position %d, found %q", value.offset+1, value.text)
+	}
+
+	c := condition{field: f, op: op.text, text: value.text}
+	if f.text != nil {
+		if op.text != "=" && op.text != "!=" {
+			return nil, fmt.Errorf("%s is a text field and only supports = and != (position %d)", tok.text, op.offset+1)
+		}
+		return c, nil
+	}
+	c.number, err = strconv.ParseFloat(value.text, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%s is a number field, %q at position %d is not a number", tok.text, value.text, value.offset+1)
+	}
+	return c, nil
+}
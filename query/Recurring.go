@@ -0,0 +1,166 @@
+package query
+
+import (
+	gameengine "chessAnalyserFree/gameEngine"
+	"chessAnalyserFree/stats"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+// recurringShown is the number of recurring mistakes listed.
+const recurringShown = 10
+
+// Slip is one mistake or blunder of a recurring mistake.
+type Slip struct {
+	Game stats.AnalysedGame
+	SAN  string
+	Move gameengine.MoveAnalysis
+	game int // Index of the game in the analysed games.
+}
+
+// RecurringMistake is a position in which a player went wrong in more than one game.
+type RecurringMistake struct {
+	Opening  string // The opening of the first game it happened in, as stats.OpeningLabel names it.
+	Move     int    // The move number.
+	Colour   string // "white" or "black".
+	FEN      string // The position, as it was first reached.
+	BestMove string // The engine's recommended move in SAN, from the first slip that has one.
+	Slips    []Slip // Oldest first.
+}
+
+// Games returns the number of games the player went wrong in.
+func (m RecurringMistake) Games() int {
+	games := make(map[int]bool)
+	for _, slip := range m.Slips {
+		games[slip.game] = true
+	}
+	return len(games)
+}
+
+// Loss returns the centipawns lost, summed over the slips.
+func (m RecurringMistake) Loss() int {
+	loss := 0
+	for _, slip := range m.Slips {
+		loss += slip.Move.CentipawnLoss
+	}
+	return loss
+}
+
+// RecurringReport is the positions in which a player keeps going wrong.
+type RecurringReport struct {
+	Player   string
+	Games    int
+	Mistakes []RecurringMistake // The most games first, then the most centipawns lost.
+}
+
+// RecurringMistakes groups the mistakes and blunders of the player in the analysed games by the
+// position they were made in, so that a position reached again by the same line or a
+// transposition falls in the same group, and keeps the positions they went wrong in more than
+// once. Positions compare by the pieces, the side to move and the castling rights.
+func RecurringMistakes(games []stats.AnalysedGame, player string) RecurringReport {
+	report := RecurringReport{Player: player}
+	byPosition := make(map[string]*RecurringMistake)
+	for i, analysed := range games {
+		if analysed.Analysis == nil || !analysed.Analysis.IsValid() {
+			continue
+		}
+		rows, err := MoveRows(analysed.Game, analysed.Analysis, player)
+		if err != nil {
+			log.Printf("Game %s left out of the recurring mistakes: %v", analysed.Game.URL, err)
+			continue
+		}
+		if len(rows) == 0 {
+			continue
+		}
+		report.Games++
+		for _, row := range rows {
+			if row.Move.Classification != gameengine.ClassMistake && row.Move.Classification != gameengine.ClassBlunder {
+				continue
+			}
+			key := positionKey(row.FEN)
+			mistake := byPosition[key]
+			if mistake == nil {
+				mistake = &RecurringMistake{Opening: stats.OpeningLabel(row.Game), Move: gameengine.FullMoveNumber(row.FEN),
+					Colour: row.Color, FEN: row.FEN}
+				byPosition[key] = mistake
+			}
+			if mistake.BestMove == "" && row.Move.BestMove != "" {
+				mistake.BestMove = gameengine.MoveSAN(row.FEN, row.Move.BestMove)
+			}
+			mistake.Slips = append(mistake.Slips, Slip{Game: analysed, SAN: row.SAN, Move: row.Move, game: i})
+		}
+	}
+	for _, mistake := range byPosition {
+		if mistake.Games() < 2 {
+			continue
+		}
+		sort.SliceStable(mistake.Slips, func(a, b int) bool {
+			return mistake.Slips[a].Game.Game.EndTime < mistake.Slips[b].Game.Game.EndTime
+		})
+		report.Mistakes = append(report.Mistakes, *mistake)
+	}
+	sort.Slice(report.Mistakes, func(i, j int) bool {
+		a, b := report.Mistakes[i], report.Mistakes[j]
+		if a.Games() != b.Games() {
+			return a.Games() > b.Games()
+		}
+		if a.Loss() != b.Loss() {
+			return a.Loss() > b.Loss()
+		}
+		return a.FEN < b.FEN
+	})
+	return report
+}
+
+// positionKey returns the fields of a FEN that make two positions the same for the recurring
+// mistakes: the pieces, the side to move and the castling rights.
+func positionKey(fen string) string {
+	fields := strings.Fields(fen)
+	return strings.Join(fields[:min(3, len(fields))], " ")
+}
+
+// Write prints the first recurringShown recurring mistakes: where they happened, the moves played
+// with their classifications, the engine's recommendation once for all of them, and the games.
+func (r RecurringReport) Write(w io.Writer) {
+	fmt.Fprintf(w, "\n--- Recurring mistakes of %s (%d analysed games) ---\n", r.Player, r.Games)
+	if len(r.Mistakes) == 0 {
+		fmt.Fprintln(w, "No position in which the player went wrong in more than one game.")
+		fmt.Fprintln(w, "---------------------")
+		return
+	}
+	for n, mistake := range r.Mistakes[:min(recurringShown, len(r.Mistakes))] {
+		colour := map[string]string{"white": "White", "black": "Black"}[mistake.Colour]
+		fmt.Fprintf(w, "\n%d. Move %d of %s, as %s: went wrong in %d games, %d centipawns lost\n", n+1, mistake.Move,
+			mistake.Opening, colour, mistake.Games(), mistake.Loss())
+		var played []string
+		counts := make(map[string]int)
+		for _, slip := range mistake.Slips {
+			name := slip.SAN + " (" + slip.Move.Classification + ")"
+			if counts[name] == 0 {
+				played = append(played, name)
+			}
+			counts[name]++
+		}
+		for i, name := range played {
+			if counts[name] > 1 {
+				played[i] = fmt.Sprintf("%s x%d", name, counts[name])
+			}
+		}
+		fmt.Fprintf(w, "   Played:  %s\n", strings.Join(played, ", "))
+		if mistake.BestMove != "" {
+			fmt.Fprintf(w, "   Instead: %s\n", mistake.BestMove)
+		}
+		fmt.Fprintf(w, "   FEN:     %s\n", mistake.FEN)
+		for _, slip := range mistake.Slips {
+			fmt.Fprintf(w, "   %s %s\n", time.Unix(slip.Game.Game.EndTime, 0).Format("2006-01-02"), slip.Game.Game.URL)
+		}
+	}
+	if len(r.Mistakes) > recurringShown {
+		fmt.Fprintf(w, "\n%d more recurring mistakes not shown.\n", len(r.Mistakes)-recurringShown)
+	}
+	fmt.Fprintln(w, "---------------------")
+}
@@ -0,0 +1,168 @@
+package query
+
+import (
+	gameengine "chessAnalyserFree/gameEngine"
+	"chessAnalyserFree/stats"
+	"fmt"
+	"io"
+	"log"
+	"slices"
+	"sort"
+)
+
+// Clock limits, in seconds, below which a player is in time trouble and in a time scramble.
+const (
+	timeTroubleSeconds  = 30
+	timeScrambleSeconds = 10
+)
+
+// clockBands are the clock bands of the time-trouble report, from the most time left down.
+var clockBands = []string{"30s or more", "10s to 30s", "under 10s"}
+
+// ClockBand is a player's moves made with a clock in one band.
+type ClockBand struct {
+	Name     string
+	Moves    int
+	Mistakes int
+	Blunders int
+}
+
+// BlunderRate returns the percentage of the moves that were blunders, or 0 without moves.
+func (b ClockBand) BlunderRate() float64 {
+	if b.Moves == 0 {
+		return 0
+	}
+	return 100 * float64(b.Blunders) / float64(b.Moves)
+}
+
+// add counts a move.
+func (b *ClockBand) add(move gameengine.MoveAnalysis) {
+	b.Moves++
+	switch move.Classification {
+	case gameengine.ClassMistake:
+		b.Mistakes++
+	case gameengine.ClassBlunder:
+		b.Blunders++
+	}
+}
+
+// TimeClassTrouble is a player's moves in one time class by the clock they were made with.
+type TimeClassTrouble struct {
+	TimeClass string
+	Games     int
+	Bands     []ClockBand // One per band, in the order of clockBands.
+}
+
+// TimeTroubleReport is how a player's blunder rate changes as their clock runs down, by time class.
+type TimeTroubleReport struct {
+	Player      string
+	Games       int // Analysed games with clock times.
+	TimeClasses []TimeClassTrouble
+}
+
+// TimeTrouble sorts the moves of the player in the analysed games into clock bands by the time
+// they had left, from the [%clk] comments of the games, for each time class. Book moves, moves
+// without a known clock and daily games, whose clocks run in days, are left out.
+func TimeTrouble(games []stats.AnalysedGame, player string) TimeTroubleReport {
+	report := TimeTroubleReport{Player: player}
+	byClass := map[string]*TimeClassTrouble{}
+	for _, analysed := range games {
+		if analysed.Analysis == nil || !analysed.Analysis.IsValid() || analysed.Game.TimeClass == "daily" {
+			continue
+		}
+		rows, err := MoveRows(analysed.Game, analysed.Analysis, player)
+		if err != nil {
+			log.Printf("Game %s left out of the time-trouble report: %v", analysed.Game.URL, err)
+			continue
+		}
+		timeClass := analysed.Game.TimeClass
+		if timeClass == "" {
+			timeClass = "unknown"
+		}
+		var class *TimeClassTrouble
+		for _, row := range rows {
+			if row.Clock < 0 || row.Move.Classification == gameengine.ClassBook {
+				continue
+			}
+			if class == nil {
+				if byClass[timeClass] == nil {
+					byClass[timeClass] = &TimeClassTrouble{TimeClass: timeClass, Bands: make([]ClockBand, len(clockBands))}
+					for i, name := range clockBands {
+						byClass[timeClass].Bands[i].Name = name
+					}
+				}
+				class = byClass[timeClass]
+				class.Games++
+				report.Games++
+			}
+			switch {
+			case row.Clock < timeScrambleSeconds:
+				class.Bands[2].add(row.Move)
+			case row.Clock < timeTroubleSeconds:
+				class.Bands[1].add(row.Move)
+			default:
+				class.Bands[0].add(row.Move)
+			}
+		}
+	}
+	for _, class := range byClass {
+		report.TimeClasses = append(report.TimeClasses, *class)
+	}
+	// Bullet, blitz and rapid first, then any other time class by name.
+	rank := func(timeClass string) int {
+		if i := slices.Index([]string{"bullet", "blitz", "rapid"}, timeClass); i >= 0 {
+			return i
+		}
+		return 3
+	}
+	sort.Slice(report.TimeClasses, func(i, j int) bool {
+		a, b := report.TimeClasses[i].TimeClass, report.TimeClasses[j].TimeClass
+		if rank(a) != rank(b) {
+			return rank(a) < rank(b)
+		}
+		return a < b
+	})
+	return report
+}
+
+// Tables returns the moves by clock band and time class for export.
+func (r TimeTroubleReport) Tables() []stats.Table {
+	table := stats.Table{Name: "time_trouble", Columns: []string{"time_class", "clock", "games", "moves", "mistakes", "blunders", "blunder_rate"}}
+	for _, class := range r.TimeClasses {
+		for _, band := range class.Bands {
+			table.Rows = append(table.Rows, []any{class.TimeClass, band.Name, class.Games, band.Moves, band.Mistakes, band.Blunders,
+				band.BlunderRate()})
+		}
+	}
+	return []stats.Table{table}
+}
+
+// Write prints a table of the clock bands for each time class and the blunder rate in time trouble
+// against the one with time to spare.
+func (r TimeTroubleReport) Write(w io.Writer) {
+	fmt.Fprintf(w, "\n--- Blunders in time trouble of %s (%d analysed games with clock times) ---\n", r.Player, r.Games)
+	if r.Games == 0 {
+		fmt.Fprintln(w, "No analysed games of the player with clock times.")
+		fmt.Fprintln(w, "---------------------")
+		return
+	}
+	for _, class := range r.TimeClasses {
+		fmt.Fprintf(w, "\n%s (%d games):\n", class.TimeClass, class.Games)
+		fmt.Fprintln(w, "Clock       | Moves | Mistakes | Blunders | Blunder rate")
+		for _, band := range class.Bands {
+			fmt.Fprintf(w, "%-11s | %5d | %8d | %8d | %11.1f%%\n", band.Name, band.Moves, band.Mistakes, band.Blunders, band.BlunderRate())
+		}
+		calm := class.Bands[0]
+		trouble := ClockBand{Moves: class.Bands[1].Moves + class.Bands[2].Moves, Blunders: class.Bands[1].Blunders + class.Bands[2].Blunders}
+		if calm.Moves == 0 || trouble.Moves == 0 {
+			fmt.Fprintln(w, "Not enough moves on both sides of 30 seconds to compare.")
+			continue
+		}
+		often := ""
+		if calm.Blunders > 0 && trouble.BlunderRate() > calm.BlunderRate() {
+			often = fmt.Sprintf(", %.1f times as often", trouble.BlunderRate()/calm.BlunderRate())
+		}
+		fmt.Fprintf(w, "Blunder rate below 30 seconds: %.1f%%, against %.1f%% with more time%s.\n", trouble.BlunderRate(), calm.BlunderRate(), often)
+	}
+	fmt.Fprintln(w, "---------------------")
+}
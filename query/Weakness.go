@@ -0,0 +1,242 @@
+package query
+
+import (
+	gameengine "chessAnalyserFree/gameEngine"
+	"chessAnalyserFree/stats"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strings"
+)
+
+// phases are the game phases of the weakness report, in order.
+var phases = []string{"opening", "middlegame", "endgame"}
+
+// leaksShown is the number of openings and endgame types listed for each colour.
+const leaksShown = 3
+
+// Leak is the evaluation a player lost in a set of moves: a phase, an opening or an endgame type.
+type Leak struct {
+	Name     string
+	Moves    int
+	Loss     int // Centipawns lost, summed over the moves.
+	Mistakes int
+	Blunders int
+	games    map[int]bool
+}
+
+// Games returns the number of games the moves were played in.
+func (l Leak) Games() int {
+	return len(l.games)
+}
+
+// AverageLoss returns the average centipawn loss per move.
+func (l Leak) AverageLoss() float64 {
+	if l.Moves == 0 {
+		return 0
+	}
+	return float64(l.Loss) / float64(l.Moves)
+}
+
+// add counts a move of the game numbered game.
+func (l *Leak) add(game int, move gameengine.MoveAnalysis) {
+	if l.games == nil {
+		l.games = make(map[int]bool)
+	}
+	l.games[game] = true
+	l.Moves++
+	l.Loss += move.CentipawnLoss
+	switch move.Classification {
+	case gameengine.ClassMistake:
+		l.Mistakes++
+	case gameengine.ClassBlunder:
+		l.Blunders++
+	}
+}
+
+// ColourWeakness is where a player lost evaluation with one colour.
+type ColourWeakness struct {
+	Colour   string // "white" or "black".
+	All      Leak
+	Phases   []Leak // One per phase, in the order of phases.
+	Openings []Leak // The opening phase by opening, the most centipawns lost first.
+	Endgames []Leak // The endgame by type, such as "rook endgame", the most centipawns lost first.
+}
+
+// WeaknessReport is where a player typically loses evaluation in their analysed games, by phase,
+// opening and endgame type, for each colour.
+type WeaknessReport struct {
+	Player  string
+	Games   int
+	Colours []ColourWeakness // White, then Black.
+}
+
+// Weaknesses sums up the centipawns the player lost in the analysed games by the colour they had and
+// by phase, and within the opening and endgame phases by opening and endgame type. Games whose
+// analysis does not fit them are left out.
+func Weaknesses(games []stats.AnalysedGame, player string) WeaknessReport {
+	report := WeaknessReport{Player: player}
+	type colourLeaks struct {
+		all      Leak
+		phases   map[string]*Leak
+		openings map[string]*Leak
+		endgames map[string]*Leak
+	}
+	colours := map[string]*colourLeaks{}
+	for _, colour := range []string{"white", "black"} {
+		colours[colour] = &colourLeaks{phases: map[string]*Leak{}, openings: map[string]*Leak{}, endgames: map[string]*Leak{}}
+	}
+	leak := func(leaks map[string]*Leak, name string) *Leak {
+		if leaks[name] == nil {
+			leaks[name] = &Leak{Name: name}
+		}
+		return leaks[name]
+	}
+	for i, analysed := range games {
+		if analysed.Analysis == nil || !analysed.Analysis.IsValid() {
+			continue
+		}
+		rows, err := MoveRows(analysed.Game, analysed.Analysis, player)
+		if err != nil {
+			log.Printf("Game %s left out of the weakness report: %v", analysed.Game.URL, err)
+			continue
+		}
+		if len(rows) == 0 {
+			continue
+		}
+		report.Games++
+		for _, row := range rows {
+			if row.Move.Classification == gameengine.ClassBook {
+				continue
+			}
+			c := colours[row.Color]
+			c.all.add(i, row.Move)
+			leak(c.phases, row.Phase).add(i, row.Move)
+			switch row.Phase {
+			case "opening":
+				leak(c.openings, stats.OpeningLabel(row.Game)).add(i, row.Move)
+			case "endgame":
+				leak(c.endgames, endgameType(row.FEN)).add(i, row.Move)
+			}
+		}
+	}
+	for _, colour := range []string{"white", "black"} {
+		c := colours[colour]
+		weakness := ColourWeakness{Colour: colour, All: c.all}
+		for _, phase := range phases {
+			weakness.Phases = append(weakness.Phases, *leak(c.phases, phase))
+		}
+		weakness.Openings = mostLost(c.openings)
+		weakness.Endgames = mostLost(c.endgames)
+		report.Colours = append(report.Colours, weakness)
+	}
+	return report
+}
+
+// mostLost returns the leaks with the most centipawns lost first.
+func mostLost(leaks map[string]*Leak) []Leak {
+	sorted := make([]Leak, 0, len(leaks))
+	for _, leak := range leaks {
+		sorted = append(sorted, *leak)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Loss != sorted[j].Loss {
+			return sorted[i].Loss > sorted[j].Loss
+		}
+		return sorted[i].Name < sorted[j].Name
+	})
+	return sorted
+}
+
+// endgameType names an endgame by the pieces left besides kings and pawns, for both sides together:
+// "pawn endgame", "rook endgame", "minor piece endgame", "rook and minor piece endgame" and so on.
+func endgameType(fen string) string {
+	placement, _, _ := strings.Cut(fen, " ")
+	var kinds []string
+	for _, kind := range []struct {
+		name   string
+		pieces string
+	}{{"queen", "Qq"}, {"rook", "Rr"}, {"minor piece", "BNbn"}} {
+		if strings.ContainsAny(placement, kind.pieces) {
+			kinds = append(kinds, kind.name)
+		}
+	}
+	switch len(kinds) {
+	case 0:
+		return "pawn endgame"
+	case 1:
+		return kinds[0] + " endgame"
+	default:
+		return strings.Join(kinds[:len(kinds)-1], ", ") + " and " + kinds[len(kinds)-1] + " endgame"
+	}
+}
+
+// Tables returns the evaluation lost by colour in every phase, opening and endgame type for export.
+func (r WeaknessReport) Tables() []stats.Table {
+	table := stats.Table{Name: "weaknesses", Columns: []string{"colour", "kind", "name", "games", "moves", "centipawns_lost",
+		"average_loss", "mistakes", "blunders"}}
+	for _, c := range r.Colours {
+		for _, leaks := range []struct {
+			kind  string
+			leaks []Leak
+		}{{"phase", c.Phases}, {"opening", c.Openings}, {"endgame", c.Endgames}} {
+			for _, leak := range leaks.leaks {
+				table.Rows = append(table.Rows, []any{c.Colour, leaks.kind, leak.Name, leak.Games(), leak.Moves, leak.Loss,
+					leak.AverageLoss(), leak.Mistakes, leak.Blunders})
+			}
+		}
+	}
+	return []stats.Table{table}
+}
+
+// Write prints the report: for each colour a table of the phases, the weakest phase, and the
+// openings and endgame types in which the most evaluation was lost.
+func (r WeaknessReport) Write(w io.Writer) {
+	fmt.Fprintf(w, "\n--- Where %s loses evaluation (%d analysed games) ---\n", r.Player, r.Games)
+	if r.Games == 0 {
+		fmt.Fprintln(w, "No analysed games of the player.")
+		fmt.Fprintln(w, "---------------------")
+		return
+	}
+	for _, c := range r.Colours {
+		if c.All.Moves == 0 {
+			continue
+		}
+		colour := map[string]string{"white": "White", "black": "Black"}[c.Colour]
+		fmt.Fprintf(w, "\nAs %s: %d games, %d moves, %.1f centipawns lost per move\n", colour, c.All.Games(), c.All.Moves, c.All.AverageLoss())
+		fmt.Fprintln(w, "Phase      | Moves | Avg loss | Share of loss | Mistakes | Blunders")
+		weakest := c.Phases[0]
+		for _, phase := range c.Phases {
+			share := 0.0
+			if c.All.Loss > 0 {
+				share = 100 * float64(phase.Loss) / float64(c.All.Loss)
+			}
+			fmt.Fprintf(w, "%-10s | %5d | %8.1f | %12.0f%% | %8d | %8d\n", phase.Name, phase.Moves, phase.AverageLoss(),
+				share, phase.Mistakes, phase.Blunders)
+			if phase.AverageLoss() > weakest.AverageLoss() {
+				weakest = phase
+			}
+		}
+		if weakest.Moves > 0 {
+			fmt.Fprintf(w, "Weakest phase: the %s, %.1f centipawns lost per move.\n", weakest.Name, weakest.AverageLoss())
+		}
+		writeLeaks(w, "Openings losing the most in the opening:", c.Openings)
+		writeLeaks(w, "Endgames losing the most:", c.Endgames)
+	}
+	fmt.Fprintln(w, "---------------------")
+}
+
+// writeLeaks prints the first leaksShown leaks that lost evaluation under a title.
+func writeLeaks(w io.Writer, title string, leaks []Leak) {
+	if len(leaks) == 0 || leaks[0].Loss == 0 {
+		return
+	}
+	fmt.Fprintln(w, title)
+	for _, leak := range leaks[:min(leaksShown, len(leaks))] {
+		if leak.Loss == 0 {
+			break
+		}
+		fmt.Fprintf(w, "  %s: %d centipawns over %d games, %.1f per move\n", leak.Name, leak.Loss, leak.Games(), leak.AverageLoss())
+	}
+}
@@ -0,0 +1,129 @@
+package references
+
+import (
+	"chessAnalyserFree/api"
+	gameengine "chessAnalyserFree/gameEngine"
+)
+
+// thresholdStep is the granularity, in centipawns, of the suggested thresholds.
+const thresholdStep = 5
+
+// maxLoss is the largest centipawn loss a move can have, as both evaluations are clamped.
+const maxLoss = 2000
+
+// Side compares one side of a reference game: its published figures and the measured ones.
+type Side struct {
+	Game      api.Game
+	White     bool
+	Published Published
+	Measured  gameengine.SideAccuracy
+}
+
+// Comparison collects the sides of the analysed reference games.
+type Comparison struct {
+	Sides []Side
+}
+
+// Deviation summarises the differences between measured and published figures.
+type Deviation struct {
+	Samples int
+	Mean    float64 // Mean of measured minus published; positive when the pipeline reads higher.
+	MeanAbs float64 // Mean absolute difference.
+}
+
+// Add compares both sides of a reference game with its analysis.
+func (c *Comparison) Add(reference Reference, analysis *gameengine.GameAnalysis) {
+	white, black := gameengine.GameAccuracy(reference.Game.PGN, analysis)
+	c.Sides = append(c.Sides,
+		Side{Game: reference.Game, White: true, Published: reference.White, Measured: white},
+		Side{Game: reference.Game, White: false, Published: reference.Black, Measured: black})
+}
+
+// ACPL returns the deviation of the average centipawn losses.
+func (c *Comparison) ACPL() Deviation {
+	return c.deviation(func(s Side) (float64, float64, bool) {
+		return s.Measured.ACPL, s.Published.ACPL, s.Measured.Moves > 0
+	})
+}
+
+// Accuracy returns the deviation of the accuracies, over the sides with a published accuracy.
+func (c *Comparison) Accuracy() Deviation {
+	return c.deviation(func(s Side) (float64, float64, bool) {
+		return s.Measured.Accuracy, s.Published.Accuracy, s.Measured.Moves > 0 && s.Published.Accuracy > 0
+	})
+}
+
+// deviation computes the deviation of the figure the pick function returns for the sides it accepts.
+func (c *Comparison) deviation(pick func(Side) (measured, published float64, ok bool)) Deviation {
+	var d Deviation
+	for _, side := range c.Sides {
+		measured, published, ok := pick(side)
+		if !ok {
+			continue
+		}
+		d.Samples++
+		d.Mean += measured - published
+		d.MeanAbs += max(measured-published, published-measured)
+	}
+	if d.Samples > 0 {
+		d.Mean /= float64(d.Samples)
+		d.MeanAbs /= float64(d.Samples)
+	}
+	return d
+}
+
+// Counts returns the total inaccuracies, mistakes and blunders, measured and published.
+func (c *Comparison) Counts() (measured, published [3]int) {
+	for _, side := range c.Sides {
+		measured[0] += side.Measured.Inaccuracies
+		measured[1] += side.Measured.Mistakes
+		measured[2] += side.Measured.Blunders
+		published[0] += side.Published.Inaccuracies
+		published[1] += side.Published.Mistakes
+		published[2] += side.Published.Blunders
+	}
+	return measured, published
+}
+
+// SuggestThresholds returns the classification thresholds under which the measured centipawn losses
+// reproduce the published inaccuracy, mistake and blunder counts most closely. Each threshold is
+// fitted on the moves losing at least that much, from the blunder threshold down, so that they stay
+// in order; among equally good values the one closest to the current threshold wins.
+func (c *Comparison) SuggestThresholds(current gameengine.Thresholds) gameengine.Thresholds {
+	var t gameengine.Thresholds
+	t.Blunder = c.fit(func(p Published) int { return p.Blunders }, 3*thresholdStep, maxLoss, current.Blunder)
+	t.Mistake = c.fit(func(p Published) int { return p.Blunders + p.Mistakes }, 2*thresholdStep, t.Blunder-thresholdStep, current.Mistake)
+	t.Inaccuracy = c.fit(func(p Published) int { return p.Blunders + p.Mistakes + p.Inaccuracies }, thresholdStep, t.Mistake-thresholdStep, current.Inaccuracy)
+	return t
+}
+
+// fit returns the threshold between low and high at which the number of moves losing at least the
+// threshold is closest, summed over the sides, to the published count.
+func (c *Comparison) fit(published func(Published) int, low, high, current int) int {
+	best := min(max(current, low), high)
+	bestError := -1
+	for threshold := low; threshold <= high; threshold += thresholdStep {
+		total := 0
+		for _, side := range c.Sides {
+			n := 0
+			for _, loss := range side.Measured.Losses {
+				if loss >= threshold {
+					n++
+				}
+			}
+			total += abs(n - published(side.Published))
+		}
+		if bestError < 0 || total < bestError || total == bestError && abs(threshold-current) < abs(best-current) {
+			best, bestError = threshold, total
+		}
+	}
+	return best
+}
+
+// abs returns the absolute value of n.
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,112 @@
+// Package references compares the analysis pipeline's accuracy figures with the ones a reference
+// site published for the same games, and suggests classification thresholds that match its counts.
+package references
+
+import (
+	"chessAnalyserFree/api"
+	pgnimport "chessAnalyserFree/pgnImport"
+	_ "embed"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// bundled is the bundled reference set, built with the calibrate command's --fetch option from
+// games analysed on Lichess.
+//
+//go:embed bundled.pgn
+var bundled string
+
+// Published are the figures a reference site published for one side of a game.
+type Published struct {
+	ACPL         float64
+	Accuracy     float64 // 0 when the site published no accuracy.
+	Inaccuracies int
+	Mistakes     int
+	Blunders     int
+}
+
+// Reference is a game along with the published figures of both sides.
+type Reference struct {
+	Game  api.Game
+	White Published
+	Black Published
+}
+
+// Bundled returns the bundled reference set.
+func Bundled() ([]Reference, error) {
+	return Read(strings.NewReader(bundled), "bundled")
+}
+
+// Read reads reference games from a PGN stream. Every game carries its figures in tags named after
+// the side: WhiteACPL, WhiteAccuracy (optional), WhiteInaccuracies, WhiteMistakes, WhiteBlunders and
+// the same for Black.
+func Read(r io.Reader, origin string) ([]Reference, error) {
+	games, err := pgnimport.ReadGames(r, origin)
+	if err != nil {
+		return nil, err
+	}
+	references := make([]Reference, 0, len(games))
+	for i, game := range games {
+		tags := pgnimport.ParseTags(game.PGN)
+		white, err := parsePublished(tags, "White")
+		if err != nil {
+			return nil, fmt.Errorf("%s game %d: %w", origin, i+1, err)
+		}
+		black, err := parsePublished(tags, "Black")
+		if err != nil {
+			return nil, fmt.Errorf("%s game %d: %w", origin, i+1, err)
+		}
+		references = append(references, Reference{Game: game, White: white, Black: black})
+	}
+	return references, nil
+}
+
+// parsePublished reads one side's figures from the reference tags.
+func parsePublished(tags map[string]string, side string) (Published, error) {
+	var p Published
+	var err error
+	if p.ACPL, err = strconv.ParseFloat(tags[side+"ACPL"], 64); err != nil {
+		return p, fmt.Errorf("missing or invalid %sACPL tag", side)
+	}
+	if accuracy := tags[side+"Accuracy"]; accuracy != "" {
+		if p.Accuracy, err = strconv.ParseFloat(accuracy, 64); err != nil {
+			return p, fmt.Errorf("invalid %sAccuracy tag", side)
+		}
+	}
+	for name, count := range map[string]*int{"Inaccuracies": &p.Inaccuracies, "Mistakes": &p.Mistakes, "Blunders": &p.Blunders} {
+		if *count, err = strconv.Atoi(tags[side+name]); err != nil {
+			return p, fmt.Errorf("missing or invalid %s%s tag", side, name)
+		}
+	}
+	return p, nil
+}
+
+// Format returns a game's PGN with the reference tags of both sides added after its other tags,
+// in the form Read expects.
+func Format(pgn string, white, black Published) string {
+	var tags []string
+	for _, side := range []struct {
+		name string
+		p    Published
+	}{{"White", white}, {"Black", black}} {
+		tags = append(tags,
+			fmt.Sprintf("[%sACPL \"%s\"]", side.name, strconv.FormatFloat(side.p.ACPL, 'f', -1, 64)),
+			fmt.Sprintf("[%sInaccuracies \"%d\"]", side.name, side.p.Inaccuracies),
+			fmt.Sprintf("[%sMistakes \"%d\"]", side.name, side.p.Mistakes),
+			fmt.Sprintf("[%sBlunders \"%d\"]", side.name, side.p.Blunders))
+		if side.p.Accuracy > 0 {
+			tags = append(tags, fmt.Sprintf("[%sAccuracy \"%s\"]", side.name, strconv.FormatFloat(side.p.Accuracy, 'f', -1, 64)))
+		}
+	}
+
+	lines := strings.Split(strings.TrimSpace(pgn), "\n")
+	end := 0
+	for end < len(lines) && strings.HasPrefix(strings.TrimSpace(lines[end]), "[") {
+		end++
+	}
+	lines = slices.Insert(lines, end, tags...)
+	return strings.Join(lines, "\n") + "\n"
+}
@@ -0,0 +1,114 @@
+package report
+
+import (
+	"chessAnalyserFree/api"
+	gameengine "chessAnalyserFree/gameEngine"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// tagLineRegex matches a PGN tag pair line.
+var tagLineRegex = regexp.MustCompile(`^\[(\w+)\s+"(.*)"\]\s*$`)
+
+// classificationNAGs maps move classifications onto PGN numeric annotation glyphs.
+var classificationNAGs = map[string]string{
+	gameengine.ClassSacrifice:  "$1", // !
+	gameengine.ClassInaccuracy: "$6", // ?!
+	gameengine.ClassMistake:    "$2", // ?
+	gameengine.ClassBlunder:    "$4", // ??
+}
+
+// AnnotatedPGN returns the game's PGN with the analysis added: the original tags plus an Annotator
+// tag, moves in SAN, a NAG for every sacrifice, inaccuracy, mistake and blunder, and an [%eval] comment from
+// White's point of view after each analysed move, as understood by Lichess and most GUIs.
+func AnnotatedPGN(game api.Game, analysis *gameengine.GameAnalysis) (string, error) {
+	positions, err := gameengine.ReplayPositions(game.PGN)
+	if err != nil {
+		return "", err
+	}
+	if len(analysis.Moves) != len(positions) {
+		return "", fmt.Errorf("analysis covers %d moves but the game has %d", len(analysis.Moves), len(positions))
+	}
+
+	var b strings.Builder
+	result := "*"
+	for _, line := range strings.Split(game.PGN, "\n") {
+		matches := tagLineRegex.FindStringSubmatch(strings.TrimSpace(line))
+		if matches == nil {
+			continue
+		}
+		if matches[1] == "Annotator" {
+			continue
+		}
+		if matches[1] == "Result" {
+			result = matches[2]
+		}
+		fmt.Fprintf(&b, "[%s \"%s\"]\n", matches[1], matches[2])
+	}
+	fmt.Fprintf(&b, "[Annotator \"chessAnalyser (%s)\"]\n\n", analysis.Preset)
+
+	var movetext []string
+	for i, position := range positions {
+		if position.SAN == "" {
+			return "", fmt.Errorf("move %s is not legal in %s", position.Move, position.FEN)
+		}
+		whiteToMove := strings.Fields(position.FEN)[1] == "w"
+		moveNumber := gameengine.FullMoveNumber(position.FEN)
+
+		if whiteToMove {
+			movetext = append(movetext, fmt.Sprintf("%d.", moveNumber))
+		} else if i == 0 || strings.HasPrefix(movetext[len(movetext)-1], "{") {
+			// Black's move needs its number after a comment or at the start of the game.
+			movetext = append(movetext, fmt.Sprintf("%d...", moveNumber))
+		}
+		movetext = append(movetext, position.SAN)
+		if nag, ok := classificationNAGs[analysis.Moves[i].Classification]; ok {
+			movetext = append(movetext, nag)
+		}
+		// The evaluation after a move is the one of the next position, which the opponent is to move in.
+		if i+1 < len(analysis.Moves) {
+			if eval, ok := whiteEval(analysis.Moves[i+1], !whiteToMove); ok {
+				movetext = append(movetext, fmt.Sprintf("{ [%%eval %s] }", eval))
+			}
+		}
+	}
+	movetext = append(movetext, result)
+
+	// Wrap the movetext at 80 columns.
+	lineLength := 0
+	for _, token := range movetext {
+		if lineLength > 0 && lineLength+1+len(token) > 80 {
+			b.WriteString("\n")
+			lineLength = 0
+		} else if lineLength > 0 {
+			b.WriteString(" ")
+			lineLength++
+		}
+		b.WriteString(token)
+		lineLength += len(token)
+	}
+	b.WriteString("\n")
+	return b.String(), nil
+}
+
+// whiteEval formats a move's evaluation, which is from the point of view of the side to move, from
+// White's point of view in [%eval] syntax ("0.35", "-1.20", "#3", "#-2"). Book moves have no evaluation.
+func whiteEval(move gameengine.MoveAnalysis, whiteToMove bool) (string, bool) {
+	sign := 1
+	if !whiteToMove {
+		sign = -1
+	}
+	if strings.HasPrefix(move.EvaluationText, "#") {
+		mateIn, err := strconv.Atoi(move.EvaluationText[1:])
+		if err != nil {
+			return "", false
+		}
+		return fmt.Sprintf("#%d", sign*mateIn), true
+	}
+	if move.Classification == gameengine.ClassBook {
+		return "", false
+	}
+	return fmt.Sprintf("%.2f", float64(sign)*move.Evaluation), true
+}
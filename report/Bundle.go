@@ -0,0 +1,121 @@
+package report
+
+import (
+	"archive/zip"
+	"bytes"
+	"chessAnalyserFree/api"
+	"chessAnalyserFree/board"
+	gameengine "chessAnalyserFree/gameEngine"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// bundleFile is a file of a bundle archive.
+type bundleFile struct {
+	name    string
+	content []byte
+}
+
+// WriteBundle writes the review of one analysed game as a self-contained zip archive, the unit to
+// attach to a forum post or send to a coach:
+//
+//	README.txt           the game and the archive's contents
+//	game.pgn             the annotated PGN
+//	analysis.json        the analysis, as one object of the JSON Lines export
+//	report.html          the HTML report
+//	diagrams/*.svg       the position before every sacrifice, mistake, blunder and missed win, with
+//	                     the move played tinted and the engine's best move as an arrow, and the final
+//	                     position
+func WriteBundle(w io.Writer, renderer *Renderer, game api.Game, analysis *gameengine.GameAnalysis) error {
+	positions, err := gameengine.ReplayPositions(game.PGN)
+	if err != nil {
+		return err
+	}
+	if len(analysis.Moves) != len(positions) {
+		return fmt.Errorf("analysis covers %d moves but the game has %d", len(analysis.Moves), len(positions))
+	}
+	pgn, err := AnnotatedPGN(game, analysis)
+	if err != nil {
+		return err
+	}
+	var analysisJSON, html bytes.Buffer
+	if err := NewJSONLWriter(&analysisJSON).WriteGame(game, analysis); err != nil {
+		return err
+	}
+	if err := renderer.Render(&html, FormatHTML, GameReport{Game: game, Moves: analysis.Moves, Preset: analysis.Preset}); err != nil {
+		return err
+	}
+
+	files := []bundleFile{
+		{"game.pgn", []byte(pgn)},
+		{"analysis.json", analysisJSON.Bytes()},
+		{"report.html", html.Bytes()},
+	}
+	keyDiagrams, err := KeyDiagrams(game, analysis, "svg")
+	if err != nil {
+		return err
+	}
+	var diagrams []string
+	for _, diagram := range keyDiagrams {
+		name := "diagrams/" + diagram.Name
+		files = append(files, bundleFile{name, diagram.Image})
+		diagrams = append(diagrams, fmt.Sprintf("  %-44s %s", name, diagram.Caption))
+	}
+	finalFEN, err := gameengine.FinalFEN(game.PGN)
+	if err != nil {
+		return err
+	}
+	final, err := board.SVG(finalFEN, false, "", "")
+	if err != nil {
+		return err
+	}
+	files = append(files, bundleFile{"diagrams/final.svg", []byte(final)})
+
+	var readme strings.Builder
+	fmt.Fprintf(&readme, "%s vs %s\n", playerName(game.White), playerName(game.Black))
+	if game.URL != "" {
+		fmt.Fprintf(&readme, "%s\n", game.URL)
+	}
+	fmt.Fprintf(&readme, "Analysed with preset %s by %s.\n\n", analysis.Preset, analysis.Engine)
+	readme.WriteString("game.pgn        Annotated PGN, for any chess GUI or a Lichess study\n")
+	readme.WriteString("analysis.json   Move-by-move analysis\n")
+	readme.WriteString("report.html     Game report, for a browser\n")
+	readme.WriteString("diagrams/       Positions before the critical moves: the move played is tinted red, the\n")
+	readme.WriteString("                engine's best move is the green arrow\n")
+	for _, diagram := range diagrams {
+		readme.WriteString(diagram + "\n")
+	}
+	fmt.Fprintf(&readme, "  %-44s final position\n", "diagrams/final.svg")
+
+	archive := zip.NewWriter(w)
+	modified := time.Now()
+	files = append([]bundleFile{{"README.txt", []byte(readme.String())}}, files...)
+	for _, file := range files {
+		entry, err := archive.CreateHeader(&zip.FileHeader{Name: file.name, Method: zip.Deflate, Modified: modified})
+		if err != nil {
+			return err
+		}
+		if _, err := entry.Write(file.content); err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
+
+// moveLabel returns the move number of the move played from a position, e.g. "12." or "12...".
+func moveLabel(position gameengine.PlyPosition) string {
+	if strings.Contains(position.FEN, " w ") {
+		return fmt.Sprintf("%d.", gameengine.FullMoveNumber(position.FEN))
+	}
+	return fmt.Sprintf("%d...", gameengine.FullMoveNumber(position.FEN))
+}
+
+// playerName returns a player's username with their rating, if known.
+func playerName(player api.Player) string {
+	if player.Rating == 0 {
+		return player.Username
+	}
+	return fmt.Sprintf("%s (%d)", player.Username, player.Rating)
+}
@@ -0,0 +1,115 @@
+package report
+
+import (
+	"chessAnalyserFree/api"
+	gameengine "chessAnalyserFree/gameEngine"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/notnil/chess"
+)
+
+// CSVHeader names the columns written by CSVWriter.
+var CSVHeader = []string{
+	"game_url", "ply", "move_number", "color", "san", "uci",
+	"eval", "mate", "best_move", "classification", "cp_loss", "clock", "depth",
+}
+
+// CSVWriter writes per-move analysis as CSV, one row per move, for spreadsheets and data frames.
+// Evaluations are of the position before the move, in pawns from White's point of view; forced
+// mates leave eval empty and give the moves to mate in the mate column, positive when White mates.
+// The best move is the engine's choice in that position, in SAN, and depth is the depth its search
+// reached. Book moves have no evaluation.
+type CSVWriter struct {
+	w *csv.Writer
+}
+
+// NewCSVWriter returns a writer to w. The header row is written first when header is true; leave it
+// out when appending to a file that already has one.
+func NewCSVWriter(w io.Writer, header bool) (*CSVWriter, error) {
+	c := &CSVWriter{w: csv.NewWriter(w)}
+	if header {
+		if err := c.w.Write(CSVHeader); err != nil {
+			return nil, err
+		}
+		c.w.Flush()
+		if err := c.w.Error(); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// WriteGame writes a row for every move of an analysed game and flushes them, so rows of finished
+// games are kept even if a later game fails.
+func (c *CSVWriter) WriteGame(game api.Game, analysis *gameengine.GameAnalysis) error {
+	positions, err := gameengine.ReplayPositions(game.PGN)
+	if err != nil {
+		return err
+	}
+	if len(analysis.Moves) != len(positions) {
+		return fmt.Errorf("analysis covers %d moves but the game has %d", len(analysis.Moves), len(positions))
+	}
+
+	for i, position := range positions {
+		move := analysis.Moves[i]
+		whiteToMove := strings.Fields(position.FEN)[1] == "w"
+		color := "white"
+		if !whiteToMove {
+			color = "black"
+		}
+		var eval, mate, depth string
+		if text, ok := whiteEval(move, whiteToMove); ok {
+			if strings.HasPrefix(text, "#") {
+				mate = text[1:]
+			} else {
+				eval = text
+			}
+		}
+		if move.Depth > 0 {
+			depth = strconv.Itoa(move.Depth)
+		}
+		row := []string{
+			game.URL,
+			strconv.Itoa(position.Ply),
+			strconv.Itoa(move.MoveNumber),
+			color,
+			position.SAN,
+			position.Move,
+			eval,
+			mate,
+			bestMoveSAN(position.FEN, move.BestMove),
+			move.Classification,
+			strconv.Itoa(move.CentipawnLoss),
+			position.Clock,
+			depth,
+		}
+		if err := c.w.Write(row); err != nil {
+			return err
+		}
+	}
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// bestMoveSAN converts a best move from UCI notation to SAN in the position fen. A move that cannot
+// be found among the legal moves is returned unchanged.
+func bestMoveSAN(fen, uci string) string {
+	if uci == "" {
+		return ""
+	}
+	option, err := chess.FEN(fen)
+	if err != nil {
+		return uci
+	}
+	position := chess.NewGame(option).Position()
+	for _, candidate := range position.ValidMoves() {
+		if candidate.String() == uci {
+			return chess.AlgebraicNotation{}.Encode(position, candidate)
+		}
+	}
+	return uci
+}
@@ -0,0 +1,67 @@
+package report
+
+import (
+	"chessAnalyserFree/api"
+	"chessAnalyserFree/board"
+	gameengine "chessAnalyserFree/gameEngine"
+	"fmt"
+	"strings"
+)
+
+// KeyDiagram is a diagram of the position before a key move of a game.
+type KeyDiagram struct {
+	Name    string // File name, e.g. "005-move-3-white-blunder.png", which sorts in game order.
+	Caption string // e.g. "before 3. Qxf7+ (blunder), best Qxe5+"
+	Kind    string // gameengine.KindMissedWin or the move's classification.
+	Move    ReportPly
+	Image   []byte
+}
+
+// DiagramLink is a diagram written next to a report, for the Markdown report to show.
+type DiagramLink struct {
+	Path    string // Path relative to the report.
+	Caption string
+}
+
+// KeyDiagrams draws the positions before the key moves of an analysed game, the sacrifices,
+// mistakes, blunders and missed wins, as images in format, "svg" or "png": the move played is
+// tinted and the engine's best move drawn as an arrow.
+func KeyDiagrams(game api.Game, analysis *gameengine.GameAnalysis, format string) ([]KeyDiagram, error) {
+	positions, err := gameengine.ReplayPositions(game.PGN)
+	if err != nil {
+		return nil, err
+	}
+	if len(analysis.Moves) != len(positions) {
+		return nil, fmt.Errorf("analysis covers %d moves but the game has %d", len(analysis.Moves), len(positions))
+	}
+	var diagrams []KeyDiagram
+	for _, key := range gameengine.KeyMoves(analysis.Moves) {
+		position, move := positions[key.Index], analysis.Moves[key.Index]
+		image, err := board.Diagram(format, position.FEN, false, position.Move, move.BestMove)
+		if err != nil {
+			return nil, err
+		}
+		colour := "white"
+		if !strings.Contains(position.FEN, " w ") {
+			colour = "black"
+		}
+		best := bestMoveSAN(position.FEN, move.BestMove)
+		diagrams = append(diagrams, KeyDiagram{
+			// The ply first keeps the diagrams in game order.
+			Name: fmt.Sprintf("%03d-move-%d-%s-%s.%s", position.Ply, gameengine.FullMoveNumber(position.FEN), colour,
+				strings.ReplaceAll(key.Kind, " ", "-"), format),
+			Caption: fmt.Sprintf("before %s %s (%s), best %s", moveLabel(position), position.SAN, key.Kind, best),
+			Kind:    key.Kind,
+			Move: ReportPly{
+				Ply:            position.Ply,
+				Label:          moveLabel(position),
+				SAN:            position.SAN,
+				Symbol:         classificationSymbols[move.Classification],
+				Classification: move.Classification,
+				Best:           best,
+			},
+			Image: image,
+		})
+	}
+	return diagrams, nil
+}
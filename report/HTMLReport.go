@@ -0,0 +1,214 @@
+package report
+
+import (
+	gameengine "chessAnalyserFree/gameEngine"
+	"chessAnalyserFree/stats"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"math"
+	"os"
+	"strings"
+	"time"
+)
+
+// classificationSymbols are the annotation symbols of move classifications in the move list.
+var classificationSymbols = map[string]string{
+	gameengine.ClassSacrifice:  "!",
+	gameengine.ClassInaccuracy: "?!",
+	gameengine.ClassMistake:    "?",
+	gameengine.ClassBlunder:    "??",
+}
+
+// Size of the evaluation chart, and the evaluation in pawns at its top and bottom edges.
+const (
+	chartWidth  = 600
+	chartHeight = 160
+	chartClamp  = 5.0
+)
+
+// ReportPly is an analysed move of the annotated move list of a report.
+type ReportPly struct {
+	Ply            int    // 1-based ply, used as the anchor of the move in the list.
+	Label          string // Move number, e.g. "12." or "12...".
+	SAN            string
+	Symbol         string // Annotation symbol of the classification, e.g. "??"; empty for plain moves.
+	Classification string
+	Eval           string // Evaluation before the move from White's point of view, e.g. "-1.20" or "#3"; empty for book moves.
+	CentipawnLoss  int
+	Best           string // Engine's best move in SAN, for inaccuracies, mistakes and blunders.
+}
+
+// ReportPlyPair groups the annotated moves of a full move.
+type ReportPlyPair struct {
+	Number int
+	White  *ReportPly // Nil when a game set up with Black to move starts with Black's move.
+	Black  *ReportPly
+}
+
+// KeyPosition is a position of a report drawn as a board: the position before a sacrifice, mistake,
+// blunder or missed win.
+type KeyPosition struct {
+	Move    ReportPly
+	Kind    string            // gameengine.KindMissedWin or the move's classification.
+	Diagram htmltemplate.HTML // SVG image of the position, with the move played tinted and the best move as an arrow.
+}
+
+// prepareHTML fills in the annotated move list, the evaluation chart and the key positions of a
+// report from its game and moves. A game that cannot be replayed keeps them empty; the template
+// then shows the plain move table.
+func (r *GameReport) prepareHTML() {
+	positions, err := gameengine.ReplayPositions(r.Game.PGN)
+	if err != nil || len(positions) != len(r.Moves) || len(positions) == 0 {
+		return
+	}
+	values := make([]float64, len(positions))
+	known := make([]bool, len(positions))
+	for i, position := range positions {
+		move := r.Moves[i]
+		whiteToMove := strings.Fields(position.FEN)[1] == "w"
+		ply := ReportPly{
+			Ply:            position.Ply,
+			Label:          moveLabel(position),
+			SAN:            position.SAN,
+			Symbol:         classificationSymbols[move.Classification],
+			Classification: move.Classification,
+			CentipawnLoss:  move.CentipawnLoss,
+		}
+		if text, ok := whiteEval(move, whiteToMove); ok {
+			ply.Eval = text
+			values[i], known[i] = math.Max(-chartClamp, math.Min(chartClamp, move.Evaluation)), true
+			if !whiteToMove {
+				values[i] = -values[i]
+			}
+		}
+		switch move.Classification {
+		case gameengine.ClassInaccuracy, gameengine.ClassMistake, gameengine.ClassBlunder:
+			ply.Best = bestMoveSAN(position.FEN, move.BestMove)
+		}
+		r.Plies = append(r.Plies, ply)
+	}
+	r.EvalChart = evalChart(r.Plies, values, known)
+	diagrams, _ := KeyDiagrams(r.Game, &gameengine.GameAnalysis{Moves: r.Moves}, "svg")
+	for _, diagram := range diagrams {
+		r.KeyPositions = append(r.KeyPositions, KeyPosition{Move: diagram.Move, Kind: diagram.Kind, Diagram: htmltemplate.HTML(diagram.Image)})
+	}
+}
+
+// PlyPairs returns the annotated moves grouped by full move.
+func (r GameReport) PlyPairs() []ReportPlyPair {
+	var pairs []ReportPlyPair
+	for i := range r.Plies {
+		ply := &r.Plies[i]
+		black := strings.HasSuffix(ply.Label, "...")
+		if !black || len(pairs) == 0 || pairs[len(pairs)-1].Black != nil {
+			pairs = append(pairs, ReportPlyPair{Number: moveNumber(ply.Label)})
+		}
+		if black {
+			pairs[len(pairs)-1].Black = ply
+		} else {
+			pairs[len(pairs)-1].White = ply
+		}
+	}
+	return pairs
+}
+
+// moveNumber returns the number of a move label such as "12." or "12...".
+func moveNumber(label string) int {
+	var number int
+	fmt.Sscanf(label, "%d", &number)
+	return number
+}
+
+// evalChart draws the evaluation curve as an SVG image from White's point of view, the values
+// clamped at chartClamp pawns. Every move is a column with its evaluation as a tooltip, linking to
+// the move in the move list; the columns of annotated moves are marked by their colour.
+func evalChart(plies []ReportPly, values []float64, known []bool) htmltemplate.HTML {
+	step := float64(chartWidth) / float64(len(plies))
+	y := func(value float64) float64 {
+		return chartHeight / 2 * (1 - value/chartClamp)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg class="chart" xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d %d" preserveAspectRatio="none">`+"\n", chartWidth, chartHeight)
+	fmt.Fprintf(&b, `<rect width="%d" height="%d" fill="#404040"/>`+"\n", chartWidth, chartHeight)
+
+	// The area below the curve is White's share of the game, as on Lichess and Chess.com.
+	var points []string
+	for i := range plies {
+		if known[i] {
+			points = append(points, fmt.Sprintf("%.1f,%.1f", (float64(i)+0.5)*step, y(values[i])))
+		}
+	}
+	if len(points) > 0 {
+		first, last := strings.Split(points[0], ",")[0], strings.Split(points[len(points)-1], ",")[0]
+		fmt.Fprintf(&b, `<polygon points="%s,%d %s %s,%d" fill="#f4f4f4"/>`+"\n", first, chartHeight, strings.Join(points, " "), last, chartHeight)
+		fmt.Fprintf(&b, `<polyline points="%s" fill="none" stroke="#888" stroke-width="1"/>`+"\n", strings.Join(points, " "))
+	}
+	fmt.Fprintf(&b, `<line x1="0" y1="%d" x2="%d" y2="%d" stroke="#888" stroke-dasharray="4 3"/>`+"\n", chartHeight/2, chartWidth, chartHeight/2)
+
+	for i, ply := range plies {
+		eval := ply.Eval
+		if eval == "" {
+			eval = "book"
+		}
+		title := htmltemplate.HTMLEscapeString(fmt.Sprintf("%s %s%s (%s)", ply.Label, ply.SAN, ply.Symbol, eval))
+		fmt.Fprintf(&b, `<a href="#ply-%d"><rect class="column %s" x="%.1f" y="0" width="%.1f" height="%d"><title>%s</title></rect></a>`+"\n",
+			ply.Ply, ply.Classification, float64(i)*step, step, chartHeight, title)
+	}
+	b.WriteString("</svg>")
+	return htmltemplate.HTML(b.String())
+}
+
+// BatchGame is a game of a batch page: its report and the report rendered as an HTML page.
+type BatchGame struct {
+	Report GameReport
+	HTML   string
+}
+
+// BatchReport is the data passed to the batch template.
+type BatchReport struct {
+	Title       string
+	Games       []BatchGame
+	Branding    Branding
+	GeneratedAt time.Time
+	// Accuracy is the player's accuracy trend over the games, as a table; nil without a player
+	// or analysed games of theirs.
+	Accuracy *AccuracyTable
+}
+
+// AccuracyTable is an accuracy trend as a table, from stats.AccuracyTrend.Table.
+type AccuracyTable struct {
+	Player string
+	Header []string
+	Rows   [][]string
+}
+
+// RenderBatch writes the HTML reports of several games as one self-contained page: a table of the
+// games, the accuracy trend when there is one, then every game's report, each embedded in a frame
+// of its own so that its layout stays that of a single report.
+func (r *Renderer) RenderBatch(w io.Writer, title string, reports []GameReport, trend *stats.AccuracyTrend) error {
+	batch := BatchReport{Title: title, Branding: r.branding, GeneratedAt: time.Now()}
+	if trend != nil && len(trend.Months) > 0 {
+		header, rows := trend.Table()
+		batch.Accuracy = &AccuracyTable{Player: trend.Player, Header: header, Rows: rows}
+	}
+	for _, report := range reports {
+		var html strings.Builder
+		if err := r.Render(&html, FormatHTML, report); err != nil {
+			return err
+		}
+		batch.Games = append(batch.Games, BatchGame{Report: report, HTML: html.String()})
+	}
+	return r.batch.Execute(w, batch)
+}
+
+// WriteBatchFile renders the HTML reports of several games, and the accuracy trend if not nil, as
+// one page to path.
+func (r *Renderer) WriteBatchFile(path, title string, reports []GameReport, trend *stats.AccuracyTrend) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create report file: %w", err)
+	}
+	defer f.Close()
+	return r.RenderBatch(f, title, reports, trend)
+}
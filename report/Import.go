@@ -0,0 +1,184 @@
+package report
+
+import (
+	"archive/zip"
+	"bytes"
+	"chessAnalyserFree/api"
+	gameengine "chessAnalyserFree/gameEngine"
+	pgnimport "chessAnalyserFree/pgnImport"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/notnil/chess"
+)
+
+// ImportedGame is a game read from an export, with its analysis.
+type ImportedGame struct {
+	Game     api.Game
+	Analysis *gameengine.GameAnalysis
+}
+
+// ReadJSONL reads the analysed games of a JSON Lines export written by JSONLWriter, such as the output
+// of pipe mode or a bundle's analysis.json. Objects written by WriteError have no analysis and are
+// skipped. Exports from before the objects carried the PGN are rebuilt from their moves; origin
+// names the export in errors and in the URL of games without one.
+func ReadJSONL(r io.Reader, origin string) ([]ImportedGame, error) {
+	decoder := json.NewDecoder(r)
+	var games []ImportedGame
+	for n := 1; ; n++ {
+		var in jsonGame
+		if err := decoder.Decode(&in); err == io.EOF {
+			return games, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("%s: game %d: %w", origin, n, err)
+		}
+		if in.Error != "" || len(in.Moves) == 0 {
+			continue
+		}
+		game, err := in.imported(fmt.Sprintf("%s#%d", origin, n))
+		if err != nil {
+			return nil, fmt.Errorf("%s: game %d: %w", origin, n, err)
+		}
+		games = append(games, game)
+	}
+}
+
+// ReadBundle reads the game of a bundle written by WriteBundle, from its analysis.json.
+func ReadBundle(data []byte, origin string) ([]ImportedGame, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", origin, err)
+	}
+	file, err := archive.Open("analysis.json")
+	if err != nil {
+		return nil, fmt.Errorf("%s is not an analysis bundle: %w", origin, err)
+	}
+	defer file.Close()
+	return ReadJSONL(file, origin)
+}
+
+// imported turns an exported game back into a game and its analysis. Evaluations go back to the
+// mover's point of view and best moves to UCI notation; moves must match the game's.
+func (in jsonGame) imported(fallbackURL string) (ImportedGame, error) {
+	pgn := in.PGN
+	if pgn == "" {
+		pgn = in.movetext()
+	}
+	game := pgnimport.GameFromPGN(pgn, fallbackURL)
+	if in.URL != "" {
+		game.URL = in.URL
+	}
+	for _, side := range []struct {
+		player *api.Player
+		in     jsonPlayer
+	}{{&game.White, in.White}, {&game.Black, in.Black}} {
+		if side.in.Username != "" {
+			side.player.Username = side.in.Username
+		}
+		if side.in.Rating != 0 {
+			side.player.Rating = side.in.Rating
+		}
+		if side.in.Result != "" {
+			side.player.Result = side.in.Result
+		}
+	}
+
+	positions, err := gameengine.ReplayPositions(pgn)
+	if err != nil {
+		return ImportedGame{}, err
+	}
+	if len(positions) != len(in.Moves) {
+		return ImportedGame{}, fmt.Errorf("analysis covers %d moves but the game has %d", len(in.Moves), len(positions))
+	}
+	analysis := &gameengine.GameAnalysis{Preset: in.Preset, Engine: in.Engine}
+	for i, position := range positions {
+		move := in.Moves[i]
+		if move.UCI != "" && move.UCI != position.Move {
+			return ImportedGame{}, fmt.Errorf("move %d is %s in the analysis but %s in the game", position.Ply, move.UCI, position.Move)
+		}
+		whiteToMove := strings.Fields(position.FEN)[1] == "w"
+		entry := gameengine.MoveAnalysis{
+			MoveNumber:     gameengine.FullMoveNumber(position.FEN),
+			Move:           position.Move,
+			CentipawnLoss:  move.CentipawnLoss,
+			Classification: move.Classification,
+			Depth:          move.Depth,
+			Source:         gameengine.ImportedSettings,
+			BestMove:       bestMoveUCI(position.FEN, move.BestMove),
+		}
+		switch {
+		case move.Mate != nil:
+			mate := *move.Mate
+			if !whiteToMove {
+				mate = -mate
+			}
+			entry.EvaluationText = fmt.Sprintf("#%d", mate)
+		case move.Eval != nil:
+			eval := *move.Eval
+			if !whiteToMove && eval != 0 {
+				eval = -eval
+			}
+			entry.EvaluationText = fmt.Sprintf("%+.2f", eval)
+		case move.Classification == gameengine.ClassBook:
+			entry.EvaluationText = gameengine.ClassBook
+		default:
+			return ImportedGame{}, fmt.Errorf("move %d has no evaluation", position.Ply)
+		}
+		entry.Evaluation, _ = gameengine.ParseEvaluation(entry.EvaluationText)
+		analysis.Moves = append(analysis.Moves, entry)
+	}
+	gameengine.ValidateAnalysis(game, analysis)
+	return ImportedGame{Game: game, Analysis: analysis}, nil
+}
+
+// movetext rebuilds the PGN of an exported game from its moves, for exports without one.
+func (in jsonGame) movetext() string {
+	result := "*"
+	switch {
+	case in.White.Result == "win":
+		result = "1-0"
+	case in.Black.Result == "win":
+		result = "0-1"
+	case in.White.Result != "" && in.Black.Result != "":
+		result = "1/2-1/2"
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "[White \"%s\"]\n[Black \"%s\"]\n[Result \"%s\"]\n", in.White.Username, in.Black.Username, result)
+	if in.URL != "" {
+		fmt.Fprintf(&b, "[Link \"%s\"]\n", in.URL)
+	}
+	b.WriteString("\n")
+	for _, move := range in.Moves {
+		if move.Color == "white" {
+			fmt.Fprintf(&b, "%d. ", move.MoveNumber)
+		} else if move.Ply == 1 {
+			fmt.Fprintf(&b, "%d... ", move.MoveNumber)
+		}
+		b.WriteString(move.SAN + " ")
+		if move.Clock != "" {
+			fmt.Fprintf(&b, "{[%%clk %s]} ", move.Clock)
+		}
+	}
+	b.WriteString(result + "\n")
+	return b.String()
+}
+
+// bestMoveUCI returns a move given in SAN in UCI notation, the reverse of bestMoveSAN. A move that
+// is not legal SAN in the position is returned unchanged, as bestMoveSAN leaves such moves in UCI.
+func bestMoveUCI(fen, san string) string {
+	if san == "" {
+		return ""
+	}
+	option, err := chess.FEN(fen)
+	if err != nil {
+		return san
+	}
+	position := chess.NewGame(option).Position()
+	move, err := chess.AlgebraicNotation{}.Decode(position, san)
+	if err != nil {
+		return san
+	}
+	return move.String()
+}
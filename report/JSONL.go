@@ -0,0 +1,152 @@
+package report
+
+import (
+	"chessAnalyserFree/api"
+	gameengine "chessAnalyserFree/gameEngine"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// jsonGame is the JSON object written for every game by JSONLWriter.
+type jsonGame struct {
+	URL    string     `json:"url"`
+	White  jsonPlayer `json:"white"`
+	Black  jsonPlayer `json:"black"`
+	Preset string     `json:"preset,omitempty"`
+	Engine string     `json:"engine,omitempty"`
+	// Issues are the sanity check failures of an invalid analysis.
+	Issues []string   `json:"issues,omitempty"`
+	Moves  []jsonMove `json:"moves,omitempty"`
+	// PGN is the game, so the export can be imported elsewhere; ReadJSONL reads it back.
+	PGN string `json:"pgn,omitempty"`
+	// Error explains why the game could not be analysed; the object then has no analysis.
+	Error string `json:"error,omitempty"`
+}
+
+// jsonPlayer is one side of a game with the figures of its analysed moves, which are left out when
+// the side has none.
+type jsonPlayer struct {
+	Username     string   `json:"username"`
+	Rating       int      `json:"rating,omitempty"`
+	Result       string   `json:"result,omitempty"`
+	ACPL         *float64 `json:"acpl,omitempty"`
+	Accuracy     *float64 `json:"accuracy,omitempty"`
+	Inaccuracies *int     `json:"inaccuracies,omitempty"`
+	Mistakes     *int     `json:"mistakes,omitempty"`
+	Blunders     *int     `json:"blunders,omitempty"`
+}
+
+// jsonMove is the analysis of one move, with the fields of the CSV export.
+type jsonMove struct {
+	Ply            int      `json:"ply"`
+	MoveNumber     int      `json:"move_number"`
+	Color          string   `json:"color"`
+	SAN            string   `json:"san"`
+	UCI            string   `json:"uci"`
+	Eval           *float64 `json:"eval,omitempty"`
+	Mate           *int     `json:"mate,omitempty"`
+	BestMove       string   `json:"best_move,omitempty"`
+	Classification string   `json:"classification"`
+	CentipawnLoss  int      `json:"cp_loss"`
+	Depth          int      `json:"depth,omitempty"`
+	Clock          string   `json:"clock,omitempty"`
+}
+
+// JSONLWriter writes analysed games as JSON Lines, one object per game on a line of its own, so
+// other programs can read each game as soon as it is written. Moves carry the fields of the CSV
+// export: evaluations are of the position before the move, in pawns from White's point of view, or
+// moves to mate, positive when White mates; the best move is in SAN.
+type JSONLWriter struct {
+	enc *json.Encoder
+}
+
+// NewJSONLWriter returns a writer to w.
+func NewJSONLWriter(w io.Writer) *JSONLWriter {
+	return &JSONLWriter{enc: json.NewEncoder(w)}
+}
+
+// WriteGame writes an analysed game.
+func (j *JSONLWriter) WriteGame(game api.Game, analysis *gameengine.GameAnalysis) error {
+	positions, err := gameengine.ReplayPositions(game.PGN)
+	if err != nil {
+		return err
+	}
+	if len(analysis.Moves) != len(positions) {
+		return fmt.Errorf("analysis covers %d moves but the game has %d", len(analysis.Moves), len(positions))
+	}
+
+	out := newJSONGame(game)
+	out.Preset, out.Engine, out.Issues, out.PGN = analysis.Preset, analysis.Engine, analysis.Issues, game.PGN
+	white, black := gameengine.GameAccuracy(game.PGN, analysis)
+	out.White.addFigures(white)
+	out.Black.addFigures(black)
+	for i, position := range positions {
+		entry := newJSONMove(position.Ply, position.FEN, position.SAN, analysis.Moves[i])
+		entry.Clock = position.Clock
+		out.Moves = append(out.Moves, entry)
+	}
+	return j.enc.Encode(out)
+}
+
+// MoveObject returns the object of a move in the moves of the JSON Lines export, for a move analysed
+// in the position fen before the rest of its game, e.g. to stream it. Its classification is the
+// one the move has so far, and it has no clock.
+func MoveObject(ply int, fen string, move gameengine.MoveAnalysis) any {
+	return newJSONMove(ply, fen, bestMoveSAN(fen, move.Move), move)
+}
+
+// newJSONMove returns the object of a move played as san in the position fen.
+func newJSONMove(ply int, fen, san string, move gameengine.MoveAnalysis) jsonMove {
+	whiteToMove := strings.Fields(fen)[1] == "w"
+	entry := jsonMove{
+		Ply:            ply,
+		MoveNumber:     move.MoveNumber,
+		Color:          "white",
+		SAN:            san,
+		UCI:            move.Move,
+		BestMove:       bestMoveSAN(fen, move.BestMove),
+		Classification: move.Classification,
+		CentipawnLoss:  move.CentipawnLoss,
+		Depth:          move.Depth,
+	}
+	if !whiteToMove {
+		entry.Color = "black"
+	}
+	if text, ok := whiteEval(move, whiteToMove); ok {
+		if mateText, isMate := strings.CutPrefix(text, "#"); isMate {
+			if mate, err := strconv.Atoi(mateText); err == nil {
+				entry.Mate = &mate
+			}
+		} else if eval, err := strconv.ParseFloat(text, 64); err == nil {
+			entry.Eval = &eval
+		}
+	}
+	return entry
+}
+
+// WriteError writes a game that could not be analysed, with the reason.
+func (j *JSONLWriter) WriteError(game api.Game, cause error) error {
+	out := newJSONGame(game)
+	out.Error = cause.Error()
+	return j.enc.Encode(out)
+}
+
+// newJSONGame returns the JSON object of a game, without its analysis.
+func newJSONGame(game api.Game) jsonGame {
+	return jsonGame{
+		URL:   game.URL,
+		White: jsonPlayer{Username: game.White.Username, Rating: game.White.Rating, Result: game.White.Result},
+		Black: jsonPlayer{Username: game.Black.Username, Rating: game.Black.Rating, Result: game.Black.Result},
+	}
+}
+
+// addFigures adds the figures of a side's analysed moves; a side without analysed moves has none.
+func (p *jsonPlayer) addFigures(side gameengine.SideAccuracy) {
+	if side.Moves > 0 {
+		p.ACPL, p.Accuracy = &side.ACPL, &side.Accuracy
+		p.Inaccuracies, p.Mistakes, p.Blunders = &side.Inaccuracies, &side.Mistakes, &side.Blunders
+	}
+}
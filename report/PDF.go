@@ -0,0 +1,142 @@
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Page layout of PDF reports: A4 in points, with the text in Courier so that the columns of the
+// template line up.
+const (
+	pdfPageWidth   = 595
+	pdfPageHeight  = 842
+	pdfMargin      = 50
+	pdfFontSize    = 9
+	pdfLineHeight  = 12
+	pdfCharWidth   = 0.6 * pdfFontSize // Width of every Courier character.
+	pdfHeadingSize = 16
+	pdfSectionSize = 12
+)
+
+// pdfLine is a line of a PDF report page.
+type pdfLine struct {
+	text  string
+	style byte // '#' for the title, '=' for a section heading, 0 for text.
+}
+
+// writePDF lays out the text of a PDF report template on A4 pages and writes them as a PDF document.
+// A line starting with "# " is the title and one starting with "## " a section heading, both set in
+// bold in the branding's primary color; other lines are set as they are, wrapped at the margin.
+// Characters outside Latin-1 are replaced with "?".
+func writePDF(w io.Writer, text string, branding Branding) error {
+	var pages [][]pdfLine
+	var page []pdfLine
+	y := pdfPageHeight - pdfMargin
+	add := func(line pdfLine, height int) {
+		if y-height < pdfMargin && len(page) > 0 {
+			pages, page, y = append(pages, page), nil, pdfPageHeight-pdfMargin
+		}
+		page = append(page, line)
+		y -= height
+	}
+	width := float64(pdfPageWidth - 2*pdfMargin)
+	columns := int(width / pdfCharWidth)
+	for _, line := range strings.Split(strings.TrimRight(text, "\n"), "\n") {
+		line = strings.TrimRight(line, " \r")
+		if title, ok := strings.CutPrefix(line, "# "); ok {
+			add(pdfLine{text: title, style: '#'}, pdfHeadingSize+pdfLineHeight)
+			continue
+		}
+		if heading, ok := strings.CutPrefix(line, "## "); ok {
+			add(pdfLine{text: heading, style: '='}, pdfSectionSize+pdfLineHeight/2)
+			continue
+		}
+		runes := []rune(line)
+		for len(runes) > columns {
+			add(pdfLine{text: string(runes[:columns])}, pdfLineHeight)
+			runes = runes[columns:]
+		}
+		add(pdfLine{text: string(runes)}, pdfLineHeight)
+	}
+	pages = append(pages, page)
+
+	red, green, blue := pdfColor(branding.PrimaryColor)
+	var doc bytes.Buffer
+	var offsets []int
+	object := func(body string) {
+		offsets = append(offsets, doc.Len())
+		fmt.Fprintf(&doc, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+	doc.WriteString("%PDF-1.4\n")
+	// Objects 1 to 4 are the catalog, the page tree and the fonts; each page adds a page and its contents.
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	kids := make([]string, len(pages))
+	for i := range pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 5+2*i)
+	}
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Courier /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	for i, lines := range pages {
+		var content strings.Builder
+		y := pdfPageHeight - pdfMargin
+		for _, line := range lines {
+			font, size, height, color := "F1", pdfFontSize, pdfLineHeight, "0 0 0"
+			switch line.style {
+			case '#':
+				font, size, height = "F2", pdfHeadingSize, pdfHeadingSize+pdfLineHeight
+			case '=':
+				font, size, height = "F2", pdfSectionSize, pdfSectionSize+pdfLineHeight/2
+			}
+			if line.style != 0 {
+				color = fmt.Sprintf("%.3f %.3f %.3f", red, green, blue)
+			}
+			y -= height
+			fmt.Fprintf(&content, "BT /%s %d Tf %s rg %d %d Td (%s) Tj ET\n", font, size, color, pdfMargin, y, pdfString(line.text))
+		}
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+			pdfPageWidth, pdfPageHeight, 6+2*i))
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", content.Len(), content.String()))
+	}
+	xref := doc.Len()
+	fmt.Fprintf(&doc, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&doc, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&doc, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	_, err := w.Write(doc.Bytes())
+	return err
+}
+
+// pdfString encodes text as the contents of a PDF string in WinAnsiEncoding.
+func pdfString(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		switch {
+		case r == '\\' || r == '(' || r == ')':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\t':
+			b.WriteByte(' ')
+		case r < 0x20 || (r >= 0x7f && r < 0xa0) || r > 0xff:
+			b.WriteByte('?')
+		case r >= 0x80:
+			fmt.Fprintf(&b, "\\%03o", r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// pdfColor returns the red, green and blue components, from 0 to 1, of a "#rrggbb" color, or black.
+func pdfColor(hex string) (red, green, blue float64) {
+	value, err := strconv.ParseUint(strings.TrimPrefix(hex, "#"), 16, 32)
+	if err != nil || len(strings.TrimPrefix(hex, "#")) != 6 {
+		return 0, 0, 0
+	}
+	return float64(value>>16) / 255, float64(value>>8&0xff) / 255, float64(value&0xff) / 255
+}
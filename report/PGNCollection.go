@@ -0,0 +1,89 @@
+package report
+
+import (
+	"chessAnalyserFree/api"
+	gameengine "chessAnalyserFree/gameEngine"
+	pgnimport "chessAnalyserFree/pgnImport"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// PGNCollection is a PGN file that analysed games are appended to with their annotations, growing
+// into a personal annotated game collection. A game the file already holds is not added again.
+type PGNCollection struct {
+	file  *os.File
+	path  string
+	keys  map[string]bool
+	empty bool
+}
+
+// OpenPGNCollection opens the collection at path, creating it if needed, and notes the games it
+// already holds, including games added by other tools.
+func OpenPGNCollection(path string) (*PGNCollection, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	// Only the keys are kept, so a big collection is read a game at a time.
+	c := &PGNCollection{file: file, path: path, keys: make(map[string]bool), empty: true}
+	for game, err := range pgnimport.Games(file, "file://"+path) {
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		c.keys[collectionKey(game.PGN)] = true
+		c.empty = false
+	}
+	return c, nil
+}
+
+// Len returns the number of games in the collection.
+func (c *PGNCollection) Len() int {
+	return len(c.keys)
+}
+
+// Add appends the annotated game to the collection unless it holds the game already, with any
+// annotations. It reports whether the game was added.
+func (c *PGNCollection) Add(game api.Game, analysis *gameengine.GameAnalysis) (bool, error) {
+	pgn, err := AnnotatedPGN(game, analysis)
+	if err != nil {
+		return false, fmt.Errorf("failed to annotate game: %w", err)
+	}
+	key := collectionKey(pgn)
+	if c.keys[key] {
+		return false, nil
+	}
+	if !c.empty {
+		pgn = "\n" + pgn
+	}
+	if _, err := c.file.WriteString(pgn); err != nil {
+		return false, fmt.Errorf("failed to write %s: %w", c.path, err)
+	}
+	c.keys[key] = true
+	c.empty = false
+	return true, nil
+}
+
+// Close closes the collection file.
+func (c *PGNCollection) Close() error {
+	return c.file.Close()
+}
+
+// collectionKey identifies a game of a collection by its Link or Site URL tag or, for games without
+// one, by its players, date, round and moves, so that differently annotated copies of a game match.
+func collectionKey(pgn string) string {
+	tags := pgnimport.ParseTags(pgn)
+	if link := tags["Link"]; link != "" {
+		return "url " + link
+	}
+	if site := tags["Site"]; strings.HasPrefix(site, "http") {
+		return "url " + site
+	}
+	identity := []string{tags["White"], tags["Black"], tags["Date"], tags["UTCDate"], tags["UTCTime"], tags["Round"],
+		strings.Join(gameengine.MovetextMoves(pgn), " ")}
+	sum := sha256.Sum256([]byte(strings.Join(identity, "\n")))
+	return "game " + hex.EncodeToString(sum[:])
+}
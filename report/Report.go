@@ -0,0 +1,310 @@
+package report
+
+import (
+	"chessAnalyserFree/api"
+	gameengine "chessAnalyserFree/gameEngine"
+	"chessAnalyserFree/query"
+	"embed"
+	"encoding/json"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	texttemplate "text/template"
+	"time"
+)
+
+// defaultTemplates holds the built-in report layouts used when no override is provided.
+//
+//go:embed templates/*.tmpl
+var defaultTemplates embed.FS
+
+// Format is an output format for a report.
+type Format string
+
+const (
+	FormatHTML     Format = "html"
+	FormatMarkdown Format = "md"
+	FormatPDF      Format = "pdf"
+)
+
+// ParseFormat returns the format with the given name: "md" or "markdown", "html" or "pdf".
+func ParseFormat(name string) (Format, error) {
+	switch strings.ToLower(name) {
+	case "md", "markdown":
+		return FormatMarkdown, nil
+	case "html":
+		return FormatHTML, nil
+	case "pdf":
+		return FormatPDF, nil
+	}
+	return "", fmt.Errorf("unknown report format %q (available: markdown, html, pdf)", name)
+}
+
+// templateFiles maps each format to the template file name looked up in a templates directory.
+var templateFiles = map[Format]string{
+	FormatHTML:     "report.html.tmpl",
+	FormatMarkdown: "report.md.tmpl",
+	FormatPDF:      "report.pdf.tmpl",
+}
+
+// batchTemplateFile is the template of the HTML page holding the reports of a batch of games.
+const batchTemplateFile = "batch.html.tmpl"
+
+// brandingFile is the optional branding configuration read from a templates directory.
+const brandingFile = "branding.json"
+
+// Branding holds the coach/club customisation applied to every report.
+type Branding struct {
+	Name         string   `json:"name"`
+	LogoURL      string   `json:"logo_url"`
+	PrimaryColor string   `json:"primary_color"`
+	AccentColor  string   `json:"accent_color"`
+	Sections     []string `json:"sections"` // Sections to include; empty means all.
+	// Queries are filters over the game's analysed moves, each rendered as a table in the
+	// "queries" section, e.g. {"title": "Endgame mistakes", "filter": "phase=endgame and cploss>100"}.
+	Queries []ReportQuery `json:"queries"`
+}
+
+// ReportQuery is a named query filter of a report configuration.
+type ReportQuery struct {
+	Title  string `json:"title"`
+	Filter string `json:"filter"`
+}
+
+// QueryResult holds the moves of a game matching a report query.
+type QueryResult struct {
+	Title  string
+	Filter string
+	Moves  []query.Row
+}
+
+// DefaultBranding returns the branding used when no branding.json is provided.
+func DefaultBranding() Branding {
+	return Branding{
+		PrimaryColor: "#2b5d34",
+		AccentColor:  "#eef4ee",
+	}
+}
+
+// HasSection reports whether the named section should be rendered.
+func (b Branding) HasSection(name string) bool {
+	if len(b.Sections) == 0 {
+		return true
+	}
+	for _, s := range b.Sections {
+		if strings.EqualFold(s, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// GameReport is the data passed to report templates.
+type GameReport struct {
+	Title       string
+	Game        api.Game
+	Moves       []gameengine.MoveAnalysis
+	Preset      string // Name of the analysis preset, stating the analysis quality level.
+	Branding    Branding
+	GeneratedAt time.Time
+	Queries     []QueryResult // Results of the branding's queries; filled in by Render.
+	Diagrams    []DiagramLink // Key positions drawn next to the report, if any.
+
+	// Filled in by Render for HTML reports.
+	Plies        []ReportPly       // Annotated move list.
+	EvalChart    htmltemplate.HTML // Evaluation chart as an SVG image.
+	KeyPositions []KeyPosition     // Positions before the sacrifices, mistakes and blunders, drawn as boards.
+}
+
+// CriticalMoves returns the analysed moves whose position has practical chances estimated.
+func (r GameReport) CriticalMoves() []gameengine.MoveAnalysis {
+	var critical []gameengine.MoveAnalysis
+	for _, move := range r.Moves {
+		if move.PracticalChances != nil {
+			critical = append(critical, move)
+		}
+	}
+	return critical
+}
+
+// SearchDepth describes the search depth reached over the analysed moves, e.g. "12-20 (average
+// 15.4)"; empty when no position was searched.
+func (r GameReport) SearchDepth() string {
+	return gameengine.SearchDepths(r.Moves).String()
+}
+
+// MovePair groups a white move with the black reply for table rendering.
+type MovePair struct {
+	Number int
+	White  gameengine.MoveAnalysis
+	Black  *gameengine.MoveAnalysis
+}
+
+// MovePairs returns the analysed moves grouped by full move.
+func (r GameReport) MovePairs() []MovePair {
+	var pairs []MovePair
+	for i := 0; i < len(r.Moves); i += 2 {
+		pair := MovePair{Number: r.Moves[i].MoveNumber, White: r.Moves[i]}
+		if i+1 < len(r.Moves) {
+			pair.Black = &r.Moves[i+1]
+		}
+		pairs = append(pairs, pair)
+	}
+	return pairs
+}
+
+// templateFuncs are the helper functions available to every report template.
+var templateFuncs = map[string]interface{}{
+	"date": func(unix int64) string {
+		return time.Unix(unix, 0).Format("2006-01-02")
+	},
+	"inc": func(i int) int {
+		return i + 1
+	},
+}
+
+// Renderer renders reports using the built-in templates or user-provided overrides.
+type Renderer struct {
+	branding Branding
+	queries  []*query.Query // Parsed branding.Queries.
+	html     *htmltemplate.Template
+	batch    *htmltemplate.Template
+	markdown *texttemplate.Template
+	pdf      *texttemplate.Template // Text laid out on the pages of PDF reports.
+}
+
+// NewRenderer creates a Renderer. If templatesDir is non-empty, any report.html.tmpl,
+// report.md.tmpl, report.pdf.tmpl, batch.html.tmpl or branding.json found there replaces the
+// corresponding default.
+func NewRenderer(templatesDir string) (*Renderer, error) {
+	r := &Renderer{branding: DefaultBranding()}
+
+	htmlSource, err := loadTemplate(templatesDir, templateFiles[FormatHTML])
+	if err != nil {
+		return nil, err
+	}
+	r.html, err = htmltemplate.New("html").Funcs(templateFuncs).Parse(htmlSource)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse html template: %w", err)
+	}
+
+	batchSource, err := loadTemplate(templatesDir, batchTemplateFile)
+	if err != nil {
+		return nil, err
+	}
+	r.batch, err = htmltemplate.New("batch").Funcs(templateFuncs).Parse(batchSource)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse batch template: %w", err)
+	}
+
+	markdownSource, err := loadTemplate(templatesDir, templateFiles[FormatMarkdown])
+	if err != nil {
+		return nil, err
+	}
+	r.markdown, err = texttemplate.New("md").Funcs(templateFuncs).Parse(markdownSource)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse markdown template: %w", err)
+	}
+
+	pdfSource, err := loadTemplate(templatesDir, templateFiles[FormatPDF])
+	if err != nil {
+		return nil, err
+	}
+	r.pdf, err = texttemplate.New("pdf").Funcs(templateFuncs).Parse(pdfSource)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse pdf template: %w", err)
+	}
+
+	if templatesDir != "" {
+		data, err := os.ReadFile(filepath.Join(templatesDir, brandingFile))
+		if err == nil {
+			if err := json.Unmarshal(data, &r.branding); err != nil {
+				return nil, fmt.Errorf("failed to parse %s: %w", brandingFile, err)
+			}
+			for _, q := range r.branding.Queries {
+				parsed, err := query.Parse(q.Filter)
+				if err != nil {
+					return nil, fmt.Errorf("invalid query %q in %s: %w", q.Title, brandingFile, err)
+				}
+				r.queries = append(r.queries, parsed)
+			}
+		} else if !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to read %s: %w", brandingFile, err)
+		}
+	}
+
+	return r, nil
+}
+
+// loadTemplate returns the override template from templatesDir if present, or the built-in default.
+func loadTemplate(templatesDir, name string) (string, error) {
+	if templatesDir != "" {
+		data, err := os.ReadFile(filepath.Join(templatesDir, name))
+		if err == nil {
+			return string(data), nil
+		}
+		if !os.IsNotExist(err) {
+			return "", fmt.Errorf("failed to read template %s: %w", name, err)
+		}
+	}
+	data, err := defaultTemplates.ReadFile("templates/" + name)
+	if err != nil {
+		return "", fmt.Errorf("failed to read built-in template %s: %w", name, err)
+	}
+	return string(data), nil
+}
+
+// Render writes the report for a game in the given format.
+func (r *Renderer) Render(w io.Writer, format Format, report GameReport) error {
+	report.Branding = r.branding
+	if report.GeneratedAt.IsZero() {
+		report.GeneratedAt = time.Now()
+	}
+	if report.Title == "" {
+		report.Title = fmt.Sprintf("%s vs %s", report.Game.White.Username, report.Game.Black.Username)
+	}
+	if len(r.queries) > 0 && report.Queries == nil {
+		rows, err := query.MoveRows(report.Game, &gameengine.GameAnalysis{Moves: report.Moves}, "")
+		if err != nil {
+			return fmt.Errorf("failed to evaluate report queries: %w", err)
+		}
+		for i, q := range r.queries {
+			report.Queries = append(report.Queries, QueryResult{
+				Title:  r.branding.Queries[i].Title,
+				Filter: q.String(),
+				Moves:  q.Filter(rows),
+			})
+		}
+	}
+
+	switch format {
+	case FormatHTML:
+		if report.Plies == nil {
+			report.prepareHTML()
+		}
+		return r.html.Execute(w, report)
+	case FormatMarkdown:
+		return r.markdown.Execute(w, report)
+	case FormatPDF:
+		var text strings.Builder
+		if err := r.pdf.Execute(&text, report); err != nil {
+			return err
+		}
+		return writePDF(w, text.String(), r.branding)
+	default:
+		return fmt.Errorf("unsupported report format: %s", format)
+	}
+}
+
+// WriteFile renders the report in the given format to path.
+func (r *Renderer) WriteFile(path string, format Format, report GameReport) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create report file: %w", err)
+	}
+	defer f.Close()
+	return r.Render(f, format, report)
+}
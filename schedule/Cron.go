@@ -0,0 +1,169 @@
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// descriptors are the shorthand schedules accepted in place of five fields.
+var descriptors = map[string]string{
+	"@hourly":  "0 * * * *",
+	"@daily":   "0 0 * * *",
+	"@weekly":  "0 0 * * 0",
+	"@monthly": "0 0 1 * *",
+}
+
+var (
+	monthNames = []string{"", "JAN", "FEB", "MAR", "APR", "MAY", "JUN", "JUL", "AUG", "SEP", "OCT", "NOV", "DEC"}
+	dayNames   = []string{"SUN", "MON", "TUE", "WED", "THU", "FRI", "SAT"}
+)
+
+// Cron is a parsed cron schedule: minute, hour, day of month, month and day of week.
+type Cron struct {
+	spec                        string
+	minutes, hours, days        []bool
+	months, weekdays            []bool
+	anyDayOfMonth, anyDayOfWeek bool
+}
+
+// Parse parses a five-field cron expression such as "0 20 * * SUN" (every Sunday at 20:00) or
+// "*/30 8-18 * * MON-FRI", or one of @hourly, @daily, @weekly and @monthly. Fields accept *, numbers,
+// ranges (a-b), steps (*/n, a-b/n) and comma-separated lists; months and days of the week also accept
+// three-letter English names, and Sunday is 0 or 7. As in cron, a time matches when both the day of
+// month and the day of week are unrestricted, or when either restricted one matches.
+func Parse(spec string) (*Cron, error) {
+	expanded := strings.TrimSpace(spec)
+	if descriptor, ok := descriptors[strings.ToLower(expanded)]; ok {
+		expanded = descriptor
+	}
+	fields := strings.Fields(expanded)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("schedule %q: expected 5 fields (minute hour day-of-month month day-of-week)", spec)
+	}
+
+	c := &Cron{spec: spec}
+	var err error
+	if c.minutes, err = parseField(fields[0], 0, 59, nil); err != nil {
+		return nil, fmt.Errorf("schedule %q: minute: %w", spec, err)
+	}
+	if c.hours, err = parseField(fields[1], 0, 23, nil); err != nil {
+		return nil, fmt.Errorf("schedule %q: hour: %w", spec, err)
+	}
+	if c.days, err = parseField(fields[2], 1, 31, nil); err != nil {
+		return nil, fmt.Errorf("schedule %q: day of month: %w", spec, err)
+	}
+	if c.months, err = parseField(fields[3], 1, 12, monthNames); err != nil {
+		return nil, fmt.Errorf("schedule %q: month: %w", spec, err)
+	}
+	if c.weekdays, err = parseField(fields[4], 0, 7, dayNames); err != nil {
+		return nil, fmt.Errorf("schedule %q: day of week: %w", spec, err)
+	}
+	c.weekdays[0] = c.weekdays[0] || c.weekdays[7]
+	c.anyDayOfMonth = strings.HasPrefix(fields[2], "*")
+	c.anyDayOfWeek = strings.HasPrefix(fields[4], "*")
+	return c, nil
+}
+
+// parseField returns which values between min and max a cron field selects. names, if not nil,
+// maps values (by index) to names accepted in place of numbers.
+func parseField(field string, min, max int, names []string) ([]bool, error) {
+	selected := make([]bool, max+1)
+	for _, part := range strings.Split(field, ",") {
+		rangePart, step := part, 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("invalid step in %q", part)
+			}
+			rangePart, step = part[:i], n
+		}
+
+		low, high := min, max
+		if rangePart != "*" {
+			bounds := strings.SplitN(rangePart, "-", 2)
+			var err error
+			if low, err = parseValue(bounds[0], names); err != nil {
+				return nil, err
+			}
+			high = low
+			if len(bounds) == 2 {
+				if high, err = parseValue(bounds[1], names); err != nil {
+					return nil, err
+				}
+			} else if step > 1 {
+				// "a/n" means from a to the end of the range.
+				high = max
+			}
+		}
+		if low < min || high > max || low > high {
+			return nil, fmt.Errorf("%q is outside %d-%d", part, min, max)
+		}
+		for v := low; v <= high; v += step {
+			selected[v] = true
+		}
+	}
+	return selected, nil
+}
+
+// parseValue parses a number or, if names is not nil, a name.
+func parseValue(value string, names []string) (int, error) {
+	for i, name := range names {
+		if name != "" && strings.EqualFold(value, name) {
+			return i, nil
+		}
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q", value)
+	}
+	return n, nil
+}
+
+// matchesDay reports whether the schedule runs on the day of t.
+func (c *Cron) matchesDay(t time.Time) bool {
+	if !c.months[int(t.Month())] {
+		return false
+	}
+	dayOfMonth, dayOfWeek := c.days[t.Day()], c.weekdays[int(t.Weekday())]
+	switch {
+	case c.anyDayOfMonth && c.anyDayOfWeek:
+		return true
+	case c.anyDayOfMonth:
+		return dayOfWeek
+	case c.anyDayOfWeek:
+		return dayOfMonth
+	default:
+		return dayOfMonth || dayOfWeek
+	}
+}
+
+// Next returns the first time after t, to the minute, at which the schedule runs, in t's location.
+// It returns the zero time if the schedule never runs, such as on February 30th.
+func (c *Cron) Next(t time.Time) time.Time {
+	next := t.Truncate(time.Minute).Add(time.Minute)
+	// Every schedule repeats within a few years; stop searching after that.
+	limit := next.AddDate(5, 0, 0)
+	for next.Before(limit) {
+		if !c.matchesDay(next) {
+			next = time.Date(next.Year(), next.Month(), next.Day()+1, 0, 0, 0, 0, next.Location())
+			continue
+		}
+		if !c.hours[next.Hour()] {
+			next = time.Date(next.Year(), next.Month(), next.Day(), next.Hour()+1, 0, 0, 0, next.Location())
+			continue
+		}
+		if !c.minutes[next.Minute()] {
+			next = next.Add(time.Minute)
+			continue
+		}
+		return next
+	}
+	return time.Time{}
+}
+
+// String returns the expression the schedule was parsed from.
+func (c *Cron) String() string {
+	return c.spec
+}
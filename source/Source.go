@@ -0,0 +1,216 @@
+// Package source fetches the games of players from Chess.com and Lichess. It is the fetching part
+// of the public API of the analyser, together with packages engine and analysis.
+package source
+
+import (
+	"chessAnalyserFree/api"
+	"chessAnalyserFree/lichess"
+	pgnimport "chessAnalyserFree/pgnImport"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Game is a game of a player, as the sources fetch it and the analysis reads it.
+type Game struct {
+	URL         string // Address of the game on the source's site.
+	PGN         string
+	TimeControl string // e.g. "180+2"
+	EndTime     int64  // Unix time the game ended.
+	Rated       bool
+	FEN         string // Final position.
+	TimeClass   string // e.g. "blitz"
+	Rules       string // "chess", "chess960" or a variant such as "crazyhouse".
+	White       Player
+	Black       Player
+}
+
+// Player is one side of a game.
+type Player struct {
+	Username string
+	Rating   int
+	Result   string // e.g. "win", "checkmated", "resigned" or "agreed"
+	Bot      bool   // Whether the account is an engine or bot account.
+}
+
+// Source is a provider of a player's games over a date range.
+type Source interface {
+	// Name identifies the source, e.g. "chesscom".
+	Name() string
+	// FetchGames returns the games the user finished from from to to.
+	FetchGames(username string, from, to time.Time) ([]Game, error)
+}
+
+// client is a Source fetching games with a client of package api or lichess.
+type client struct {
+	api.GameSource
+}
+
+// FetchGames returns the games the user finished from from to to.
+func (c client) FetchGames(username string, from, to time.Time) ([]Game, error) {
+	games, err := c.GameSource.FetchGames(username, from, to)
+	converted := make([]Game, len(games))
+	for i, game := range games {
+		converted[i] = newGame(game)
+	}
+	return converted, err
+}
+
+// newGame returns a game of package api as a Game.
+func newGame(game api.Game) Game {
+	return Game{
+		URL: game.URL, PGN: game.PGN, TimeControl: game.TimeControl, EndTime: game.EndTime, Rated: game.Rated,
+		FEN: game.FEN, TimeClass: game.TimeClass, Rules: game.Rules, White: newPlayer(game.White), Black: newPlayer(game.Black),
+	}
+}
+
+// newPlayer returns a player of package api as a Player.
+func newPlayer(player api.Player) Player {
+	return Player{Username: player.Username, Rating: player.Rating, Result: player.Result, Bot: player.Bot}
+}
+
+// Names are the sources Open knows.
+var Names = []string{"chesscom", "lichess"}
+
+// Options are the settings of a source opened by Open or OpenClient.
+type Options struct {
+	// PGNArchives downloads Chess.com monthly archives in PGN format, which is smaller, but whose
+	// games only carry what the PGN tags tell.
+	PGNArchives bool
+	// Retries is the retry budget of Chess.com requests failing with a server or network error.
+	Retries int
+}
+
+// DefaultOptions returns the options the command line opens sources with unless told otherwise.
+func DefaultOptions() Options {
+	return Options{Retries: api.DefaultRetries}
+}
+
+// Open returns the named source, "chesscom" or "lichess", with the options applied.
+func Open(name string, options Options) (Source, error) {
+	c, err := OpenClient(name, options)
+	if err != nil {
+		return nil, err
+	}
+	return client{c}, nil
+}
+
+// OpenClient returns the client of package api or lichess behind the named source, for programs
+// working with the games of package api, as the command line does.
+func OpenClient(name string, options Options) (api.GameSource, error) {
+	switch name {
+	case "chesscom":
+		chesscom := api.NewClient()
+		chesscom.Retries = options.Retries
+		if options.PGNArchives {
+			chesscom.PGNDecoder = pgnimport.ReadGames
+		}
+		return chesscom, nil
+	case "lichess":
+		return lichess.NewClient(), nil
+	}
+	return nil, fmt.Errorf("unknown game source %q, use 'chesscom' or 'lichess'", name)
+}
+
+// FetchesMonthly reports whether a source, or a client of OpenClient, fetches a player's games one
+// monthly archive at a time.
+func FetchesMonthly(source interface{ Name() string }) bool {
+	return source.Name() == "chesscom"
+}
+
+// Months returns the first day of every month from start's month to end's month.
+func Months(start, end time.Time) []time.Time {
+	var months []time.Time
+	for d := time.Date(start.Year(), start.Month(), 1, 0, 0, 0, 0, time.UTC); !d.After(end); d = d.AddDate(0, 1, 0) {
+		months = append(months, d)
+	}
+	return months
+}
+
+// PlannedRequests returns the number of requests fetching the games of players from start's month
+// to end's month makes.
+func PlannedRequests(source interface{ Name() string }, players int, start, end time.Time) int {
+	if !FetchesMonthly(source) {
+		// Other sources export a player's whole range in a single streamed request.
+		return players
+	}
+	return players * len(Months(start, end))
+}
+
+// Fetch downloads the games of every user from the first day of start's month to the last day of
+// end's month, concurrency downloads at a time: a month of a user's from sources that fetch month by
+// month, and a user's whole range from the others. The games keep the order of the users and months
+// whatever order the downloads finish in. progress, if not nil, is called after every download with
+// the number done and the total, one call at a time. The errors of every user are returned joined,
+// at the user's index, with those repeating the same message reported once: a closed account or
+// maintenance fails every month of a user alike.
+func Fetch(source Source, usernames []string, start, end time.Time, concurrency int, progress func(done, total int)) ([]Game, []error) {
+	return fetch(source.FetchGames, FetchesMonthly(source), usernames, start, end, concurrency, progress)
+}
+
+// FetchClient downloads the games of every user with a client of OpenClient as Fetch does.
+func FetchClient(client api.GameSource, usernames []string, start, end time.Time, concurrency int, progress func(done, total int)) ([]api.Game, []error) {
+	return fetch(client.FetchGames, FetchesMonthly(client), usernames, start, end, concurrency, progress)
+}
+
+// fetch downloads the games of every user with fetchGames as Fetch does, a month at a time if monthly.
+func fetch[G any](fetchGames func(username string, from, to time.Time) ([]G, error), monthly bool, usernames []string, start, end time.Time, concurrency int, progress func(done, total int)) ([]G, []error) {
+	type download struct {
+		user     int // Index in usernames.
+		from, to time.Time
+		games    []G
+		err      error
+	}
+	var downloads []*download
+	endOfRange := end.AddDate(0, 1, 0).Add(-time.Second)
+	for i := range usernames {
+		if !monthly {
+			downloads = append(downloads, &download{user: i, from: start, to: endOfRange})
+			continue
+		}
+		for _, month := range Months(start, end) {
+			downloads = append(downloads, &download{user: i, from: month, to: month.AddDate(0, 1, 0).Add(-time.Second)})
+		}
+	}
+
+	var mu sync.Mutex
+	done := 0
+	next := make(chan *download)
+	var wg sync.WaitGroup
+	for range max(min(concurrency, len(downloads)), 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for d := range next {
+				d.games, d.err = fetchGames(usernames[d.user], d.from, d.to)
+				if progress != nil {
+					mu.Lock()
+					done++
+					progress(done, len(downloads))
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	for _, d := range downloads {
+		next <- d
+	}
+	close(next)
+	wg.Wait()
+
+	var games []G
+	userErrs := make([][]error, len(usernames))
+	for _, d := range downloads {
+		if d.err != nil && !slices.ContainsFunc(userErrs[d.user], func(err error) bool { return err.Error() == d.err.Error() }) {
+			userErrs[d.user] = append(userErrs[d.user], d.err)
+		}
+		games = append(games, d.games...)
+	}
+	errs := make([]error, len(usernames))
+	for i := range usernames {
+		errs[i] = errors.Join(userErrs[i]...)
+	}
+	return games, errs
+}
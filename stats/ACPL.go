@@ -0,0 +1,200 @@
+package stats
+
+import (
+	gameengine "chessAnalyserFree/gameEngine"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+)
+
+// ratingBands are the opponent rating bands of the centipawn loss breakdown, by the opponent's
+// rating less the player's, from the weakest opponents up: a difference belongs to the first band
+// whose upper bound it is below.
+var ratingBands = []struct {
+	name  string
+	below int
+}{
+	{"200+ lower", -199},
+	{"100-199 lower", -99},
+	{"-99 to +99", 100},
+	{"100-199 higher", 200},
+	{"200+ higher", 1 << 30},
+}
+
+// LossAverage is the average centipawn loss over the analysed moves of a number of games.
+type LossAverage struct {
+	Games    int
+	Moves    int
+	Blunders int
+	loss     int
+}
+
+// add counts a game's side.
+func (l *LossAverage) add(side gameengine.SideAccuracy) {
+	l.Games++
+	l.Moves += side.Moves
+	l.Blunders += side.Blunders
+	for _, loss := range side.Losses {
+		l.loss += loss
+	}
+}
+
+// merge adds the games of another average.
+func (l *LossAverage) merge(other LossAverage) {
+	l.Games += other.Games
+	l.Moves += other.Moves
+	l.Blunders += other.Blunders
+	l.loss += other.loss
+}
+
+// ACPL returns the average centipawn loss per move, or 0 without moves.
+func (l LossAverage) ACPL() float64 {
+	if l.Moves == 0 {
+		return 0
+	}
+	return float64(l.loss) / float64(l.Moves)
+}
+
+// LossGroup is the average centipawn loss of one time class or rating band.
+type LossGroup struct {
+	Name string
+	LossAverage
+}
+
+// ACPLBreakdown is a player's average centipawn loss by time class and by the opponent's rating
+// relative to theirs.
+type ACPLBreakdown struct {
+	Player      string
+	All         LossAverage
+	TimeClasses []LossGroup // Bullet, blitz and rapid first, then any other time class played.
+	RatingBands []LossGroup // One per rating band, from the weakest opponents up.
+	Unrated     int         // Analysed games left out of the rating bands for a missing rating.
+}
+
+// BuildACPLBreakdown averages the centipawn loss of the player's moves in the analysed games, book
+// moves left out, by time class and by rating band. Games whose time class is unknown only count
+// towards all games.
+func BuildACPLBreakdown(games []AnalysedGame, player string) ACPLBreakdown {
+	breakdown := ACPLBreakdown{Player: player}
+	byTimeClass := make(map[string]*LossAverage)
+	bands := make([]LossAverage, len(ratingBands))
+	for _, analysed := range games {
+		game := analysed.Game
+		colour, own, opponent, played := sides(game, player)
+		if !played || analysed.Analysis == nil || !analysed.Analysis.IsValid() {
+			continue
+		}
+		side, black := gameengine.GameAccuracy(game.PGN, analysed.Analysis)
+		if colour == "black" {
+			side = black
+		}
+		if side.Moves == 0 {
+			continue
+		}
+		breakdown.All.add(side)
+		if game.TimeClass != "" {
+			if byTimeClass[game.TimeClass] == nil {
+				byTimeClass[game.TimeClass] = &LossAverage{}
+			}
+			byTimeClass[game.TimeClass].add(side)
+		}
+		if own.Rating <= 0 || opponent.Rating <= 0 {
+			breakdown.Unrated++
+			continue
+		}
+		difference := opponent.Rating - own.Rating
+		for i, band := range ratingBands {
+			if difference < band.below {
+				bands[i].add(side)
+				break
+			}
+		}
+	}
+	for timeClass, average := range byTimeClass {
+		breakdown.TimeClasses = append(breakdown.TimeClasses, LossGroup{timeClass, *average})
+	}
+	sort.Slice(breakdown.TimeClasses, func(i, j int) bool {
+		a, b := breakdown.TimeClasses[i].Name, breakdown.TimeClasses[j].Name
+		rankA, rankB := slices.Index(trendTimeClasses, a), slices.Index(trendTimeClasses, b)
+		if rankA < 0 {
+			rankA = len(trendTimeClasses)
+		}
+		if rankB < 0 {
+			rankB = len(trendTimeClasses)
+		}
+		if rankA != rankB {
+			return rankA < rankB
+		}
+		return a < b
+	})
+	for i, band := range ratingBands {
+		breakdown.RatingBands = append(breakdown.RatingBands, LossGroup{band.name, bands[i]})
+	}
+	return breakdown
+}
+
+// Tables returns the average centipawn loss by time class and by rating band for export.
+func (a ACPLBreakdown) Tables() []Table {
+	table := Table{Name: "acpl", Columns: []string{"group", "name", "games", "moves", "acpl", "blunders"}}
+	row := func(group, name string, average LossAverage) []any {
+		acpl := any(nil)
+		if average.Moves > 0 {
+			acpl = average.ACPL()
+		}
+		return []any{group, name, average.Games, average.Moves, acpl, average.Blunders}
+	}
+	table.Rows = append(table.Rows, row("all", "all", a.All))
+	for _, groups := range []struct {
+		name   string
+		groups []LossGroup
+	}{{"time_class", a.TimeClasses}, {"rating_band", a.RatingBands}} {
+		for _, group := range groups.groups {
+			table.Rows = append(table.Rows, row(groups.name, group.Name, group.LossAverage))
+		}
+	}
+	return []Table{table}
+}
+
+// Write prints the average centipawn loss overall, by time class and by rating band, and compares
+// the games against weaker opponents with those against stronger ones.
+func (a ACPLBreakdown) Write(w io.Writer) {
+	fmt.Fprintf(w, "\n--- Average centipawn loss of %s (%d analysed games) ---\n", a.Player, a.All.Games)
+	if a.All.Games == 0 {
+		fmt.Fprintln(w, "No analysed games of the player.")
+		fmt.Fprintln(w, "---------------------")
+		return
+	}
+	fmt.Fprintf(w, "Overall: %.1f over %d moves, %.2f blunders per game\n", a.All.ACPL(), a.All.Moves,
+		float64(a.All.Blunders)/float64(a.All.Games))
+	writeLossGroups(w, "Time class", a.TimeClasses)
+	writeLossGroups(w, "Opponent", a.RatingBands)
+	if a.Unrated > 0 {
+		fmt.Fprintf(w, "%d games without both ratings are left out of the opponent bands.\n", a.Unrated)
+	}
+
+	var weaker, stronger LossAverage
+	for _, band := range a.RatingBands[:2] {
+		weaker.merge(band.LossAverage)
+	}
+	for _, band := range a.RatingBands[3:] {
+		stronger.merge(band.LossAverage)
+	}
+	if weaker.Moves > 0 && stronger.Moves > 0 {
+		fmt.Fprintf(w, "\nAgainst opponents rated 100 or more below: %.1f; 100 or more above: %.1f (%+.1f).\n",
+			weaker.ACPL(), stronger.ACPL(), stronger.ACPL()-weaker.ACPL())
+	}
+	fmt.Fprintln(w, "---------------------")
+}
+
+// writeLossGroups prints a table of centipawn losses, leaving out groups without games.
+func writeLossGroups(w io.Writer, title string, groups []LossGroup) {
+	fmt.Fprintf(w, "\n%-14s | Games | Moves | ACPL  | Blunders/game\n", title)
+	for _, group := range groups {
+		if group.Games == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "%-14s | %5d | %5d | %5.1f | %.2f\n", group.Name, group.Games, group.Moves, group.ACPL(),
+			float64(group.Blunders)/float64(group.Games))
+	}
+}
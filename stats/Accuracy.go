@@ -0,0 +1,189 @@
+package stats
+
+import (
+	"chessAnalyserFree/api"
+	gameengine "chessAnalyserFree/gameEngine"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// trendTimeClasses are the time classes the accuracy trend always has a column for; others get one
+// when the player has analysed games of them.
+var trendTimeClasses = []string{"bullet", "blitz", "rapid"}
+
+// AnalysedGame is a game with its engine analysis.
+type AnalysedGame struct {
+	Game     api.Game
+	Analysis *gameengine.GameAnalysis
+}
+
+// AccuracyAverage is the average accuracy of a number of games.
+type AccuracyAverage struct {
+	Games int
+	sum   float64
+}
+
+// add counts a game of the given accuracy.
+func (a *AccuracyAverage) add(accuracy float64) {
+	a.Games++
+	a.sum += accuracy
+}
+
+// Accuracy returns the average accuracy, 0 to 100, or 0 without games.
+func (a AccuracyAverage) Accuracy() float64 {
+	if a.Games == 0 {
+		return 0
+	}
+	return a.sum / float64(a.Games)
+}
+
+// AccuracyMonth is a player's average accuracy over the games of a month.
+type AccuracyMonth struct {
+	Month       string // YYYY-MM.
+	All         AccuracyAverage
+	ByTimeClass map[string]AccuracyAverage
+}
+
+// AccuracyTrend is a player's average accuracy by month and time class.
+type AccuracyTrend struct {
+	Player      string
+	TimeClasses []string        // The columns: bullet, blitz, rapid and any other time class played.
+	Months      []AccuracyMonth // Oldest first; months without analysed games are left out.
+}
+
+// BuildAccuracyTrend averages the player's accuracy in the analysed games by the month they ended
+// in and by time class. Games the player did not play and invalid analyses are left out; games of
+// an unknown time class only count towards all games.
+func BuildAccuracyTrend(games []AnalysedGame, player string) AccuracyTrend {
+	trend := AccuracyTrend{Player: player, TimeClasses: slices.Clone(trendTimeClasses)}
+	months := make(map[string]*AccuracyMonth)
+	for _, analysed := range games {
+		game := analysed.Game
+		colour, _, _, played := sides(game, player)
+		if !played || analysed.Analysis == nil || !analysed.Analysis.IsValid() {
+			continue
+		}
+		side, black := gameengine.GameAccuracy(game.PGN, analysed.Analysis)
+		if colour == "black" {
+			side = black
+		}
+		if side.Moves == 0 {
+			continue
+		}
+		key := time.Unix(game.EndTime, 0).Format("2006-01")
+		month := months[key]
+		if month == nil {
+			month = &AccuracyMonth{Month: key, ByTimeClass: make(map[string]AccuracyAverage)}
+			months[key] = month
+		}
+		month.All.add(side.Accuracy)
+		if game.TimeClass == "" {
+			continue
+		}
+		average := month.ByTimeClass[game.TimeClass]
+		average.add(side.Accuracy)
+		month.ByTimeClass[game.TimeClass] = average
+		if !slices.Contains(trend.TimeClasses, game.TimeClass) {
+			trend.TimeClasses = append(trend.TimeClasses, game.TimeClass)
+		}
+	}
+	for _, month := range months {
+		trend.Months = append(trend.Months, *month)
+	}
+	sort.Slice(trend.Months, func(i, j int) bool { return trend.Months[i].Month < trend.Months[j].Month })
+	return trend
+}
+
+// Change returns how much the average accuracy in a time class, or in all games when timeClass is
+// empty, changed from the first month with games of it to the last, and whether there were two
+// such months.
+func (t AccuracyTrend) Change(timeClass string) (float64, bool) {
+	var first, last AccuracyAverage
+	for _, month := range t.Months {
+		average := month.All
+		if timeClass != "" {
+			average = month.ByTimeClass[timeClass]
+		}
+		if average.Games == 0 {
+			continue
+		}
+		if first.Games == 0 {
+			first = average
+		} else {
+			last = average
+		}
+	}
+	if last.Games == 0 {
+		return 0, false
+	}
+	return last.Accuracy() - first.Accuracy(), true
+}
+
+// Table returns the trend as a table: a header, then a row for every month with the average
+// accuracy and number of games in every time class and over all games, e.g. "81.4 (12)", and a last
+// row with the change from the first month to the last.
+func (t AccuracyTrend) Table() (header []string, rows [][]string) {
+	header = append(append([]string{"Month"}, t.TimeClasses...), "all")
+	cell := func(average AccuracyAverage) string {
+		if average.Games == 0 {
+			return "-"
+		}
+		return fmt.Sprintf("%.1f (%d)", average.Accuracy(), average.Games)
+	}
+	for _, month := range t.Months {
+		row := []string{month.Month}
+		for _, timeClass := range t.TimeClasses {
+			row = append(row, cell(month.ByTimeClass[timeClass]))
+		}
+		rows = append(rows, append(row, cell(month.All)))
+	}
+	change := []string{"Change"}
+	for _, timeClass := range append(slices.Clone(t.TimeClasses), "") {
+		points, ok := t.Change(timeClass)
+		if !ok {
+			change = append(change, "-")
+			continue
+		}
+		change = append(change, fmt.Sprintf("%+.1f", points))
+	}
+	return header, append(rows, change)
+}
+
+// Tables returns the trend for export, a row per month and time class with games, "all" for all
+// games of the month.
+func (t AccuracyTrend) Tables() []Table {
+	table := Table{Name: "accuracy", Columns: []string{"month", "time_class", "games", "accuracy"}}
+	for _, month := range t.Months {
+		for _, timeClass := range t.TimeClasses {
+			if average := month.ByTimeClass[timeClass]; average.Games > 0 {
+				table.Rows = append(table.Rows, []any{month.Month, timeClass, average.Games, average.Accuracy()})
+			}
+		}
+		table.Rows = append(table.Rows, []any{month.Month, "all", month.All.Games, month.All.Accuracy()})
+	}
+	return []Table{table}
+}
+
+// Write prints the trend as a table.
+func (t AccuracyTrend) Write(w io.Writer) {
+	fmt.Fprintf(w, "\n--- Accuracy of %s by month ---\n", t.Player)
+	if len(t.Months) == 0 {
+		fmt.Fprintln(w, "No analysed games of the player.")
+		fmt.Fprintln(w, "---------------------")
+		return
+	}
+	header, rows := t.Table()
+	for _, row := range append([][]string{header}, rows...) {
+		line := fmt.Sprintf("%-7s", row[0])
+		for _, cell := range row[1:] {
+			line += fmt.Sprintf(" | %-10s", cell)
+		}
+		fmt.Fprintln(w, strings.TrimRight(line, " "))
+	}
+	fmt.Fprintln(w, "Cells: average accuracy (games). Change: from the first month with games to the last.")
+	fmt.Fprintln(w, "---------------------")
+}
@@ -0,0 +1,194 @@
+package stats
+
+import (
+	"chessAnalyserFree/api"
+	gameengine "chessAnalyserFree/gameEngine"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// MoveRecord is a player's results after one move.
+type MoveRecord struct {
+	Move   string // Numbered like a PGN, e.g. "1. e4" or "1... c5".
+	Record Record
+}
+
+// Reply is how a player answered one first move of the opponent's as Black.
+type Reply struct {
+	Against MoveRecord   // White's first move and the player's results against it.
+	Replies []MoveRecord // The player's answers, most played first.
+}
+
+// ColourRecord is a player's results with one colour.
+type ColourRecord struct {
+	Record      Record
+	Performance Performance
+	Openings    []OpeningRecord // Most played first.
+}
+
+// ColourStats is a player's results as White and as Black: overall, by opening, and by the first
+// moves they chose as White and the replies they chose as Black.
+type ColourStats struct {
+	Player     string
+	Policy     Policy
+	White      ColourRecord
+	Black      ColourRecord
+	FirstMoves []MoveRecord // The player's first moves as White, most played first.
+	Replies    []Reply      // By White's first move against the player, most played first.
+}
+
+// BuildColourStats splits the player's results in the games that count under the policy by the
+// colour they had. First moves and replies are taken from standard chess games from the starting
+// position only.
+func BuildColourStats(games []api.Game, player string, policy Policy) ColourStats {
+	colourStats := ColourStats{Player: player, Policy: policy}
+	var asWhite, asBlack []api.Game
+	firstMoves := make(map[string]*MoveRecord)
+	against := make(map[string]*MoveRecord)
+	answers := make(map[string]map[string]*MoveRecord)
+	for _, game := range policy.Filter(games, player) {
+		outcome, ok := Outcome(game, player)
+		colour, _, opponent, played := sides(game, player)
+		if !ok || !played {
+			continue
+		}
+		record := &colourStats.Black
+		if colour == "white" {
+			record = &colourStats.White
+			asWhite = append(asWhite, game)
+		} else {
+			asBlack = append(asBlack, game)
+		}
+		record.Record.add(outcome)
+		record.Performance.add(opponent.Rating, outcome)
+
+		positions, err := gameengine.ReplayPositions(game.PGN)
+		if err != nil || len(positions) == 0 || game.Rules != "chess" && game.Rules != "" || gameengine.StartFEN(game.PGN) != gameengine.StandardStartFEN {
+			continue
+		}
+		first := numberedMove(positions[0])
+		if colour == "white" {
+			moveRecord(firstMoves, first).Record.add(outcome)
+			continue
+		}
+		moveRecord(against, first).Record.add(outcome)
+		if len(positions) > 1 {
+			if answers[first] == nil {
+				answers[first] = make(map[string]*MoveRecord)
+			}
+			moveRecord(answers[first], numberedMove(positions[1])).Record.add(outcome)
+		}
+	}
+	// The games have been chosen already, so all of them make up the openings.
+	all := Policy{IncludeUnrated: true, IncludeBots: true}
+	white, _ := Openings(asWhite, player, all, "frequency")
+	black, _ := Openings(asBlack, player, all, "frequency")
+	colourStats.White.Openings, colourStats.Black.Openings = white.Openings, black.Openings
+
+	colourStats.FirstMoves = mostPlayed(firstMoves)
+	for _, move := range mostPlayed(against) {
+		colourStats.Replies = append(colourStats.Replies, Reply{Against: move, Replies: mostPlayed(answers[move.Move])})
+	}
+	return colourStats
+}
+
+// moveRecord returns the record of a move, adding it if it is new.
+func moveRecord(records map[string]*MoveRecord, move string) *MoveRecord {
+	if records[move] == nil {
+		records[move] = &MoveRecord{Move: move}
+	}
+	return records[move]
+}
+
+// mostPlayed returns the moves from the most played down.
+func mostPlayed(records map[string]*MoveRecord) []MoveRecord {
+	moves := make([]MoveRecord, 0, len(records))
+	for _, move := range records {
+		moves = append(moves, *move)
+	}
+	sort.Slice(moves, func(i, j int) bool {
+		if moves[i].Record.Games != moves[j].Record.Games {
+			return moves[i].Record.Games > moves[j].Record.Games
+		}
+		return moves[i].Move < moves[j].Move
+	})
+	return moves
+}
+
+// Tables returns the results by colour, the first moves as White and the replies as Black for
+// export.
+func (c ColourStats) Tables() []Table {
+	colours := Table{Name: "colours", Columns: append(append([]string{"colour"}, recordColumns...), "performance")}
+	for _, side := range []struct {
+		name   string
+		record ColourRecord
+	}{{"white", c.White}, {"black", c.Black}} {
+		performance := any(nil)
+		if side.record.Performance.Games > 0 {
+			performance = side.record.Performance.Rating()
+		}
+		colours.Rows = append(colours.Rows, append(append([]any{side.name}, recordCells(side.record.Record)...), performance))
+	}
+	firstMoves := Table{Name: "first_moves", Columns: append([]string{"move"}, recordColumns...)}
+	for _, move := range c.FirstMoves {
+		firstMoves.Rows = append(firstMoves.Rows, append([]any{move.Move}, recordCells(move.Record)...))
+	}
+	replies := Table{Name: "replies", Columns: append([]string{"against", "reply"}, recordColumns...)}
+	for _, reply := range c.Replies {
+		for _, move := range reply.Replies {
+			replies.Rows = append(replies.Rows, append([]any{reply.Against.Move, move.Move}, recordCells(move.Record)...))
+		}
+	}
+	return []Table{colours, firstMoves, replies}
+}
+
+// Write prints the results as White, with the first moves and the openings, then as Black, with
+// the replies to every first move and the openings.
+func (c ColourStats) Write(w io.Writer) {
+	fmt.Fprintf(w, "\n--- Results of %s by colour ---\n", c.Player)
+	fmt.Fprintf(w, "Policy: %s\n", c.Policy.Describe())
+	for _, side := range []struct {
+		name   string
+		record ColourRecord
+	}{{"White", c.White}, {"Black", c.Black}} {
+		record := side.record.Record
+		fmt.Fprintf(w, "\nAs %s: %d games, +%d =%d -%d (%.1f%%)%s\n", side.name, record.Games, record.Wins, record.Draws,
+			record.Losses, record.Score(), side.record.Performance.describe())
+		if record.Games == 0 {
+			continue
+		}
+		if side.name == "White" {
+			fmt.Fprintln(w, "First moves:")
+			total := 0
+			for _, move := range c.FirstMoves {
+				total += move.Record.Games
+			}
+			for _, move := range c.FirstMoves {
+				writeMoveRecord(w, "  ", move, total)
+			}
+		} else {
+			fmt.Fprintln(w, "Replies:")
+			total := 0
+			for _, reply := range c.Replies {
+				total += reply.Against.Record.Games
+			}
+			for _, reply := range c.Replies {
+				writeMoveRecord(w, "  against ", reply.Against, total)
+				for _, move := range reply.Replies {
+					writeMoveRecord(w, "    ", move, reply.Against.Record.Games)
+				}
+			}
+		}
+		fmt.Fprintln(w, "Openings:")
+		writeOpeningRows(w, side.record.Openings)
+	}
+	fmt.Fprintln(w, "---------------------")
+}
+
+// writeMoveRecord prints a move with its share of total games and the score after it.
+func writeMoveRecord(w io.Writer, indent string, move MoveRecord, total int) {
+	record := move.Record
+	fmt.Fprintf(w, "%s%-8s %4d games (%3.0f%%)  +%-3d =%-3d -%-3d %5.1f%%\n", indent, move.Move, record.Games,
+		100*float64(record.Games)/float64(total), record.Wins, record.Draws, record.Losses, record.Score())
+}
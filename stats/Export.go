@@ -0,0 +1,100 @@
+package stats
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// Table is a statistic as rows and columns, for export to JSON and CSV.
+type Table struct {
+	Name    string   // E.g. "openings": the key in the JSON export and the name of the CSV file.
+	Columns []string // Snake case, e.g. "time_class".
+	Rows    [][]any  // Cells are strings, ints, float64s or nil for no value, one per column.
+}
+
+// recordCells returns the cells of a record: games, wins, draws, losses and score.
+func recordCells(r Record) []any {
+	return []any{r.Games, r.Wins, r.Draws, r.Losses, r.Score()}
+}
+
+// recordColumns are the columns of recordCells.
+var recordColumns = []string{"games", "wins", "draws", "losses", "score"}
+
+// exportCell rounds floats to two decimals; other cells are kept as they are.
+func exportCell(cell any) any {
+	if value, ok := cell.(float64); ok {
+		return math.Round(value*100) / 100
+	}
+	return cell
+}
+
+// WriteTablesJSON writes the tables as one JSON document: the player, the policy and, by table
+// name, the rows as objects keyed by column.
+func WriteTablesJSON(w io.Writer, player string, policy Policy, tables []Table) error {
+	document := struct {
+		Player string                      `json:"player"`
+		Policy string                      `json:"policy"`
+		Tables map[string][]map[string]any `json:"tables"`
+	}{Player: player, Policy: policy.Describe(), Tables: make(map[string][]map[string]any)}
+	for _, table := range tables {
+		rows := make([]map[string]any, 0, len(table.Rows))
+		for _, row := range table.Rows {
+			object := make(map[string]any, len(row))
+			for i, cell := range row {
+				object[table.Columns[i]] = exportCell(cell)
+			}
+			rows = append(rows, object)
+		}
+		document.Tables[table.Name] = rows
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(document)
+}
+
+// WriteTablesCSV writes every table to its own CSV file in dir, named after the table, with a
+// header row of the columns.
+func WriteTablesCSV(dir string, tables []Table) error {
+	for _, table := range tables {
+		if err := writeTableCSV(filepath.Join(dir, table.Name+".csv"), table); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeTableCSV writes one table to path.
+func writeTableCSV(path string, table Table) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	writer := csv.NewWriter(file)
+	writer.Write(table.Columns)
+	for _, row := range table.Rows {
+		record := make([]string, len(row))
+		for i, cell := range row {
+			switch value := exportCell(cell).(type) {
+			case nil:
+				record[i] = ""
+			case float64:
+				record[i] = strconv.FormatFloat(value, 'f', -1, 64)
+			default:
+				record[i] = fmt.Sprint(value)
+			}
+		}
+		writer.Write(record)
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		file.Close()
+		return fmt.Errorf("error writing %s: %w", path, err)
+	}
+	return file.Close()
+}
@@ -0,0 +1,145 @@
+package stats
+
+import (
+	"chessAnalyserFree/api"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// timeClasses are the time classes games can be filtered by.
+var timeClasses = map[string]bool{"bullet": true, "blitz": true, "rapid": true, "daily": true, "classical": true}
+
+// resultWords maps the words for results, singular and plural, to Outcome values.
+var resultWords = map[string]int{"win": 1, "wins": 1, "draw": 0, "draws": 0, "loss": -1, "losses": -1}
+
+// GameFilter narrows a list of games down to those of interest. Results, colour and opponent are
+// those of a player; the zero value keeps every game.
+type GameFilter struct {
+	TimeClasses []string // Time classes to keep; all when empty.
+	RatedOnly   bool
+	Results     []int  // Outcomes for the player to keep (1 win, 0 draw, -1 loss); all when empty.
+	Color       string // Side the player had, "white" or "black"; either when empty.
+	Opponent    string // Part of the opponent's username, in any case; anyone when empty.
+}
+
+// ParseGameFilter builds a filter from words such as "losses blitz": time classes (bullet, blitz,
+// rapid, daily, classical), "rated", results (win, draw, loss, or their plurals), a colour ("white"
+// or "black") and "vs <name>" for the opponent. Words of one kind add up: "blitz rapid" keeps both.
+func ParseGameFilter(words []string) (GameFilter, error) {
+	var f GameFilter
+	for i := 0; i < len(words); i++ {
+		word := strings.ToLower(words[i])
+		outcome, isResult := resultWords[word]
+		switch {
+		case timeClasses[word]:
+			f.TimeClasses = append(f.TimeClasses, word)
+		case word == "rated":
+			f.RatedOnly = true
+		case isResult:
+			f.Results = append(f.Results, outcome)
+		case word == "white" || word == "black":
+			if f.Color != "" && f.Color != word {
+				return GameFilter{}, errors.New("choose white or black, not both")
+			}
+			f.Color = word
+		case word == "vs":
+			if i+1 == len(words) {
+				return GameFilter{}, errors.New("vs needs an opponent name")
+			}
+			i++
+			f.Opponent = words[i]
+		default:
+			return GameFilter{}, fmt.Errorf("unknown filter %q", words[i])
+		}
+	}
+	return f, nil
+}
+
+// IsEmpty reports whether the filter keeps every game.
+func (f GameFilter) IsEmpty() bool {
+	return len(f.TimeClasses) == 0 && !f.RatedOnly && len(f.Results) == 0 && f.Color == "" && f.Opponent == ""
+}
+
+// NeedsPlayer reports whether the filter judges games from a player's point of view.
+func (f GameFilter) NeedsPlayer() bool {
+	return len(f.Results) > 0 || f.Color != "" || f.Opponent != ""
+}
+
+// Apply returns the games the filter keeps for player, in their original order. Games player did
+// not take part in are only kept by filters that do not need a player.
+func (f GameFilter) Apply(games []api.Game, player string) []api.Game {
+	var kept []api.Game
+	for _, game := range games {
+		if f.keeps(game, player) {
+			kept = append(kept, game)
+		}
+	}
+	return kept
+}
+
+// keeps reports whether the filter keeps a game.
+func (f GameFilter) keeps(game api.Game, player string) bool {
+	if len(f.TimeClasses) > 0 && !slices.Contains(f.TimeClasses, game.TimeClass) {
+		return false
+	}
+	if f.RatedOnly && !game.Rated {
+		return false
+	}
+	if !f.NeedsPlayer() {
+		return true
+	}
+	color, _, opponent, ok := sides(game, player)
+	if !ok {
+		return false
+	}
+	if f.Color != "" && f.Color != color {
+		return false
+	}
+	if f.Opponent != "" && !strings.Contains(strings.ToLower(opponent.Username), strings.ToLower(f.Opponent)) {
+		return false
+	}
+	if len(f.Results) > 0 {
+		outcome, ok := Outcome(game, player)
+		if !ok || !slices.Contains(f.Results, outcome) {
+			return false
+		}
+	}
+	return true
+}
+
+// Describe returns a short description of the filter, e.g. "blitz, losses, vs hikaru".
+func (f GameFilter) Describe() string {
+	var parts []string
+	parts = append(parts, f.TimeClasses...)
+	if f.RatedOnly {
+		parts = append(parts, "rated")
+	}
+	for _, outcome := range f.Results {
+		parts = append(parts, map[int]string{1: "wins", 0: "draws", -1: "losses"}[outcome])
+	}
+	if f.Color != "" {
+		parts = append(parts, "as "+f.Color)
+	}
+	if f.Opponent != "" {
+		parts = append(parts, "vs "+f.Opponent)
+	}
+	if len(parts) == 0 {
+		return "all games"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// sides returns the colour player had in a game, their side and their opponent's, and whether they
+// played the game at all.
+func sides(game api.Game, player string) (string, api.Player, api.Player, bool) {
+	switch {
+	case strings.EqualFold(game.White.Username, player):
+		return "white", game.White, game.Black, true
+	case strings.EqualFold(game.Black.Username, player):
+		return "black", game.Black, game.White, true
+	default:
+		return "", api.Player{}, api.Player{}, false
+	}
+}
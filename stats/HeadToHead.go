@@ -0,0 +1,108 @@
+package stats
+
+import (
+	"chessAnalyserFree/api"
+	gameengine "chessAnalyserFree/gameEngine"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// HeadToHead is a player's record against one opponent.
+type HeadToHead struct {
+	Player   string
+	Opponent string
+	Policy   Policy
+	Total    Record
+	White    Record // The games the player had White.
+	Black    Record
+	Openings []OpeningRecord // Most played first.
+	Games    []int           // Indices of the games in the list searched, oldest first.
+	// AverageMoves is the average length of the games in moves, over those whose moves could be read.
+	AverageMoves float64
+	games        []api.Game
+}
+
+// HeadToHeadGames returns the player's record against opponent, in any case, over the games
+// between them that count under the policy.
+func HeadToHeadGames(games []api.Game, player, opponent string, policy Policy) HeadToHead {
+	h := HeadToHead{Player: player, Opponent: opponent, Policy: policy, games: games}
+	var between []api.Game
+	plies, measured := 0, 0
+	for i, counts := range policy.counts(games, player) {
+		game := games[i]
+		colour, _, other, played := sides(game, player)
+		outcome, ok := Outcome(game, player)
+		if !counts || !played || !ok || !strings.EqualFold(other.Username, opponent) {
+			continue
+		}
+		h.Opponent = other.Username
+		h.Games = append(h.Games, i)
+		between = append(between, game)
+		h.Total.add(outcome)
+		if colour == "white" {
+			h.White.add(outcome)
+		} else {
+			h.Black.add(outcome)
+		}
+		if positions, err := gameengine.ReplayPositions(game.PGN); err == nil {
+			plies += len(positions)
+			measured++
+		}
+	}
+	sort.SliceStable(h.Games, func(a, b int) bool { return games[h.Games[a]].EndTime < games[h.Games[b]].EndTime })
+	if measured > 0 {
+		h.AverageMoves = float64(plies) / 2 / float64(measured)
+	}
+	// The games between them have been chosen already, so all of them make up the openings.
+	table, _ := Openings(between, player, Policy{IncludeUnrated: true, IncludeBots: true}, "frequency")
+	h.Openings = table.Openings
+	return h
+}
+
+// Write prints the record, the openings and the games with their links.
+func (h HeadToHead) Write(w io.Writer) {
+	fmt.Fprintf(w, "\n--- %s vs %s ---\n", h.Player, h.Opponent)
+	fmt.Fprintf(w, "Policy: %s\n", h.Policy.Describe())
+	if len(h.Games) == 0 {
+		fmt.Fprintln(w, "No games between them.")
+		fmt.Fprintln(w, "---------------------")
+		return
+	}
+	for _, side := range []struct {
+		name   string
+		record Record
+	}{{"Overall", h.Total}, {"As White", h.White}, {"As Black", h.Black}} {
+		fmt.Fprintf(w, "%-10s %d games, +%d =%d -%d (%.1f%%)\n", side.name+":", side.record.Games, side.record.Wins,
+			side.record.Draws, side.record.Losses, side.record.Score())
+	}
+	if h.AverageMoves > 0 {
+		fmt.Fprintf(w, "Length:    %.1f moves on average\n", h.AverageMoves)
+	}
+
+	fmt.Fprintln(w, "\nOpenings:")
+	for _, opening := range h.Openings {
+		name := strings.TrimSpace(opening.ECO + " " + opening.Name)
+		if name == "" {
+			name = "unknown"
+		}
+		fmt.Fprintf(w, "%4d games  +%-3d =%-3d -%-3d %5.1f%%  %s\n", opening.Record.Games, opening.Record.Wins,
+			opening.Record.Draws, opening.Record.Losses, opening.Record.Score(), name)
+	}
+
+	fmt.Fprintln(w, "\nGames:")
+	results := map[int]string{1: "won", 0: "drawn", -1: "lost"}
+	for _, i := range h.Games {
+		game := h.games[i]
+		colour, _, _, _ := sides(game, h.Player)
+		outcome, _ := Outcome(game, h.Player)
+		fmt.Fprintf(w, "[%d] %s %-6s %-5s as %-5s %s\n", i+1, time.Unix(game.EndTime, 0).Format("2006-01-02"),
+			game.TimeClass, results[outcome], colour, OpeningLabel(game))
+		if game.URL != "" {
+			fmt.Fprintf(w, "    %s\n", game.URL)
+		}
+	}
+	fmt.Fprintln(w, "---------------------")
+}
@@ -0,0 +1,152 @@
+package stats
+
+import (
+	"chessAnalyserFree/api"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// moveWordRegex matches a move number and move in an opening name, such as "4.c3" or "6...Be7" in
+// the names Chess.com derives from its ECOUrl tag, where the named opening ends.
+var moveWordRegex = regexp.MustCompile(`^\d+\.`)
+
+// OpeningRecord is a player's results in one opening.
+type OpeningRecord struct {
+	ECO    string
+	Name   string
+	Record Record
+	// OpponentRating is the average rating of the opponents, over the games with a known rating.
+	OpponentRating float64
+	ratingSum      int
+	ratedGames     int
+}
+
+// OpeningOrders are the orders of the openings table.
+var OpeningOrders = []string{"frequency", "worst"}
+
+// OpeningTable is a player's results by opening.
+type OpeningTable struct {
+	Player   string
+	Policy   Policy
+	Order    string // One of OpeningOrders.
+	Openings []OpeningRecord
+}
+
+// Openings returns the player's results in every opening of their games that count under the
+// policy, by ECO code and opening name, in order: "frequency", the most played first, or "worst",
+// the lowest score first.
+func Openings(games []api.Game, player string, policy Policy, order string) (OpeningTable, error) {
+	if order == "" {
+		order = "frequency"
+	}
+	if !slices.Contains(OpeningOrders, order) {
+		return OpeningTable{}, fmt.Errorf("unknown order %q (available: %s)", order, strings.Join(OpeningOrders, ", "))
+	}
+	byOpening := make(map[string]*OpeningRecord)
+	table := OpeningTable{Player: player, Policy: policy, Order: order}
+	for _, game := range policy.Filter(games, player) {
+		outcome, ok := Outcome(game, player)
+		_, _, opponent, played := sides(game, player)
+		if !ok || !played {
+			continue
+		}
+		eco, name := Opening(game)
+		name = openingName(name)
+		key := eco + "\x00" + name
+		opening := byOpening[key]
+		if opening == nil {
+			opening = &OpeningRecord{ECO: eco, Name: name}
+			byOpening[key] = opening
+		}
+		opening.Record.add(outcome)
+		if opponent.Rating > 0 {
+			opening.ratingSum += opponent.Rating
+			opening.ratedGames++
+		}
+	}
+	for _, opening := range byOpening {
+		if opening.ratedGames > 0 {
+			opening.OpponentRating = float64(opening.ratingSum) / float64(opening.ratedGames)
+		}
+		table.Openings = append(table.Openings, *opening)
+	}
+	sort.Slice(table.Openings, func(i, j int) bool {
+		a, b := table.Openings[i], table.Openings[j]
+		if order == "worst" && a.Record.Score() != b.Record.Score() {
+			return a.Record.Score() < b.Record.Score()
+		}
+		if a.Record.Games != b.Record.Games {
+			return a.Record.Games > b.Record.Games
+		}
+		return a.ECO+a.Name < b.ECO+b.Name
+	})
+	return table, nil
+}
+
+// openingName returns the name of an opening without the moves some names end with.
+func openingName(name string) string {
+	words := strings.Fields(name)
+	for i, word := range words {
+		if moveWordRegex.MatchString(word) {
+			words = words[:i]
+			break
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// OpeningLabel returns the ECO code and name of a game's opening for listings, without the moves
+// some names end with, e.g. "C50 Italian Game", or "unknown".
+func OpeningLabel(game api.Game) string {
+	eco, name := Opening(game)
+	if label := strings.TrimSpace(eco + " " + openingName(name)); label != "" {
+		return label
+	}
+	return "unknown"
+}
+
+// Tables returns the openings table for export.
+func (t OpeningTable) Tables() []Table {
+	table := Table{Name: "openings", Columns: append(append([]string{"eco", "name"}, recordColumns...), "opponent_rating")}
+	for _, opening := range t.Openings {
+		rating := any(nil)
+		if opening.ratedGames > 0 {
+			rating = opening.OpponentRating
+		}
+		table.Rows = append(table.Rows, append(append([]any{opening.ECO, opening.Name}, recordCells(opening.Record)...), rating))
+	}
+	return []Table{table}
+}
+
+// Write prints the table.
+func (t OpeningTable) Write(w io.Writer) {
+	order := "most played first"
+	if t.Order == "worst" {
+		order = "worst score first"
+	}
+	fmt.Fprintf(w, "\n--- Openings of %s (%s) ---\n", t.Player, order)
+	fmt.Fprintf(w, "Policy: %s\n", t.Policy.Describe())
+	writeOpeningRows(w, t.Openings)
+	fmt.Fprintln(w, "---------------------")
+}
+
+// writeOpeningRows prints openings as a table.
+func writeOpeningRows(w io.Writer, openings []OpeningRecord) {
+	fmt.Fprintln(w, "Games | Score  | +/=/-        | Opp. rating | Opening")
+	for _, opening := range openings {
+		rating := "-"
+		if opening.OpponentRating > 0 {
+			rating = fmt.Sprintf("%.0f", opening.OpponentRating)
+		}
+		name := strings.TrimSpace(opening.ECO + " " + opening.Name)
+		if name == "" {
+			name = "unknown"
+		}
+		fmt.Fprintf(w, "%5d | %5.1f%% | %-12s | %-11s | %s\n", opening.Record.Games, opening.Record.Score(),
+			fmt.Sprintf("+%d =%d -%d", opening.Record.Wins, opening.Record.Draws, opening.Record.Losses), rating, name)
+	}
+}
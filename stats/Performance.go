@@ -0,0 +1,107 @@
+package stats
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+)
+
+// Levels from which a game stands out from a player's history.
+const (
+	unusualPercentile = 95  // Accuracy percentile.
+	unusualMatchZ     = 2.5 // Standard score of the engine move matches.
+	// minHistory is the number of history games below which the comparison is only indicative.
+	minHistory = 20
+)
+
+// GamePerformance is how one side played an analysed game.
+type GamePerformance struct {
+	Accuracy  float64 // 0 to 100.
+	Moves     int     // Analysed moves.
+	BestMoves int     // Analysed moves that were the engine's best move.
+}
+
+// MatchRate returns the share of the analysed moves that were the engine's best move, 0 to 1.
+func (p GamePerformance) MatchRate() float64 {
+	if p.Moves == 0 {
+		return 0
+	}
+	return float64(p.BestMoves) / float64(p.Moves)
+}
+
+// PerformanceCheck compares a player's performance in one game with their other games.
+type PerformanceCheck struct {
+	Game    GamePerformance
+	History int // Games compared with.
+	// AccuracyPercentile is the share of the history games played less accurately, 0 to 100; ties
+	// count half.
+	AccuracyPercentile float64
+	MedianAccuracy     float64
+	// HistoryMatchRate is the share of all the analysed moves of the history that were the engine's
+	// best move, 0 to 1.
+	HistoryMatchRate float64
+	// MatchZ is the standard score of the game's engine move matches: how many standard deviations
+	// more moves matched than expected from the history's match rate over as many moves.
+	MatchZ float64
+}
+
+// ComparePerformance compares the performance in one game with the performances in history.
+func ComparePerformance(game GamePerformance, history []GamePerformance) PerformanceCheck {
+	check := PerformanceCheck{Game: game, History: len(history)}
+	if len(history) == 0 {
+		return check
+	}
+	accuracies := make([]float64, len(history))
+	var moves, bestMoves int
+	for i, p := range history {
+		accuracies[i] = p.Accuracy
+		switch {
+		case p.Accuracy < game.Accuracy:
+			check.AccuracyPercentile += 1
+		case p.Accuracy == game.Accuracy:
+			check.AccuracyPercentile += 0.5
+		}
+		moves += p.Moves
+		bestMoves += p.BestMoves
+	}
+	check.AccuracyPercentile *= 100 / float64(len(history))
+	sort.Float64s(accuracies)
+	if middle := len(accuracies) / 2; len(accuracies)%2 == 1 {
+		check.MedianAccuracy = accuracies[middle]
+	} else {
+		check.MedianAccuracy = (accuracies[middle-1] + accuracies[middle]) / 2
+	}
+	if moves > 0 {
+		check.HistoryMatchRate = float64(bestMoves) / float64(moves)
+	}
+	// Matches are counted as independent trials at the history's rate.
+	p, n := check.HistoryMatchRate, float64(game.Moves)
+	if p > 0 && p < 1 && n > 0 {
+		check.MatchZ = (float64(game.BestMoves) - n*p) / math.Sqrt(n*p*(1-p))
+	}
+	return check
+}
+
+// Unusual returns the ways the game stands out from the history; none if it is in line with it.
+func (c PerformanceCheck) Unusual() []string {
+	var unusual []string
+	if c.History > 0 && c.AccuracyPercentile >= unusualPercentile {
+		unusual = append(unusual, fmt.Sprintf("more accurate than %.0f%% of their games", c.AccuracyPercentile))
+	}
+	if c.MatchZ >= unusualMatchZ {
+		unusual = append(unusual, fmt.Sprintf("%.1f standard deviations more engine moves than usual", c.MatchZ))
+	}
+	return unusual
+}
+
+// Write prints the comparison.
+func (c PerformanceCheck) Write(w io.Writer) {
+	fmt.Fprintf(w, "Accuracy:   %.1f, median %.1f over %d games; higher than %.0f%% of them\n",
+		c.Game.Accuracy, c.MedianAccuracy, c.History, c.AccuracyPercentile)
+	fmt.Fprintf(w, "Move match: %d of %d moves were the engine's best (%.0f%%), usually %.0f%%; z = %+.1f\n",
+		c.Game.BestMoves, c.Game.Moves, c.Game.MatchRate()*100, c.HistoryMatchRate*100, c.MatchZ)
+	if c.History < minHistory {
+		fmt.Fprintf(w, "Only %d games to compare with; the comparison is indicative.\n", c.History)
+	}
+}
@@ -0,0 +1,174 @@
+package stats
+
+import (
+	"chessAnalyserFree/api"
+	gameengine "chessAnalyserFree/gameEngine"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// playingTimesMinGames is the number of games an hour or weekday needs to be named the worst.
+const playingTimesMinGames = 5
+
+// PeriodRecord is a player's results in the games ending in one hour of the day or on one weekday,
+// and the blunders in those of them that were analysed.
+type PeriodRecord struct {
+	Label    string // E.g. "23:00" or "Monday".
+	Record   Record
+	Analysed int
+	Blunders int
+}
+
+// BlundersPerGame returns the average number of blunders in the analysed games, or 0 without any.
+func (p PeriodRecord) BlundersPerGame() float64 {
+	if p.Analysed == 0 {
+		return 0
+	}
+	return float64(p.Blunders) / float64(p.Analysed)
+}
+
+// PlayingTimes is a player's results by the local hour and weekday their games ended.
+type PlayingTimes struct {
+	Player   string
+	Policy   Policy
+	Hours    [24]PeriodRecord
+	Weekdays [7]PeriodRecord // Monday first.
+}
+
+// BuildPlayingTimes breaks the player's results in the games that count under the policy down by the
+// hour of the day and the weekday they ended, in local time, with the blunders of the games among
+// them that were analysed.
+func BuildPlayingTimes(games []api.Game, analysed []AnalysedGame, player string, policy Policy) PlayingTimes {
+	times := PlayingTimes{Player: player, Policy: policy}
+	for hour := range times.Hours {
+		times.Hours[hour].Label = fmt.Sprintf("%02d:00", hour)
+	}
+	for day := range times.Weekdays {
+		times.Weekdays[day].Label = time.Weekday((day + 1) % 7).String()
+	}
+	analyses := make(map[string]*gameengine.GameAnalysis)
+	for _, game := range analysed {
+		if game.Game.URL != "" && game.Analysis != nil && game.Analysis.IsValid() {
+			analyses[game.Game.URL] = game.Analysis
+		}
+	}
+	for _, game := range policy.Filter(games, player) {
+		outcome, ok := Outcome(game, player)
+		colour, _, _, played := sides(game, player)
+		if !ok || !played || game.EndTime == 0 {
+			continue
+		}
+		end := time.Unix(game.EndTime, 0)
+		periods := []*PeriodRecord{&times.Hours[end.Hour()], &times.Weekdays[(int(end.Weekday())+6)%7]}
+		for _, period := range periods {
+			period.Record.add(outcome)
+		}
+		analysis := analyses[game.URL]
+		if analysis == nil {
+			continue
+		}
+		side, black := gameengine.GameAccuracy(game.PGN, analysis)
+		if colour == "black" {
+			side = black
+		}
+		for _, period := range periods {
+			period.Analysed++
+			period.Blunders += side.Blunders
+		}
+	}
+	return times
+}
+
+// Tables returns the results and blunders by hour and by weekday for export.
+func (t PlayingTimes) Tables() []Table {
+	table := Table{Name: "playing_times", Columns: append(append([]string{"period", "label"}, recordColumns...), "analysed", "blunders")}
+	for _, periods := range []struct {
+		name    string
+		periods []PeriodRecord
+	}{{"hour", t.Hours[:]}, {"weekday", t.Weekdays[:]}} {
+		for _, period := range periods.periods {
+			table.Rows = append(table.Rows, append(append([]any{periods.name, period.Label}, recordCells(period.Record)...),
+				period.Analysed, period.Blunders))
+		}
+	}
+	return []Table{table}
+}
+
+// Write prints the results by hour, leaving out hours without games, and by weekday, then the hour
+// and weekday with the lowest score and the most blunders among those with enough games to compare.
+func (t PlayingTimes) Write(w io.Writer) {
+	fmt.Fprintf(w, "\n--- Playing times of %s (local time) ---\n", t.Player)
+	fmt.Fprintf(w, "Policy: %s\n", t.Policy.Describe())
+	hours := make([]PeriodRecord, 0, len(t.Hours))
+	for _, hour := range t.Hours {
+		if hour.Record.Games > 0 {
+			hours = append(hours, hour)
+		}
+	}
+	if len(hours) == 0 {
+		fmt.Fprintln(w, "No games with a known end time.")
+		fmt.Fprintln(w, "---------------------")
+		return
+	}
+	writePeriods(w, "Hour", hours)
+	writePeriods(w, "Weekday", t.Weekdays[:])
+	fmt.Fprintln(w)
+	writeWorst(w, "hour", hours)
+	writeWorst(w, "weekday", t.Weekdays[:])
+	fmt.Fprintln(w, "---------------------")
+}
+
+// writePeriods prints a table of periods.
+func writePeriods(w io.Writer, title string, periods []PeriodRecord) {
+	fmt.Fprintf(w, "\n%-9s | Games | Score  | +/=/-        | Blunders/game\n", title)
+	for _, period := range periods {
+		record := period.Record
+		blunders := "-"
+		if period.Analysed > 0 {
+			blunders = fmt.Sprintf("%.2f (%d analysed)", period.BlundersPerGame(), period.Analysed)
+		}
+		score := "-"
+		if record.Games > 0 {
+			score = fmt.Sprintf("%5.1f%%", record.Score())
+		}
+		fmt.Fprintf(w, "%-9s | %5d | %-6s | %-12s | %s\n", period.Label, record.Games, score,
+			fmt.Sprintf("+%d =%d -%d", record.Wins, record.Draws, record.Losses), blunders)
+	}
+}
+
+// writeWorst names the period with the lowest score and the one with the most blunders per game,
+// when at least two periods have playingTimesMinGames games, or analysed games for blunders, to
+// compare.
+func writeWorst(w io.Writer, kind string, periods []PeriodRecord) {
+	var lowest, blundering *PeriodRecord
+	played, analysed := 0, 0
+	for i := range periods {
+		period := &periods[i]
+		if period.Record.Games >= playingTimesMinGames {
+			played++
+			if lowest == nil || period.Record.Score() < lowest.Record.Score() {
+				lowest = period
+			}
+		}
+		if period.Analysed >= playingTimesMinGames {
+			analysed++
+			if blundering == nil || period.BlundersPerGame() > blundering.BlundersPerGame() {
+				blundering = period
+			}
+		}
+	}
+	var parts []string
+	if played > 1 {
+		parts = append(parts, fmt.Sprintf("lowest score %s (%.1f%% in %d games)", lowest.Label, lowest.Record.Score(), lowest.Record.Games))
+	}
+	if analysed > 1 {
+		parts = append(parts, fmt.Sprintf("most blunders %s (%.2f per game)", blundering.Label, blundering.BlundersPerGame()))
+	}
+	if len(parts) == 0 {
+		fmt.Fprintf(w, "Too few games to compare by %s: two %ss or more need %d games each.\n", kind, kind, playingTimesMinGames)
+		return
+	}
+	fmt.Fprintf(w, "Worst %s: %s.\n", kind, strings.Join(parts, "; "))
+}
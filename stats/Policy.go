@@ -0,0 +1,92 @@
+package stats
+
+import (
+	"chessAnalyserFree/api"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Policy decides which games count towards statistics. The zero value is the default policy:
+// rated games between humans, including a player's provisional games.
+type Policy struct {
+	IncludeUnrated   bool // Count unrated (casual) games.
+	IncludeBots      bool // Count games against bots and computer opponents.
+	ProvisionalGames int  // Leave out each player's first N rated games of every time class.
+}
+
+// Filter returns the games that count under the policy, in their original order. Provisional games
+// are judged for player, or for both sides when player is empty. Only the given games are known, so
+// "first N rated games" means the first N in this set.
+func (p Policy) Filter(games []api.Game, player string) []api.Game {
+	var counted []api.Game
+	for i, counts := range p.counts(games, player) {
+		if counts {
+			counted = append(counted, games[i])
+		}
+	}
+	return counted
+}
+
+// counts reports for each game whether it counts under the policy, like Filter.
+func (p Policy) counts(games []api.Game, player string) []bool {
+	provisional := p.provisionalGames(games, player)
+	counts := make([]bool, len(games))
+	for i, game := range games {
+		counts[i] = (game.Rated || p.IncludeUnrated) && (!game.AgainstBot() || p.IncludeBots) && !provisional[i]
+	}
+	return counts
+}
+
+// provisionalGames marks the games that are among a player's first ProvisionalGames rated games
+// of their time class.
+func (p Policy) provisionalGames(games []api.Game, player string) map[int]bool {
+	provisional := make(map[int]bool)
+	if p.ProvisionalGames <= 0 {
+		return provisional
+	}
+	order := make([]int, len(games))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return games[order[a]].EndTime < games[order[b]].EndTime })
+
+	played := make(map[string]int)
+	for _, i := range order {
+		game := games[i]
+		if !game.Rated {
+			continue
+		}
+		for _, side := range []api.Player{game.White, game.Black} {
+			name := strings.ToLower(side.Username)
+			if player != "" && name != strings.ToLower(player) {
+				continue
+			}
+			key := name + "|" + game.TimeClass
+			played[key]++
+			if played[key] <= p.ProvisionalGames {
+				provisional[i] = true
+			}
+		}
+	}
+	return provisional
+}
+
+// Describe returns a one-line description of the policy for report headers.
+func (p Policy) Describe() string {
+	parts := []string{"rated games only"}
+	if p.IncludeUnrated {
+		parts[0] = "rated and unrated games"
+	}
+	if p.IncludeBots {
+		parts = append(parts, "games vs computers included")
+	} else {
+		parts = append(parts, "games vs computers excluded")
+	}
+	if p.ProvisionalGames > 0 {
+		parts = append(parts, fmt.Sprintf("first %d rated games per time class excluded as provisional", p.ProvisionalGames))
+	} else {
+		parts = append(parts, "provisional games included")
+	}
+	return strings.Join(parts, "; ")
+}
@@ -0,0 +1,149 @@
+package stats
+
+import (
+	"chessAnalyserFree/api"
+	gameengine "chessAnalyserFree/gameEngine"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// DefaultRepertoirePlies is how deep the opening tree goes unless asked otherwise.
+const DefaultRepertoirePlies = 8
+
+// repertoireMinGames is the number of games a line needs to be shown; lines played once are noise
+// in all but the smallest samples.
+const repertoireMinGames = 2
+
+// OpeningLine is a node of an opening tree: the games that reached a position by one move order,
+// with the player's results in them, and the moves played next.
+type OpeningLine struct {
+	Move     string // Move leading to the line, numbered like a PGN, e.g. "1. e4" or "1... c5"; empty at the root.
+	Record   Record // From the player's point of view.
+	Children []*OpeningLine
+}
+
+// child returns the line that continues with move, adding it if it is new.
+func (l *OpeningLine) child(move string) *OpeningLine {
+	for _, child := range l.Children {
+		if child.Move == move {
+			return child
+		}
+	}
+	child := &OpeningLine{Move: move}
+	l.Children = append(l.Children, child)
+	return child
+}
+
+// sortChildren orders every level of the tree from the most played line down.
+func (l *OpeningLine) sortChildren() {
+	sort.SliceStable(l.Children, func(i, j int) bool {
+		if l.Children[i].Record.Games != l.Children[j].Record.Games {
+			return l.Children[i].Record.Games > l.Children[j].Record.Games
+		}
+		return l.Children[i].Move < l.Children[j].Move
+	})
+	for _, child := range l.Children {
+		child.sortChildren()
+	}
+}
+
+// Repertoire is the opening tree of a player's games, split by the colour they played.
+type Repertoire struct {
+	Player  string
+	Policy  Policy
+	Plies   int
+	Skipped int // Games left out: variants, games from set-up positions and unknown results.
+	White   OpeningLine
+	Black   OpeningLine
+}
+
+// BuildRepertoire aggregates the first plies moves of the player's games that count under the
+// policy into opening trees, one for the games as White and one for those as Black. Only standard
+// chess games from the starting position take part.
+func BuildRepertoire(games []api.Game, player string, plies int, policy Policy) Repertoire {
+	repertoire := Repertoire{Player: player, Policy: policy, Plies: plies}
+	for _, game := range policy.Filter(games, player) {
+		outcome, ok := Outcome(game, player)
+		positions, err := gameengine.ReplayPositions(game.PGN)
+		if !ok || err != nil || game.Rules != "chess" && game.Rules != "" || gameengine.StartFEN(game.PGN) != gameengine.StandardStartFEN {
+			repertoire.Skipped++
+			continue
+		}
+		line := &repertoire.Black
+		if strings.EqualFold(game.White.Username, player) {
+			line = &repertoire.White
+		}
+		line.Record.add(outcome)
+		for _, position := range positions[:min(plies, len(positions))] {
+			line = line.child(numberedMove(position))
+			line.Record.add(outcome)
+		}
+	}
+	repertoire.White.sortChildren()
+	repertoire.Black.sortChildren()
+	return repertoire
+}
+
+// numberedMove returns the move played from a position numbered like a PGN, e.g. "1. e4" or "1... c5".
+func numberedMove(position gameengine.PlyPosition) string {
+	if strings.Contains(position.FEN, " w ") {
+		return fmt.Sprintf("%d. %s", gameengine.FullMoveNumber(position.FEN), position.SAN)
+	}
+	return fmt.Sprintf("%d... %s", gameengine.FullMoveNumber(position.FEN), position.SAN)
+}
+
+// Write prints the opening trees of the colours asked for, "white", "black" or "" for both. Lines
+// played fewer than twice are left out.
+func (r Repertoire) Write(w io.Writer, colour string) {
+	fmt.Fprintf(w, "\n--- Opening repertoire of %s (first %d plies) ---\n", r.Player, r.Plies)
+	fmt.Fprintf(w, "Policy: %s\n", r.Policy.Describe())
+	if r.Skipped > 0 {
+		fmt.Fprintf(w, "%d games left out: variants, set-up positions or unknown results.\n", r.Skipped)
+	}
+	for _, side := range []struct {
+		name string
+		root *OpeningLine
+	}{{"White", &r.White}, {"Black", &r.Black}} {
+		if colour != "" && !strings.EqualFold(colour, side.name) {
+			continue
+		}
+		record := side.root.Record
+		fmt.Fprintf(w, "\nAs %s: %d games, +%d =%d -%d (%.1f%%)\n", side.name, record.Games, record.Wins, record.Draws, record.Losses, record.Score())
+		hidden := writeLines(w, side.root.Children, 1)
+		switch {
+		case hidden == 1:
+			fmt.Fprintln(w, "Not shown: 1 move played in a single game.")
+		case hidden > 1:
+			fmt.Fprintf(w, "Not shown: %d moves played in a single game each.\n", hidden)
+		}
+	}
+	fmt.Fprintln(w, "---------------------")
+}
+
+// writeLines prints lines indented by their depth and returns how many were too rare to show. A
+// line that always continues with the same move is shown on one row with its continuation.
+func writeLines(w io.Writer, lines []*OpeningLine, depth int) int {
+	hidden := 0
+	for _, line := range lines {
+		record := line.Record
+		if record.Games < repertoireMinGames {
+			hidden++
+			continue
+		}
+		moves := line.Move
+		for len(line.Children) == 1 && line.Children[0].Record.Games == record.Games {
+			line = line.Children[0]
+			if _, san, black := strings.Cut(line.Move, "... "); black {
+				moves += " " + san
+			} else {
+				moves += " " + line.Move
+			}
+		}
+		fmt.Fprintf(w, "%4d games  +%-3d =%-3d -%-3d %5.1f%%  %s%s\n", record.Games, record.Wins, record.Draws,
+			record.Losses, record.Score(), strings.Repeat("  ", depth-1), moves)
+		hidden += writeLines(w, line.Children, depth+1)
+	}
+	return hidden
+}
@@ -0,0 +1,68 @@
+package stats
+
+import (
+	"chessAnalyserFree/api"
+	pgnimport "chessAnalyserFree/pgnImport"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// ecoRegex matches an ECO code, or the start of one such as "B" or "C4".
+var ecoRegex = regexp.MustCompile(`^[A-Ea-e][0-9]{0,2}$`)
+
+// SearchMatch is a game found by SearchGames.
+type SearchMatch struct {
+	Index  int    // Index of the game in the list searched.
+	Reason string // What matched, e.g. "opponent hikaru" or "opening B01 Scandinavian Defense".
+}
+
+// Opening returns the ECO code and name of the opening of a game, from the ECO and Opening tags
+// of its PGN or, for Chess.com games, the ECOUrl tag. Either is empty when unknown.
+func Opening(game api.Game) (eco, name string) {
+	tags := pgnimport.ParseTags(game.PGN)
+	name = tags["Opening"]
+	if name == "" && tags["ECOUrl"] != "" {
+		name = strings.ReplaceAll(path.Base(tags["ECOUrl"]), "-", " ")
+	}
+	eco = tags["ECO"]
+	if eco == "?" {
+		eco = ""
+	}
+	return eco, name
+}
+
+// SearchGames finds the games whose opponent's username or opening name contains text, in any
+// case, or whose ECO code starts with text when it looks like one, such as "B01" or "C4". Opponents
+// are player's; without a player, or in games player did not take part in, either username matches.
+func SearchGames(games []api.Game, text, player string) []SearchMatch {
+	text = strings.ToLower(strings.TrimSpace(text))
+	if text == "" {
+		return nil
+	}
+	var matches []SearchMatch
+	for i, game := range games {
+		if reason := searchGame(game, text, player); reason != "" {
+			matches = append(matches, SearchMatch{Index: i, Reason: reason})
+		}
+	}
+	return matches
+}
+
+// searchGame returns what matched the lower-case text in a game, or "" if nothing did.
+func searchGame(game api.Game, text, player string) string {
+	names := []string{game.White.Username, game.Black.Username}
+	if _, _, opponent, ok := sides(game, player); ok {
+		names = []string{opponent.Username}
+	}
+	for _, name := range names {
+		if strings.Contains(strings.ToLower(name), text) {
+			return "opponent " + name
+		}
+	}
+	eco, name := Opening(game)
+	if (ecoRegex.MatchString(text) && strings.HasPrefix(strings.ToLower(eco), text)) || strings.Contains(strings.ToLower(name), text) {
+		return strings.TrimSpace("opening " + eco + " " + name)
+	}
+	return ""
+}
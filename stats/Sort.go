@@ -0,0 +1,127 @@
+package stats
+
+import (
+	"chessAnalyserFree/api"
+	gameengine "chessAnalyserFree/gameEngine"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// sortKeys describe the keys games can be sorted by.
+var sortKeys = map[string]string{
+	"date":      "date",
+	"rating":    "opponent rating",
+	"diff":      "opponent rating minus the player's",
+	"length":    "number of moves",
+	"timeclass": "time class",
+}
+
+// timeClassOrder ranks time classes from the fastest to the slowest.
+var timeClassOrder = map[string]int{"bullet": 1, "blitz": 2, "rapid": 3, "classical": 4, "daily": 5}
+
+// GameSort orders a list of games. Ratings are those of a player's opponents; the zero value keeps
+// the games in their order.
+type GameSort struct {
+	Key        string // One of the keys of sortKeys; none when empty.
+	Descending bool
+}
+
+// ParseGameSort reads a sort order from words such as "rating desc" or "-length": a key (date,
+// rating, diff, length, timeclass), descending with a leading "-" or a following "desc".
+func ParseGameSort(words []string) (GameSort, error) {
+	if len(words) == 0 || len(words) > 2 {
+		return GameSort{}, errors.New("expected a key and an optional asc or desc")
+	}
+	var s GameSort
+	key := strings.ToLower(words[0])
+	key, s.Descending = strings.CutPrefix(key, "-")
+	key = strings.ReplaceAll(key, "-", "")
+	if _, ok := sortKeys[key]; !ok {
+		return GameSort{}, fmt.Errorf("unknown sort key %q (available: date, rating, diff, length, timeclass)", words[0])
+	}
+	s.Key = key
+	if len(words) == 2 {
+		switch strings.ToLower(words[1]) {
+		case "asc":
+			s.Descending = false
+		case "desc":
+			s.Descending = true
+		default:
+			return GameSort{}, fmt.Errorf("expected asc or desc, got %q", words[1])
+		}
+	}
+	return s, nil
+}
+
+// NeedsPlayer reports whether the order depends on whose opponent a player is.
+func (s GameSort) NeedsPlayer() bool {
+	return s.Key == "rating" || s.Key == "diff"
+}
+
+// Apply returns the games in the sort order for player. The sort is stable, so games with equal
+// keys keep their order; games without a value for the key, e.g. an unknown rating, come last.
+func (s GameSort) Apply(games []api.Game, player string) []api.Game {
+	if s.Key == "" {
+		return append([]api.Game(nil), games...)
+	}
+	keys := make([]float64, len(games))
+	order := make([]int, len(games))
+	for i, game := range games {
+		keys[i], order[i] = s.value(game, player), i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		ka, kb := keys[order[a]], keys[order[b]]
+		if math.IsNaN(ka) || math.IsNaN(kb) {
+			return !math.IsNaN(ka) && math.IsNaN(kb)
+		}
+		if s.Descending {
+			return ka > kb
+		}
+		return ka < kb
+	})
+	sorted := make([]api.Game, len(games))
+	for i, index := range order {
+		sorted[i] = games[index]
+	}
+	return sorted
+}
+
+// value returns the key of a game, or NaN if it has none.
+func (s GameSort) value(game api.Game, player string) float64 {
+	switch s.Key {
+	case "date":
+		return float64(game.EndTime)
+	case "length":
+		return float64(gameengine.CountMovetextPlies(game.PGN))
+	case "timeclass":
+		if rank, ok := timeClassOrder[game.TimeClass]; ok {
+			return float64(rank)
+		}
+	case "rating", "diff":
+		_, own, opponent, ok := sides(game, player)
+		if !ok || opponent.Rating == 0 {
+			break
+		}
+		if s.Key == "rating" {
+			return float64(opponent.Rating)
+		}
+		if own.Rating != 0 {
+			return float64(opponent.Rating - own.Rating)
+		}
+	}
+	return math.NaN()
+}
+
+// Describe returns a short description of the order, e.g. "opponent rating, descending".
+func (s GameSort) Describe() string {
+	if s.Key == "" {
+		return "as loaded"
+	}
+	if s.Descending {
+		return sortKeys[s.Key] + ", descending"
+	}
+	return sortKeys[s.Key] + ", ascending"
+}
@@ -0,0 +1,291 @@
+package stats
+
+import (
+	"chessAnalyserFree/api"
+	gameengine "chessAnalyserFree/gameEngine"
+	pgnimport "chessAnalyserFree/pgnImport"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// Breaks between games: a game started within tiltBreak of a loss is played on tilt, and games
+// with breaks of up to sessionBreak between them make up a session.
+const (
+	tiltBreak    = 10 * time.Minute
+	sessionBreak = 30 * time.Minute
+)
+
+// sessionSlots is the number of places in a session the results are broken down by; later games
+// count in the last.
+const sessionSlots = 5
+
+// Streak is a run of wins or losses in a row.
+type Streak struct {
+	Outcome int // 1 for wins, -1 for losses.
+	Length  int
+	From    int64 // End time of the first game.
+	To      int64 // End time of the last game.
+}
+
+// SessionSlot is a player's results and accuracy in the games at one place in their sessions.
+type SessionSlot struct {
+	Label    string // E.g. "1st" or "5th+".
+	Record   Record
+	Accuracy AccuracyAverage
+}
+
+// TiltStats is a player's streaks in chronological order and the signs of tilt: how they do in
+// games started soon after a loss, and how their results and accuracy change over a session.
+type TiltStats struct {
+	Player      string
+	Policy      Policy
+	Games       int
+	LongestWin  Streak
+	LongestLoss Streak
+	Current     Streak // Length 0 when the last game was drawn.
+	// QuickAfterLoss are the games started within tiltBreak of the end of a loss, RestedAfterLoss
+	// those started after a longer break.
+	QuickAfterLoss, RestedAfterLoss Record
+	QuickAccuracy, RestedAccuracy   AccuracyAverage
+	Sessions                        int
+	LongestSession                  int
+	BySessionGame                   [sessionSlots]SessionSlot
+}
+
+// BuildTiltStats puts the player's games that count under the policy in the order they ended and
+// finds their streaks and sessions. A game starts at the time its PGN's tags give, or when it ended
+// if they give none. Accuracy is taken from the analysed games among them.
+func BuildTiltStats(games []api.Game, analysed []AnalysedGame, player string, policy Policy) TiltStats {
+	tilt := TiltStats{Player: player, Policy: policy}
+	for i := range tilt.BySessionGame {
+		tilt.BySessionGame[i].Label = ordinal(i + 1)
+	}
+	tilt.BySessionGame[sessionSlots-1].Label += "+"
+	analyses := make(map[string]*gameengine.GameAnalysis)
+	for _, game := range analysed {
+		if game.Game.URL != "" && game.Analysis != nil && game.Analysis.IsValid() {
+			analyses[game.Game.URL] = game.Analysis
+		}
+	}
+
+	type played struct {
+		game    api.Game
+		outcome int
+		colour  string
+	}
+	var chronological []played
+	for _, game := range policy.Filter(games, player) {
+		outcome, ok := Outcome(game, player)
+		colour, _, _, isPlayer := sides(game, player)
+		if ok && isPlayer && game.EndTime != 0 {
+			chronological = append(chronological, played{game, outcome, colour})
+		}
+	}
+	sort.SliceStable(chronological, func(i, j int) bool { return chronological[i].game.EndTime < chronological[j].game.EndTime })
+	tilt.Games = len(chronological)
+
+	var previous *played
+	session := 0
+	for i := range chronological {
+		current := &chronological[i]
+		game, outcome := current.game, current.outcome
+
+		switch {
+		case outcome == 0:
+			tilt.Current = Streak{}
+		case tilt.Current.Outcome == outcome:
+			tilt.Current.Length++
+			tilt.Current.To = game.EndTime
+		default:
+			tilt.Current = Streak{Outcome: outcome, Length: 1, From: game.EndTime, To: game.EndTime}
+		}
+		if tilt.Current.Outcome == 1 && tilt.Current.Length > tilt.LongestWin.Length {
+			tilt.LongestWin = tilt.Current
+		}
+		if tilt.Current.Outcome == -1 && tilt.Current.Length > tilt.LongestLoss.Length {
+			tilt.LongestLoss = tilt.Current
+		}
+
+		accuracy, analysed := 0.0, false
+		if analysis := analyses[game.URL]; analysis != nil {
+			side, black := gameengine.GameAccuracy(game.PGN, analysis)
+			if current.colour == "black" {
+				side = black
+			}
+			accuracy, analysed = side.Accuracy, side.Moves > 0
+		}
+
+		start := pgnimport.StartTime(game.PGN)
+		if start == 0 || start > game.EndTime {
+			start = game.EndTime
+		}
+		if previous == nil || time.Duration(start-previous.game.EndTime)*time.Second > sessionBreak {
+			tilt.Sessions++
+			session = 0
+		}
+		session++
+		tilt.LongestSession = max(tilt.LongestSession, session)
+		slot := &tilt.BySessionGame[min(session, sessionSlots)-1]
+		slot.Record.add(outcome)
+		if analysed {
+			slot.Accuracy.add(accuracy)
+		}
+
+		if previous != nil && previous.outcome == -1 {
+			record, average := &tilt.RestedAfterLoss, &tilt.RestedAccuracy
+			if time.Duration(start-previous.game.EndTime)*time.Second <= tiltBreak {
+				record, average = &tilt.QuickAfterLoss, &tilt.QuickAccuracy
+			}
+			record.add(outcome)
+			if analysed {
+				average.add(accuracy)
+			}
+		}
+		previous = current
+	}
+	return tilt
+}
+
+// ordinal returns "1st", "2nd", "3rd", "4th" and so on.
+func ordinal(n int) string {
+	switch {
+	case n%100 >= 11 && n%100 <= 13:
+		return fmt.Sprintf("%dth", n)
+	case n%10 == 1:
+		return fmt.Sprintf("%dst", n)
+	case n%10 == 2:
+		return fmt.Sprintf("%dnd", n)
+	case n%10 == 3:
+		return fmt.Sprintf("%drd", n)
+	}
+	return fmt.Sprintf("%dth", n)
+}
+
+// Tables returns the streaks, the results after a loss and the results by place in the session for
+// export.
+func (t TiltStats) Tables() []Table {
+	streaks := Table{Name: "streaks", Columns: []string{"streak", "outcome", "length", "from", "to"}}
+	for _, streak := range []struct {
+		name   string
+		streak Streak
+	}{{"longest_win", t.LongestWin}, {"longest_loss", t.LongestLoss}, {"current", t.Current}} {
+		row := []any{streak.name, map[int]string{1: "win", -1: "loss"}[streak.streak.Outcome], streak.streak.Length, nil, nil}
+		if streak.streak.Length > 0 {
+			row[3], row[4] = time.Unix(streak.streak.From, 0).Format("2006-01-02"), time.Unix(streak.streak.To, 0).Format("2006-01-02")
+		}
+		streaks.Rows = append(streaks.Rows, row)
+	}
+	afterLoss := Table{Name: "after_loss", Columns: append(append([]string{"break"}, recordColumns...), "analysed", "accuracy")}
+	for _, after := range []struct {
+		name     string
+		record   Record
+		accuracy AccuracyAverage
+	}{{"quick", t.QuickAfterLoss, t.QuickAccuracy}, {"longer", t.RestedAfterLoss, t.RestedAccuracy}} {
+		afterLoss.Rows = append(afterLoss.Rows, append(append([]any{after.name}, recordCells(after.record)...),
+			after.accuracy.Games, exportAccuracy(after.accuracy)))
+	}
+	sessions := Table{Name: "sessions", Columns: append(append([]string{"game_in_session"}, recordColumns...), "analysed", "accuracy")}
+	for _, slot := range t.BySessionGame {
+		sessions.Rows = append(sessions.Rows, append(append([]any{slot.Label}, recordCells(slot.Record)...),
+			slot.Accuracy.Games, exportAccuracy(slot.Accuracy)))
+	}
+	return []Table{streaks, afterLoss, sessions}
+}
+
+// exportAccuracy returns the average accuracy for export, or nil without analysed games.
+func exportAccuracy(a AccuracyAverage) any {
+	if a.Games == 0 {
+		return nil
+	}
+	return a.Accuracy()
+}
+
+// Write prints the streaks, the results after a loss by the break taken, and the results and
+// accuracy by place in the session.
+func (t TiltStats) Write(w io.Writer) {
+	fmt.Fprintf(w, "\n--- Streaks and tilt of %s ---\n", t.Player)
+	fmt.Fprintf(w, "Policy: %s\n", t.Policy.Describe())
+	if t.Games == 0 {
+		fmt.Fprintln(w, "No games with a known result and end time.")
+		fmt.Fprintln(w, "---------------------")
+		return
+	}
+	fmt.Fprintf(w, "Longest winning streak: %s\n", t.LongestWin.describe())
+	fmt.Fprintf(w, "Longest losing streak:  %s\n", t.LongestLoss.describe())
+	current := "none, the last game was drawn"
+	if t.Current.Length > 0 {
+		current = map[int]string{1: "won ", -1: "lost "}[t.Current.Outcome] + t.Current.describe()
+	}
+	fmt.Fprintf(w, "Current streak:         %s\n", current)
+
+	fmt.Fprintf(w, "\nAfter a loss (up to %.0f minutes' break counts as a quick restart):\n", tiltBreak.Minutes())
+	for _, after := range []struct {
+		name     string
+		record   Record
+		accuracy AccuracyAverage
+	}{{"Quick restart", t.QuickAfterLoss, t.QuickAccuracy}, {"Longer break", t.RestedAfterLoss, t.RestedAccuracy}} {
+		fmt.Fprintf(w, "  %-13s %s%s\n", after.name+":", describeRecord(after.record), describeAccuracy(after.accuracy))
+	}
+	quick, rested := t.QuickAfterLoss, t.RestedAfterLoss
+	if quick.Games > 0 && rested.Games > 0 {
+		quickLost, restedLost := 100*float64(quick.Losses)/float64(quick.Games), 100*float64(rested.Losses)/float64(rested.Games)
+		if quickLost > restedLost {
+			fmt.Fprintf(w, "  Tilt: %d of the %d games started within %.0f minutes of a loss were lost (%.0f%%), against %.0f%% after a longer break.\n",
+				quick.Losses, quick.Games, tiltBreak.Minutes(), quickLost, restedLost)
+		}
+	}
+
+	fmt.Fprintf(w, "\nSessions (games up to %.0f minutes apart): %d, the longest %d games\n", sessionBreak.Minutes(), t.Sessions, t.LongestSession)
+	fmt.Fprintln(w, "Game in session | Games | Score  | Accuracy")
+	for _, slot := range t.BySessionGame {
+		if slot.Record.Games == 0 {
+			continue
+		}
+		accuracy := "-"
+		if slot.Accuracy.Games > 0 {
+			accuracy = fmt.Sprintf("%.1f (%d analysed)", slot.Accuracy.Accuracy(), slot.Accuracy.Games)
+		}
+		fmt.Fprintf(w, "%-15s | %5d | %5.1f%% | %s\n", slot.Label, slot.Record.Games, slot.Record.Score(), accuracy)
+	}
+	first := t.BySessionGame[0].Accuracy
+	later := AccuracyAverage{}
+	for _, slot := range t.BySessionGame[1:] {
+		later.Games += slot.Accuracy.Games
+		later.sum += slot.Accuracy.sum
+	}
+	if first.Games > 0 && later.Games > 0 {
+		fmt.Fprintf(w, "Accuracy in later games of a session: %+.1f against the first game of the session.\n",
+			later.Accuracy()-first.Accuracy())
+	}
+	fmt.Fprintln(w, "---------------------")
+}
+
+// describe returns the length of a streak and the dates it ran between.
+func (s Streak) describe() string {
+	if s.Length == 0 {
+		return "none"
+	}
+	from, to := time.Unix(s.From, 0).Format("2006-01-02"), time.Unix(s.To, 0).Format("2006-01-02")
+	if from == to {
+		return fmt.Sprintf("%d games on %s", s.Length, from)
+	}
+	return fmt.Sprintf("%d games, %s to %s", s.Length, from, to)
+}
+
+// describeRecord returns a record as "N games, +W =D -L (S%)", or "no games".
+func describeRecord(r Record) string {
+	if r.Games == 0 {
+		return "no games"
+	}
+	return fmt.Sprintf("%d games, +%d =%d -%d (%.1f%%)", r.Games, r.Wins, r.Draws, r.Losses, r.Score())
+}
+
+// describeAccuracy returns ", accuracy A" for the average accuracy of analysed games, or "" without any.
+func describeAccuracy(a AccuracyAverage) string {
+	if a.Games == 0 {
+		return ""
+	}
+	return fmt.Sprintf(", accuracy %.1f (%d analysed)", a.Accuracy(), a.Games)
+}
@@ -0,0 +1,231 @@
+package stats
+
+import (
+	"chessAnalyserFree/api"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+)
+
+// drawResults are the Chess.com result codes of a drawn game.
+var drawResults = map[string]bool{
+	"agreed":             true,
+	"repetition":         true,
+	"stalemate":          true,
+	"insufficient":       true,
+	"50move":             true,
+	"timevsinsufficient": true,
+}
+
+// Record counts game results. Without a player, Wins are White wins and Losses are Black wins.
+type Record struct {
+	Games  int
+	Wins   int
+	Draws  int
+	Losses int
+}
+
+// Score returns the points scored as a percentage of the games.
+func (r Record) Score() float64 {
+	if r.Games == 0 {
+		return 0
+	}
+	return (float64(r.Wins) + float64(r.Draws)/2) * 100 / float64(r.Games)
+}
+
+// add counts one game result: 1 for a win, 0 for a draw, -1 for a loss.
+func (r *Record) add(outcome int) {
+	r.Games++
+	switch outcome {
+	case 1:
+		r.Wins++
+	case 0:
+		r.Draws++
+	default:
+		r.Losses++
+	}
+}
+
+// Summary holds the results of the games that count under a policy.
+type Summary struct {
+	Player      string // Player the results are from the point of view of; empty for all games.
+	Policy      Policy
+	Excluded    int // Games left out by the policy.
+	Total       Record
+	ByTimeClass map[string]Record
+	// Performance is the player's performance rating over their games against rated opponents,
+	// overall and by time class; empty without a player.
+	Performance            Performance
+	PerformanceByTimeClass map[string]Performance
+}
+
+// maxPerformanceGap is the furthest a performance rating lies from the opponents' average, reached
+// with a perfect or a zero score, as in FIDE's table.
+const maxPerformanceGap = 800
+
+// Performance sums up the opponents' ratings and the player's points in games with a known rating.
+type Performance struct {
+	Games     int
+	ratingSum int
+	points    float64
+}
+
+// add counts a game against an opponent of the given rating: 1 for a win, 0 for a draw, -1 for a loss.
+func (p *Performance) add(opponentRating, outcome int) {
+	if opponentRating <= 0 {
+		return
+	}
+	p.Games++
+	p.ratingSum += opponentRating
+	p.points += float64(outcome+1) / 2
+}
+
+// OpponentAverage returns the average rating of the opponents.
+func (p Performance) OpponentAverage() float64 {
+	if p.Games == 0 {
+		return 0
+	}
+	return float64(p.ratingSum) / float64(p.Games)
+}
+
+// Rating returns the performance rating: the rating at which the Elo formula expects the player's
+// score against opponents of their average rating, at most 800 points from that average.
+func (p Performance) Rating() float64 {
+	if p.Games == 0 {
+		return 0
+	}
+	score := p.points / float64(p.Games)
+	gap := float64(maxPerformanceGap)
+	if score > 0 && score < 1 {
+		gap = math.Min(gap, math.Abs(400*math.Log10(score/(1-score))))
+	}
+	if score < 0.5 {
+		gap = -gap
+	}
+	return p.OpponentAverage() + gap
+}
+
+// Summarize computes the results of the games that count under the policy, from player's point of
+// view. With an empty player, results are counted from White's point of view.
+func Summarize(games []api.Game, player string, policy Policy) Summary {
+	counted := policy.Filter(games, player)
+	summary := Summary{
+		Player:      player,
+		Policy:      policy,
+		Excluded:    len(games) - len(counted),
+		ByTimeClass: make(map[string]Record),
+
+		PerformanceByTimeClass: make(map[string]Performance),
+	}
+	for _, game := range counted {
+		outcome, ok := Outcome(game, player)
+		if !ok {
+			summary.Excluded++
+			continue
+		}
+		summary.Total.add(outcome)
+		record := summary.ByTimeClass[game.TimeClass]
+		record.add(outcome)
+		summary.ByTimeClass[game.TimeClass] = record
+		if _, _, opponent, played := sides(game, player); played {
+			summary.Performance.add(opponent.Rating, outcome)
+			performance := summary.PerformanceByTimeClass[game.TimeClass]
+			performance.add(opponent.Rating, outcome)
+			summary.PerformanceByTimeClass[game.TimeClass] = performance
+		}
+	}
+	return summary
+}
+
+// Outcome returns the result of a game for player (1 win, 0 draw, -1 loss), or for White when player
+// is empty. It returns false if the player did not play the game or the result is unknown.
+func Outcome(game api.Game, player string) (int, bool) {
+	own, opponent := game.White, game.Black
+	if player != "" && !strings.EqualFold(game.White.Username, player) {
+		if !strings.EqualFold(game.Black.Username, player) {
+			return 0, false
+		}
+		own, opponent = game.Black, game.White
+	}
+	switch {
+	case own.Result == "win":
+		return 1, true
+	case opponent.Result == "win":
+		return -1, true
+	case drawResults[own.Result]:
+		return 0, true
+	default:
+		return 0, false
+	}
+}
+
+// Write prints the summary, headed by the policy that selected the games.
+func (s Summary) Write(w io.Writer) {
+	title := "all players"
+	if s.Player != "" {
+		title = s.Player
+	}
+	fmt.Fprintf(w, "\n--- Statistics for %s ---\n", title)
+	fmt.Fprintf(w, "Policy: %s\n", s.Policy.Describe())
+	fmt.Fprintf(w, "Games:  %d counted, %d excluded\n", s.Total.Games, s.Excluded)
+
+	labels := "+%d =%d -%d (%.1f%%)"
+	if s.Player == "" {
+		labels = "White %d, draws %d, Black %d (White scores %.1f%%)"
+	}
+	fmt.Fprintf(w, "%-12s "+labels+"%s\n", "Overall:", s.Total.Wins, s.Total.Draws, s.Total.Losses, s.Total.Score(),
+		s.Performance.describe())
+
+	timeClasses := make([]string, 0, len(s.ByTimeClass))
+	for timeClass := range s.ByTimeClass {
+		timeClasses = append(timeClasses, timeClass)
+	}
+	sort.Strings(timeClasses)
+	for _, timeClass := range timeClasses {
+		record := s.ByTimeClass[timeClass]
+		name := timeClass
+		if name == "" {
+			name = "unknown"
+		}
+		fmt.Fprintf(w, "%-12s "+labels+"%s\n", name+":", record.Wins, record.Draws, record.Losses, record.Score(),
+			s.PerformanceByTimeClass[timeClass].describe())
+	}
+	fmt.Fprintln(w, "---------------------")
+}
+
+// Tables returns the results overall and by time class for export.
+func (s Summary) Tables() []Table {
+	table := Table{Name: "results", Columns: append(append([]string{"time_class"}, recordColumns...), "performance", "opponent_average")}
+	performanceCells := func(p Performance) []any {
+		if p.Games == 0 {
+			return []any{nil, nil}
+		}
+		return []any{p.Rating(), p.OpponentAverage()}
+	}
+	table.Rows = append(table.Rows, append(append([]any{"all"}, recordCells(s.Total)...), performanceCells(s.Performance)...))
+	timeClasses := make([]string, 0, len(s.ByTimeClass))
+	for timeClass := range s.ByTimeClass {
+		timeClasses = append(timeClasses, timeClass)
+	}
+	sort.Strings(timeClasses)
+	for _, timeClass := range timeClasses {
+		name := timeClass
+		if name == "" {
+			name = "unknown"
+		}
+		table.Rows = append(table.Rows, append(append([]any{name}, recordCells(s.ByTimeClass[timeClass])...),
+			performanceCells(s.PerformanceByTimeClass[timeClass])...))
+	}
+	return []Table{table}
+}
+
+// describe returns the performance rating for a summary line, e.g. ", performance 1612 (opponents
+// 1540 on average)", or nothing without games against rated opponents.
+func (p Performance) describe() string {
+	if p.Games == 0 {
+		return ""
+	}
+	return fmt.Sprintf(", performance %.0f (opponents %.0f on average)", p.Rating(), p.OpponentAverage())
+}
@@ -0,0 +1,178 @@
+package stats
+
+import (
+	"chessAnalyserFree/api"
+	gameengine "chessAnalyserFree/gameEngine"
+	"fmt"
+	"io"
+)
+
+// terminations names the ways games end by the Chess.com result code of the losing side, or of
+// both sides in a draw, in the order of the terminations table.
+var terminations = []struct{ code, name string }{
+	{"checkmated", "checkmate"},
+	{"resigned", "resignation"},
+	{"timeout", "timeout"},
+	{"abandoned", "abandonment"},
+	{"agreed", "agreement"},
+	{"repetition", "repetition"},
+	{"stalemate", "stalemate"},
+	{"insufficient", "insufficient material"},
+	{"timevsinsufficient", "timeout vs insufficient material"},
+	{"50move", "50-move rule"},
+}
+
+// otherTermination is the name of the terminations not in terminations, such as variant wins.
+const otherTermination = "other"
+
+// Termination returns how a game ended, e.g. "checkmate", "timeout" or "agreement", from the result
+// codes of its players, and false if the result is unknown.
+func Termination(game api.Game) (string, bool) {
+	code := game.White.Result
+	switch {
+	case game.White.Result == "win":
+		code = game.Black.Result
+	case game.Black.Result == "win":
+		// White lost; code is theirs.
+	case !drawResults[code]:
+		return "", false
+	}
+	for _, termination := range terminations {
+		if termination.code == code {
+			return termination.name, true
+		}
+	}
+	return otherTermination, true
+}
+
+// TerminationRecord is a player's results in the games that ended one way. Of the analysed games,
+// it counts those whose final position was at odds with the result: losses and draws in which the
+// player was winning, and wins in which they were losing.
+type TerminationRecord struct {
+	Name   string
+	Record Record
+	// Analysed counts the analysed wins, draws and losses; Winning the analysed draws and losses in
+	// winning positions, Losing the analysed wins in losing positions.
+	Analysed Record
+	Winning  Record
+	Losing   Record
+}
+
+// TerminationStats is a player's results by how their games ended.
+type TerminationStats struct {
+	Player       string
+	Policy       Policy
+	Total        Record
+	Terminations []TerminationRecord // In the order of terminations, then other; only those that occurred.
+}
+
+// BuildTerminationStats counts the player's results in the games that count under the policy by how
+// the games ended. The analysed games among them tell in which the final position was at odds with
+// the result: winning, from gameengine.WinningEval pawns up, or losing.
+func BuildTerminationStats(games []api.Game, analysed []AnalysedGame, player string, policy Policy) TerminationStats {
+	termStats := TerminationStats{Player: player, Policy: policy}
+	analyses := make(map[string]*gameengine.GameAnalysis)
+	for _, game := range analysed {
+		if game.Game.URL != "" && game.Analysis != nil && game.Analysis.IsValid() {
+			analyses[game.Game.URL] = game.Analysis
+		}
+	}
+	byName := make(map[string]*TerminationRecord)
+	for _, game := range policy.Filter(games, player) {
+		outcome, ok := Outcome(game, player)
+		colour, _, _, played := sides(game, player)
+		name, ended := Termination(game)
+		if !ok || !played || !ended {
+			continue
+		}
+		termStats.Total.add(outcome)
+		record := byName[name]
+		if record == nil {
+			record = &TerminationRecord{Name: name}
+			byName[name] = record
+		}
+		record.Record.add(outcome)
+
+		analysis := analyses[game.URL]
+		if analysis == nil {
+			continue
+		}
+		evaluation, known := gameengine.FinalEvaluation(game.PGN, analysis)
+		if !known {
+			continue
+		}
+		if colour == "black" {
+			evaluation = -evaluation
+		}
+		record.Analysed.add(outcome)
+		switch {
+		case outcome <= 0 && evaluation >= gameengine.WinningEval:
+			record.Winning.add(outcome)
+		case outcome == 1 && evaluation <= -gameengine.WinningEval:
+			record.Losing.add(outcome)
+		}
+	}
+	for _, termination := range terminations {
+		if record := byName[termination.name]; record != nil {
+			termStats.Terminations = append(termStats.Terminations, *record)
+		}
+	}
+	if record := byName[otherTermination]; record != nil {
+		termStats.Terminations = append(termStats.Terminations, *record)
+	}
+	return termStats
+}
+
+// Tables returns the results by termination for export, with the analysed games whose final
+// position was at odds with the result.
+func (t TerminationStats) Tables() []Table {
+	table := Table{Name: "terminations", Columns: append(append([]string{"termination"}, recordColumns...),
+		"analysed", "winning_draws", "winning_losses", "losing_wins")}
+	for _, termination := range t.Terminations {
+		table.Rows = append(table.Rows, append(append([]any{termination.Name}, recordCells(termination.Record)...),
+			termination.Analysed.Games, termination.Winning.Draws, termination.Winning.Losses, termination.Losing.Wins))
+	}
+	return []Table{table}
+}
+
+// Write prints the results by termination and then the results at odds with the final position,
+// e.g. "3 of your 10 analysed losses by timeout (30%) came in winning positions".
+func (t TerminationStats) Write(w io.Writer) {
+	fmt.Fprintf(w, "\n--- How the games of %s ended ---\n", t.Player)
+	fmt.Fprintf(w, "Policy: %s\n", t.Policy.Describe())
+	if t.Total.Games == 0 {
+		fmt.Fprintln(w, "No games with a known result.")
+		fmt.Fprintln(w, "---------------------")
+		return
+	}
+	fmt.Fprintln(w, "Termination                      | Games | Share  | Won | Drawn | Lost")
+	for _, termination := range t.Terminations {
+		record := termination.Record
+		fmt.Fprintf(w, "%-32s | %5d | %5.1f%% | %3d | %5d | %4d\n", termination.Name, record.Games,
+			100*float64(record.Games)/float64(t.Total.Games), record.Wins, record.Draws, record.Losses)
+	}
+
+	var notes []string
+	for _, termination := range t.Terminations {
+		analysed, winning, losing := termination.Analysed, termination.Winning, termination.Losing
+		if winning.Losses > 0 {
+			notes = append(notes, fmt.Sprintf("%d of your %d analysed losses by %s (%.0f%%) came in winning positions.",
+				winning.Losses, analysed.Losses, termination.Name, 100*float64(winning.Losses)/float64(analysed.Losses)))
+		}
+		if winning.Draws > 0 {
+			notes = append(notes, fmt.Sprintf("%d of your %d analysed draws by %s (%.0f%%) came in winning positions.",
+				winning.Draws, analysed.Draws, termination.Name, 100*float64(winning.Draws)/float64(analysed.Draws)))
+		}
+		if losing.Wins > 0 {
+			notes = append(notes, fmt.Sprintf("%d of your %d analysed wins by %s (%.0f%%) came in losing positions.",
+				losing.Wins, analysed.Wins, termination.Name, 100*float64(losing.Wins)/float64(analysed.Wins)))
+		}
+	}
+	if len(notes) > 0 {
+		fmt.Fprintf(w, "\nFinal positions at odds with the result (%+.0f pawns or more):\n", gameengine.WinningEval)
+		for _, note := range notes {
+			fmt.Fprintln(w, "  "+note)
+		}
+	}
+	fmt.Fprintln(w, "---------------------")
+}
@@ -0,0 +1,108 @@
+package stats
+
+import (
+	"chessAnalyserFree/api"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// DefaultUpsetGap is the rating difference from which a result counts as an upset.
+const DefaultUpsetGap = 100
+
+// Upsets are a player's wins against opponents rated at least Gap above them and their losses to
+// opponents rated at least Gap below them.
+type Upsets struct {
+	Player string
+	Policy Policy
+	Gap    int
+	Higher Record // All games against opponents rated at least Gap above the player.
+	Lower  Record // All games against opponents rated at least Gap below the player.
+	Wins   []int  // Indices of the upset wins in the list searched, the largest rating gap first.
+	Losses []int  // Indices of the upset losses, the largest rating gap first.
+	games  []api.Game
+}
+
+// FindUpsets looks through the games that count under the policy, with both ratings known, for
+// the player's results against opponents rated gap or more away from them.
+func FindUpsets(games []api.Game, player string, gap int, policy Policy) Upsets {
+	upsets := Upsets{Player: player, Policy: policy, Gap: gap, games: games}
+	for i, counts := range policy.counts(games, player) {
+		game := games[i]
+		_, own, opponent, played := sides(game, player)
+		outcome, ok := Outcome(game, player)
+		if !counts || !played || !ok || own.Rating <= 0 || opponent.Rating <= 0 {
+			continue
+		}
+		switch difference := opponent.Rating - own.Rating; {
+		case difference >= gap:
+			upsets.Higher.add(outcome)
+			if outcome == 1 {
+				upsets.Wins = append(upsets.Wins, i)
+			}
+		case difference <= -gap:
+			upsets.Lower.add(outcome)
+			if outcome == -1 {
+				upsets.Losses = append(upsets.Losses, i)
+			}
+		}
+	}
+	for _, list := range [][]int{upsets.Wins, upsets.Losses} {
+		sort.SliceStable(list, func(a, b int) bool { return upsets.gap(list[a]) > upsets.gap(list[b]) })
+	}
+	return upsets
+}
+
+// gap returns how far apart the ratings of the game numbered i are.
+func (u Upsets) gap(i int) int {
+	_, own, opponent, _ := sides(u.games[i], u.Player)
+	difference := opponent.Rating - own.Rating
+	if difference < 0 {
+		return -difference
+	}
+	return difference
+}
+
+// Write prints the counts of upsets among the games against higher- and lower-rated opponents and
+// lists the upset games with their links for review.
+func (u Upsets) Write(w io.Writer) {
+	fmt.Fprintf(w, "\n--- Upsets of %s (rating gap %d or more) ---\n", u.Player, u.Gap)
+	fmt.Fprintf(w, "Policy: %s\n", u.Policy.Describe())
+	for _, side := range []struct {
+		name, upset string
+		record      Record
+		count       int
+	}{
+		{"Against higher-rated opponents", "wins", u.Higher, len(u.Wins)},
+		{"Against lower-rated opponents", "losses", u.Lower, len(u.Losses)},
+	} {
+		if side.record.Games == 0 {
+			fmt.Fprintf(w, "%s: no games\n", side.name)
+			continue
+		}
+		fmt.Fprintf(w, "%s: %d games, +%d =%d -%d (%.1f%%), %d upset %s (%.0f%%)\n", side.name, side.record.Games,
+			side.record.Wins, side.record.Draws, side.record.Losses, side.record.Score(), side.count, side.upset,
+			100*float64(side.count)/float64(side.record.Games))
+	}
+	u.writeGames(w, "Upset wins:", u.Wins)
+	u.writeGames(w, "Upset losses:", u.Losses)
+	fmt.Fprintln(w, "---------------------")
+}
+
+// writeGames lists the games numbered in indices under a title, unless there are none.
+func (u Upsets) writeGames(w io.Writer, title string, indices []int) {
+	if len(indices) == 0 {
+		return
+	}
+	fmt.Fprintln(w, "\n"+title)
+	for _, i := range indices {
+		game := u.games[i]
+		colour, own, opponent, _ := sides(game, u.Player)
+		fmt.Fprintf(w, "[%d] %s %-6s as %-5s %d vs %s %d (%+d) %s\n", i+1, time.Unix(game.EndTime, 0).Format("2006-01-02"),
+			game.TimeClass, colour, own.Rating, opponent.Username, opponent.Rating, opponent.Rating-own.Rating, OpeningLabel(game))
+		if game.URL != "" {
+			fmt.Fprintf(w, "    %s\n", game.URL)
+		}
+	}
+}